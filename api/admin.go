@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/praelatus/backend/mw"
+)
+
+func initAdminRoutes() {
+	Router.Handle("/admin/tickets", mw.Default(mw.RequireAdmin(GetAdminTicketStats))).Methods("GET")
+}
+
+// AdminTicketStats is the number of tickets in each status across every
+// project, including archived projects, along with the counts for each
+// project by it's key
+type AdminTicketStats struct {
+	Total     int                       `json:"total"`
+	ByStatus  map[string]int            `json:"by_status"`
+	ByProject map[string]map[string]int `json:"by_project"`
+}
+
+// GetAdminTicketStats will return the ticket counts of every project, it can
+// only be used by sys admins
+func GetAdminTicketStats(w http.ResponseWriter, r *http.Request) {
+	s := reqStore(r)
+
+	projects, err := s.Projects().GetAllIncludingArchived()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	stats := AdminTicketStats{
+		ByStatus:  make(map[string]int),
+		ByProject: make(map[string]map[string]int),
+	}
+
+	counts, err := s.Tickets().CountAllByStatus()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	// Every project is listed even if it has nothing to count.
+	for _, p := range projects {
+		stats.ByProject[p.Key] = make(map[string]int)
+	}
+
+	for key, byStatus := range counts {
+		for status, n := range byStatus {
+			stats.Total += n
+			stats.ByStatus[status] += n
+		}
+
+		stats.ByProject[key] = byStatus
+	}
+
+	sendJSON(w, stats)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetAdminTicketStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		login func(*http.Request)
+		code  int
+	}{
+		{"anonymous", func(*http.Request) {}, 401},
+		{"core member", testLogin, 403},
+		{"admin", testAdminLogin, 200},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/admin/tickets", nil)
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/admin/tickets", nil)
+	testAdminLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var stats AdminTicketStats
+
+	e := json.Unmarshal(w.Body.Bytes(), &stats)
+	if e != nil {
+		t.Fatalf("Failed with error %s\n", e.Error())
+	}
+
+	// The mock has 3 projects with 2 open and 1 in progress ticket each.
+	if stats.Total != 9 || stats.ByStatus["Open"] != 6 || len(stats.ByProject) != 3 {
+		t.Errorf("Expected 9 tickets in 3 projects Got %v\n", stats)
+	}
+}
@@ -1,15 +1,22 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/config"
+	"github.com/praelatus/backend/logger"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/notify"
 	"github.com/praelatus/backend/store"
-	"github.com/praelatus/backend/store/pg"
+	"github.com/praelatus/backend/store/defaults"
+	"github.com/praelatus/backend/store/localfs"
 )
 
 // Router is used to store all the various resource routes.
@@ -21,46 +28,216 @@ var Store store.Store
 // Cache is the global cache object used in our HTTP handlers.
 var Cache *store.Cache
 
+// Blobs is the global store for attachment contents used in our HTTP
+// handlers.
+var Blobs store.BlobStore
+
+// Log is used by the HTTP handlers to log errors.
+var Log logger.Logger = logger.Std{}
+
+// Notifier is used to send events such as password resets and ticket
+// changes to users, it should be set before calling Run.
+var Notifier notify.Notifier = notify.Nop{}
+
 // Run will start running the api on the given port
 func Run(port string) {
-	Store = pg.New(os.Getenv("PRAELATUS_DB"))
+	// Tokens are never signed with a default key, so without one the api
+	// can't run.
+	err := mw.ConfigureJWTFromEnv()
+	if err != nil {
+		log.Fatal("configuring jwt signing: ", err)
+	}
+
+	s := defaults.Store()
+	if sq, ok := s.(store.SQLStore); ok {
+		DB = sq.Conn()
+	}
+
+	s = store.CachedUsers(s, config.GetUserCacheSize(), config.GetUserCacheTTL())
+	Store = notify.Store(s, notify.Multi(Notifier, Events))
+
+	Blobs, err = localfs.New(config.GetBlobDir())
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	Router = mux.NewRouter()
+	Docs = newDocs()
 
 	initUserRoutes()
 	initProjectRoutes()
+	initTeamRoutes()
 	initTicketRoutes()
+	initTypeRoutes()
+	initStatusRoutes()
+	initFieldRoutes()
+	initAdminRoutes()
+	initHealthRoutes()
+	initWSRoutes()
+	initDocRoutes()
 
-	http.ListenAndServe(port, Router)
+	http.ListenAndServe(port, mw.CORS(Router))
 }
 
-// Message is a general purpose json struct used primarily for error responses.
-type Message struct {
-	Field   string `json:"field,omitempty"`
-	Message string `json:"message"`
+// reqStore returns the Store bound to the request's context so the queries
+// made while handling it are cancelled if the client goes away.
+func reqStore(r *http.Request) store.Store {
+	return Store.WithContext(r.Context())
 }
 
-func apiError(msg string, fields ...string) []byte {
-	e := Message{
-		Message: msg,
+// sysAdmin will write the error response and return false unless the user
+// making the request is a sys admin, action describes what they tried to do.
+func sysAdmin(w http.ResponseWriter, r *http.Request, action string) bool {
+	u := mw.GetUser(r.Context())
+	if u == nil || !u.IsAdmin {
+		w.WriteHeader(403)
+		w.Write(apiError("you must be logged in as a system administrator to " + action))
+		return false
 	}
 
-	if fields != nil {
-		e.Field = strings.Join(fields, ",")
+	return true
+}
+
+// idVar will parse the id route variable, writing the error response and
+// returning false if it isn't a number
+func idVar(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("id must be a number", "id"))
+		return 0, false
 	}
 
-	byt, _ := json.Marshal(e)
-	return byt
+	return id, true
 }
 
+// sendJSON will send v as the json body of a 200 response
 func sendJSON(w http.ResponseWriter, v interface{}) {
+	sendJSONStatus(w, 200, v)
+}
+
+// sendJSONStatus will send v as the json body of a response with the given
+// status code. v is marshalled before anything is written so if it can't be
+// a 500 is sent instead.
+func sendJSONStatus(w http.ResponseWriter, code int, v interface{}) {
 	resp, err := json.Marshal(v)
+	writeJSON(w, code, resp, err)
+}
+
+// sendJSONFiltered will send v like sendJSON, shaped by the query parameters
+// of r. fields is a comma separated list of the top level fields of v, or of
+// each object in v if it's an array, to send and unknown fields are ignored.
+// pretty=true indents the response.
+func sendJSONFiltered(w http.ResponseWriter, r *http.Request, v interface{}) {
+	resp, err := json.Marshal(v)
+
+	if fields := r.FormValue("fields"); err == nil && fields != "" {
+		resp, err = filterFields(resp, strings.Split(fields, ","))
+	}
+
+	if pretty, _ := strconv.ParseBool(r.FormValue("pretty")); err == nil && pretty {
+		var buf bytes.Buffer
+		err = json.Indent(&buf, resp, "", "  ")
+		resp = buf.Bytes()
+	}
+
+	writeJSON(w, 200, resp, err)
+}
+
+// filterFields will remove every top level field not in fields from the json
+// object or array of objects in body, anything else is returned unchanged.
+func filterFields(body []byte, fields []string) ([]byte, error) {
+	keep := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		keep[strings.TrimSpace(f)] = true
+	}
+
+	filter := func(obj map[string]json.RawMessage) {
+		for k := range obj {
+			if !keep[k] {
+				delete(obj, k)
+			}
+		}
+	}
+
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(body, &obj) == nil && obj != nil {
+		filter(obj)
+		return json.Marshal(obj)
+	}
+
+	var objs []map[string]json.RawMessage
+	if json.Unmarshal(body, &objs) == nil && objs != nil {
+		for _, obj := range objs {
+			filter(obj)
+		}
+
+		return json.Marshal(objs)
+	}
+
+	return body, nil
+}
+
+// writeJSON will write resp as the json body of a response with the given
+// status code, sending a 500 instead if err is set since resp couldn't be
+// marshalled.
+func writeJSON(w http.ResponseWriter, code int, resp []byte, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write(apiError("Failed to marshal database response to JSON."))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal,
+			"Failed to marshal database response to JSON.").JSON())
+		Log.Error(err)
 		return
 	}
 
+	w.WriteHeader(code)
 	w.Write(resp)
 }
+
+// errInvalidOrder is returned by pageOptions when order is not asc or desc
+var errInvalidOrder = errors.New("order must be asc or desc")
+
+// pageOptions will parse the pagination query parameters limit, offset,
+// order_by, and order, which is either asc or desc, from the given request.
+func pageOptions(r *http.Request) (store.PageOptions, error) {
+	var opts store.PageOptions
+	var err error
+
+	if l := r.FormValue("limit"); l != "" {
+		opts.Limit, err = strconv.Atoi(l)
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	if o := r.FormValue("offset"); o != "" {
+		opts.Offset, err = strconv.Atoi(o)
+		if err != nil {
+			return opts, err
+		}
+	}
+
+	switch r.FormValue("order") {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, errInvalidOrder
+	}
+
+	opts.OrderBy = r.FormValue("order_by")
+	return opts, nil
+}
+
+// setTotalCount will set the X-Total-Count header so clients can render
+// pagination controls for a paged response.
+func setTotalCount(w http.ResponseWriter, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+}
+
+// logError will log err along with the id of the request it happened in.
+func logError(r *http.Request, err error) {
+	Log.Error(mw.GetRequestID(r.Context()), err)
+}
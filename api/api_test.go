@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/praelatus/backend/mw"
+)
+
+func init() {
+	err := mw.ConfigureJWT(mw.JWTOptions{Secret: []byte("a test secret which is long enough")})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestSendJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	sendJSONStatus(w, 201, map[string]string{"key": "TEST-1"})
+
+	if w.Code != 201 {
+		t.Errorf("Expected 201 Got %d", w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json Got %s", ct)
+	}
+
+	if w.Body.String() != `{"key":"TEST-1"}` {
+		t.Errorf("Expected the json body Got %s", w.Body)
+	}
+}
+
+func TestSendJSONMarshalError(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	// Channels can't be marshalled to json.
+	sendJSONStatus(w, 201, map[string]interface{}{"bad": make(chan int)})
+
+	if w.Code != 500 {
+		t.Errorf("Expected 500 Got %d", w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json Got %s", ct)
+	}
+
+	var body struct {
+		Error APIError `json:"error"`
+	}
+
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("Expected an api error Got %s: %s", w.Body, err)
+	}
+
+	if body.Error.Code != CodeInternal {
+		t.Errorf("Expected code %s Got %s", CodeInternal, body.Error.Code)
+	}
+}
+
+func TestSendJSONFiltered(t *testing.T) {
+	v := []map[string]interface{}{
+		{"key": "TEST-1", "summary": "First", "description": "Long"},
+		{"key": "TEST-2", "summary": "Second", "description": "Longer"},
+	}
+
+	tests := []struct {
+		query string
+		body  string
+	}{
+		{"", `[{"description":"Long","key":"TEST-1","summary":"First"},` +
+			`{"description":"Longer","key":"TEST-2","summary":"Second"}]`},
+		{"?fields=key,summary", `[{"key":"TEST-1","summary":"First"},` +
+			`{"key":"TEST-2","summary":"Second"}]`},
+		{"?fields=key,missing", `[{"key":"TEST-1"},{"key":"TEST-2"}]`},
+		{"?fields=key&pretty=true", "[\n  {\n    \"key\": \"TEST-1\"\n  },\n" +
+			"  {\n    \"key\": \"TEST-2\"\n  }\n]"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/tickets"+test.query, nil)
+
+		sendJSONFiltered(w, r, v)
+
+		if w.Code != 200 {
+			t.Errorf("%s: Expected 200 Got %d\n", test.query, w.Code)
+		}
+
+		if w.Body.String() != test.body {
+			t.Errorf("%s: Expected %s Got %s\n", test.query, test.body, w.Body)
+		}
+	}
+}
+
+func TestPageOptionsOrder(t *testing.T) {
+	r := httptest.NewRequest("GET", "/tickets?order_by=field:Points&order=desc", nil)
+
+	opts, err := pageOptions(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !opts.Desc || opts.OrderBy != "field:Points" {
+		t.Errorf("Expected descending by field:Points Got %v", opts)
+	}
+
+	r = httptest.NewRequest("GET", "/tickets?order=sideways", nil)
+
+	_, err = pageOptions(r)
+	if err != errInvalidOrder {
+		t.Errorf("Expected errInvalidOrder Got %v", err)
+	}
+}
+
+func TestRequireAuthRoutes(t *testing.T) {
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/users"},
+		{"GET", "/projects"},
+		{"GET", "/teams"},
+		{"GET", "/sessions/me"},
+		{"GET", "/users/foouser/filters"},
+	}
+
+	for _, route := range routes {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(route.method, route.path, nil)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != 401 {
+			t.Errorf("%s %s: Expected 401 without a login Got %d\n",
+				route.method, route.path, w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(route.method, route.path, nil)
+		testLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != 200 {
+			t.Errorf("%s %s: Expected 200 with a login Got %d\n",
+				route.method, route.path, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
@@ -1,13 +1,18 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/praelatus/backend/models"
 	"github.com/praelatus/backend/mw"
 	"github.com/praelatus/backend/store"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var loc, _ = time.LoadLocation("")
@@ -16,10 +21,19 @@ func init() {
 	Store = mockStore{}
 
 	Router = mux.NewRouter()
+	Docs = newDocs()
 
 	initUserRoutes()
 	initProjectRoutes()
+	initTeamRoutes()
 	initTicketRoutes()
+	initTypeRoutes()
+	initStatusRoutes()
+	initFieldRoutes()
+	initAdminRoutes()
+	initHealthRoutes()
+	initWSRoutes()
+	initDocRoutes()
 }
 
 type mockStore struct{}
@@ -32,6 +46,14 @@ func (ms mockStore) Teams() store.TeamStore {
 	return mockTeamStore{}
 }
 
+func (ms mockStore) WithContext(ctx context.Context) store.Store {
+	return ms
+}
+
+func (ms mockStore) WithTx(fn func(tx store.Store) error) error {
+	return fn(ms)
+}
+
 func (ms mockStore) Labels() store.LabelStore {
 	return mockLabelStore{}
 }
@@ -62,16 +84,44 @@ func (ms mockStore) Workflows() store.WorkflowStore {
 
 type mockUsersStore struct{}
 
+// fooPassHash is the bcrypt hash of "foopass" returned as foouser's password
+var fooPassHash, _ = bcrypt.GenerateFromPassword([]byte("foopass"), bcrypt.MinCost)
+
 func (ms mockUsersStore) Get(u *models.User) error {
+	if u.Username == "nouser" || u.Username == "nobody@foo.com" {
+		return store.ErrNotFound
+	}
+
+	if u.Username == "erroruser" {
+		return errors.New("connection refused")
+	}
+
+	if u.Username == "otheruser" {
+		u.ID = 2
+		u.Email = "other@foo.com"
+		u.IsActive = true
+		return nil
+	}
+
 	u.ID = 1
 	u.Username = "foouser"
-	u.Password = "foopass"
+	u.Password = string(fooPassHash)
 	u.Email = "foo@foo.com"
 	u.FullName = "Foo McFooserson"
 	u.IsActive = true
 	return nil
 }
 
+func (ms mockUsersStore) GetByEmail(email string) (models.User, error) {
+	if email != "foo@foo.com" {
+		return models.User{}, store.ErrNotFound
+	}
+
+	u := models.User{Username: "foouser"}
+	err := ms.Get(&u)
+	return u, err
+}
+
 func (ms mockUsersStore) GetAll() ([]models.User, error) {
 	return []models.User{
 		models.User{
@@ -85,6 +135,10 @@ func (ms mockUsersStore) GetAll() ([]models.User, error) {
 			false,
 			true,
 			models.Settings{},
+			true,
+			"",
+			"",
+			"",
 		},
 		models.User{
 			2,
@@ -97,10 +151,42 @@ func (ms mockUsersStore) GetAll() ([]models.User, error) {
 			false,
 			true,
 			models.Settings{},
+			true,
+			"",
+			"",
+			"",
 		},
 	}, nil
 }
 
+func (ms mockUsersStore) GetAllIncludingInactive() ([]models.User, error) {
+	return ms.GetAll()
+}
+
+func (ms mockUsersStore) GetAllFiltered(q string, opts store.PageOptions) ([]models.User, int, error) {
+	all, _ := ms.GetAll()
+
+	var users []models.User
+	for _, u := range all {
+		if strings.Contains(u.Username, strings.ToLower(q)) {
+			users = append(users, u)
+		}
+	}
+
+	total := len(users)
+	if opts.Offset < len(users) {
+		users = users[opts.Offset:]
+	} else {
+		users = nil
+	}
+
+	if opts.Limit > 0 && opts.Limit < len(users) {
+		users = users[:opts.Limit]
+	}
+
+	return users, total, nil
+}
+
 func (ms mockUsersStore) New(u *models.User) error {
 	u.ID = 1
 	return nil
@@ -114,12 +200,84 @@ func (ms mockUsersStore) Remove(u models.User) error {
 	return nil
 }
 
-//A mock TeamStore struct
+func (ms mockUsersStore) CreatePasswordReset(u models.User, token string, expires time.Time) error {
+	return nil
+}
+
+func (ms mockUsersStore) ConsumePasswordReset(u models.User, token string) error {
+	switch token {
+	case "expired":
+		return store.ErrTokenExpired
+	case "used":
+		return store.ErrInvalidToken
+	}
+
+	return nil
+}
+
+func (ms mockUsersStore) CreateEmailVerification(u models.User, token string, expires time.Time) error {
+	return nil
+}
+
+func (ms mockUsersStore) VerifyEmail(token string) (models.User, error) {
+	switch token {
+	case "expired":
+		return models.User{}, store.ErrTokenExpired
+	case "used":
+		return models.User{}, store.ErrInvalidToken
+	}
+
+	return models.User{ID: 1, Username: "foouser", EmailVerified: true}, nil
+}
+
+func (ms mockUsersStore) CreateRefreshToken(u models.User, token string, expires time.Time) error {
+	return nil
+}
+
+func (ms mockUsersStore) RotateRefreshToken(token, next string, expires time.Time) (models.User, error) {
+	switch token {
+	case "expired":
+		return models.User{}, store.ErrTokenExpired
+	case "used":
+		return models.User{}, store.ErrTokenReused
+	case "missing":
+		return models.User{}, store.ErrInvalidToken
+	}
+
+	return models.User{ID: 1, Username: "foouser"}, nil
+}
+
+func (ms mockUsersStore) LoginLockedUntil(u models.User) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (ms mockUsersStore) RecordFailedLogin(u models.User, attempts int, lockUntil time.Time) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (ms mockUsersStore) ResetFailedLogins(u models.User) error {
+	return nil
+}
+
+func (ms mockUsersStore) RevokeRefreshToken(u models.User, token string) error {
+	if token == "missing" {
+		return store.ErrInvalidToken
+	}
+
+	return nil
+}
+
+// A mock TeamStore struct
 type mockTeamStore struct{}
 
 func (ms mockTeamStore) Get(t *models.Team) error {
+	if t.URLSlug == "missing" {
+		return store.ErrNotFound
+	}
+
 	t.ID = 1
 	t.Name = "A"
+	t.URLSlug = "a"
 	t.Lead = models.User{
 		1,
 		"foouser",
@@ -131,6 +289,10 @@ func (ms mockTeamStore) Get(t *models.Team) error {
 		false,
 		true,
 		models.Settings{},
+		true,
+		"",
+		"",
+		"",
 	}
 	t.Members = []models.User{
 		models.User{
@@ -144,6 +306,10 @@ func (ms mockTeamStore) Get(t *models.Team) error {
 			false,
 			true,
 			models.Settings{},
+			true,
+			"",
+			"",
+			"",
 		},
 		models.User{
 			2,
@@ -156,6 +322,10 @@ func (ms mockTeamStore) Get(t *models.Team) error {
 			false,
 			true,
 			models.Settings{},
+			true,
+			"",
+			"",
+			"",
 		},
 	}
 	return nil
@@ -177,6 +347,10 @@ func (ms mockTeamStore) GetAll() ([]models.Team, error) {
 					false,
 					true,
 					models.Settings{},
+					true,
+					"",
+					"",
+					"",
 				},
 				Members: []models.User{
 					models.User{
@@ -190,6 +364,10 @@ func (ms mockTeamStore) GetAll() ([]models.Team, error) {
 						false,
 						true,
 						models.Settings{},
+						true,
+						"",
+						"",
+						"",
 					},
 					models.User{
 						2,
@@ -202,6 +380,10 @@ func (ms mockTeamStore) GetAll() ([]models.Team, error) {
 						false,
 						true,
 						models.Settings{},
+						true,
+						"",
+						"",
+						"",
 					},
 				},
 			},
@@ -219,6 +401,10 @@ func (ms mockTeamStore) GetAll() ([]models.Team, error) {
 					false,
 					true,
 					models.Settings{},
+					true,
+					"",
+					"",
+					"",
 				},
 				Members: []models.User{
 					models.User{
@@ -232,6 +418,10 @@ func (ms mockTeamStore) GetAll() ([]models.Team, error) {
 						false,
 						true,
 						models.Settings{},
+						true,
+						"",
+						"",
+						"",
 					},
 					models.User{
 						4,
@@ -244,6 +434,10 @@ func (ms mockTeamStore) GetAll() ([]models.Team, error) {
 						false,
 						true,
 						models.Settings{},
+						true,
+						"",
+						"",
+						"",
 					},
 				},
 			},
@@ -267,6 +461,10 @@ func (ms mockTeamStore) GetForUser(m models.User) ([]models.Team, error) {
 				false,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 			Members: []models.User{
 				models.User{
@@ -280,6 +478,10 @@ func (ms mockTeamStore) GetForUser(m models.User) ([]models.Team, error) {
 					false,
 					true,
 					models.Settings{},
+					true,
+					"",
+					"",
+					"",
 				},
 				models.User{
 					2,
@@ -292,6 +494,10 @@ func (ms mockTeamStore) GetForUser(m models.User) ([]models.Team, error) {
 					false,
 					true,
 					models.Settings{},
+					true,
+					"",
+					"",
+					"",
 				},
 			},
 		},
@@ -309,6 +515,10 @@ func (ms mockTeamStore) GetForUser(m models.User) ([]models.Team, error) {
 				false,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 			Members: []models.User{
 				models.User{
@@ -322,6 +532,10 @@ func (ms mockTeamStore) GetForUser(m models.User) ([]models.Team, error) {
 					false,
 					true,
 					models.Settings{},
+					true,
+					"",
+					"",
+					"",
 				},
 				models.User{
 					4,
@@ -334,6 +548,10 @@ func (ms mockTeamStore) GetForUser(m models.User) ([]models.Team, error) {
 					false,
 					true,
 					models.Settings{},
+					true,
+					"",
+					"",
+					"",
 				},
 			},
 		},
@@ -344,7 +562,28 @@ func (ms mockTeamStore) AddMembers(m models.Team, u ...models.User) error {
 	return nil
 }
 
+func (ms mockTeamStore) AddMember(t models.Team, u models.User) error {
+	return nil
+}
+
+func (ms mockTeamStore) RemoveMember(t models.Team, u models.User) error {
+	if u.ID == t.Lead.ID {
+		return store.ErrRemoveLead
+	}
+
+	return nil
+}
+
+func (ms mockTeamStore) GetMembers(t models.Team) ([]models.User, error) {
+	ms.Get(&t)
+	return t.Members, nil
+}
+
 func (ms mockTeamStore) New(t *models.Team) error {
+	if t.Name == "A" {
+		return store.ErrDuplicateEntry
+	}
+
 	t.ID = 1
 	return nil
 }
@@ -357,7 +596,7 @@ func (ms mockTeamStore) Remove(t models.Team) error {
 	return nil
 }
 
-//A mock LabelStore struct
+// A mock LabelStore struct
 type mockLabelStore struct{}
 
 func (ms mockLabelStore) Get(l *models.Label) error {
@@ -392,7 +631,7 @@ func (ms mockLabelStore) Remove(l models.Label) error {
 	return nil
 }
 
-//A mock FieldStore struct
+// A mock FieldStore struct
 type mockFieldStore struct{}
 
 func (mockFieldStore) Get(f *models.Field) error {
@@ -432,6 +671,17 @@ func (mockFieldStore) GetByProject(p models.Project) ([]models.Field, error) {
 	}, nil
 }
 
+func (mockFieldStore) GetFieldsForType(p models.Project, t models.TicketType) ([]models.Field, error) {
+	return []models.Field{
+		models.Field{
+			ID:       1,
+			Name:     "String Field",
+			DataType: "STRING",
+			Required: true,
+		},
+	}, nil
+}
+
 func (mockFieldStore) AddToProject(p models.Project, f *models.Field, t ...models.TicketType) error {
 	return nil
 }
@@ -449,10 +699,45 @@ func (mockFieldStore) Remove(f models.Field) error {
 	return nil
 }
 
+func (mockFieldStore) GetFieldOptions(fieldID int64) ([]string, error) {
+	if fieldID != 1 {
+		return []string{}, store.ErrNotFound
+	}
+
+	return []string{"HIGH", "MEDIUM", "LOW"}, nil
+}
+
+func (mockFieldStore) AddFieldOption(fieldID int64, option string) error {
+	if option == "HIGH" {
+		return store.ErrDuplicateEntry
+	}
+
+	return nil
+}
+
+func (mockFieldStore) RemoveFieldOption(fieldID int64, option string) error {
+	switch option {
+	case "HIGH":
+		return store.ErrInUse
+	case "MISSING":
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
 // //A mock TicketStore struct
 type mockTicketStore struct{}
 
+// savedTicket is the last ticket given to mockTicketStore.Save
+var savedTicket models.Ticket
+
 func (mockTicketStore) Get(t *models.Ticket) error {
+	// TEST-5 is a subtask of TEST-1, every other key is TEST-1.
+	if t.Key == "TEST-5" {
+		defer func() { t.ID, t.Key, t.ParentID = 5, "TEST-5", 1 }()
+	}
+
 	t.ID = 1
 
 	t.CreatedDate = time.Date(2016, time.Month(12), 25, 0, 0, 0, 0, loc)
@@ -498,6 +783,10 @@ func (mockTicketStore) Get(t *models.Ticket) error {
 		false,
 		true,
 		models.Settings{},
+		true,
+		"",
+		"",
+		"",
 	}
 
 	t.Assignee = models.User{
@@ -511,6 +800,10 @@ func (mockTicketStore) Get(t *models.Ticket) error {
 		true,
 		true,
 		models.Settings{},
+		true,
+		"",
+		"",
+		"",
 	}
 
 	t.Status = models.Status{
@@ -521,6 +814,16 @@ func (mockTicketStore) Get(t *models.Ticket) error {
 	return nil
 }
 
+func (ms mockTicketStore) GetByKey(key string) (models.Ticket, error) {
+	t := models.Ticket{Key: key}
+	if key != "TEST-1" {
+		return t, store.ErrNotFound
+	}
+
+	err := ms.Get(&t)
+	return t, err
+}
+
 func (ms mockTicketStore) GetAll() ([]models.Ticket, error) {
 	return []models.Ticket{
 		models.Ticket{
@@ -568,6 +871,10 @@ func (ms mockTicketStore) GetAll() ([]models.Ticket, error) {
 				false,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Assignee: models.User{
@@ -581,6 +888,10 @@ func (ms mockTicketStore) GetAll() ([]models.Ticket, error) {
 				true,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Status: models.Status{
@@ -634,6 +945,10 @@ func (ms mockTicketStore) GetAll() ([]models.Ticket, error) {
 				false,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Assignee: models.User{
@@ -647,6 +962,10 @@ func (ms mockTicketStore) GetAll() ([]models.Ticket, error) {
 				true,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Status: models.Status{
@@ -656,7 +975,7 @@ func (ms mockTicketStore) GetAll() ([]models.Ticket, error) {
 		},
 	}, nil
 }
-func (ms mockTicketStore) GetAllByProject(p models.Project) ([]models.Ticket, error) {
+func (ms mockTicketStore) GetAllByProject(p models.Project, dir string) ([]models.Ticket, error) {
 	return []models.Ticket{
 		models.Ticket{
 			ID:          1,
@@ -703,6 +1022,10 @@ func (ms mockTicketStore) GetAllByProject(p models.Project) ([]models.Ticket, er
 				false,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Assignee: models.User{
@@ -716,6 +1039,10 @@ func (ms mockTicketStore) GetAllByProject(p models.Project) ([]models.Ticket, er
 				true,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Status: models.Status{
@@ -769,6 +1096,10 @@ func (ms mockTicketStore) GetAllByProject(p models.Project) ([]models.Ticket, er
 				false,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Assignee: models.User{
@@ -782,6 +1113,10 @@ func (ms mockTicketStore) GetAllByProject(p models.Project) ([]models.Ticket, er
 				true,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 
 			Status: models.Status{
@@ -792,14 +1127,80 @@ func (ms mockTicketStore) GetAllByProject(p models.Project) ([]models.Ticket, er
 	}, nil
 }
 
+// mockPage will return the page of tickets described by opts
+func mockPage(tks []models.Ticket, opts store.PageOptions) ([]models.Ticket, int) {
+	total := len(tks)
+
+	if opts.Offset >= total {
+		return []models.Ticket{}, total
+	}
+
+	tks = tks[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(tks) {
+		tks = tks[:opts.Limit]
+	}
+
+	return tks, total
+}
+
+func (ms mockTicketStore) GetAllPaged(opts store.PageOptions) ([]models.Ticket, int, error) {
+	tks, _ := ms.GetAll()
+	page, total := mockPage(tks, opts)
+	return page, total, nil
+}
+
+func (ms mockTicketStore) GetAllByProjectPaged(p models.Project, opts store.PageOptions) ([]models.Ticket, int, error) {
+	tks, _ := ms.GetAllByProject(p, "")
+	page, total := mockPage(tks, opts)
+	return page, total, nil
+}
+
+func (ms mockTicketStore) Search(query string, p models.Project) ([]models.Ticket, error) {
+	return ms.GetAll()
+}
+
+func (ms mockTicketStore) GetCommentsForTickets(ids []int64) (map[int64][]models.Comment, error) {
+	comments := make(map[int64][]models.Comment)
+
+	for _, id := range ids {
+		cs, err := ms.GetComments(models.Ticket{ID: id})
+		if err != nil {
+			return nil, err
+		}
+
+		comments[id] = cs
+	}
+
+	return comments, nil
+}
+
+func (ms mockTicketStore) GetCommentsPaged(t models.Ticket, opts store.PageOptions) ([]models.Comment, int, error) {
+	comments, err := ms.GetComments(t)
+	if opts.Offset > 0 {
+		return nil, len(comments), err
+	}
+
+	return comments, len(comments), err
+}
+
+func (ms mockTicketStore) GetComment(c *models.Comment) error {
+	if c.ID != 1 {
+		return store.ErrNotFound
+	}
+
+	c.Body = "This is a comment"
+	c.Author = models.User{ID: 1, Username: "foouser"}
+	return nil
+}
+
 func (ms mockTicketStore) GetComments(t models.Ticket) ([]models.Comment, error) {
 	return []models.Comment{
 		models.Comment{
-			1,
-			time.Date(2016, time.Month(12), 25, 0, 0, 0, 0, loc),
-			time.Date(2016, time.Month(12), 25, 0, 0, 0, 0, loc),
-			"This is a fake comment",
-			models.User{
+			ID:          1,
+			UpdatedDate: time.Date(2016, time.Month(12), 25, 0, 0, 0, 0, loc),
+			CreatedDate: time.Date(2016, time.Month(12), 25, 0, 0, 0, 0, loc),
+			Body:        "This is a fake comment",
+			Author: models.User{
 				2,
 				"baruser",
 				"barpass",
@@ -810,6 +1211,10 @@ func (ms mockTicketStore) GetComments(t models.Ticket) ([]models.Comment, error)
 				true,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 		},
 	}, nil
@@ -820,6 +1225,17 @@ func (ms mockTicketStore) NewComment(t models.Ticket, c *models.Comment) error {
 	return nil
 }
 
+func (ms mockTicketStore) GetCommentHistory(c models.Comment) ([]models.CommentRevision, error) {
+	return []models.CommentRevision{
+		models.CommentRevision{
+			ID:       1,
+			OldBody:  "The original body",
+			EditedAt: time.Date(2016, time.Month(12), 25, 0, 0, 0, 0, loc),
+			Editor:   models.User{ID: 1, Username: "foouser"},
+		},
+	}, nil
+}
+
 func (ms mockTicketStore) SaveComment(c models.Comment) error {
 	return nil
 }
@@ -828,20 +1244,285 @@ func (ms mockTicketStore) RemoveComment(c models.Comment) error {
 	return nil
 }
 
+func (ms mockTicketStore) AddReaction(c models.Comment, u models.User, emoji string) error {
+	if emoji == "+1" {
+		return store.ErrDuplicateEntry
+	}
+
+	return nil
+}
+
+func (ms mockTicketStore) RemoveReaction(c models.Comment, u models.User, emoji string) error {
+	return nil
+}
+
+func (ms mockTicketStore) GetReactions(c models.Comment) (map[string]int, error) {
+	return map[string]int{"+1": 2, "tada": 1}, nil
+}
+
+func (ms mockTicketStore) GetFiltered(f store.TicketFilter) ([]models.Ticket, error) {
+	return ms.GetAll()
+}
+
+func (ms mockTicketStore) SaveFilter(u models.User, f *store.SavedFilter) error {
+	if f.Name == "" {
+		return store.ErrMissingField
+	}
+
+	f.ID = 1
+	return nil
+}
+
+func (ms mockTicketStore) GetFilters(u models.User) ([]store.SavedFilter, error) {
+	return []store.SavedFilter{
+		{ID: 1, Name: "mine", Filter: store.TicketFilter{AssigneeUsername: u.Username}},
+	}, nil
+}
+
+func (ms mockTicketStore) DeleteFilter(u models.User, name string) error {
+	if name != "mine" {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (ms mockTicketStore) RunFilter(name string, u models.User) ([]models.Ticket, error) {
+	if name != "mine" {
+		return nil, store.ErrNotFound
+	}
+
+	return ms.GetAll()
+}
+
+func (ms mockTicketStore) GetRecentlyUpdated(p models.Project, limit int) ([]models.Ticket, error) {
+	tks, err := ms.GetAllByProject(p, "")
+	if limit > 0 && limit < len(tks) {
+		tks = tks[:limit]
+	}
+
+	return tks, err
+}
+
+func (ms mockTicketStore) GetByAssignee(u models.User) ([]models.Ticket, error) {
+	return ms.GetAll()
+}
+
+func (ms mockTicketStore) GetByReporter(u models.User) ([]models.Ticket, error) {
+	return ms.GetAll()
+}
+
+func (ms mockTicketStore) CountByStatus(p models.Project) (map[string]int, error) {
+	return map[string]int{"Open": 2, "In Progress": 1, "Closed": 0}, nil
+}
+
+// CountAllByStatus gives each of the mock projects the counts of
+// CountByStatus
+func (ms mockTicketStore) CountAllByStatus() (map[string]map[string]int, error) {
+	projects, _ := mockProjectStore{}.GetAllIncludingArchived()
+
+	counts := make(map[string]map[string]int)
+	for _, p := range projects {
+		counts[p.Key], _ = ms.CountByStatus(p)
+	}
+
+	return counts, nil
+}
+
+func (ms mockTicketStore) GetChildren(t models.Ticket) ([]models.Ticket, error) {
+	tickets, err := ms.GetAll()
+	for i := range tickets {
+		tickets[i].ParentID = 1
+	}
+
+	return tickets, err
+}
+
+func (ms mockTicketStore) AddLabel(t models.Ticket, l models.Label) error {
+	return nil
+}
+
+func (ms mockTicketStore) RemoveLabel(t models.Ticket, l models.Label) error {
+	return nil
+}
+
+func (ms mockTicketStore) GetLabels(t models.Ticket) ([]models.Label, error) {
+	return []models.Label{models.Label{ID: 1, Name: "test"}}, nil
+}
+
+func (ms mockTicketStore) AddWatcher(t models.Ticket, u models.User) error {
+	return nil
+}
+
+func (ms mockTicketStore) RemoveWatcher(t models.Ticket, u models.User) error {
+	return nil
+}
+
+func (ms mockTicketStore) GetWatchers(t models.Ticket) ([]models.User, error) {
+	var u models.User
+	mockUsersStore{}.Get(&u)
+	u.Password = ""
+	return []models.User{u}, nil
+}
+
+func (ms mockTicketStore) LinkTickets(src, dst models.Ticket, linkType string) error {
+	return nil
+}
+
+func (ms mockTicketStore) Unlink(src, dst models.Ticket, linkType string) error {
+	return nil
+}
+
+func (ms mockTicketStore) GetLinks(t models.Ticket) ([]models.TicketLink, error) {
+	return []models.TicketLink{}, nil
+}
+
+func (ms mockTicketStore) GetTransitions(t models.Ticket) ([]models.Transition, error) {
+	return []models.Transition{
+		models.Transition{
+			ID:       1,
+			Name:     "Done",
+			ToStatus: models.Status{ID: 3, Name: "Done"},
+		},
+	}, nil
+}
+
+func (ms mockTicketStore) TransitionTicket(t models.Ticket, s models.Status) error {
+	if s.ID != 3 && s.Name != "Done" {
+		return store.ErrInvalidTransition
+	}
+
+	return nil
+}
+
+func (ms mockTicketStore) TransitionBatch(tickets []models.Ticket, s models.Status) error {
+	for _, t := range tickets {
+		err := ms.TransitionTicket(t, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms mockTicketStore) AssignTicket(t models.Ticket, assignee models.User) error {
+	if t.Key == "NOPE-1" || assignee.Username == "nouser" {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (ms mockTicketStore) GetOverdue(p models.Project) ([]models.Ticket, error) {
+	return []models.Ticket{}, nil
+}
+
+func (ms mockTicketStore) LogWork(t models.Ticket, seconds int64, u models.User) error {
+	if t.Key == "NOPE-1" {
+		return store.ErrNotFound
+	}
+
+	if seconds <= 0 {
+		return store.ErrInvalidDuration
+	}
+
+	return nil
+}
+
+func (ms mockTicketStore) GetWorklogs(t models.Ticket) ([]models.Worklog, error) {
+	return []models.Worklog{
+		{ID: 1, Seconds: 1800, Author: models.User{ID: 1, Username: "foouser"}},
+		{ID: 2, Seconds: 3600, Author: models.User{ID: 1, Username: "foouser"}},
+	}, nil
+}
+
+func (ms mockTicketStore) TotalTimeSpent(t models.Ticket) (int64, error) {
+	if t.Key == "NOPE-1" {
+		return 0, store.ErrNotFound
+	}
+
+	return 5400, nil
+}
+
+func (ms mockTicketStore) GetHistory(t models.Ticket) ([]models.HistoryEntry, error) {
+	return []models.HistoryEntry{
+		models.HistoryEntry{
+			ID:       1,
+			Field:    "summary",
+			OldValue: "A test ticket",
+			NewValue: "An updated test ticket",
+			User:     models.User{ID: 1, Username: "foouser"},
+		},
+	}, nil
+}
+
+func (ms mockTicketStore) AddAttachment(t models.Ticket, a *models.Attachment) error {
+	a.ID = 1
+	return nil
+}
+
+func (ms mockTicketStore) GetAttachments(t models.Ticket) ([]models.Attachment, error) {
+	return []models.Attachment{
+		models.Attachment{
+			ID:          1,
+			Filename:    "test.txt",
+			ContentType: "text/plain",
+			Size:        5,
+			StorageKey:  "tickets/1/test.txt",
+			UploadedBy:  models.User{ID: 1, Username: "foouser"},
+		},
+	}, nil
+}
+
+func (ms mockTicketStore) RemoveAttachment(a models.Attachment) error {
+	return nil
+}
+
+// savedBatch is the last batch given to mockTicketStore.NewBatch
+var savedBatch []*models.Ticket
+
+func (ms mockTicketStore) NewBatch(p models.Project, tickets []*models.Ticket) error {
+	savedBatch = tickets
+
+	for i, t := range tickets {
+		t.ID = int64(i + 1)
+		t.Key = p.Key + strconv.Itoa(i+1)
+	}
+
+	return nil
+}
+
 func (ms mockTicketStore) NextTicketKey(p models.Project) string {
 	return "TEST-2"
 }
 
 func (ms mockTicketStore) New(p models.Project, t *models.Ticket) error {
+	// There are no statuses past Done in the mock data
+	if t.Status.ID > 3 {
+		return store.ReferenceError{Field: "status"}
+	}
+
 	t.ID = 1
+	t.Key = p.Key + "1"
 	return nil
 }
 
 func (ms mockTicketStore) Save(t models.Ticket) error {
+	savedTicket = t
+
+	if t.Version != 1 {
+		return store.ErrStaleObject
+	}
+
 	return nil
 }
 
 func (ms mockTicketStore) Remove(t models.Ticket) error {
+	if t.Key == "TEST1" {
+		return store.ErrHasChildren
+	}
+
 	return nil
 }
 
@@ -868,15 +1549,27 @@ func (ms mockTypeStore) GetAll() ([]models.TicketType, error) {
 }
 
 func (ms mockTypeStore) New(t *models.TicketType) error {
+	if t.Name == "Fake Type" {
+		return store.ErrDuplicateEntry
+	}
+
 	t.ID = 1
 	return nil
 }
 
 func (ms mockTypeStore) Save(t models.TicketType) error {
+	if t.ID > 2 {
+		return store.ErrNotFound
+	}
+
 	return nil
 }
 
 func (ms mockTypeStore) Remove(t models.TicketType) error {
+	if t.ID == 1 {
+		return store.ErrInUse
+	}
+
 	return nil
 }
 
@@ -899,6 +1592,10 @@ func (ms mockProjectStore) Get(p *models.Project) error {
 		true,
 		true,
 		models.Settings{},
+		true,
+		"",
+		"",
+		"",
 	}
 	return nil
 }
@@ -921,6 +1618,10 @@ func (ms mockProjectStore) GetAll() ([]models.Project, error) {
 				true,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 		},
 		models.Project{
@@ -939,11 +1640,25 @@ func (ms mockProjectStore) GetAll() ([]models.Project, error) {
 				false,
 				true,
 				models.Settings{},
+				true,
+				"",
+				"",
+				"",
 			},
 		},
 	}, nil
 }
 
+func (ms mockProjectStore) GetAllIncludingArchived() ([]models.Project, error) {
+	projects, err := ms.GetAll()
+	return append(projects, models.Project{
+		ID:       3,
+		Name:     "Archived Project",
+		Key:      "OLD",
+		Archived: true,
+	}), err
+}
+
 func (ms mockProjectStore) New(p *models.Project) error {
 	p.ID = 1
 	return nil
@@ -953,10 +1668,81 @@ func (ms mockProjectStore) Save(p models.Project) error {
 	return nil
 }
 
-func (ms mockProjectStore) Remove(p models.Project) error {
+func (ms mockProjectStore) ArchiveProject(p models.Project) error {
+	if p.Key != "TEST" {
+		return store.ErrNotFound
+	}
+
 	return nil
 }
 
+func (ms mockProjectStore) UnarchiveProject(p models.Project) error {
+	return ms.ArchiveProject(p)
+}
+
+func (ms mockProjectStore) Remove(p models.Project, cascade bool) error {
+	if p.Key == "TEST" && !cascade {
+		return store.ErrProjectHasTickets
+	}
+
+	return nil
+}
+
+func (ms mockProjectStore) AddMember(p models.Project, u models.User, role models.PermissionLevel) error {
+	return nil
+}
+
+func (ms mockProjectStore) RemoveMember(p models.Project, u models.User) error {
+	return nil
+}
+
+func (ms mockProjectStore) GetMembers(p models.Project) ([]models.ProjectMember, error) {
+	return []models.ProjectMember{
+		{User: models.User{ID: 1, Username: "foouser"}, Role: models.CoreR},
+	}, nil
+}
+
+func (ms mockProjectStore) SuggestAssignee(p models.Project) (models.User, error) {
+	if p.Key == "EMPTY" {
+		return models.User{}, store.ErrNoMembers
+	}
+
+	if p.Key != "TEST" {
+		return models.User{}, store.ErrNotFound
+	}
+
+	return models.User{ID: 1, Username: "foouser"}, nil
+}
+
+// GetRole makes foouser a core member of TEST
+func (ms mockProjectStore) GetRole(p models.Project, u models.User) (models.PermissionLevel, error) {
+	if p.Key == "TEST" && u.Username == "foouser" {
+		return models.CoreR, nil
+	}
+
+	return "", store.ErrNotFound
+}
+
+func (ms mockProjectStore) GetRoles(u models.User) (map[string]models.PermissionLevel, error) {
+	roles := make(map[string]models.PermissionLevel)
+	if u.Username == "foouser" {
+		roles["TEST"] = models.CoreR
+	}
+
+	return roles, nil
+}
+
+func (ms mockProjectStore) GetProjectForTicket(key string) (models.Project, error) {
+	var p models.Project
+
+	if key != "TEST-1" {
+		return p, store.ErrNotFound
+	}
+
+	err := ms.Get(&p)
+	return p, err
+}
+
 // A mock StatusStore struct
 type mockStatusStore struct{}
 
@@ -969,12 +1755,27 @@ func (ms mockStatusStore) Get(s *models.Status) error {
 func (ms mockStatusStore) GetAll() ([]models.Status, error) {
 	return []models.Status{
 		models.Status{
-			1,
-			"mock Status",
+			ID:   1,
+			Name: "mock Status",
 		},
 		models.Status{
-			2,
-			"Fake Status",
+			ID:   2,
+			Name: "Fake Status",
+		},
+	}, nil
+}
+
+func (ms mockStatusStore) GetAllOrdered() ([]models.Status, error) {
+	return []models.Status{
+		models.Status{
+			ID:       2,
+			Name:     "Fake Status",
+			Position: 0,
+		},
+		models.Status{
+			ID:       1,
+			Name:     "mock Status",
+			Position: 1,
 		},
 	}, nil
 }
@@ -989,6 +1790,20 @@ func (ms mockStatusStore) Save(p models.Status) error {
 }
 
 func (ms mockStatusStore) Remove(p models.Status) error {
+	if p.ID == 1 {
+		return store.ErrInUse
+	}
+
+	return nil
+}
+
+func (ms mockStatusStore) Reorder(ids []int64) error {
+	for _, id := range ids {
+		if id > 2 {
+			return store.ErrNotFound
+		}
+	}
+
 	return nil
 }
 
@@ -1196,6 +2011,10 @@ func testLogin(r *http.Request) {
 		false,
 		true,
 		models.Settings{},
+		true,
+		"",
+		"",
+		"",
 	}
 
 	token, err := mw.JWTSignUser(u)
@@ -1218,6 +2037,10 @@ func testAdminLogin(r *http.Request) {
 		true,
 		true,
 		models.Settings{},
+		true,
+		"",
+		"",
+		"",
 	}
 
 	token, err := mw.JWTSignUser(u)
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Error codes are stable machine readable identifiers sent with every error
+// response so clients do not have to match on the message.
+const (
+	CodeUnknown         = "unknown_error"
+	CodeInternal        = "internal_error"
+	CodeInvalidRequest  = "invalid_request"
+	CodeNotLoggedIn     = "not_logged_in"
+	CodeForbidden       = "forbidden"
+	CodeUserNotFound    = "user_not_found"
+	CodeUserExists      = "user_exists"
+	CodeInvalidPassword = "invalid_password"
+	CodeWeakPassword    = "weak_password"
+	CodeInvalidToken    = "invalid_token"
+	CodeAccountLocked   = "account_locked"
+)
+
+// APIError is the json body of an error response, it is always sent wrapped
+// in an object like {"error": {...}}.
+type APIError struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+}
+
+// NewAPIError creates an APIError, if the error applies to more than one
+// field they are joined with commas.
+func NewAPIError(code, msg string, fields ...string) APIError {
+	return APIError{
+		Message: msg,
+		Code:    code,
+		Field:   strings.Join(fields, ","),
+	}
+}
+
+func (e APIError) Error() string {
+	return e.Message
+}
+
+// errorBody is the json body of an error response
+type errorBody struct {
+	Error APIError `json:"error"`
+}
+
+// JSON will marshal the error into the body of an error response.
+func (e APIError) JSON() []byte {
+	byt, _ := json.Marshal(errorBody{e})
+	return byt
+}
+
+// apiError is used by handlers which do not yet send a specific code.
+func apiError(msg string, fields ...string) []byte {
+	return NewAPIError(CodeUnknown, msg, fields...).JSON()
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// exportPageSize is how many tickets are read from the store at a time when
+// exporting a project, so large projects are never held in memory at once.
+const exportPageSize = 500
+
+// exportColumns is the header row of a project's CSV export
+var exportColumns = []string{
+	"key",
+	"summary",
+	"status",
+	"type",
+	"assignee",
+	"reporter",
+	"created_date",
+	"updated_date",
+}
+
+// ExportProjectCSV will stream every ticket in the project as CSV, a page of
+// tickets at a time.
+func ExportProjectCSV(w http.ResponseWriter, r *http.Request) {
+	p := models.Project{Key: mux.Vars(r)["pkey"]}
+
+	err := reqStore(r).Projects().Get(&p)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	cw := csv.NewWriter(w)
+
+	for offset := 0; ; offset += exportPageSize {
+		tks, _, err := reqStore(r).Tickets().GetAllByProjectPaged(p,
+			store.PageOptions{Limit: exportPageSize, Offset: offset})
+		if err != nil {
+			logError(r, err)
+
+			// Once rows have been sent the status can't be changed, the
+			// client gets a truncated file.
+			if offset == 0 {
+				w.WriteHeader(500)
+				w.Write(apiError(err.Error()))
+			}
+
+			return
+		}
+
+		if offset == 0 {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition",
+				`attachment; filename="`+p.Key+`.csv"`)
+			cw.Write(exportColumns)
+		}
+
+		for _, t := range tks {
+			cw.Write(exportRow(t))
+		}
+
+		cw.Flush()
+		if err = cw.Error(); err != nil {
+			logError(r, err)
+			return
+		}
+
+		// Send each page as it's written instead of when the export is done.
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		if len(tks) < exportPageSize {
+			return
+		}
+	}
+}
+
+// exportRow returns the CSV row for the ticket in the order of exportColumns
+func exportRow(t models.Ticket) []string {
+	return []string{
+		t.Key,
+		csvText(t.Summary),
+		t.Status.Name,
+		t.Type.Name,
+		t.Assignee.Username,
+		t.Reporter.Username,
+		t.CreatedDate.Format(time.RFC3339),
+		t.UpdatedDate.Format(time.RFC3339),
+	}
+}
+
+// csvText quotes text which a spreadsheet would run as a formula, the
+// summary is written by users so it can't be trusted not to be one.
+func csvText(s string) string {
+	if s != "" && strings.ContainsAny(s[:1], "=+-@") {
+		return "'" + s
+	}
+
+	return s
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
+)
+
+func initFieldRoutes() {
+	Router.Handle("/fields/{id}/options", mw.Default(GetFieldOptions)).Methods("GET")
+	Router.Handle("/fields/{id}/options", mw.Default(AddFieldOption)).Methods("POST")
+	Router.Handle("/fields/{id}/options/{option}", mw.Default(RemoveFieldOption)).Methods("DELETE")
+}
+
+// FieldOptionRequest is the body used to add an option to a field
+type FieldOptionRequest struct {
+	Option string `json:"option"`
+}
+
+// GetFieldOptions will return the json encoded array of the options of the
+// field indicated by the id
+func GetFieldOptions(w http.ResponseWriter, r *http.Request) {
+	id, ok := idVar(w, r)
+	if !ok {
+		return
+	}
+
+	options, err := reqStore(r).Fields().GetFieldOptions(id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("field not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, options)
+}
+
+// AddFieldOption will add the option in the body to the field indicated by
+// the id, it can only be used by sys admins
+func AddFieldOption(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change field options") {
+		return
+	}
+
+	id, ok := idVar(w, r)
+	if !ok {
+		return
+	}
+
+	var req FieldOptionRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	err = reqStore(r).Fields().AddFieldOption(id, req.Option)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrMissingField):
+			w.WriteHeader(400)
+			w.Write(apiError("option is required", "option"))
+		case err == store.ErrNotFound:
+			w.WriteHeader(404)
+			w.Write(apiError("field not found"))
+		case errors.Is(err, store.ErrDuplicateEntry):
+			w.WriteHeader(409)
+			w.Write(apiError("the field already has that option", "option"))
+		default:
+			w.WriteHeader(500)
+			w.Write(apiError(err.Error()))
+			logError(r, err)
+		}
+
+		return
+	}
+
+	GetFieldOptions(w, r)
+}
+
+// RemoveFieldOption will remove the option in the url from the field
+// indicated by the id, options which are selected on tickets are not
+// removed. It can only be used by sys admins.
+func RemoveFieldOption(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change field options") {
+		return
+	}
+
+	id, ok := idVar(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Fields().RemoveFieldOption(id, mux.Vars(r)["option"])
+	if err != nil {
+		switch err {
+		case store.ErrInUse:
+			w.WriteHeader(409)
+			w.Write(apiError("option is selected on tickets"))
+		case store.ErrNotFound:
+			w.WriteHeader(404)
+			w.Write(apiError("field does not have that option"))
+		default:
+			w.WriteHeader(500)
+			w.Write(apiError(err.Error()))
+			logError(r, err)
+		}
+
+		return
+	}
+
+	w.Write([]byte{})
+}
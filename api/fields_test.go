@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetFieldOptions(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/fields/1/options", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var options []string
+
+	e := json.Unmarshal(w.Body.Bytes(), &options)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(options) != 3 {
+		t.Errorf("Expected 3 options Got %v\n", options)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestChangeFieldOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		login  func(*http.Request)
+		code   int
+	}{
+		{"get missing field", "GET", "/fields/2/options", "", testLogin, 404},
+		{"add as core member", "POST", "/fields/1/options", `{"option":"NONE"}`, testLogin, 403},
+		{"add", "POST", "/fields/1/options", `{"option":"NONE"}`, testAdminLogin, 200},
+		{"add duplicate", "POST", "/fields/1/options", `{"option":"HIGH"}`, testAdminLogin, 409},
+		{"remove as core member", "DELETE", "/fields/1/options/LOW", "", testLogin, 403},
+		{"remove", "DELETE", "/fields/1/options/LOW", "", testAdminLogin, 200},
+		{"remove in use", "DELETE", "/fields/1/options/HIGH", "", testAdminLogin, 409},
+		{"remove missing", "DELETE", "/fields/1/options/MISSING", "", testAdminLogin, 404},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path,
+			bytes.NewBufferString(test.body))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
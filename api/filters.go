@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
+)
+
+// filterOwner returns the logged in user if they are the user named in the
+// url, saved filters are private so anyone else is forbidden. Routes using it
+// must require a login with mw.RequireAuth.
+func filterOwner(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	u := mw.GetUser(r.Context())
+
+	if u.Username != mux.Vars(r)["username"] {
+		w.WriteHeader(403)
+		w.Write(NewAPIError(CodeForbidden,
+			"you can only use your own saved filters").JSON())
+		return models.User{}, false
+	}
+
+	return *u, true
+}
+
+// GetFilters will return the saved filters of the user in the url
+func GetFilters(w http.ResponseWriter, r *http.Request) {
+	u, ok := filterOwner(w, r)
+	if !ok {
+		return
+	}
+
+	filters, err := reqStore(r).Tickets().GetFilters(u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	if filters == nil {
+		filters = []store.SavedFilter{}
+	}
+
+	sendJSON(w, filters)
+}
+
+// SaveFilter will save the filter in the body for the user in the url, a
+// filter with the same name is replaced.
+func SaveFilter(w http.ResponseWriter, r *http.Request) {
+	u, ok := filterOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var f store.SavedFilter
+
+	err := json.NewDecoder(r.Body).Decode(&f)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, "invalid body").JSON())
+		logError(r, err)
+		return
+	}
+
+	err = reqStore(r).Tickets().SaveFilter(u, &f)
+	if err != nil {
+		if errors.Is(err, store.ErrMissingField) {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidRequest,
+				"a filter must have a name", "name").JSON())
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, f)
+}
+
+// DeleteFilter will remove the saved filter named in the url
+func DeleteFilter(w http.ResponseWriter, r *http.Request) {
+	u, ok := filterOwner(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Tickets().DeleteFilter(u, mux.Vars(r)["name"])
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("filter not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// RunFilter will return the tickets matching the saved filter named in the
+// url
+func RunFilter(w http.ResponseWriter, r *http.Request) {
+	u, ok := filterOwner(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := reqStore(r).Tickets().RunFilter(mux.Vars(r)["name"], u)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("filter not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	sendJSONFiltered(w, r, tickets)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+// DB is the database pinged by the readiness check, it's nil when the store
+// is not backed by a sql database.
+var DB *sql.DB
+
+// pingTimeout is how long the readiness check waits for the database
+const pingTimeout = 2 * time.Second
+
+// The health checks are used by load balancers and orchestrators so they
+// skip the default middleware, neither needs a user and logging every probe
+// would drown out the rest of the log.
+func initHealthRoutes() {
+	Router.HandleFunc("/healthz", Healthz).Methods("GET")
+	Router.HandleFunc("/readyz", Readyz).Methods("GET")
+}
+
+// readiness is the response of the readiness check
+type readiness struct {
+	Status      string  `json:"status"`
+	DBLatencyMS float64 `json:"db_latency_ms"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Healthz reports that the server is running
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, readiness{Status: "ok"})
+}
+
+// Readyz will ping the database, responding with 503 if it can't be reached
+// and 200 otherwise along with how long the ping took.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	if DB == nil {
+		sendJSON(w, readiness{Status: "ok"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := DB.PingContext(ctx)
+	rd := readiness{
+		Status:      "ok",
+		DBLatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+
+	if err != nil {
+		rd.Status = "unavailable"
+		rd.Error = err.Error()
+		logError(r, err)
+		sendJSONStatus(w, 503, rd)
+		return
+	}
+
+	sendJSON(w, rd)
+}
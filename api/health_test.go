@@ -0,0 +1,53 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/healthz", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d", w.Code)
+	}
+}
+
+func TestReadyzClosedDB(t *testing.T) {
+	db, err := sql.Open("postgres", "postgres://localhost/praelatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+
+	DB = db
+	defer func() { DB = nil }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/readyz", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 503 {
+		t.Errorf("Expected 503 Got %d", w.Code)
+	}
+
+	var rd readiness
+
+	err = json.Unmarshal(w.Body.Bytes(), &rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rd.Status != "unavailable" || rd.Error == "" {
+		t.Errorf("Expected an unavailable status Got %v", rd)
+	}
+
+	t.Log(w.Body)
+}
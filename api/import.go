@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
+)
+
+// ImportError is the error for a single ticket of an import, Row is it's
+// index in the imported array.
+type ImportError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// ImportResult is the response to an import, Keys holds the keys of the
+// created tickets in the order they were imported.
+type ImportResult struct {
+	Keys   []string      `json:"keys"`
+	Errors []ImportError `json:"errors,omitempty"`
+}
+
+var (
+	errUnknownStatus = errors.New("unknown status")
+	errUnknownType   = errors.New("unknown ticket type")
+	errNoSummary     = errors.New("summary is required")
+)
+
+// ImportTickets will create the tickets in the json array body in the project
+// indicated by the url. By default the import is strict and nothing is
+// created if any ticket is invalid, with mode=best_effort the valid tickets
+// are created and the rest are reported as errors.
+func ImportTickets(w http.ResponseWriter, r *http.Request) {
+	u := mw.GetUser(r.Context())
+
+	var bestEffort bool
+
+	switch r.FormValue("mode") {
+	case "", "strict":
+	case "best_effort":
+		bestEffort = true
+	default:
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest,
+			"mode must be strict or best_effort", "mode").JSON())
+		return
+	}
+
+	var tickets []*models.Ticket
+
+	err := json.NewDecoder(r.Body).Decode(&tickets)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	s := reqStore(r)
+
+	p := models.Project{Key: mux.Vars(r)["pkey"]}
+
+	err = s.Projects().Get(&p)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	v, err := newImportValidator(s)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	var result ImportResult
+	var valid []*models.Ticket
+	var rows []int
+
+	for i, t := range tickets {
+		if t == nil {
+			t = &models.Ticket{}
+		}
+
+		// Tickets can't be imported as reported by someone else.
+		t.Reporter = *u
+
+		err = v.validate(t)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{i, err.Error()})
+			continue
+		}
+
+		valid = append(valid, t)
+		rows = append(rows, i)
+	}
+
+	if len(result.Errors) > 0 && !bestEffort {
+		sendJSONStatus(w, 400, result)
+		return
+	}
+
+	if len(valid) > 0 {
+		err = s.Tickets().NewBatch(p, valid)
+	}
+
+	// None of the tickets would be created one at a time either.
+	if err == store.ErrProjectArchived {
+		w.WriteHeader(409)
+		w.Write(apiError("project is archived"))
+		return
+	}
+
+	if err != nil && !bestEffort {
+		w.WriteHeader(400)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	// The batch fails as a whole, so to find which tickets the store
+	// rejected they are created one at a time instead.
+	for i, t := range valid {
+		if err != nil {
+			e := s.Tickets().New(p, t)
+			if e != nil {
+				result.Errors = append(result.Errors, ImportError{rows[i], e.Error()})
+				continue
+			}
+		}
+
+		result.Keys = append(result.Keys, t.Key)
+	}
+
+	sendJSON(w, result)
+}
+
+// importValidator checks the statuses and types referenced by imported
+// tickets exist, they can be referenced by ID or by name.
+type importValidator struct {
+	statuses []models.Status
+	types    []models.TicketType
+}
+
+func newImportValidator(s store.Store) (importValidator, error) {
+	var v importValidator
+	var err error
+
+	v.statuses, err = s.Statuses().GetAll()
+	if err != nil {
+		return v, err
+	}
+
+	v.types, err = s.Types().GetAll()
+	return v, err
+}
+
+// validate fills in the status and type of t, returning an error if either
+// doesn't exist or t has no summary.
+func (v importValidator) validate(t *models.Ticket) error {
+	if t.Summary == "" {
+		return errNoSummary
+	}
+
+	found := false
+	for _, st := range v.statuses {
+		if st.ID == t.Status.ID ||
+			(t.Status.ID == 0 && t.Status.Name != "" && st.Name == t.Status.Name) {
+			t.Status, found = st, true
+			break
+		}
+	}
+
+	if !found {
+		return errUnknownStatus
+	}
+
+	found = false
+	for _, typ := range v.types {
+		if typ.ID == t.Type.ID ||
+			(t.Type.ID == 0 && t.Type.Name != "" && typ.Name == t.Type.Name) {
+			t.Type, found = typ, true
+			break
+		}
+	}
+
+	if !found {
+		return errUnknownType
+	}
+
+	return nil
+}
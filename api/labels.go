@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
 
@@ -21,21 +20,14 @@ func initLabelRoutes() {
 
 // GetAllLabels will return a JSON array of all labels from the store.
 func GetAllLabels(w http.ResponseWriter, r *http.Request) {
-	labels, err := Store.Labels().GetAll()
+	labels, err := reqStore(r).Labels().GetAll()
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		w.Write(apiError(err.Error()))
 		return
 	}
 
-	jsn, err := json.Marshal(labels)
-	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
-		return
-	}
-
-	w.Write(jsn)
+	sendJSON(w, labels)
 }
 
 // GetLabel will return a JSON representation of a model.
@@ -51,21 +43,14 @@ func GetLabel(w http.ResponseWriter, r *http.Request) {
 
 	lbl.ID = int64(i)
 
-	err = Store.Labels().Get(lbl)
-	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
-		return
-	}
-
-	jsn, err := json.Marshal(lbl)
+	err = reqStore(r).Labels().Get(lbl)
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		w.Write(apiError(err.Error()))
 		return
 	}
 
-	w.Write(jsn)
+	sendJSON(w, lbl)
 }
 
 // CreateLabel creates a label in the db and return a JSON object of
@@ -81,14 +66,14 @@ func CreateLabel(w http.ResponseWriter, r *http.Request) {
 
 	lbl.ID = int64(i)
 
-	err = Store.Labels().New(lbl)
+	err = reqStore(r).Labels().New(lbl)
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		w.Write(apiError(err.Error()))
 		return
 	}
 
-	w.Write([]byte(lbl.String()))
+	sendJSON(w, lbl)
 }
 
 // UpdateLabel updates the label in the db and returns a message indicating
@@ -96,10 +81,10 @@ func CreateLabel(w http.ResponseWriter, r *http.Request) {
 func UpdateLabel(w http.ResponseWriter, r *http.Request) {
 	lbl := models.Label{}
 
-	err := Store.Labels().Save(lbl)
+	err := reqStore(r).Labels().Save(lbl)
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		w.Write(apiError(err.Error()))
 		return
 	}
 
@@ -112,10 +97,10 @@ func UpdateLabel(w http.ResponseWriter, r *http.Request) {
 func DeleteLabel(w http.ResponseWriter, r *http.Request) {
 	lbl := models.Label{}
 
-	err := Store.Labels().Remove(lbl)
+	err := reqStore(r).Labels().Remove(lbl)
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write([]byte(err.Error()))
+		w.Write(apiError(err.Error()))
 		return
 	}
 
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/openapi"
+)
+
+// Docs describes the routes of the api, it's served as an OpenAPI document
+// by GetOpenAPI. Routes are added to it by document as they are registered.
+var Docs *openapi.Spec
+
+func newDocs() *openapi.Spec {
+	s := openapi.New("Praelatus", "1.0.0")
+	s.Error = errorBody{}
+	return s
+}
+
+func initDocRoutes() {
+	Router.Handle("/openapi.json", mw.Default(GetOpenAPI)).Methods("GET")
+}
+
+// document adds the route to Docs, request and response are values of the
+// body types and are nil if the route has no body.
+func document(method, path, summary string, request, response interface{}) {
+	Docs.Register(method, path, summary, request, response)
+}
+
+// GetOpenAPI will return the OpenAPI document describing the api
+func GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, Docs)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/praelatus/backend/openapi"
+)
+
+func TestGetOpenAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/openapi.json", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var doc openapi.Document
+
+	e := json.Unmarshal(w.Body.Bytes(), &doc)
+	if e != nil {
+		t.Fatalf("Failed with error %s\n", e.Error())
+	}
+
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("Expected %s Got %s\n", openapi.Version, doc.OpenAPI)
+	}
+
+	login, ok := doc.Paths["/sessions"]["post"]
+	if !ok {
+		t.Fatalf("Expected POST /sessions Got %v\n", doc.Paths["/sessions"])
+	}
+
+	if login.RequestBody == nil {
+		t.Errorf("Expected POST /sessions to have a request body\n")
+	}
+
+	res := login.Responses["200"].Content["application/json"].Schema
+	if res == nil || res.Ref != "#/components/schemas/TokenResponse" {
+		t.Errorf("Expected a TokenResponse Got %v\n", res)
+	}
+
+	if _, ok := doc.Components.Schemas["User"]; !ok {
+		t.Errorf("Expected the User schema Got %v\n", doc.Components.Schemas)
+	}
+}
@@ -1,21 +1,76 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/praelatus/backend/models"
 	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
 )
 
 func initProjectRoutes() {
-	Router.Handle("/projects", mw.Default(GetAllProjects)).Methods("GET")
+	Router.Handle("/projects", mw.Default(mw.RequireAuth(GetAllProjects))).Methods("GET")
 	Router.Handle("/projects", mw.Default(CreateProject)).Methods("POST")
 	Router.Handle("/projects/{pkey}", mw.Default(GetProject)).Methods("GET")
 	Router.Handle("/projects/{pkey}", mw.Default(RemoveProject)).Methods("DELETE")
 	Router.Handle("/projects/{pkey}", mw.Default(UpdateProject)).Methods("PUT")
+	Router.Handle("/projects/{pkey}/archive", mw.Default(ArchiveProject)).Methods("POST")
+	Router.Handle("/projects/{pkey}/unarchive", mw.Default(UnarchiveProject)).Methods("POST")
+	Router.Handle("/projects/{pkey}/stats", mw.Default(GetProjectStats)).Methods("GET")
+	Router.Handle("/projects/{pkey}/recent", mw.Default(GetRecentTickets)).Methods("GET")
+	Router.Handle("/projects/{pkey}/export.csv", mw.Default(ExportProjectCSV)).Methods("GET")
+	Router.Handle("/projects/{pkey}/import", mw.Default(mw.RequireAuth(ImportTickets))).Methods("POST")
+	Router.Handle("/projects/{pkey}/tickets/bulk", mw.Default(mw.RequireAuth(BulkCreateTickets))).Methods("POST")
+	Router.Handle("/projects/{pkey}/tickets/transition", mw.Default(mw.RequireAuth(TransitionTickets))).Methods("POST")
+	Router.Handle("/projects/{pkey}/members",
+		mw.Default(mw.RequireProjectRole(models.UserR)(GetProjectMembers))).Methods("GET")
+	Router.Handle("/projects/{pkey}/members",
+		mw.Default(mw.RequireProjectRole(models.AdminR)(AddProjectMember))).Methods("POST")
+	Router.Handle("/projects/{pkey}/members/{username}",
+		mw.Default(mw.RequireProjectRole(models.AdminR)(RemoveProjectMember))).Methods("DELETE")
+	Router.Handle("/projects/{pkey}/suggest-assignee",
+		mw.Default(mw.RequireProjectRole(models.UserR)(SuggestAssignee))).Methods("GET")
+
+	mw.ProjectRole = projectRole
+	mw.UserRoles = userRoles
+}
+
+// userRoles looks up the roles claim of the user for mw.JWTSignUser
+func userRoles(ctx context.Context, u models.User) (map[string]models.PermissionLevel, error) {
+	return Store.WithContext(ctx).Projects().GetRoles(u)
+}
+
+// projectRole looks up the role of the user in the project for
+// mw.RequireProjectRole, the lead of a project is always an admin of it.
+func projectRole(ctx context.Context, pkey string, u models.User) (models.PermissionLevel, error) {
+	s := Store.WithContext(ctx)
+
+	p := models.Project{Key: pkey}
+	err := s.Projects().Get(&p)
+	if err == store.ErrNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if p.Lead.ID == u.ID {
+		return models.AdminR, nil
+	}
+
+	role, err := s.Projects().GetRole(p, u)
+	if err == store.ErrNotFound {
+		return "", nil
+	}
+
+	return role, err
 }
 
 // GetProject will get a project by it's project key
@@ -23,36 +78,124 @@ func GetProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	p := models.Project{
-		Key: vars["key"],
+		Key: vars["pkey"],
 	}
 
-	err := Store.Projects().Get(&p)
+	err := reqStore(r).Projects().Get(&p)
 	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
 	sendJSON(w, p)
 }
 
+// GetProjectStats will return the number of tickets in each status for the
+// project indicated by the url
+func GetProjectStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	p := models.Project{Key: vars["pkey"]}
+
+	err := reqStore(r).Projects().Get(&p)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	counts, err := reqStore(r).Tickets().CountByStatus(p)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, counts)
+}
+
+// defaultRecentLimit is how many tickets GetRecentTickets returns when no
+// limit is given
+const defaultRecentLimit = 20
+
+// GetRecentTickets will return the most recently updated tickets in the
+// project indicated by the url, up to the limit query parameter.
+func GetRecentTickets(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRecentLimit
+
+	if l := r.FormValue("limit"); l != "" {
+		var err error
+
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidRequest,
+				"limit must be a positive number", "limit").JSON())
+			return
+		}
+	}
+
+	p := models.Project{Key: mux.Vars(r)["pkey"]}
+
+	err := reqStore(r).Projects().Get(&p)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	tks, err := reqStore(r).Tickets().GetRecentlyUpdated(p, limit)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, tks)
+}
+
 // GetAllProjects will get all the projects on this instance that the user has
-// permissions to
+// permissions to, archived projects are only included when the archived query
+// parameter is true.
 // TODO handle permissions
 func GetAllProjects(w http.ResponseWriter, r *http.Request) {
-	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to view all projects"))
-		return
+	var projects []models.Project
+	var err error
+
+	if r.FormValue("archived") == "true" {
+		projects, err = reqStore(r).Projects().GetAllIncludingArchived()
+	} else {
+		projects, err = reqStore(r).Projects().GetAll()
 	}
 
-	projects, err := Store.Projects().GetAll()
 	if err != nil {
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
@@ -76,15 +219,25 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write(apiError("invalid body"))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	err = Store.Projects().New(&p)
+	// Ticket keys are looked up in upper case so the project key they are
+	// generated from must be too.
+	p.Key = strings.ToUpper(p.Key)
+
+	err = reqStore(r).Projects().New(&p)
 	if err != nil {
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			w.WriteHeader(409)
+			w.Write(apiError("a project with that key already exists", "key"))
+			return
+		}
+
 		w.WriteHeader(400)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
@@ -92,38 +245,251 @@ func CreateProject(w http.ResponseWriter, r *http.Request) {
 }
 
 // RemoveProject will remove the project indicated by the key passed in as a
-// url parameter
+// url parameter, projects with tickets are only removed when the cascade query
+// parameter is true.
 func RemoveProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	u := mw.GetUser(r.Context())
 	if u == nil || !u.IsAdmin {
 		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in as a system administrator to create a project"))
+		w.Write(apiError("you must be logged in as a system administrator to remove a project"))
+		return
+	}
+
+	cascade := r.FormValue("cascade") == "true"
+
+	holders, err := roleHolders(reqStore(r), models.Project{Key: vars["pkey"]})
+	if err == nil {
+		err = reqStore(r).Projects().Remove(models.Project{Key: vars["pkey"]}, cascade)
+	}
+
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		if err == store.ErrProjectHasTickets {
+			w.WriteHeader(409)
+			w.Write(apiError("project has tickets, set cascade=true to remove them"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	// A project created with the key later mustn't be granted by the roles
+	// claims of this one's members.
+	for _, h := range holders {
+		mw.RolesChanged(h)
+	}
+
+	w.Write([]byte{})
+}
+
+// roleHolders returns the lead and members of the project, the users whose
+// roles change when the project is removed or it's key changes.
+func roleHolders(s store.Store, p models.Project) ([]models.User, error) {
+	err := s.Projects().Get(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.Projects().GetMembers(p)
+	if err != nil {
+		return nil, err
+	}
+
+	holders := []models.User{p.Lead}
+	for _, m := range members {
+		holders = append(holders, m.User)
+	}
+
+	return holders, nil
+}
+
+// ArchiveProject will archive the project indicated by the url, archived
+// projects are hidden from the list of projects and can't have tickets
+// created in them. It can only be used by sys admins.
+func ArchiveProject(w http.ResponseWriter, r *http.Request) {
+	setProjectArchived(w, r, true)
+}
+
+// UnarchiveProject will reverse ArchiveProject, it can only be used by sys
+// admins.
+func UnarchiveProject(w http.ResponseWriter, r *http.Request) {
+	setProjectArchived(w, r, false)
+}
+
+func setProjectArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	if !sysAdmin(w, r, "archive projects") {
+		return
+	}
+
+	p := models.Project{Key: mux.Vars(r)["pkey"]}
+	projects := reqStore(r).Projects()
+
+	var err error
+	if archived {
+		err = projects.ArchiveProject(p)
+	} else {
+		err = projects.UnarchiveProject(p)
+	}
+
+	if err == nil {
+		err = projects.Get(&p)
+	}
+
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, p)
+}
+
+// GetProjectMembers will return the members of the project indicated by the
+// url along with their roles
+func GetProjectMembers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	members, err := reqStore(r).Projects().GetMembers(models.Project{Key: vars["pkey"]})
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, members)
+}
+
+// SuggestAssignee will return the member of the project indicated by the url
+// with the fewest open tickets assigned to them
+func SuggestAssignee(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	u, err := reqStore(r).Projects().SuggestAssignee(models.Project{Key: vars["pkey"]})
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		if err == store.ErrNoMembers {
+			w.WriteHeader(409)
+			w.Write(apiError("project has no members to suggest"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
 		return
 	}
 
-	err := Store.Projects().Remove(models.Project{Key: vars["key"]})
+	sendJSON(w, u)
+}
+
+// AddProjectMember will add the user named in the body to the project
+// indicated by the url, if they are already a member their role is changed
+func AddProjectMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var body struct {
+		Username string                 `json:"username"`
+		Role     models.PermissionLevel `json:"role"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
 	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	if !body.Role.Valid() {
+		w.WriteHeader(400)
+		w.Write(apiError("role must be one of ADMIN, CORE or USER", "role"))
+		return
+	}
+
+	u := models.User{Username: body.Username}
+
+	err = reqStore(r).Users().Get(&u)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("user not found", "username"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
+	err = reqStore(r).Projects().AddMember(models.Project{Key: vars["pkey"]}, u, body.Role)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	mw.RolesChanged(u)
+
 	w.Write([]byte{})
+}
+
+// RemoveProjectMember will remove the user indicated by the url from the
+// members of the project
+func RemoveProjectMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	u := models.User{Username: vars["username"]}
+
+	err := reqStore(r).Projects().RemoveMember(models.Project{Key: vars["pkey"]}, u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	mw.RolesChanged(u)
 
+	w.Write([]byte{})
 }
 
 // UpdateProject will update a project based on the JSON representation sent to
 // the API
 func UpdateProject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
 	var p models.Project
 
 	u := mw.GetUser(r.Context())
 	if u == nil || !u.IsAdmin {
 		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in as a system administrator to create a project"))
+		w.Write(apiError("you must be logged in as a system administrator to update a project"))
 		return
 	}
 
@@ -132,17 +498,188 @@ func UpdateProject(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write(apiError("invalid body"))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	err = Store.Projects().New(&p)
+	// The project being updated is the one in the url, not whichever project
+	// the ID in the body belongs to.
+	existing := models.Project{Key: vars["pkey"]}
+	err = reqStore(r).Projects().Get(&existing)
 	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	p.ID = existing.ID
+
+	// The roles claims of the project's users are keyed by it's old key.
+	var holders []models.User
+	if p.Key != existing.Key {
+		holders, err = roleHolders(reqStore(r), existing)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write(apiError(err.Error()))
+			logError(r, err)
+			return
+		}
+	} else if p.Lead.ID != existing.Lead.ID {
+		// The old lead is no longer an admin of the project.
+		holders = []models.User{existing.Lead}
+	}
+
+	err = reqStore(r).Projects().Save(p)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			w.WriteHeader(409)
+			w.Write(apiError("a project with that key already exists", "key"))
+			return
+		}
+
 		w.WriteHeader(400)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
+	for _, h := range holders {
+		mw.RolesChanged(h)
+	}
+
 	sendJSON(w, p)
 }
+
+// BulkCreateTickets will create all of the tickets in the json array body in
+// the project indicated by the url, if any ticket can't be created none of
+// them are.
+func BulkCreateTickets(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var tickets []*models.Ticket
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&tickets)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	err = reqStore(r).Tickets().NewBatch(models.Project{Key: vars["pkey"]}, tickets)
+	if err != nil {
+		if err == store.ErrProjectArchived {
+			w.WriteHeader(409)
+			w.Write(apiError("project is archived"))
+			return
+		}
+
+		w.WriteHeader(400)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, tickets)
+}
+
+// BulkTransition is the body of a request to move many tickets to Status
+type BulkTransition struct {
+	Keys   []string      `json:"keys"`
+	Status models.Status `json:"status"`
+}
+
+// TransitionTickets will move all of the tickets in the body to it's status,
+// if the workflow doesn't allow moving any one of them none of them are moved.
+func TransitionTickets(w http.ResponseWriter, r *http.Request) {
+	u := mw.GetUser(r.Context())
+
+	var bt BulkTransition
+
+	err := json.NewDecoder(r.Body).Decode(&bt)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	if len(bt.Keys) == 0 {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, "keys is required", "keys").JSON())
+		return
+	}
+
+	s := reqStore(r)
+
+	p := models.Project{Key: mux.Vars(r)["pkey"]}
+
+	err = s.Projects().Get(&p)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	tickets := make([]models.Ticket, len(bt.Keys))
+
+	for i, k := range bt.Keys {
+		key, err := models.NormalizeKey(k)
+		if err != nil || !keyInProject(key, p) {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidRequest,
+				k+" is not a ticket in "+p.Key, "keys").JSON())
+			return
+		}
+
+		tickets[i] = models.Ticket{Key: key, UpdatedBy: *u}
+		bt.Keys[i] = key
+	}
+
+	err = s.Tickets().TransitionBatch(tickets, bt.Status)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidTransition:
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidRequest, err.Error(), "status").JSON())
+		case store.ErrNotFound:
+			w.WriteHeader(404)
+			w.Write(apiError("ticket not found", "keys"))
+		default:
+			w.WriteHeader(500)
+			w.Write(apiError(err.Error()))
+			logError(r, err)
+		}
+
+		return
+	}
+
+	sendJSON(w, bt)
+}
+
+// keyInProject reports whether the normalized ticket key was generated from
+// the project's key.
+func keyInProject(key string, p models.Project) bool {
+	n := strings.TrimPrefix(key, strings.ToUpper(p.Key))
+	if len(n) == len(key) {
+		return false
+	}
+
+	n = strings.TrimPrefix(n, "-")
+	return n != "" && strings.Trim(n, "0123456789") == ""
+}
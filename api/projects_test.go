@@ -2,13 +2,98 @@ package api
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/praelatus/backend/models"
 )
 
+func TestProjectMembers(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		login  func(*http.Request)
+		code   int
+	}{
+		{"list as member", "GET", "", testLogin, 200},
+		{"list anonymous", "GET", "", func(*http.Request) {}, 403},
+		{"add as core member", "POST", `{"username":"foouser","role":"ADMIN"}`, testLogin, 403},
+		{"add as admin", "POST", `{"username":"foouser","role":"ADMIN"}`, testAdminLogin, 200},
+		{"add invalid role", "POST", `{"username":"foouser","role":"OWNER"}`, testAdminLogin, 400},
+		{"add missing user", "POST", `{"username":"nouser","role":"USER"}`, testAdminLogin, 404},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, "/projects/TEST/members",
+			bytes.NewBufferString(test.body))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/projects/TEST/members/foouser", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 403 {
+		t.Errorf("Expected 403 removing a member as a core member Got %d\n", w.Code)
+	}
+}
+
+func TestSuggestAssignee(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/projects/TEST/suggest-assignee", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var u models.User
+
+	e := json.Unmarshal(w.Body.Bytes(), &u)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if w.Code != 200 || u.Username != "foouser" {
+		t.Errorf("Expected 200 suggesting foouser Got %d %v\n", w.Code, u)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/projects/TEST/suggest-assignee", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 403 {
+		t.Errorf("Expected 403 for an anonymous user Got %d\n", w.Code)
+	}
+
+	for key, code := range map[string]int{"NOPE": 404, "EMPTY": 409} {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest("GET", "/projects/"+key+"/suggest-assignee", nil)
+		testAdminLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("Expected %d for %s Got %d\n", code, key, w.Code)
+		}
+	}
+}
+
 func TestGetProject(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/projects/TEST", nil)
@@ -29,6 +114,88 @@ func TestGetProject(t *testing.T) {
 	t.Log(w.Body)
 }
 
+func TestGetProjectStats(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/projects/TEST/stats", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var counts map[string]int
+
+	e := json.Unmarshal(w.Body.Bytes(), &counts)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(counts) != 3 || counts["Open"] != 2 || counts["Closed"] != 0 {
+		t.Errorf("Expected counts for 3 statuses Got %v\n", counts)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetRecentTickets(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/projects/TEST/recent?limit=1", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var tks []models.Ticket
+
+	e := json.Unmarshal(w.Body.Bytes(), &tks)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(tks) != 1 {
+		t.Errorf("Expected 1 ticket Got %d\n", len(tks))
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/projects/TEST/recent?limit=none", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d\n", w.Code)
+	}
+}
+
+func TestExportProjectCSV(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/projects/TEST/export.csv", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200 Got %d\n", w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Expected text/csv Got %s\n", ct)
+	}
+
+	if !w.Flushed {
+		t.Errorf("Expected the export to be flushed as it's written\n")
+	}
+
+	rows, e := csv.NewReader(w.Body).ReadAll()
+	if e != nil {
+		t.Fatalf("Failed with error %s\n", e.Error())
+	}
+
+	header := "key,summary,status,type,assignee,reporter,created_date,updated_date"
+	if len(rows) < 2 || strings.Join(rows[0], ",") != header {
+		t.Fatalf("Expected the header row and tickets Got %v\n", rows)
+	}
+
+	first := "TEST-1,A mock issue,In Progress,Bug,baruser,foouser," +
+		"2016-12-25T00:00:00Z,2016-12-25T00:00:00Z"
+	if strings.Join(rows[1], ",") != first {
+		t.Errorf("Expected %s Got %v\n", first, rows[1])
+	}
+}
+
 func TestGetAllProjects(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/projects", nil)
@@ -54,6 +221,55 @@ func TestGetAllProjects(t *testing.T) {
 	t.Log(w.Body)
 }
 
+func TestGetAllProjectsArchived(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/projects?archived=true", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var p []models.Project
+
+	e := json.Unmarshal(w.Body.Bytes(), &p)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(p) != 3 || !p[2].Archived {
+		t.Errorf("Expected 3 projects with OLD archived Got %v\n", p)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestArchiveProject(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		login func(*http.Request)
+		code  int
+	}{
+		{"archive as core member", "/projects/TEST/archive", testLogin, 403},
+		{"archive", "/projects/TEST/archive", testAdminLogin, 200},
+		{"archive missing", "/projects/MISSING/archive", testAdminLogin, 404},
+		{"unarchive", "/projects/TEST/unarchive", testAdminLogin, 200},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", test.path, nil)
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
 func TestCreateProject(t *testing.T) {
 	p := models.Project{Name: "Grumpy Cat", Key: "NOPE"}
 	byt, _ := json.Marshal(p)
@@ -76,3 +292,188 @@ func TestCreateProject(t *testing.T) {
 
 	t.Log(w.Body)
 }
+
+func TestUpdateProject(t *testing.T) {
+	p := models.Project{ID: 5, Name: "Renamed Project", Key: "TEST"}
+	byt, _ := json.Marshal(p)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/projects/TEST", bytes.NewReader(byt))
+	testAdminLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	e := json.Unmarshal(w.Body.Bytes(), &p)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if p.ID != 1 || p.Name != "Renamed Project" {
+		t.Errorf("Expected the renamed project 1 Got %v", p)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestRemoveProject(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/projects/TEST", nil)
+	testAdminLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 409 {
+		t.Errorf("Expected 409 Got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/projects/TEST?cascade=true", nil)
+	testAdminLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+// importBody has a valid ticket, referencing it's status and type by name and
+// ID, followed by one with a status that doesn't exist.
+var importBody = `[
+	{"summary": "Imported", "status": {"name": "mock Status"}, "ticket_type": {"id": 2}},
+	{"summary": "Bad status", "status": {"name": "Nope"}, "ticket_type": {"id": 1}}
+]`
+
+func TestImportTicketsStrict(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/projects/TEST/import",
+		strings.NewReader(importBody))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d\n", w.Code)
+	}
+
+	var res ImportResult
+
+	e := json.Unmarshal(w.Body.Bytes(), &res)
+	if e != nil {
+		t.Fatalf("Failed with error %s\n", e.Error())
+	}
+
+	if len(res.Keys) != 0 || len(res.Errors) != 1 || res.Errors[0].Row != 1 ||
+		res.Errors[0].Message != errUnknownStatus.Error() {
+		t.Errorf("Expected only the unknown status error for row 1 Got %v\n", res)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestImportTicketsBestEffort(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/projects/TEST/import?mode=best_effort",
+		strings.NewReader(importBody))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d\n", w.Code)
+	}
+
+	var res ImportResult
+
+	e := json.Unmarshal(w.Body.Bytes(), &res)
+	if e != nil {
+		t.Fatalf("Failed with error %s\n", e.Error())
+	}
+
+	if len(res.Keys) != 1 || res.Keys[0] != "TEST1" {
+		t.Errorf("Expected the first ticket to be created as TEST1 Got %v\n", res.Keys)
+	}
+
+	if len(res.Errors) != 1 || res.Errors[0].Row != 1 {
+		t.Errorf("Expected an error for row 1 Got %v\n", res.Errors)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestImportTicketsReporter(t *testing.T) {
+	body := `[{"summary": "Imported", "status": {"id": 1}, "ticket_type": {"id": 1},
+			   "reporter": {"id": 2, "username": "baruser"}}]`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/projects/TEST/import", strings.NewReader(body))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d\n", w.Code)
+	}
+
+	if len(savedBatch) != 1 || savedBatch[0].Reporter.Username != "foouser" {
+		t.Errorf("Expected the ticket to be reported by foouser Got %v\n", savedBatch)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestBulkCreateTickets(t *testing.T) {
+	tickets := []models.Ticket{
+		models.Ticket{Summary: "First imported ticket"},
+		models.Ticket{Summary: "Second imported ticket"},
+	}
+	byt, _ := json.Marshal(tickets)
+	rd := bytes.NewReader(byt)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/projects/TEST/tickets/bulk", rd)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	e := json.Unmarshal(w.Body.Bytes(), &tickets)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if len(tickets) != 2 || tickets[1].Key != "TEST2" {
+		t.Errorf("Expected 2 tickets ending with TEST2 Got %v", tickets)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestTransitionTickets(t *testing.T) {
+	tests := []struct {
+		keys   []string
+		status models.Status
+		code   int
+	}{
+		{[]string{"TEST-1", "test-2"}, models.Status{Name: "Done"}, 200},
+		{[]string{"TEST-1"}, models.Status{Name: "Backlog"}, 400},
+		{[]string{"TEST-1", "OTHER-2"}, models.Status{Name: "Done"}, 400},
+		{nil, models.Status{Name: "Done"}, 400},
+	}
+
+	for _, test := range tests {
+		byt, _ := json.Marshal(BulkTransition{Keys: test.keys, Status: test.status})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/projects/TEST/tickets/transition",
+			bytes.NewReader(byt))
+		testLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("Expected %d for %v Got %d", test.code, test.keys, w.Code)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
+)
+
+func initStatusRoutes() {
+	Router.Handle("/statuses", mw.Default(GetAllStatuses)).Methods("GET")
+	Router.Handle("/statuses", mw.Default(CreateStatus)).Methods("POST")
+	Router.Handle("/statuses/reorder", mw.Default(ReorderStatuses)).Methods("POST")
+	Router.Handle("/statuses/{id}", mw.Default(UpdateStatus)).Methods("PUT")
+	Router.Handle("/statuses/{id}", mw.Default(RemoveStatus)).Methods("DELETE")
+}
+
+// GetAllStatuses will return the json encoded array of all statuses in the
+// order they appear as columns on a board
+func GetAllStatuses(w http.ResponseWriter, r *http.Request) {
+	statuses, err := reqStore(r).Statuses().GetAllOrdered()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, statuses)
+}
+
+// decodeStatus will decode the status in the request body, writing the error
+// response and returning false if it's invalid
+func decodeStatus(w http.ResponseWriter, r *http.Request) (models.Status, bool) {
+	var s models.Status
+
+	err := json.NewDecoder(r.Body).Decode(&s)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return s, false
+	}
+
+	if s.Name == "" {
+		w.WriteHeader(400)
+		w.Write(apiError("name is required", "name"))
+		return s, false
+	}
+
+	return s, true
+}
+
+// CreateStatus will create the status in the request body after every other
+// status, it can only be used by sys admins
+func CreateStatus(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change statuses") {
+		return
+	}
+
+	s, ok := decodeStatus(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Statuses().New(&s)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, s)
+}
+
+// UpdateStatus will update the status indicated by the id, it's position is
+// only changed by ReorderStatuses. It can only be used by sys admins.
+func UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change statuses") {
+		return
+	}
+
+	id, ok := idVar(w, r)
+	if !ok {
+		return
+	}
+
+	s, ok := decodeStatus(w, r)
+	if !ok {
+		return
+	}
+
+	s.ID = id
+
+	err := reqStore(r).Statuses().Save(s)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, s)
+}
+
+// RemoveStatus will remove the status indicated by the id, statuses which
+// are used by tickets or transitions are not removed. It can only be used by
+// sys admins.
+func RemoveStatus(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change statuses") {
+		return
+	}
+
+	id, ok := idVar(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Statuses().Remove(models.Status{ID: id})
+	if err != nil {
+		if err == store.ErrInUse {
+			w.WriteHeader(409)
+			w.Write(apiError("status is in use"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// ReorderStatuses will put the statuses in the order of the json array of
+// ids in the body and respond with the reordered statuses, any statuses left
+// out keep their order after them. It can only be used by sys admins.
+func ReorderStatuses(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change statuses") {
+		return
+	}
+
+	var ids []int64
+
+	err := json.NewDecoder(r.Body).Decode(&ids)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	err = reqStore(r).Statuses().Reorder(ids)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("status not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	GetAllStatuses(w, r)
+}
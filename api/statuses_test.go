@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/praelatus/backend/models"
+)
+
+func TestGetAllStatuses(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/statuses", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var statuses []models.Status
+
+	e := json.Unmarshal(w.Body.Bytes(), &statuses)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(statuses) != 2 || statuses[0].Name != "Fake Status" {
+		t.Errorf("Expected Fake Status first Got %v\n", statuses)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestChangeStatuses(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		login  func(*http.Request)
+		code   int
+	}{
+		{"create as core member", "POST", "/statuses", `{"name":"Review"}`, testLogin, 403},
+		{"create", "POST", "/statuses", `{"name":"Review"}`, testAdminLogin, 200},
+		{"create without name", "POST", "/statuses", `{}`, testAdminLogin, 400},
+		{"update", "PUT", "/statuses/2", `{"name":"Testing"}`, testAdminLogin, 200},
+		{"update bad id", "PUT", "/statuses/two", `{"name":"Testing"}`, testAdminLogin, 400},
+		{"reorder as core member", "POST", "/statuses/reorder", `[2,1]`, testLogin, 403},
+		{"reorder", "POST", "/statuses/reorder", `[2,1]`, testAdminLogin, 200},
+		{"reorder invalid body", "POST", "/statuses/reorder", `{}`, testAdminLogin, 400},
+		{"reorder missing", "POST", "/statuses/reorder", `[3,1]`, testAdminLogin, 404},
+		{"remove in use", "DELETE", "/statuses/1", "", testAdminLogin, 409},
+		{"remove", "DELETE", "/statuses/2", "", testAdminLogin, 200},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path,
+			bytes.NewBufferString(test.body))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
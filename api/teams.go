@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
+)
+
+func initTeamRoutes() {
+	Router.Handle("/teams", mw.Default(mw.RequireAuth(GetAllTeams))).Methods("GET")
+	Router.Handle("/teams", mw.Default(mw.RequireAuth(CreateTeam))).Methods("POST")
+	Router.Handle("/teams/{slug}", mw.Default(GetTeam)).Methods("GET")
+	Router.Handle("/teams/{slug}", mw.Default(UpdateTeam)).Methods("PUT")
+	Router.Handle("/teams/{slug}", mw.Default(RemoveTeam)).Methods("DELETE")
+	Router.Handle("/teams/{slug}/members", mw.Default(GetTeamMembers)).Methods("GET")
+	Router.Handle("/teams/{slug}/members", mw.Default(AddTeamMember)).Methods("POST")
+	Router.Handle("/teams/{slug}/members", mw.Default(RemoveTeamMember)).Methods("DELETE")
+}
+
+// targetTeam will get the team named by the slug route variable, writing the
+// error response and returning false if it can't be found
+func targetTeam(w http.ResponseWriter, r *http.Request) (models.Team, bool) {
+	t := models.Team{URLSlug: mux.Vars(r)["slug"]}
+
+	err := reqStore(r).Teams().Get(&t)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("No team exists with that url slug."))
+			return t, false
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return t, false
+	}
+
+	return t, true
+}
+
+// canModifyTeam reports whether the user u is allowed to change or remove
+// the team t
+func canModifyTeam(u *models.User, t models.Team) bool {
+	return u != nil && (u.IsAdmin || u.ID == t.Lead.ID)
+}
+
+// GetTeam will get a team by it's url slug
+func GetTeam(w http.ResponseWriter, r *http.Request) {
+	t, ok := targetTeam(w, r)
+	if !ok {
+		return
+	}
+
+	sendJSON(w, t)
+}
+
+// GetAllTeams will return the json encoded array of all teams in the store
+func GetAllTeams(w http.ResponseWriter, r *http.Request) {
+	teams, err := reqStore(r).Teams().GetAll()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, teams)
+}
+
+// CreateTeam will create a team based on the JSON representation sent to the
+// API, if no lead is given the current user will lead the team
+func CreateTeam(w http.ResponseWriter, r *http.Request) {
+	u := mw.GetUser(r.Context())
+
+	var t models.Team
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&t)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	if t.Lead.ID == 0 {
+		t.Lead = *u
+	}
+
+	err = reqStore(r).Teams().New(&t)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			w.WriteHeader(400)
+			w.Write(apiError("a team with that url slug already exists", "url_slug"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, t)
+}
+
+// UpdateTeam will update the team indicated by the url slug, it can only be
+// used by the team lead or sys admins
+func UpdateTeam(w http.ResponseWriter, r *http.Request) {
+	old, ok := targetTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if !canModifyTeam(mw.GetUser(r.Context()), old) {
+		w.WriteHeader(403)
+		w.Write(apiError("you do not have permission to update this team"))
+		return
+	}
+
+	var t models.Team
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&t)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	t.ID = old.ID
+	if t.Lead.ID == 0 {
+		t.Lead = old.Lead
+	}
+
+	err = reqStore(r).Teams().Save(t)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			w.WriteHeader(400)
+			w.Write(apiError("a team with that url slug already exists", "url_slug"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, t)
+}
+
+// RemoveTeam will remove the team indicated by the url slug, it can only be
+// used by the team lead or sys admins
+func RemoveTeam(w http.ResponseWriter, r *http.Request) {
+	t, ok := targetTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if !canModifyTeam(mw.GetUser(r.Context()), t) {
+		w.WriteHeader(403)
+		w.Write(apiError("you do not have permission to remove this team"))
+		return
+	}
+
+	err := reqStore(r).Teams().Remove(t)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// GetTeamMembers will return the json encoded array of members of the team
+// indicated by the url slug
+func GetTeamMembers(w http.ResponseWriter, r *http.Request) {
+	t, ok := targetTeam(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := reqStore(r).Teams().GetMembers(t)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, members)
+}
+
+// memberRequest will decode the user sent in the body of a membership
+// request and look them up in the store, writing the error response and
+// returning false if that fails
+func memberRequest(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	var u models.User
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&u)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return u, false
+	}
+
+	err = reqStore(r).Users().Get(&u)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("No user exists with that username."))
+			return u, false
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return u, false
+	}
+
+	u.Password = ""
+	return u, true
+}
+
+// AddTeamMember will add the user in the request body to the team indicated
+// by the url slug, it can only be used by the team lead or sys admins
+func AddTeamMember(w http.ResponseWriter, r *http.Request) {
+	t, ok := targetTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if !canModifyTeam(mw.GetUser(r.Context()), t) {
+		w.WriteHeader(403)
+		w.Write(apiError("you do not have permission to change the members of this team"))
+		return
+	}
+
+	u, ok := memberRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Teams().AddMember(t, u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, u)
+}
+
+// RemoveTeamMember will remove the user in the request body from the team
+// indicated by the url slug, it can only be used by the team lead or sys
+// admins and the team lead can not be removed
+func RemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	t, ok := targetTeam(w, r)
+	if !ok {
+		return
+	}
+
+	if !canModifyTeam(mw.GetUser(r.Context()), t) {
+		w.WriteHeader(403)
+		w.Write(apiError("you do not have permission to change the members of this team"))
+		return
+	}
+
+	u, ok := memberRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Teams().RemoveMember(t, u)
+	if err != nil {
+		if err == store.ErrRemoveLead {
+			w.WriteHeader(400)
+			w.Write(apiError(err.Error()))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
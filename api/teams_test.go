@@ -0,0 +1,225 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+)
+
+func TestGetTeam(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/teams/a", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var team models.Team
+
+	e := json.Unmarshal(w.Body.Bytes(), &team)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if team.URLSlug != "a" {
+		t.Errorf("Expected a Got %s\n", team.URLSlug)
+	}
+
+	if team.Lead.Username != "foouser" {
+		t.Errorf("Expected foouser Got %s\n", team.Lead.Username)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetMissingTeam(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/teams/missing", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Errorf("Expected 404 Got %d\n", w.Code)
+	}
+}
+
+func TestGetAllTeams(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/teams", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var teams []models.Team
+
+	e := json.Unmarshal(w.Body.Bytes(), &teams)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(teams) == 0 {
+		t.Error("Expected to get more than 0 teams")
+	}
+
+	t.Log(w.Body)
+}
+
+func TestCreateTeam(t *testing.T) {
+	byt, _ := json.Marshal(models.Team{Name: "B"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/teams", bytes.NewBuffer(byt))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var team models.Team
+
+	e := json.Unmarshal(w.Body.Bytes(), &team)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if team.ID != 1 {
+		t.Errorf("Expected 1 Got %d\n", team.ID)
+	}
+
+	if team.Lead.Username != "foouser" {
+		t.Errorf("Expected the creator to lead the team Got %s\n", team.Lead.Username)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestCreateDuplicateTeam(t *testing.T) {
+	byt, _ := json.Marshal(models.Team{Name: "A"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/teams", bytes.NewBuffer(byt))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestUpdateTeamForbidden(t *testing.T) {
+	token, e := mw.JWTSignUser(models.User{ID: 2, Username: "baruser"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	byt, _ := json.Marshal(models.Team{Name: "C"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/teams/a", bytes.NewBuffer(byt))
+	r.Header.Add("Authorization", "Bearer "+token)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 403 {
+		t.Errorf("Expected 403 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestRemoveTeam(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/teams/a", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetTeamMembers(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/teams/a/members", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var members []models.User
+
+	e := json.Unmarshal(w.Body.Bytes(), &members)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(members) != 2 {
+		t.Errorf("Expected 2 members Got %d\n", len(members))
+	}
+
+	t.Log(w.Body)
+}
+
+func TestAddTeamMember(t *testing.T) {
+	byt, _ := json.Marshal(models.User{Username: "foouser"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/teams/a/members", bytes.NewBuffer(byt))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var u models.User
+
+	e := json.Unmarshal(w.Body.Bytes(), &u)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if u.Username != "foouser" || u.Password != "" {
+		t.Errorf("Expected foouser without a password Got %v\n", u)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestRemoveTeamLead(t *testing.T) {
+	byt, _ := json.Marshal(models.User{Username: "foouser"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/teams/a/members", bytes.NewBuffer(byt))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestAddTeamMemberForbidden(t *testing.T) {
+	token, e := mw.JWTSignUser(models.User{ID: 2, Username: "baruser"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	byt, _ := json.Marshal(models.User{Username: "baruser"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/teams/a/members", bytes.NewBuffer(byt))
+	r.Header.Add("Authorization", "Bearer "+token)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 403 {
+		t.Errorf("Expected 403 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
@@ -2,9 +2,11 @@ package api
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/praelatus/backend/models"
@@ -14,16 +16,47 @@ import (
 
 func initTicketRoutes() {
 	Router.Handle("/tickets", mw.Default(GetAllTickets)).Methods("GET")
-	Router.Handle("/tickets/{pkey}", mw.Default(CreateTicket)).Methods("POST")
+	Router.Handle("/tickets/{pkey}", mw.Default(mw.RequireAuth(CreateTicket))).Methods("POST")
 	Router.Handle("/tickets/{pkey}", mw.Default(GetAllTicketsByProject)).Methods("GET")
-	Router.Handle("/tickets/{pkey}/{key}", mw.Default(GetTicket)).Methods("GET")
-	Router.Handle("/tickets/{pkey}/{key}", mw.Default(RemoveTicket)).Methods("DELETE")
-	Router.Handle("/tickets/{pkey}/{key}", mw.Default(UpdateTicket)).Methods("PUT")
-	Router.Handle("/tickets/{pkey}/{key}/comments", mw.Default(GetComments)).Methods("GET")
-	Router.Handle("/tickets/{pkey}/{key}/comments", mw.Default(CreateComment)).Methods("POST")
-
-	Router.Handle("/comments/{id}", mw.Default(UpdateComment)).Methods("PUT")
-	Router.Handle("/comments/{id}", mw.Default(RemoveComment)).Methods("DELETE")
+	Router.Handle("/tickets/{pkey}/{key}", mw.Default(normalizedKey(GetTicket))).Methods("GET")
+	Router.Handle("/tickets/{pkey}/{key}", mw.Default(mw.RequireAuth(normalizedKey(RemoveTicket)))).Methods("DELETE")
+	Router.Handle("/tickets/{pkey}/{key}", mw.Default(mw.RequireAuth(normalizedKey(UpdateTicket)))).Methods("PUT")
+	Router.Handle("/tickets/{pkey}/{key}/comments", mw.Default(normalizedKey(GetComments))).Methods("GET")
+	Router.Handle("/tickets/{pkey}/{key}/comments", mw.Default(mw.RequireAuth(normalizedKey(CreateComment)))).Methods("POST")
+	Router.Handle("/tickets/{pkey}/{key}/children", mw.Default(normalizedKey(GetChildren))).Methods("GET")
+	Router.Handle("/tickets/{pkey}/{key}/watchers", mw.Default(normalizedKey(GetWatchers))).Methods("GET")
+	Router.Handle("/tickets/{pkey}/{key}/watchers", mw.Default(mw.RequireAuth(normalizedKey(AddWatcher)))).Methods("POST")
+	Router.Handle("/tickets/{pkey}/{key}/watchers", mw.Default(mw.RequireAuth(normalizedKey(RemoveWatcher)))).Methods("DELETE")
+	Router.Handle("/tickets/{pkey}/{key}/assign", mw.Default(mw.RequireAuth(normalizedKey(AssignTicket)))).Methods("POST")
+	Router.Handle("/tickets/{pkey}/{key}/worklogs", mw.Default(normalizedKey(GetWorklogs))).Methods("GET")
+	Router.Handle("/tickets/{pkey}/{key}/worklogs", mw.Default(mw.RequireAuth(normalizedKey(LogWork)))).Methods("POST")
+
+	Router.Handle("/comments/{id}", mw.Default(GetComment)).Methods("GET")
+	Router.Handle("/comments/{id}", mw.Default(mw.RequireAuth(UpdateComment))).Methods("PUT")
+	Router.Handle("/comments/{id}/history", mw.Default(GetCommentHistory)).Methods("GET")
+	Router.Handle("/comments/{id}/reactions", mw.Default(GetReactions)).Methods("GET")
+	Router.Handle("/comments/{id}/reactions", mw.Default(mw.RequireAuth(ToggleReaction))).Methods("POST")
+	Router.Handle("/comments/{id}/reactions", mw.Default(mw.RequireAuth(RemoveReaction))).Methods("DELETE")
+	Router.Handle("/comments/{id}", mw.Default(mw.RequireAuth(RemoveComment))).Methods("DELETE")
+}
+
+// normalizedKey rejects requests whose ticket key is malformed before they
+// reach the store and otherwise replaces the key with it's normalized form, so
+// tickets can be looked up regardless of case.
+func normalizedKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		key, err := models.NormalizeKey(vars["key"])
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidRequest, err.Error(), "key").JSON())
+			return
+		}
+
+		vars["key"] = key
+		next(w, r)
+	}
 }
 
 // GetTicket will get a ticket by the ticket key
@@ -40,7 +73,7 @@ func GetTicket(w http.ResponseWriter, r *http.Request) {
 		Key: vars["key"],
 	}
 
-	err := Store.Tickets().Get(tk)
+	err := reqStore(r).Tickets().Get(tk)
 	if err != nil {
 		if err == store.ErrNotFound {
 			w.WriteHeader(404)
@@ -50,51 +83,118 @@ func GetTicket(w http.ResponseWriter, r *http.Request) {
 
 		w.WriteHeader(500)
 		w.Write(apiError("failed to retrieve comments"))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
+	// Editing a comment doesn't change the ticket's updated date so only
+	// responses without the comments are given an ETag.
+	if !preload {
+		etag := ticketETag(*tk)
+		w.Header().Set("ETag", etag)
+
+		if etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
 	if preload {
-		cm, err := Store.Tickets().GetComments(*tk)
+		cm, err := reqStore(r).Tickets().GetComments(*tk)
 		if err != nil {
 			w.WriteHeader(500)
 			w.Write(apiError("failed to retrieve comments"))
-			log.Println(err)
+			logError(r, err)
 			return
 		}
 
 		tk.Comments = cm
 	}
 
-	sendJSON(w, tk)
+	sendJSONFiltered(w, r, tk)
+}
+
+// ticketETag returns the ETag for the ticket, it changes whenever the ticket
+// is updated.
+func ticketETag(t models.Ticket) string {
+	return fmt.Sprintf(`"%d-%d"`, t.ID, t.UpdatedDate.UnixNano())
 }
 
-// GetAllTickets will get all the tickets for this instance
+// etagMatches reports whether the If-None-Match header of the request matches
+// etag, weak validators are compared as if they were strong.
+func etagMatches(r *http.Request, etag string) bool {
+	inm := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if inm == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(inm, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetAllTickets will get all the tickets for this instance, the limit and
+// offset query parameters can be used to request a single page of tickets
 func GetAllTickets(w http.ResponseWriter, r *http.Request) {
-	tks, err := Store.Tickets().GetAll()
+	opts, err := pageOptions(r)
 	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid pagination parameters"))
+		return
+	}
+
+	tks, total, err := reqStore(r).Tickets().GetAllPaged(opts)
+	if err != nil {
+		if err == store.ErrInvalidOrderBy {
+			w.WriteHeader(400)
+			w.Write(apiError(err.Error(), "order_by"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError("failed to retrieve tickets from the database"))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	sendJSON(w, tks)
+	setTotalCount(w, total)
+	sendJSONFiltered(w, r, tks)
 }
 
-// GetAllTicketsByProject will get all the tickets for a given project
+// GetAllTicketsByProject will get all the tickets for a given project, the
+// limit and offset query parameters can be used to request a single page of
+// tickets
 func GetAllTicketsByProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	tks, err := Store.Tickets().GetAllByProject(models.Project{Key: vars["pkey"]})
+	opts, err := pageOptions(r)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid pagination parameters"))
+		return
+	}
+
+	tks, total, err := reqStore(r).Tickets().
+		GetAllByProjectPaged(models.Project{Key: vars["pkey"]}, opts)
 	if err != nil {
+		if err == store.ErrInvalidOrderBy {
+			w.WriteHeader(400)
+			w.Write(apiError(err.Error(), "order_by"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError("failed to retrieve tickets from the database"))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	sendJSON(w, tks)
+	setTotalCount(w, total)
+	sendJSONFiltered(w, r, tks)
 }
 
 // CreateTicket will create a ticket in the database and send the json
@@ -102,13 +202,6 @@ func GetAllTicketsByProject(w http.ResponseWriter, r *http.Request) {
 func CreateTicket(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to create a ticket"))
-		return
-	}
-
 	var tk models.Ticket
 
 	decoder := json.NewDecoder(r.Body)
@@ -116,37 +209,59 @@ func CreateTicket(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write(apiError("invalid body"))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	err = Store.Tickets().New(models.Project{Key: vars["pkey"]}, &tk)
+	err = reqStore(r).Tickets().New(models.Project{Key: vars["pkey"]}, &tk)
 	if err != nil {
+		if err == store.ErrProjectArchived {
+			w.WriteHeader(409)
+			w.Write(apiError("project is archived"))
+			return
+		}
+
+		var ref store.ReferenceError
+		if errors.As(err, &ref) {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidRequest, ref.Error(), ref.Field).JSON())
+			return
+		}
+
 		w.WriteHeader(400)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
+		return
+	}
+
+	// The ticket is read back so the response has the generated key and
+	// the full assignee, reporter, status and type.
+	created := models.Ticket{ID: tk.ID}
+	err = reqStore(r).Tickets().Get(&created)
+	if err != nil {
+		logError(r, err)
+		sendJSON(w, tk)
 		return
 	}
 
-	sendJSON(w, tk)
+	sendJSON(w, created)
 }
 
 // RemoveTicket will remove the ticket with the given key from the database
 func RemoveTicket(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to remove a ticket"))
-		return
-	}
-
-	err := Store.Tickets().Remove(models.Ticket{Key: vars["key"]})
+	err := reqStore(r).Tickets().Remove(models.Ticket{Key: vars["key"]})
 	if err != nil {
+		if err == store.ErrHasChildren {
+			w.WriteHeader(400)
+			w.Write(apiError("the sub tasks of the ticket must be moved or removed first"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
@@ -159,32 +274,83 @@ func UpdateTicket(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to update a ticket"))
+
+	tk := models.Ticket{Key: vars["key"]}
+
+	err := reqStore(r).Tickets().Get(&tk)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("ticket not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError("failed to retrieve ticket"))
+		logError(r, err)
 		return
 	}
 
-	var tk models.Ticket
+	// The body is decoded onto the stored ticket so anything which isn't sent,
+	// like the parent, is left alone. The version still has to be sent and
+	// only the field values which are sent are saved.
+	id, key := tk.ID, tk.Key
+	tk.Version = 0
+	tk.Fields = nil
 
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&tk)
+	err = decoder.Decode(&tk)
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	if tk.Key == "" {
-		tk.Key = vars["key"]
-	}
+	tk.ID, tk.Key = id, key
+	tk.UpdatedBy = *u
 
-	err = Store.Tickets().Save(tk)
+	err = reqStore(r).Tickets().Save(tk)
 	if err != nil {
+		if err == store.ErrStaleObject {
+			w.WriteHeader(409)
+			w.Write(apiError(err.Error(), "version"))
+			return
+		}
+
+		if fe, ok := err.(*models.FieldValueError); ok {
+			w.WriteHeader(400)
+			w.Write(apiError(fe.Error(), fe.Name))
+			return
+		}
+
+		if err == store.ErrInvalidDuration {
+			w.WriteHeader(400)
+			w.Write(apiError("estimates can not be negative"))
+			return
+		}
+
+		if err == store.ErrInvalidPriority {
+			w.WriteHeader(400)
+			w.Write(apiError(err.Error(), "priority"))
+			return
+		}
+
+		if err == store.ErrInvalidParent {
+			w.WriteHeader(400)
+			w.Write(apiError(err.Error(), "parent_id"))
+			return
+		}
+
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("ticket not found"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
@@ -196,14 +362,29 @@ func UpdateTicket(w http.ResponseWriter, r *http.Request) {
 func GetComments(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	comments, err := Store.Tickets().GetComments(models.Ticket{Key: vars["key"]})
+	opts, err := pageOptions(r)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid pagination parameters"))
+		return
+	}
+
+	comments, total, err := reqStore(r).Tickets().
+		GetCommentsPaged(models.Ticket{Key: vars["key"]}, opts)
 	if err != nil {
+		if err == store.ErrInvalidOrderBy {
+			w.WriteHeader(400)
+			w.Write(apiError(err.Error(), "order_by"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
+	setTotalCount(w, total)
 	sendJSON(w, comments)
 }
 
@@ -212,11 +393,6 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
 	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to update a ticket"))
-		return
-	}
 
 	var cm models.Comment
 
@@ -225,7 +401,7 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		w.WriteHeader(400)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
@@ -234,11 +410,19 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 		cm.ID = int64(id)
 	}
 
-	err = Store.Tickets().SaveComment(cm)
+	cm.UpdatedBy = *u
+
+	err = reqStore(r).Tickets().SaveComment(cm)
 	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("No comment exists with that id."))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
@@ -249,20 +433,13 @@ func UpdateComment(w http.ResponseWriter, r *http.Request) {
 func RemoveComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to update a ticket"))
-		return
-	}
-
 	id, _ := strconv.Atoi(vars["id"])
 
-	err := Store.Tickets().RemoveComment(models.Comment{ID: int64(id)})
+	err := reqStore(r).Tickets().RemoveComment(models.Comment{ID: int64(id)})
 	if err != nil {
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
@@ -273,31 +450,396 @@ func RemoveComment(w http.ResponseWriter, r *http.Request) {
 func CreateComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
+	var cm models.Comment
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&cm)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	cm.Author = *mw.GetUser(r.Context())
+
+	err = reqStore(r).Tickets().NewComment(models.Ticket{Key: vars["key"]}, &cm)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("ticket not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, cm)
+}
+
+// GetComment will return the comment indicated by the id in the url
+func GetComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid comment id"))
+		return
+	}
+
+	cm := models.Comment{ID: int64(id)}
+
+	err = reqStore(r).Tickets().GetComment(&cm)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("comment not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, cm)
+}
+
+// GetCommentHistory will return the previous revisions of the comment
+// indicated by the url
+func GetCommentHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid comment id"))
+		return
+	}
+
+	revisions, err := reqStore(r).Tickets().GetCommentHistory(models.Comment{ID: int64(id)})
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, revisions)
+}
+
+// GetReactions will return the number of users who reacted to the comment
+// indicated by the url with each emoji
+func GetReactions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid comment id"))
+		return
+	}
+
+	reactions, err := reqStore(r).Tickets().GetReactions(models.Comment{ID: int64(id)})
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, reactions)
+}
+
+// reactionRequest will parse the comment id from the url and the emoji from
+// the json body of a reaction request, writing the error response and
+// returning false if either is invalid
+func reactionRequest(w http.ResponseWriter, r *http.Request) (models.Comment, string, bool) {
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid comment id"))
+		return models.Comment{}, "", false
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&req)
+	if err != nil || req.Emoji == "" || len(req.Emoji) > 64 {
+		w.WriteHeader(400)
+		w.Write(apiError("an emoji of at most 64 characters is required", "emoji"))
+		return models.Comment{}, "", false
+	}
+
+	return models.Comment{ID: int64(id)}, req.Emoji, true
+}
+
+// ToggleReaction will add the emoji in the body as a reaction from the
+// current user to the comment indicated by the url, if the user already
+// reacted with that emoji the reaction is removed instead. The updated
+// reaction counts are returned.
+func ToggleReaction(w http.ResponseWriter, r *http.Request) {
 	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to update a ticket"))
+
+	c, emoji, ok := reactionRequest(w, r)
+	if !ok {
 		return
 	}
 
-	var cm models.Comment
+	err := reqStore(r).Tickets().AddReaction(c, *u, emoji)
+	if errors.Is(err, store.ErrDuplicateEntry) {
+		err = reqStore(r).Tickets().RemoveReaction(c, *u, emoji)
+	}
+
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("No comment exists with that id."))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	reactions, err := reqStore(r).Tickets().GetReactions(c)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, reactions)
+}
+
+// RemoveReaction will remove the emoji in the body from the reactions of the
+// current user to the comment indicated by the url
+func RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	u := mw.GetUser(r.Context())
+
+	c, emoji, ok := reactionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Tickets().RemoveReaction(c, *u, emoji)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// AssignTicket will assign the ticket indicated in the url to the user named
+// in the body as {"username": "..."}, a null username unassigns the ticket
+func AssignTicket(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	u := mw.GetUser(r.Context())
+
+	var body struct {
+		Username *string `json:"username"`
+	}
 
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&cm)
+	err := decoder.Decode(&body)
 	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	var assignee models.User
+	if body.Username != nil {
+		if *body.Username == "" {
+			w.WriteHeader(400)
+			w.Write(apiError("username must be null to unassign the ticket",
+				"username"))
+			return
+		}
+
+		assignee.Username = *body.Username
+	}
+
+	tk := models.Ticket{Key: vars["key"], UpdatedBy: *u}
+
+	err = reqStore(r).Tickets().AssignTicket(tk, assignee)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("ticket or assignee not found"))
+			return
+		}
+
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	err = Store.Tickets().NewComment(models.Ticket{Key: vars["key"]}, &cm)
+	w.Write([]byte{})
+}
+
+// GetChildren will return the sub tasks of the ticket indicated by the url
+func GetChildren(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	tickets, err := reqStore(r).Tickets().GetChildren(models.Ticket{Key: vars["key"]})
 	if err != nil {
 		w.WriteHeader(500)
 		w.Write(apiError(err.Error()))
-		log.Println(err)
+		logError(r, err)
 		return
 	}
 
-	sendJSON(w, cm)
+	sendJSONFiltered(w, r, tickets)
+}
+
+// GetWatchers will get the users watching the ticket indicated by the ticket
+// key in the url
+func GetWatchers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	users, err := reqStore(r).Tickets().GetWatchers(models.Ticket{Key: vars["key"]})
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSONFiltered(w, r, users)
+}
+
+// AddWatcher will add the current user as a watcher of the ticket indicated
+// in the url
+func AddWatcher(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	u := mw.GetUser(r.Context())
+
+	err := reqStore(r).Tickets().AddWatcher(models.Ticket{Key: vars["key"]}, *u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// RemoveWatcher will remove the current user from the watchers of the ticket
+// indicated in the url
+func RemoveWatcher(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	u := mw.GetUser(r.Context())
+
+	err := reqStore(r).Tickets().RemoveWatcher(models.Ticket{Key: vars["key"]}, *u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// Worklogs is the response to GetWorklogs, TotalTimeSpent is the sum of the
+// worklogs in seconds.
+type Worklogs struct {
+	Worklogs       []models.Worklog `json:"worklogs"`
+	TotalTimeSpent int64            `json:"total_time_spent"`
+}
+
+// GetWorklogs will return the work logged on the ticket indicated by the url
+// along with the total time spent on it
+func GetWorklogs(w http.ResponseWriter, r *http.Request) {
+	tk := models.Ticket{Key: mux.Vars(r)["key"]}
+
+	total, err := reqStore(r).Tickets().TotalTimeSpent(tk)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("ticket not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	worklogs, err := reqStore(r).Tickets().GetWorklogs(tk)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	if worklogs == nil {
+		worklogs = []models.Worklog{}
+	}
+
+	sendJSON(w, Worklogs{worklogs, total})
+}
+
+// LogWork will log the number of seconds in the body as {"seconds": ...} of
+// work by the current user on the ticket indicated in the url
+func LogWork(w http.ResponseWriter, r *http.Request) {
+	u := mw.GetUser(r.Context())
+
+	var body struct {
+		Seconds int64 `json:"seconds"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	err = reqStore(r).Tickets().LogWork(models.Ticket{Key: mux.Vars(r)["key"]},
+		body.Seconds, *u)
+	if err != nil {
+		if err == store.ErrInvalidDuration {
+			w.WriteHeader(400)
+			w.Write(apiError("seconds must be greater than 0", "seconds"))
+			return
+		}
+
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("ticket not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
 }
@@ -3,7 +3,9 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/praelatus/backend/models"
@@ -29,6 +31,116 @@ func TestGetTicket(t *testing.T) {
 	t.Log(w.Body)
 }
 
+func TestGetTicketFields(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1?fields=key,summary", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var tk map[string]interface{}
+
+	e := json.Unmarshal(w.Body.Bytes(), &tk)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(tk) != 2 || tk["key"] != "TEST-1" || tk["summary"] == nil {
+		t.Errorf("Expected only the key and summary Got %v\n", tk)
+	}
+}
+
+func TestGetTicketLowerCaseKey(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/test-1", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d", w.Code)
+	}
+}
+
+func TestGetTicketInvalidKey(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/not-a-key", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d", w.Code)
+	}
+
+	var e struct {
+		Error APIError `json:"error"`
+	}
+
+	err := json.Unmarshal(w.Body.Bytes(), &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Error.Field != "key" {
+		t.Errorf("Expected the error for key Got %v", e.Error)
+	}
+}
+
+func TestGetTicketETag(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d", w.Code)
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag header")
+	}
+
+	if w.Body.Len() == 0 {
+		t.Error("Expected the ticket in the body")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/tickets/TEST/TEST-1", nil)
+	r.Header.Set("If-None-Match", etag)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 Got %d", w.Code)
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected no body Got %s", w.Body)
+	}
+
+	if w.Header().Get("ETag") != etag {
+		t.Errorf("Expected ETag %s Got %s", etag, w.Header().Get("ETag"))
+	}
+}
+
+func TestGetTicketETagMismatch(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1", nil)
+	r.Header.Set("If-None-Match", `"1-0"`)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d", w.Code)
+	}
+
+	var tk models.Ticket
+
+	e := json.Unmarshal(w.Body.Bytes(), &tk)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+}
+
 func TestGetTicketPreloadComments(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1?preload=comments", nil)
@@ -102,6 +214,47 @@ func TestGetAllTicketsByProject(t *testing.T) {
 	t.Log(w.Body)
 }
 
+func TestGetAllTicketsPaged(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets?limit=1&offset=1", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var tk []models.Ticket
+
+	e := json.Unmarshal(w.Body.Bytes(), &tk)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if len(tk) != 1 {
+		t.Fatalf("Expected 1 ticket got %d", len(tk))
+	}
+
+	if tk[0].Key != "TEST-2" {
+		t.Errorf("Expected TEST-2 Got %s", tk[0].Key)
+	}
+
+	if w.Header().Get("X-Total-Count") != "2" {
+		t.Errorf("Expected X-Total-Count 2 Got %s", w.Header().Get("X-Total-Count"))
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetAllTicketsInvalidPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets?limit=nope", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
 func TestCreateTicket(t *testing.T) {
 	tk := models.Ticket{Summary: "Nope"}
 	byt, _ := json.Marshal(tk)
@@ -122,9 +275,87 @@ func TestCreateTicket(t *testing.T) {
 		t.Errorf("Expected 1 Got %d", tk.ID)
 	}
 
+	if !strings.HasPrefix(tk.Key, "TEST") {
+		t.Errorf("Expected a key for project TEST Got %q", tk.Key)
+	}
+
 	t.Log(w.Body)
 }
 
+func TestCreateTicketInvalidStatus(t *testing.T) {
+	byt, _ := json.Marshal(models.Ticket{Summary: "Nope", Status: models.Status{ID: 42}})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/tickets/TEST", bytes.NewReader(byt))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d", w.Code)
+	}
+
+	var e struct {
+		Error APIError `json:"error"`
+	}
+
+	err := json.Unmarshal(w.Body.Bytes(), &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Error.Field != "status" {
+		t.Errorf("Expected the error for status Got %v", e.Error)
+	}
+}
+
+func TestUpdateTicket(t *testing.T) {
+	for version, code := range map[int]int{1: 200, 0: 409} {
+		byt, _ := json.Marshal(models.Ticket{Summary: "Updated", Version: version})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/tickets/TEST/TEST-1", bytes.NewBuffer(byt))
+		testLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("Expected %d for version %d Got %d\n", code, version, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+func TestUpdateTicketParent(t *testing.T) {
+	tests := []struct {
+		body   string
+		parent int64
+	}{
+		{`{"summary": "Updated", "version": 1}`, 1},
+		{`{"summary": "Updated", "version": 1, "parent_id": 0}`, 0},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/tickets/TEST/TEST-5",
+			bytes.NewBufferString(test.body))
+		testLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != 200 {
+			t.Errorf("Expected 200 for %s Got %d\n", test.body, w.Code)
+		}
+
+		if savedTicket.ParentID != test.parent || savedTicket.ID != 5 ||
+			savedTicket.Description != "This issue is a fake." {
+			t.Errorf("Expected parent %d and the stored description for %s Got %v\n",
+				test.parent, test.body, savedTicket)
+		}
+	}
+}
+
 func TestGetComments(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1/comments", nil)
@@ -146,6 +377,30 @@ func TestGetComments(t *testing.T) {
 	t.Log(w.Body)
 }
 
+func TestGetCommentsPaged(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1/comments?offset=1", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Header().Get("X-Total-Count") != "1" {
+		t.Errorf("Expected X-Total-Count 1 Got %q", w.Header().Get("X-Total-Count"))
+	}
+
+	var cm []models.Comment
+
+	e := json.Unmarshal(w.Body.Bytes(), &cm)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if len(cm) != 0 {
+		t.Errorf("Expected no comments on the second page Got %d", len(cm))
+	}
+
+	t.Log(w.Body)
+}
+
 func TestCreateComment(t *testing.T) {
 	cm := models.Comment{}
 	byt, _ := json.Marshal(cm)
@@ -167,5 +422,263 @@ func TestCreateComment(t *testing.T) {
 		t.Errorf("Expected 1 Got %d\n", cm.ID)
 	}
 
+	if cm.Author.Username != "foouser" {
+		t.Errorf("Expected the comment to be by foouser Got %v\n", cm.Author)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetWatchers(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1/watchers", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var u []models.User
+
+	e := json.Unmarshal(w.Body.Bytes(), &u)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+		t.Log(w.Body)
+	}
+
+	if len(u) != 1 {
+		t.Errorf("Expected 1 watcher got %d\n", len(u))
+	}
+
+	t.Log(w.Body)
+}
+
+func TestAddWatcher(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/tickets/TEST/TEST-1/watchers", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 401 {
+		t.Errorf("Expected 401 without a login Got %d\n", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/tickets/TEST/TEST-1/watchers", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestAssignTicket(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		body  string
+		login func(*http.Request)
+		code  int
+	}{
+		{"assign", "/tickets/TEST/TEST-1/assign", `{"username":"foouser"}`, testLogin, 200},
+		{"unassign", "/tickets/TEST/TEST-1/assign", `{"username":null}`, testLogin, 200},
+		{"empty username", "/tickets/TEST/TEST-1/assign", `{"username":""}`, testLogin, 400},
+		{"no such user", "/tickets/TEST/TEST-1/assign", `{"username":"nouser"}`, testLogin, 404},
+		{"no such ticket", "/tickets/NOPE/NOPE-1/assign", `{"username":"foouser"}`, testLogin, 404},
+		{"anonymous", "/tickets/TEST/TEST-1/assign", `{"username":"foouser"}`, func(*http.Request) {}, 401},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.body))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+func TestGetWorklogs(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/TEST-1/worklogs", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var wl Worklogs
+
+	e := json.Unmarshal(w.Body.Bytes(), &wl)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(wl.Worklogs) != 2 || wl.TotalTimeSpent != 5400 {
+		t.Errorf("Expected 2 worklogs totalling 5400 Got %v\n", wl)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/tickets/NOPE/NOPE-1/worklogs", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Errorf("Expected 404 Got %d\n", w.Code)
+	}
+
 	t.Log(w.Body)
 }
+
+func TestLogWork(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		body  string
+		login func(*http.Request)
+		code  int
+	}{
+		{"log work", "/tickets/TEST/TEST-1/worklogs", `{"seconds":1800}`, testLogin, 200},
+		{"no time", "/tickets/TEST/TEST-1/worklogs", `{"seconds":0}`, testLogin, 400},
+		{"no such ticket", "/tickets/NOPE/NOPE-1/worklogs", `{"seconds":1800}`, testLogin, 404},
+		{"anonymous", "/tickets/TEST/TEST-1/worklogs", `{"seconds":1800}`, func(*http.Request) {}, 401},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", test.path, bytes.NewBufferString(test.body))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+func TestGetChildren(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets/TEST/TEST1/children", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var tks []models.Ticket
+
+	e := json.Unmarshal(w.Body.Bytes(), &tks)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if len(tks) == 0 || tks[0].ParentID != 1 {
+		t.Errorf("Expected children of ticket 1 Got %v", tks)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestRemoveTicketWithChildren(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/tickets/TEST/TEST1", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetComment(t *testing.T) {
+	tests := map[string]int{"1": 200, "2": 404, "nope": 400}
+
+	for id, code := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/comments/"+id, nil)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("Expected %d for comment %s Got %d", code, id, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+func TestGetCommentHistory(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/comments/1/history", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var revisions []models.CommentRevision
+
+	e := json.Unmarshal(w.Body.Bytes(), &revisions)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if len(revisions) != 1 || revisions[0].OldBody != "The original body" {
+		t.Errorf("Expected 1 revision Got %v", revisions)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetReactions(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/comments/1/reactions", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var reactions map[string]int
+
+	e := json.Unmarshal(w.Body.Bytes(), &reactions)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if reactions["+1"] != 2 {
+		t.Errorf("Expected 2 Got %d", reactions["+1"])
+	}
+
+	t.Log(w.Body)
+}
+
+func TestToggleReaction(t *testing.T) {
+	for _, emoji := range []string{"tada", "+1"} {
+		byt, _ := json.Marshal(map[string]string{"emoji": emoji})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/comments/1/reactions", bytes.NewBuffer(byt))
+		testLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != 200 {
+			t.Errorf("Expected 200 for %s Got %d", emoji, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+func TestToggleReactionNoEmoji(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/comments/1/reactions", bytes.NewBufferString("{}"))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 Got %d", w.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
+)
+
+func initTypeRoutes() {
+	Router.Handle("/ticket-types", mw.Default(GetAllTypes)).Methods("GET")
+	Router.Handle("/ticket-types", mw.Default(CreateType)).Methods("POST")
+	Router.Handle("/ticket-types/{id}", mw.Default(UpdateType)).Methods("PUT")
+	Router.Handle("/ticket-types/{id}", mw.Default(RemoveType)).Methods("DELETE")
+}
+
+// GetAllTypes will return the json encoded array of all ticket types
+func GetAllTypes(w http.ResponseWriter, r *http.Request) {
+	typs, err := reqStore(r).Types().GetAll()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, typs)
+}
+
+// CreateType will create the ticket type in the request body, it can only be
+// used by sys admins
+func CreateType(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change ticket types") {
+		return
+	}
+
+	var tt models.TicketType
+
+	err := json.NewDecoder(r.Body).Decode(&tt)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	if tt.Name == "" {
+		w.WriteHeader(400)
+		w.Write(apiError("name is required", "name"))
+		return
+	}
+
+	err = reqStore(r).Types().New(&tt)
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			w.WriteHeader(409)
+			w.Write(apiError("a ticket type with that name already exists", "name"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, tt)
+}
+
+// UpdateType will rename the ticket type indicated by the id, it can only be
+// used by sys admins
+func UpdateType(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change ticket types") {
+		return
+	}
+
+	id, ok := idVar(w, r)
+	if !ok {
+		return
+	}
+
+	var tt models.TicketType
+
+	err := json.NewDecoder(r.Body).Decode(&tt)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(apiError("invalid body"))
+		logError(r, err)
+		return
+	}
+
+	if tt.Name == "" {
+		w.WriteHeader(400)
+		w.Write(apiError("name is required", "name"))
+		return
+	}
+
+	tt.ID = id
+
+	err = reqStore(r).Types().Save(tt)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("ticket type not found"))
+			return
+		}
+
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			w.WriteHeader(409)
+			w.Write(apiError("a ticket type with that name already exists", "name"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, tt)
+}
+
+// RemoveType will remove the ticket type indicated by the id, types which are
+// used by tickets, workflows or project fields are not removed. It can only
+// be used by sys admins.
+func RemoveType(w http.ResponseWriter, r *http.Request) {
+	if !sysAdmin(w, r, "change ticket types") {
+		return
+	}
+
+	id, ok := idVar(w, r)
+	if !ok {
+		return
+	}
+
+	err := reqStore(r).Types().Remove(models.TicketType{ID: id})
+	if err != nil {
+		if err == store.ErrInUse {
+			w.WriteHeader(409)
+			w.Write(apiError("ticket type is in use"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
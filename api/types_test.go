@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/praelatus/backend/models"
+)
+
+func TestGetAllTypes(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/ticket-types", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var typs []models.TicketType
+
+	e := json.Unmarshal(w.Body.Bytes(), &typs)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(typs) != 2 {
+		t.Errorf("Expected 2 Got %d\n", len(typs))
+	}
+
+	t.Log(w.Body)
+}
+
+func TestChangeTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		login  func(*http.Request)
+		code   int
+	}{
+		{"create as core member", "POST", "/ticket-types", `{"name":"Story"}`, testLogin, 403},
+		{"create", "POST", "/ticket-types", `{"name":"Story"}`, testAdminLogin, 200},
+		{"create without name", "POST", "/ticket-types", `{}`, testAdminLogin, 400},
+		{"create duplicate", "POST", "/ticket-types", `{"name":"Fake Type"}`, testAdminLogin, 409},
+		{"update", "PUT", "/ticket-types/2", `{"name":"Epic"}`, testAdminLogin, 200},
+		{"update bad id", "PUT", "/ticket-types/two", `{"name":"Epic"}`, testAdminLogin, 400},
+		{"update missing", "PUT", "/ticket-types/3", `{"name":"Epic"}`, testAdminLogin, 404},
+		{"remove as core member", "DELETE", "/ticket-types/2", "", testLogin, 403},
+		{"remove in use", "DELETE", "/ticket-types/1", "", testAdminLogin, 409},
+		{"remove", "DELETE", "/ticket-types/2", "", testAdminLogin, 200},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path,
+			bytes.NewBufferString(test.body))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
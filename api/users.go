@@ -1,32 +1,89 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/config"
 	"github.com/praelatus/backend/models"
 	"github.com/praelatus/backend/mw"
 	"github.com/praelatus/backend/store"
 )
 
 func initUserRoutes() {
+	// verify has to be matched before it's taken as a username by the
+	// routes below.
+	Router.Handle("/users/verify", mw.Default(VerifyEmail)).Methods("GET")
 	Router.Handle("/users/{username}", mw.Default(UpdateUser)).Methods("PUT")
+	Router.Handle("/users/{username}/profile", mw.Default(UpdateProfile)).Methods("PUT")
+	Router.Handle("/users/{username}/password", mw.Default(ChangePassword)).Methods("POST")
 	Router.Handle("/users/{username}", mw.Default(DeleteUser)).Methods("DELETE")
 	Router.Handle("/users/{username}", mw.Default(GetUser)).Methods("GET")
-	Router.Handle("/users", mw.Default(GetAllUsers)).Methods("GET")
+	Router.Handle("/users", mw.Default(mw.RequireAuth(GetAllUsers))).Methods("GET")
 	Router.Handle("/users", mw.Default(CreateUser)).Methods("POST")
-
-	Router.Handle("/sessions", mw.Default(CreateSession)).Methods("POST")
-	Router.Handle("/sessions", mw.Default(RefreshSession)).Methods("GET")
+	Router.Handle("/users/{username}/tickets", mw.Default(GetAssignedTickets)).Methods("GET")
+	Router.Handle("/users/{username}/reported", mw.Default(GetReportedTickets)).Methods("GET")
+	Router.Handle("/users/{username}/reset", mw.Default(CreatePasswordReset)).Methods("POST")
+	Router.Handle("/users/{username}/reset/confirm", mw.Default(ConfirmPasswordReset)).Methods("POST")
+	Router.Handle("/users/{username}/filters", mw.Default(mw.RequireAuth(GetFilters))).Methods("GET")
+	Router.Handle("/users/{username}/filters", mw.Default(mw.RequireAuth(SaveFilter))).Methods("POST")
+	Router.Handle("/users/{username}/filters/{name}", mw.Default(mw.RequireAuth(DeleteFilter))).Methods("DELETE")
+	Router.Handle("/users/{username}/filters/{name}/tickets", mw.Default(mw.RequireAuth(RunFilter))).Methods("GET")
+
+	Router.Handle("/sessions", mw.RateLimit(mw.Default(CreateSession))).Methods("POST")
+	Router.Handle("/sessions/refresh", mw.RateLimit(mw.Default(RotateSession))).Methods("POST")
+	Router.Handle("/sessions", mw.Default(mw.RequireAuth(DeleteSession))).Methods("DELETE")
+	Router.Handle("/sessions/me", mw.Default(mw.RequireAuth(GetSession))).Methods("GET")
+
+	document("GET", "/users/verify", "Verify an email address", nil, nil)
+	document("PUT", "/users/{username}", "Update a user", models.User{}, models.User{})
+	document("PUT", "/users/{username}/profile", "Update a user's profile",
+		profileRequest{}, models.User{})
+	document("POST", "/users/{username}/password", "Change a user's password",
+		passwordRequest{}, nil)
+	document("DELETE", "/users/{username}", "Remove a user", nil, nil)
+	document("GET", "/users/{username}", "Get a user", nil, models.User{})
+	document("GET", "/users", "List or search users", nil, []models.User{})
+	document("POST", "/users", "Sign up", models.User{}, TokenResponse{})
+	document("GET", "/users/{username}/tickets", "List the tickets assigned to a user",
+		nil, []models.Ticket{})
+	document("GET", "/users/{username}/reported", "List the tickets reported by a user",
+		nil, []models.Ticket{})
+	document("POST", "/users/{username}/reset", "Request a password reset", nil, nil)
+	document("POST", "/users/{username}/reset/confirm", "Reset a password",
+		confirmRequest{}, nil)
+	document("GET", "/users/{username}/filters", "List a user's saved filters",
+		nil, []store.SavedFilter{})
+	document("POST", "/users/{username}/filters", "Save a filter",
+		store.SavedFilter{}, store.SavedFilter{})
+	document("DELETE", "/users/{username}/filters/{name}", "Remove a saved filter",
+		nil, nil)
+	document("GET", "/users/{username}/filters/{name}/tickets",
+		"List the tickets matching a saved filter", nil, []models.Ticket{})
+
+	document("POST", "/sessions", "Log in", loginRequest{}, TokenResponse{})
+	document("POST", "/sessions/refresh", "Exchange a refresh token for new tokens",
+		refreshRequest{}, TokenResponse{})
+	document("DELETE", "/sessions", "Log out", refreshRequest{}, nil)
+	document("GET", "/sessions/me", "Get the session's user", nil, SessionResponse{})
 }
 
 // TokenResponse is used when logging in or signing up, it will return a
-// generated token plus the user model for use by the client.
+// generated token plus the user model for use by the client. RefreshToken is
+// only set when a session is started or rotated.
 type TokenResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+	User         models.User `json:"user"`
 }
 
 // GetUser will get a user from the database by the given username
@@ -37,44 +94,58 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 		Username: vars["username"],
 	}
 
-	err := Store.Users().Get(&u)
+	err := reqStore(r).Users().Get(&u)
 	if err != nil {
 		if err == store.ErrNotFound {
 			w.WriteHeader(404)
-			w.Write(apiError("No user exists with that username."))
+			w.Write(NewAPIError(CodeUserNotFound,
+				"No user exists with that username.").JSON())
 			return
 		}
 
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
 	u.Password = ""
 
-	sendJSON(w, u)
+	sendJSONFiltered(w, r, u)
 }
 
 // GetAllUsers will return the json encoded array of all users in the given
-// store
+// store, the q query parameter searches usernames and display names and the
+// limit and offset query parameters can be used to request a single page
 func GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(403)
-		w.Write(apiError("you must be logged in to view other users"))
+	opts, err := pageOptions(r)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest,
+			"invalid pagination parameters").JSON())
 		return
 	}
 
-	users, err := Store.Users().GetAll()
+	users, total, err := reqStore(r).Users().GetAllFiltered(r.FormValue("q"), opts)
 	if err != nil {
+		if err == store.ErrInvalidOrderBy {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidRequest, err.Error(), "order_by").JSON())
+			return
+		}
+
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	sendJSON(w, users)
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	setTotalCount(w, total)
+	sendJSONFiltered(w, r, users)
 }
 
 // CreateUser will take the JSON given and attempt to
@@ -85,164 +156,839 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	err := decoder.Decode(&u)
 	if err != nil {
 		w.WriteHeader(400)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	err = Store.Users().New(&u)
+	err = models.ValidatePassword(u.Password)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeWeakPassword, err.Error(), "password").JSON())
+		return
+	}
+
+	err = u.SetPassword(u.Password)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	// Only following the verification token can verify the email, and only
+	// an admin can make another admin.
+	u.EmailVerified = false
+	u.IsAdmin = false
+	u.IsActive = true
+
+	err = reqStore(r).Users().New(&u)
 	if err != nil {
-		if err == store.ErrDuplicateEntry {
+		if errors.Is(err, store.ErrDuplicateEntry) {
 			w.WriteHeader(400)
-			w.Write(apiError(err.Error()))
+			w.Write(NewAPIError(CodeUserExists, err.Error()).JSON())
 			return
 		}
 
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	token, err := mw.JWTSignUser(u)
+	// The user is created even if the verification can't be sent, they
+	// can still log in and the email can be verified later.
+	err = sendEmailVerification(r, u)
+	if err != nil {
+		logError(r, err)
+	}
+
+	res, err := startSession(r, u)
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	sendJSON(w, TokenResponse{
-		token,
-		u,
-	})
+	sendJSON(w, res)
+}
+
+// canModifyUser reports whether the user u is allowed to change or remove the
+// target user
+func canModifyUser(u *models.User, target models.User) bool {
+	return u != nil && (u.IsAdmin || isUser(u, target))
+}
+
+// isUser reports whether u is the target user, users can rename themselves
+// so they are compared by ID since a username may belong to someone else by
+// the time an old token is used.
+func isUser(u *models.User, target models.User) bool {
+	return u != nil && u.ID == target.ID
+}
+
+// targetUser will get the user named by the username route variable, writing
+// the error response and returning false if it can't be found
+func targetUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	u := models.User{Username: mux.Vars(r)["username"]}
+
+	err := reqStore(r).Users().Get(&u)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(NewAPIError(CodeUserNotFound,
+				"No user exists with that username.").JSON())
+			return u, false
+		}
+
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return u, false
+	}
+
+	return u, true
+}
+
+// GetAssignedTickets will return the tickets assigned to the user indicated
+// by the url
+func GetAssignedTickets(w http.ResponseWriter, r *http.Request) {
+	u, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := reqStore(r).Tickets().GetByAssignee(u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	sendJSONFiltered(w, r, tickets)
+}
+
+// GetReportedTickets will return the tickets reported by the user indicated
+// by the url
+func GetReportedTickets(w http.ResponseWriter, r *http.Request) {
+	u, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := reqStore(r).Tickets().GetByReporter(u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	sendJSONFiltered(w, r, tickets)
 }
 
 // UpdateUser will update a user in the database, it will reject the call if
 // the user sending is not the user being updated or if the user sending is not
 // a sys admin
 func UpdateUser(w http.ResponseWriter, r *http.Request) {
-	var u models.User
+	target, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	cu := mw.GetUser(r.Context())
+	if !canModifyUser(cu, target) {
+		w.WriteHeader(403)
+		w.Write(NewAPIError(CodeForbidden,
+			"you do not have permission to update this user").JSON())
+		return
+	}
+
+	// The body is decoded onto the stored user so fields which aren't sent
+	// keep their values.
+	u := target
 
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&u)
 	if err != nil {
 		w.WriteHeader(400)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	if !validProfile(w, u) {
 		return
 	}
 
-	err = Store.Users().Save(u)
+	u.ID = target.ID
+	if !cu.IsAdmin {
+		u.IsAdmin = target.IsAdmin
+	}
+
+	// Passwords are only changed by ChangePassword and the reset flow which
+	// validate and hash them.
+	u.Password = ""
+
+	// The store unverifies a changed email, a new address has to be verified
+	// again.
+	emailChanged := u.Email != target.Email
+	u.EmailVerified = target.EmailVerified && !emailChanged
+
+	err = reqStore(r).Users().Save(u)
 	if err != nil {
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeUserExists, err.Error()).JSON())
+			return
+		}
+
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
+	if u.IsAdmin != target.IsAdmin {
+		mw.RolesChanged(target)
+	}
+
+	if emailChanged {
+		err = sendEmailVerification(r, u)
+		if err != nil {
+			logError(r, err)
+		}
+	}
+
 	sendJSON(w, u)
 }
 
-// DeleteUser will remove a user from the database by setting is_inactive = 1
-// can only be used by sys admins
-func DeleteUser(w http.ResponseWriter, r *http.Request) {
-	var u models.User
+// profileRequest is the body sent to UpdateProfile
+type profileRequest struct {
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Bio         string `json:"bio"`
+}
+
+// UpdateProfile will update the display name, avatar and bio of a user, unlike
+// UpdateUser only the user themselves can change their profile
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	u, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !isUser(mw.GetUser(r.Context()), u) {
+		w.WriteHeader(403)
+		w.Write(NewAPIError(CodeForbidden,
+			"you do not have permission to update this profile").JSON())
+		return
+	}
+
+	// Fields which aren't sent keep their values.
+	p := profileRequest{u.DisplayName, u.AvatarURL, u.Bio}
 
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&u)
+	err := decoder.Decode(&p)
 	if err != nil {
 		w.WriteHeader(400)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	err = Store.Users().Remove(u)
+	u.DisplayName = p.DisplayName
+	u.AvatarURL = p.AvatarURL
+	u.Bio = p.Bio
+	u.Password = ""
+
+	if !validProfile(w, u) {
+		return
+	}
+
+	err = reqStore(r).Users().Save(u)
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, u)
+}
+
+// validProfile will check the profile of the user, writing the error response
+// and returning false if it's invalid
+func validProfile(w http.ResponseWriter, u models.User) bool {
+	err := u.ValidateProfile()
+	switch err {
+	case nil:
+		return true
+	case models.ErrDisplayNameTooLong:
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error(), "display_name").JSON())
+	default:
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error(), "avatar_url").JSON())
+	}
+
+	return false
+}
+
+// passwordRequest is the body sent to ChangePassword
+type passwordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// ChangePassword will set a new password for the user after checking their
+// current one, only the user themselves can change their password
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	u, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if !isUser(mw.GetUser(r.Context()), u) {
+		w.WriteHeader(403)
+		w.Write(NewAPIError(CodeForbidden,
+			"you do not have permission to change this password").JSON())
+		return
+	}
+
+	var p passwordRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&p)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	if !u.CheckPw([]byte(p.OldPassword)) {
+		w.WriteHeader(401)
+		w.Write(NewAPIError(CodeInvalidPassword,
+			"the current password is incorrect", "old_password").JSON())
+		return
+	}
+
+	err = models.ValidatePassword(p.NewPassword)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeWeakPassword, err.Error(), "new_password").JSON())
+		return
+	}
+
+	err = u.SetPassword(p.NewPassword)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	err = reqStore(r).Users().Save(u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
 	w.Write([]byte(""))
 }
 
-// CreateSession will log in a user and create a jwt token for the current
-// session
-func CreateSession(w http.ResponseWriter, r *http.Request) {
-	type loginRequest struct {
-		Username string `json:"username"`
-		Password string `json:"passoword"`
+// DeleteUser will remove a user from the database by setting is_inactive = 1
+// can only be used by the user being removed or sys admins
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := targetUser(w, r)
+	if !ok {
+		return
 	}
 
+	if !canModifyUser(mw.GetUser(r.Context()), u) {
+		w.WriteHeader(403)
+		w.Write(NewAPIError(CodeForbidden,
+			"you do not have permission to remove this user").JSON())
+		return
+	}
+
+	err := reqStore(r).Users().Remove(u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte(""))
+}
+
+// loginRequest is the body sent to CreateSession, Identifier is a username or
+// email. Username is still accepted for clients which send it instead.
+type loginRequest struct {
+	Identifier string `json:"identifier,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password"`
+}
+
+// loginUser will get the user identified by id, an id containing an @ is
+// looked up as an email first since usernames rarely contain one.
+func loginUser(r *http.Request, id string) (models.User, error) {
+	if strings.Contains(id, "@") {
+		u, err := reqStore(r).Users().GetByEmail(id)
+		if err != store.ErrNotFound {
+			return u, err
+		}
+	}
+
+	u := models.User{Username: id}
+	err := reqStore(r).Users().Get(&u)
+	return u, err
+}
+
+// After loginAttempts failed logins in a row an account can't log in for
+// lockoutWindow.
+var (
+	loginAttempts = config.GetLoginAttempts()
+	lockoutWindow = config.GetLockoutWindow()
+)
+
+// CreateSession will log in a user and create a jwt token for the current
+// session, accounts which are locked out get a 423 without their password
+// being checked.
+func CreateSession(w http.ResponseWriter, r *http.Request) {
 	var l loginRequest
 
 	decode := json.NewDecoder(r.Body)
 	err := decode.Decode(&l)
 	if err != nil {
 		w.WriteHeader(400)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	u := models.User{Username: l.Username}
+	id := l.Identifier
+	if id == "" {
+		id = l.Username
+	}
 
-	err = Store.Users().Get(&u)
+	u, err := loginUser(r, id)
 	if err != nil {
 		if err == store.ErrNotFound {
 			w.WriteHeader(404)
-			w.Write(apiError("No user exists with that username."))
+			w.Write(NewAPIError(CodeUserNotFound,
+				"No user exists with that username or email.").JSON())
 			return
 		}
 
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	until, err := reqStore(r).Users().LoginLockedUntil(u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	if wait := time.Until(until); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		w.WriteHeader(http.StatusLocked)
+		w.Write(NewAPIError(CodeAccountLocked,
+			"too many failed logins, try again later").JSON())
 		return
 	}
 
 	if u.CheckPw([]byte(l.Password)) {
-		u.Password = ""
-		token, err := mw.JWTSignUser(u)
+		err = reqStore(r).Users().ResetFailedLogins(u)
 		if err != nil {
 			w.WriteHeader(500)
-			w.Write(apiError(err.Error()))
-			log.Println(err)
+			w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+			logError(r, err)
 			return
+		}
 
+		res, err := startSession(r, u)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+			logError(r, err)
+			return
 		}
 
-		sendJSON(w, TokenResponse{
-			token,
-			u,
-		})
+		sendJSON(w, res)
+		return
+	}
 
+	_, err = reqStore(r).Users().RecordFailedLogin(u, loginAttempts,
+		time.Now().Add(lockoutWindow))
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
 	w.WriteHeader(401)
-	w.Write(apiError("invalid password", "password"))
+	w.Write(NewAPIError(CodeInvalidPassword,
+		"invalid password", "password").JSON())
+}
+
+// startSession will sign an access token for the user and create the refresh
+// token of a new session for them
+func startSession(r *http.Request, u models.User) (TokenResponse, error) {
+	u.Password = ""
+
+	token, err := mw.JWTSignUserContext(r.Context(), u)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	refresh, err := newToken()
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	err = reqStore(r).Users().CreateRefreshToken(u, refresh,
+		time.Now().Add(config.GetRefreshTokenTTL()))
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{Token: token, RefreshToken: refresh, User: u}, nil
 }
 
-// RefreshSession will reset the expiration on the current jwt token
-func RefreshSession(w http.ResponseWriter, r *http.Request) {
+// refreshRequest is the body sent to RotateSession and optionally to
+// DeleteSession
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RotateSession will exchange the refresh token in the body for a new access
+// token and refresh token, the old refresh token can't be used again. Using
+// it again revokes the session since only a stolen copy would be.
+func RotateSession(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	next, err := newToken()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	u, err := reqStore(r).Users().RotateRefreshToken(req.RefreshToken, next,
+		time.Now().Add(config.GetRefreshTokenTTL()))
+	if err != nil {
+		switch err {
+		case store.ErrInvalidToken, store.ErrTokenExpired:
+			w.WriteHeader(401)
+			w.Write(NewAPIError(CodeInvalidToken, err.Error(), "refresh_token").JSON())
+		case store.ErrTokenReused:
+			w.WriteHeader(401)
+			w.Write(NewAPIError(CodeInvalidToken,
+				"refresh token has already been used, the session has been revoked",
+				"refresh_token").JSON())
+			logError(r, err)
+		default:
+			w.WriteHeader(500)
+			w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+			logError(r, err)
+		}
+
+		return
+	}
+
+	u.Password = ""
+
+	token, err := mw.JWTSignUserContext(r.Context(), u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, TokenResponse{Token: token, RefreshToken: next, User: u})
+}
+
+// SessionResponse is the body of GetSession
+type SessionResponse struct {
+	User      models.User `json:"user"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// GetSession will return the user the token used to make the request belongs
+// to and when the token expires, unlike RotateSession no new token is issued.
+func GetSession(w http.ResponseWriter, r *http.Request) {
 	u := mw.GetUser(r.Context())
-	if u == nil {
-		w.WriteHeader(401)
-		w.Write(apiError("you must be logged in to refresh your session"))
+
+	exp, err := mw.TokenExpiry(r)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	u.Password = ""
+	sendJSON(w, SessionResponse{*u, exp})
+}
+
+// DeleteSession will log out the current user by revoking the jwt token used
+// to make the request, if the body has the session's refresh token it is
+// revoked as well
+func DeleteSession(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	if req.RefreshToken != "" {
+		err = reqStore(r).Users().RevokeRefreshToken(*mw.GetUser(r.Context()), req.RefreshToken)
+		if err != nil && err != store.ErrInvalidToken {
+			w.WriteHeader(500)
+			w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+			logError(r, err)
+			return
+		}
+	}
+
+	err = mw.RevokeRequestToken(r)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// resetTokenTTL is how long a password reset token can be used for
+const resetTokenTTL = time.Hour
+
+// verificationTokenTTL is how long an email verification token can be used
+// for
+const verificationTokenTTL = 24 * time.Hour
+
+// newToken generates a random single use token for password resets, email
+// verification and refresh tokens
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// sendEmailVerification will create an email verification token for the user
+// and send it to them using the Notifier
+func sendEmailVerification(r *http.Request, u models.User) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	err = reqStore(r).Users().CreateEmailVerification(u, token,
+		time.Now().Add(verificationTokenTTL))
+	if err != nil {
+		return err
+	}
+
+	return Notifier.Notify(models.Event{
+		Type:  models.EventEmailVerification,
+		Actor: u,
+		Data:  map[string]string{"token": token},
+	})
+}
+
+// VerifyEmail will mark the email of the user who was sent the token query
+// parameter as verified, responding with a new session token for the user
+// since the verified state is part of the session.
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	u, err := reqStore(r).Users().VerifyEmail(r.FormValue("token"))
+	if err != nil {
+		if err == store.ErrInvalidToken || err == store.ErrTokenExpired {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidToken, err.Error(), "token").JSON())
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	u.Password = ""
+
+	token, err := mw.JWTSignUserContext(r.Context(), u)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	sendJSON(w, TokenResponse{Token: token, User: u})
+}
+
+// CreatePasswordReset will generate a single use password reset token for the
+// given user and send it to them using the Notifier, it answers 200 even if
+// there is no such user.
+func CreatePasswordReset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	u := models.User{Username: vars["username"]}
+
+	err := reqStore(r).Users().Get(&u)
+	if err != nil {
+		// Unknown users get the same answer as known ones so the reset can't
+		// be used to find out which usernames exist.
+		if err == store.ErrNotFound {
+			w.Write([]byte{})
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	err = reqStore(r).Users().CreatePasswordReset(u, token, time.Now().Add(resetTokenTTL))
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	err = Notifier.Notify(models.Event{
+		Type:  models.EventPasswordReset,
+		Actor: u,
+		Data:  map[string]string{"token": token},
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal,
+			"failed to send password reset").JSON())
+		logError(r, err)
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+// confirmRequest is the body sent to ConfirmPasswordReset
+type confirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ConfirmPasswordReset will set the password for the given user if the reset
+// token sent with it is valid, the token can only be used once
+func ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var c confirmRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&c)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidRequest, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	if c.Password == "" {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeInvalidPassword,
+			"a new password is required", "password").JSON())
+		return
+	}
+
+	err = models.ValidatePassword(c.Password)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write(NewAPIError(CodeWeakPassword, err.Error(), "password").JSON())
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	u := models.User{Username: vars["username"]}
+
+	// Like CreatePasswordReset an unknown user isn't told apart from a bad
+	// token.
+	err = reqStore(r).Users().Get(&u)
+	if err == store.ErrNotFound {
+		err = store.ErrInvalidToken
+	} else if err == nil {
+		err = reqStore(r).Users().ConsumePasswordReset(u, c.Token)
+	}
+
+	if err != nil {
+		if err == store.ErrInvalidToken || err == store.ErrTokenExpired {
+			w.WriteHeader(400)
+			w.Write(NewAPIError(CodeInvalidToken, err.Error(), "token").JSON())
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
+		return
+	}
+
+	err = u.SetPassword(c.Password)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	token, err := mw.JWTSignUser(*u)
+	err = reqStore(r).Users().Save(u)
 	if err != nil {
 		w.WriteHeader(500)
-		w.Write(apiError(err.Error()))
-		log.Println(err)
+		w.Write(NewAPIError(CodeInternal, err.Error()).JSON())
+		logError(r, err)
 		return
 	}
 
-	w.Write([]byte(token))
+	w.Write([]byte{})
 }
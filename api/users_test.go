@@ -2,11 +2,19 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/store"
+	"github.com/praelatus/backend/store/mem"
 )
 
 func TestGetUser(t *testing.T) {
@@ -59,8 +67,50 @@ func TestGetAllUsers(t *testing.T) {
 	t.Log(w.Body)
 }
 
+func TestGetAllUsersFiltered(t *testing.T) {
+	tests := []struct {
+		query string
+		count int
+		total string
+	}{
+		{"?q=FOO", 2, "2"},
+		{"?q=nomatch", 0, "0"},
+		{"?q=foo&limit=1&offset=1", 1, "2"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/users"+test.query, nil)
+		testAdminLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		var u []models.User
+
+		e := json.Unmarshal(w.Body.Bytes(), &u)
+		if e != nil {
+			t.Errorf("%s: Failed with error %s", test.query, e.Error())
+		}
+
+		if len(u) != test.count {
+			t.Errorf("%s: Expected %d users Got %d", test.query, test.count, len(u))
+		}
+
+		if w.Header().Get("X-Total-Count") != test.total {
+			t.Errorf("%s: Expected a total of %s Got %s", test.query, test.total,
+				w.Header().Get("X-Total-Count"))
+		}
+
+		for _, usr := range u {
+			if usr.Password != "" {
+				t.Errorf("%s: Expected no passwords to be returned", test.query)
+			}
+		}
+	}
+}
+
 func TestCreateUser(t *testing.T) {
-	u := models.User{Username: "grumpycat"}
+	u := models.User{Username: "grumpycat", Password: "grumpy123"}
 	byt, _ := json.Marshal(u)
 	rd := bytes.NewReader(byt)
 
@@ -87,20 +137,962 @@ func TestCreateUser(t *testing.T) {
 	t.Log(w.Body)
 }
 
-func TestRefreshSession(t *testing.T) {
+// recordingStore records the users passed to Users().New and Users().Save
+type recordingStore struct {
+	mockStore
+	created *models.User
+	saved   *models.User
+}
+
+func (s recordingStore) WithContext(ctx context.Context) store.Store {
+	return s
+}
+
+func (s recordingStore) WithTx(fn func(tx store.Store) error) error {
+	return fn(s)
+}
+
+func (s recordingStore) Users() store.UserStore {
+	return recordingUsersStore{created: s.created, saved: s.saved}
+}
+
+type recordingUsersStore struct {
+	mockUsersStore
+	created *models.User
+	saved   *models.User
+}
+
+func (s recordingUsersStore) New(u *models.User) error {
+	*s.created = *u
+	return s.mockUsersStore.New(u)
+}
+
+func (s recordingUsersStore) Save(u models.User) error {
+	*s.saved = u
+	return s.mockUsersStore.Save(u)
+}
+
+func TestCreateUserHashesPassword(t *testing.T) {
+	var created models.User
+
+	old := Store
+	Store = recordingStore{created: &created, saved: &models.User{}}
+	defer func() { Store = old }()
+
+	byt, _ := json.Marshal(models.User{Username: "grumpycat", Password: "grumpy123"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/users", bytes.NewReader(byt))
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d\n", w.Code)
+	}
+
+	if created.Password == "" || created.Password == "grumpy123" {
+		t.Errorf("Expected a hashed password Got %q\n", created.Password)
+	}
+
+	if !created.CheckPw([]byte("grumpy123")) {
+		t.Error("Expected the stored hash to match the password")
+	}
+
+	var l TokenResponse
+
+	e := json.Unmarshal(w.Body.Bytes(), &l)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if l.User.Password != "" {
+		t.Error("Expected no password to be returned but instead got a password.")
+	}
+}
+
+func TestUserErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"not found", "GET", "/users/nouser", "", 404, CodeUserNotFound, ""},
+		{"login not found", "POST", "/sessions",
+			`{"username":"nouser","password":"foopass"}`,
+			404, CodeUserNotFound, ""},
+		{"login bad password", "POST", "/sessions",
+			`{"username":"foouser","password":"wrong"}`,
+			401, CodeInvalidPassword, "password"},
+		{"login bad json", "POST", "/sessions", `{`,
+			400, CodeInvalidRequest, ""},
+		{"short password", "POST", "/users",
+			`{"username":"grumpycat","password":"cat1"}`,
+			400, CodeWeakPassword, "password"},
+		{"simple password", "POST", "/users",
+			`{"username":"grumpycat","password":"grumpycat"}`,
+			400, CodeWeakPassword, "password"},
+	}
+
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tc.method, tc.path,
+			bytes.NewBufferString(tc.body))
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: Expected %d Got %d\n", tc.name, tc.status, w.Code)
+		}
+
+		var resp map[string]map[string]string
+
+		e := json.Unmarshal(w.Body.Bytes(), &resp)
+		if e != nil {
+			t.Errorf("%s: Failed with error %s\n", tc.name, e.Error())
+			continue
+		}
+
+		err, ok := resp["error"]
+		if !ok || err["message"] == "" {
+			t.Errorf("%s: Expected an error object Got %s\n", tc.name, w.Body)
+		}
+
+		if err["code"] != tc.code || err["field"] != tc.field {
+			t.Errorf("%s: Expected code %s field %q Got %v\n", tc.name,
+				tc.code, tc.field, err)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+type captureLogger struct {
+	errors []string
+}
+
+func (c *captureLogger) Debug(v ...interface{}) {}
+func (c *captureLogger) Info(v ...interface{})  {}
+
+func (c *captureLogger) Error(v ...interface{}) {
+	c.errors = append(c.errors, fmt.Sprint(v...))
+}
+
+func TestGetUserLogsError(t *testing.T) {
+	c := &captureLogger{}
+	old := Log
+	Log = c
+	defer func() { Log = old }()
+
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest("GET", "/sessions", nil)
+	r := httptest.NewRequest("GET", "/users/erroruser", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 500 {
+		t.Errorf("Expected 500 Got %d\n", w.Code)
+	}
+
+	if len(c.errors) != 1 || !strings.Contains(c.errors[0], "connection refused") {
+		t.Errorf("Expected the store error to be logged Got %v\n", c.errors)
+	}
+}
+
+func TestLoginRequest(t *testing.T) {
+	var l loginRequest
+
+	e := json.Unmarshal([]byte(`{"username":"x","password":"secret"}`), &l)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if l.Username != "x" || l.Password != "secret" {
+		t.Errorf("Expected x with password secret Got %v\n", l)
+	}
+}
+
+func TestGetAssignedTickets(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users/foouser/tickets", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var tickets []models.Ticket
+
+	e := json.Unmarshal(w.Body.Bytes(), &tickets)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(tickets) != 2 {
+		t.Errorf("Expected 2 tickets Got %d\n", len(tickets))
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/users/nouser/tickets", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Errorf("Expected 404 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetReportedTickets(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users/foouser/reported", nil)
+
+	Router.ServeHTTP(w, r)
+
+	var tickets []models.Ticket
+
+	e := json.Unmarshal(w.Body.Bytes(), &tickets)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if len(tickets) != 2 {
+		t.Errorf("Expected 2 tickets Got %d\n", len(tickets))
+	}
+
+	t.Log(w.Body)
+}
+
+func TestGetSession(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/sessions/me", nil)
 	testLogin(r)
 
 	Router.ServeHTTP(w, r)
 
-	if w.Body.String() == "" {
-		t.Errorf("Expected a token response got %s\n", w.Body.String())
+	if w.Code != 200 {
+		t.Fatalf("Expected 200 Got %d\n", w.Code)
 	}
 
+	var s SessionResponse
+
+	e := json.Unmarshal(w.Body.Bytes(), &s)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if s.User.Username != "foouser" {
+		t.Errorf("Expected foouser Got %s\n", s.User.Username)
+	}
+
+	if s.User.Password != "" {
+		t.Errorf("Expected the password to be zeroed Got %s\n", s.User.Password)
+	}
+
+	if !s.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected an expiry in the future Got %v\n", s.ExpiresAt)
+	}
+}
+
+func TestGetSessionAnonymous(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/sessions/me", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 401 {
+		t.Errorf("Expected 401 Got %d\n", w.Code)
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/sessions", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
 	if w.Code != 200 {
 		t.Errorf("Expected 200 Got %d\n", w.Code)
 	}
 
+	w = httptest.NewRecorder()
+	me := httptest.NewRequest("GET", "/sessions/me", nil)
+	me.Header = r.Header
+
+	Router.ServeHTTP(w, me)
+
+	if w.Code != 401 {
+		t.Errorf("Expected 401 Got %d\n", w.Code)
+	}
+
 	t.Log(w.Body)
 }
+
+func TestRotateSession(t *testing.T) {
+	tests := map[string]int{
+		"valid":   200,
+		"expired": 401,
+		"used":    401,
+		"missing": 401,
+	}
+
+	for token, code := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/sessions/refresh",
+			bytes.NewBufferString(`{"refresh_token":"`+token+`"}`))
+		// The route is rate limited along with logging in, so these
+		// requests come from their own client.
+		r.RemoteAddr = "192.0.2.20:1234"
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("Expected %d for %s token Got %d\n", code, token, w.Code)
+		}
+
+		if code != 200 {
+			continue
+		}
+
+		var l TokenResponse
+
+		e := json.Unmarshal(w.Body.Bytes(), &l)
+		if e != nil {
+			t.Errorf("Failed with error %s", e.Error())
+		}
+
+		if l.Token == "" || l.RefreshToken == "" || l.RefreshToken == token {
+			t.Errorf("Expected new tokens Got %v\n", l)
+		}
+	}
+}
+
+func TestDeleteSessionWithRefreshToken(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("DELETE", "/sessions",
+		bytes.NewBufferString(`{"refresh_token":"valid"}`))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d\n", w.Code)
+	}
+
+	t.Log(w.Body)
+}
+
+func TestLoginByEmail(t *testing.T) {
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"identifier":"foo@foo.com","password":"foopass"}`, 200},
+		{`{"identifier":"foouser","password":"foopass"}`, 200},
+		{`{"username":"foouser","password":"foopass"}`, 200},
+		{`{"identifier":"nobody@foo.com","password":"foopass"}`, 404},
+		{`{"identifier":"foo@foo.com","password":"wrong"}`, 401},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/sessions", bytes.NewBufferString(test.body))
+		r.RemoteAddr = fmt.Sprintf("198.51.100.%d:1234", 200+i)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.body, test.code, w.Code)
+		}
+
+		if test.code != 200 {
+			continue
+		}
+
+		var l TokenResponse
+
+		e := json.Unmarshal(w.Body.Bytes(), &l)
+		if e != nil {
+			t.Errorf("Failed with error %s", e.Error())
+		}
+
+		if l.User.Username != "foouser" || l.Token == "" {
+			t.Errorf("Expected a token for foouser Got %v\n", l)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	old, oldWindow := Store, lockoutWindow
+	Store = mem.New()
+	lockoutWindow = 50 * time.Millisecond
+	defer func() { Store, lockoutWindow = old, oldWindow }()
+
+	u := models.User{Username: "lockme", Email: "lockme@example.com"}
+	e := u.SetPassword("correct horse battery staple")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	e = Store.Users().New(&u)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	client := 0
+	login := func(password string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/sessions", bytes.NewBufferString(
+			`{"username":"lockme","password":"`+password+`"}`))
+		// Each attempt comes from another client so the rate limit on
+		// logging in isn't what stops them.
+		client++
+		r.RemoteAddr = fmt.Sprintf("198.51.100.%d:1234", client)
+
+		Router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for i := 0; i < loginAttempts; i++ {
+		if code := login("wrong"); code != 401 {
+			t.Errorf("Expected 401 for failed login %d Got %d\n", i+1, code)
+		}
+	}
+
+	if code := login("correct horse battery staple"); code != 423 {
+		t.Errorf("Expected 423 once locked out Got %d\n", code)
+	}
+
+	time.Sleep(2 * lockoutWindow)
+
+	if code := login("correct horse battery staple"); code != 200 {
+		t.Errorf("Expected 200 after the lockout window Got %d\n", code)
+	}
+
+	// Logging in reset the count so one failure doesn't lock the account.
+	login("wrong")
+
+	if code := login("correct horse battery staple"); code != 200 {
+		t.Errorf("Expected 200 after a single failure Got %d\n", code)
+	}
+}
+
+type recordingNotifier struct {
+	event models.Event
+}
+
+func (n *recordingNotifier) Notify(e models.Event) error {
+	n.event = e
+	return nil
+}
+
+func TestCreatePasswordReset(t *testing.T) {
+	n := &recordingNotifier{}
+	old := Notifier
+	Notifier = n
+	defer func() { Notifier = old }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/users/foouser/reset", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected 200 Got %d\n", w.Code)
+	}
+
+	if n.event.Type != models.EventPasswordReset ||
+		n.event.Actor.Username != "foouser" || n.event.Data["token"] == "" {
+		t.Errorf("Expected a reset to be sent to foouser Got %v\n", n)
+	}
+
+	t.Log(w.Body)
+
+	n.event = models.Event{}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/users/nouser/reset", nil)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 200 || n.event.Type != "" {
+		t.Errorf("Expected 200 without sending a reset Got %d %v\n", w.Code, n.event)
+	}
+}
+
+func TestCreateUserNotAdmin(t *testing.T) {
+	byt, _ := json.Marshal(models.User{
+		Username: "grumpycat",
+		Password: "grumpy123",
+		IsAdmin:  true,
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/users", bytes.NewReader(byt))
+
+	Router.ServeHTTP(w, r)
+
+	var l TokenResponse
+
+	e := json.Unmarshal(w.Body.Bytes(), &l)
+	if e != nil {
+		t.Fatalf("Failed with error %s", e.Error())
+	}
+
+	if l.User.IsAdmin {
+		t.Error("Expected a new user not to be an admin")
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/admin/tickets", nil)
+	r.Header.Set("Authorization", "Bearer "+l.Token)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 403 {
+		t.Errorf("Expected 403 Got %d\n", w.Code)
+	}
+}
+
+func TestCreateUserSendsVerification(t *testing.T) {
+	n := &recordingNotifier{}
+	old := Notifier
+	Notifier = n
+	defer func() { Notifier = old }()
+
+	byt, _ := json.Marshal(models.User{
+		Username:      "grumpycat",
+		Password:      "grumpy123",
+		EmailVerified: true,
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/users", bytes.NewReader(byt))
+
+	Router.ServeHTTP(w, r)
+
+	var l TokenResponse
+
+	e := json.Unmarshal(w.Body.Bytes(), &l)
+	if e != nil {
+		t.Errorf("Failed with error %s", e.Error())
+	}
+
+	if l.User.EmailVerified {
+		t.Error("Expected a new user to be unverified")
+	}
+
+	if n.event.Type != models.EventEmailVerification || n.event.Data["token"] == "" {
+		t.Errorf("Expected a verification to be sent Got %v\n", n)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := map[string]int{
+		"valid":   200,
+		"expired": 400,
+		"used":    400,
+	}
+
+	for token, code := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/users/verify?token="+token, nil)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("Expected %d for %s token Got %d\n", code, token, w.Code)
+		}
+
+		if code != 200 {
+			continue
+		}
+
+		var l TokenResponse
+
+		e := json.Unmarshal(w.Body.Bytes(), &l)
+		if e != nil {
+			t.Errorf("Failed with error %s", e.Error())
+		}
+
+		if !l.User.EmailVerified || l.Token == "" {
+			t.Errorf("Expected a verified user and token Got %v\n", l)
+		}
+	}
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	tests := map[string]int{
+		"valid":   200,
+		"expired": 400,
+		"used":    400,
+	}
+
+	for token, code := range tests {
+		byt, _ := json.Marshal(map[string]string{
+			"token":    token,
+			"password": "newpass123",
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/users/foouser/reset/confirm",
+			bytes.NewBuffer(byt))
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("Expected %d for %s token Got %d\n", code, token, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		login func(*http.Request)
+		code  int
+	}{
+		{"self", "/users/foouser", testLogin, 200},
+		{"admin", "/users/otheruser", testAdminLogin, 200},
+		{"other", "/users/otheruser", testLogin, 403},
+		{"anonymous", "/users/foouser", func(*http.Request) {}, 403},
+	}
+
+	for _, test := range tests {
+		byt, _ := json.Marshal(models.User{Username: "foouser", Email: "new@foo.com"})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", test.path, bytes.NewBuffer(byt))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+
+	byt, _ := json.Marshal(models.User{Username: "foouser", Password: "plaintext1"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/users/foouser", bytes.NewBuffer(byt))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var u models.User
+
+	e := json.Unmarshal(w.Body.Bytes(), &u)
+	if e != nil {
+		t.Errorf("Failed with error %s\n", e.Error())
+	}
+
+	if w.Code != 200 || u.Password != "" {
+		t.Errorf("Expected 200 without a password Got %d %s\n", w.Code, w.Body)
+	}
+}
+
+// verifiedStore is a recordingStore whose users have verified their email
+type verifiedStore struct {
+	recordingStore
+}
+
+func (s verifiedStore) WithContext(ctx context.Context) store.Store {
+	return s
+}
+
+func (s verifiedStore) Users() store.UserStore {
+	return verifiedUsersStore{recordingUsersStore{created: s.created, saved: s.saved}}
+}
+
+type verifiedUsersStore struct {
+	recordingUsersStore
+}
+
+func (s verifiedUsersStore) Get(u *models.User) error {
+	err := s.recordingUsersStore.Get(u)
+	u.EmailVerified = true
+	return err
+}
+
+func TestUpdateUserEmail(t *testing.T) {
+	n := &recordingNotifier{}
+	oldNotifier := Notifier
+	Notifier = n
+	defer func() { Notifier = oldNotifier }()
+
+	var saved models.User
+
+	old := Store
+	Store = verifiedStore{recordingStore{created: &models.User{}, saved: &saved}}
+	defer func() { Store = old }()
+
+	tests := []struct {
+		email    string
+		verified bool
+	}{
+		{"foo@foo.com", true},
+		{"new@foo.com", false},
+	}
+
+	for _, test := range tests {
+		n.event = models.Event{}
+		byt, _ := json.Marshal(models.User{Username: "foouser", Email: test.email})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", "/users/foouser", bytes.NewBuffer(byt))
+		testLogin(r)
+
+		Router.ServeHTTP(w, r)
+
+		var u models.User
+
+		e := json.Unmarshal(w.Body.Bytes(), &u)
+		if e != nil {
+			t.Fatalf("Failed with error %s\n", e.Error())
+		}
+
+		if saved.EmailVerified != test.verified || u.EmailVerified != test.verified {
+			t.Errorf("%s: Expected verified %t Got %t %t\n", test.email,
+				test.verified, saved.EmailVerified, u.EmailVerified)
+		}
+
+		sent := n.event.Type == models.EventEmailVerification
+		if sent == test.verified {
+			t.Errorf("%s: Expected a verification to be sent %t Got %v\n",
+				test.email, !test.verified, n.event)
+		}
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		login func(*http.Request)
+		code  int
+	}{
+		{"self", "/users/foouser/profile", testLogin, 200},
+		{"admin", "/users/otheruser/profile", testAdminLogin, 403},
+		{"other", "/users/otheruser/profile", testLogin, 403},
+		{"anonymous", "/users/foouser/profile", func(*http.Request) {}, 403},
+	}
+
+	for _, test := range tests {
+		byt, _ := json.Marshal(profileRequest{
+			DisplayName: "Foo",
+			AvatarURL:   "https://example.com/foo.png",
+			Bio:         "Just a foo.",
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("PUT", test.path, bytes.NewBuffer(byt))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		if w.Code != 200 {
+			continue
+		}
+
+		var u models.User
+
+		e := json.Unmarshal(w.Body.Bytes(), &u)
+		if e != nil {
+			t.Errorf("Failed with error %s\n", e.Error())
+		}
+
+		if u.DisplayName != "Foo" || u.Bio != "Just a foo." {
+			t.Errorf("Expected the profile to be updated Got %v\n", u)
+		}
+
+		if u.Password != "" {
+			t.Error("Expected no password to be returned but instead got a password.")
+		}
+	}
+
+	byt, _ := json.Marshal(profileRequest{AvatarURL: strings.Repeat("a", 251)})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/users/foouser/profile", bytes.NewBuffer(byt))
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for a long avatar_url Got %d\n", w.Code)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		login func(*http.Request)
+		old   string
+		code  int
+	}{
+		{"self", "/users/foouser/password", testLogin, "foopass", 200},
+		{"wrong old password", "/users/foouser/password", testLogin, "wrong", 401},
+		{"admin", "/users/otheruser/password", testAdminLogin, "foopass", 403},
+		{"other", "/users/otheruser/password", testLogin, "foopass", 403},
+		{"anonymous", "/users/foouser/password", func(*http.Request) {}, "foopass", 403},
+	}
+
+	for _, test := range tests {
+		var saved models.User
+
+		old := Store
+		Store = recordingStore{created: &models.User{}, saved: &saved}
+
+		byt, _ := json.Marshal(passwordRequest{
+			OldPassword: test.old,
+			NewPassword: "newpass123",
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", test.path, bytes.NewBuffer(byt))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+		Store = old
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		if test.code != 200 {
+			if saved.ID != 0 {
+				t.Errorf("%s: Expected the user not to be saved\n", test.name)
+			}
+
+			continue
+		}
+
+		if !saved.CheckPw([]byte("newpass123")) {
+			t.Errorf("%s: Expected the new password to be saved Got %q\n",
+				test.name, saved.Password)
+		}
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		login func(*http.Request)
+		code  int
+	}{
+		{"self", "/users/foouser", testLogin, 200},
+		{"admin", "/users/otheruser", testAdminLogin, 200},
+		{"other", "/users/otheruser", testLogin, 403},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("DELETE", test.path, nil)
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+}
+
+// TestRenamedUserToken checks a token issued to a user who has since renamed
+// themselves can't change whoever took their old username.
+func TestRenamedUserToken(t *testing.T) {
+	token, err := mw.JWTSignUser(models.User{ID: 2, Username: "foouser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"PUT", "/users/foouser", `{"email":"taken@foo.com"}`},
+		{"PUT", "/users/foouser/profile", `{"bio":"taken"}`},
+		{"POST", "/users/foouser/password",
+			`{"old_password":"foopass","new_password":"takenpass1"}`},
+		{"DELETE", "/users/foouser", ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		r.Header.Add("Authorization", "Bearer "+token)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != 403 {
+			t.Errorf("%s %s: Expected 403 Got %d\n", test.method, test.path, w.Code)
+		}
+	}
+}
+
+func TestSavedFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		login  func(*http.Request)
+		code   int
+	}{
+		{"list", "GET", "/users/foouser/filters", "", testLogin, 200},
+		{"list anonymous", "GET", "/users/foouser/filters", "", func(*http.Request) {}, 401},
+		{"list other", "GET", "/users/otheruser/filters", "", testLogin, 403},
+		{"save", "POST", "/users/foouser/filters", `{"name":"mine","filter":{"status_id":1}}`, testLogin, 200},
+		{"save without name", "POST", "/users/foouser/filters", `{"filter":{"status_id":1}}`, testLogin, 400},
+		{"run", "GET", "/users/foouser/filters/mine/tickets", "", testLogin, 200},
+		{"run missing", "GET", "/users/foouser/filters/nope/tickets", "", testLogin, 404},
+		{"delete", "DELETE", "/users/foouser/filters/mine", "", testLogin, 200},
+		{"delete missing", "DELETE", "/users/foouser/filters/nope", "", testLogin, 404},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path, bytes.NewBufferString(test.body))
+		test.login(r)
+
+		Router.ServeHTTP(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d\n", test.name, test.code, w.Code)
+		}
+
+		t.Log(w.Body)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/users/foouser/filters/mine/tickets", nil)
+	testLogin(r)
+
+	Router.ServeHTTP(w, r)
+
+	var tks []models.Ticket
+
+	e := json.Unmarshal(w.Body.Bytes(), &tks)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(tks) == 0 {
+		t.Errorf("Expected the filter's tickets Got %v\n", tks)
+	}
+}
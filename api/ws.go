@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/mw"
+	"github.com/praelatus/backend/notify"
+	"github.com/praelatus/backend/store"
+)
+
+// Events receives the ticket events sent by Store and passes them on to the
+// websockets watching each project.
+var Events = notify.NewHub()
+
+const (
+	// wsWriteWait is how long writing a message to a websocket may take
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long a websocket may go without answering a ping
+	// before it's closed
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod is how often websockets are pinged, it must be less than
+	// wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+// upgrader accepts websockets from any origin, they are authenticated with a
+// token rather than cookies so another site can't open one as the user.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+func initWSRoutes() {
+	Router.Handle("/ws/projects/{pkey}", mw.Default(mw.RequireAuth(ProjectEvents))).Methods("GET")
+}
+
+// ProjectEvents will upgrade the request to a websocket and send the ticket
+// events for the project as json messages until the client disconnects.
+// Browsers can't set the Authorization header for a websocket so the token
+// may be sent as the token query parameter instead.
+func ProjectEvents(w http.ResponseWriter, r *http.Request) {
+
+	p := models.Project{Key: mux.Vars(r)["pkey"]}
+
+	err := reqStore(r).Projects().Get(&p)
+	if err != nil {
+		if err == store.ErrNotFound {
+			w.WriteHeader(404)
+			w.Write(apiError("project not found"))
+			return
+		}
+
+		w.WriteHeader(500)
+		w.Write(apiError(err.Error()))
+		logError(r, err)
+		return
+	}
+
+	// Subscribing before the upgrade means no event is missed once the
+	// client's handshake has finished.
+	events, cancel := Events.Subscribe(p.Key)
+	defer cancel()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already responded with the error.
+		logError(r, err)
+		return
+	}
+
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go readUntilClosed(conn, closed)
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case e := <-events:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+
+			err = conn.WriteJSON(publicEvent(e))
+			if err != nil {
+				return
+			}
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil,
+				time.Now().Add(wsWriteWait))
+			if err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// readUntilClosed reads from the websocket, answering pings and extending
+// the read deadline when a pong arrives, and closes closed once the client
+// disconnects or stops answering. Clients aren't expected to send anything
+// else so other messages are discarded.
+func readUntilClosed(conn *websocket.Conn, closed chan struct{}) {
+	defer close(closed)
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, _, err := conn.NextReader()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// publicEvent returns e without the password hashes of it's users
+func publicEvent(e models.Event) models.Event {
+	e.Actor.Password = ""
+	e.Ticket.Reporter.Password = ""
+	e.Ticket.Assignee.Password = ""
+
+	if e.Comment != nil {
+		c := *e.Comment
+		c.Author.Password = ""
+		e.Comment = &c
+	}
+
+	return e
+}
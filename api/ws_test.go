@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/notify"
+)
+
+// wsURL returns the websocket url for path on the test server
+func wsURL(s *httptest.Server, path string) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + path
+}
+
+func TestProjectEvents(t *testing.T) {
+	old := Store
+	Store = notify.Store(mockStore{}, Events)
+	defer func() { Store = old }()
+
+	s := httptest.NewServer(Router)
+	defer s.Close()
+
+	login := httptest.NewRequest("GET", "/", nil)
+	testLogin(login)
+	token := strings.TrimPrefix(login.Header.Get("Authorization"), "Bearer ")
+
+	// The token is sent as a query parameter the way a browser would.
+	conn, _, err := websocket.DefaultDialer.Dial(
+		wsURL(s, "/ws/projects/TEST?token="+token), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	byt, _ := json.Marshal(models.Ticket{Summary: "Live"})
+	r, _ := http.NewRequest("POST", s.URL+"/tickets/TEST", bytes.NewReader(byt))
+	r.Header.Set("Authorization", login.Header.Get("Authorization"))
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var e models.Event
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	err = conn.ReadJSON(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Type != models.EventTicketCreated || e.ProjectKey != "TEST" ||
+		e.Ticket.Summary != "Live" {
+		t.Errorf("Expected the new ticket in TEST Got %v", e)
+	}
+
+	if e.Actor.Password != "" {
+		t.Errorf("Expected the password to be removed Got %v", e.Actor)
+	}
+}
+
+func TestProjectEventsAnonymous(t *testing.T) {
+	s := httptest.NewServer(Router)
+	defer s.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(s, "/ws/projects/TEST"), nil)
+	if err == nil {
+		t.Fatal("Expected the upgrade to be refused")
+	}
+
+	if resp == nil || resp.StatusCode != 401 {
+		t.Errorf("Expected 401 Got %v", resp)
+	}
+}
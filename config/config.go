@@ -1,6 +1,11 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // GetDbURL will return the environment variable PRAELATUS_DB if set, otherwise
 // return the default development database url.
@@ -13,6 +18,28 @@ func GetDbURL() string {
 	return url
 }
 
+// GetStoreBackend will return the environment variable PRAELATUS_STORE if
+// set, otherwise return the default store backend postgres.
+func GetStoreBackend() string {
+	backend := os.Getenv("PRAELATUS_STORE")
+	if backend == "" {
+		return "postgres"
+	}
+
+	return backend
+}
+
+// GetBlobDir will return the environment variable PRAELATUS_BLOB_DIR if set,
+// otherwise return the default directory for storing attachments.
+func GetBlobDir() string {
+	dir := os.Getenv("PRAELATUS_BLOB_DIR")
+	if dir == "" {
+		return "attachments"
+	}
+
+	return dir
+}
+
 // IsDevEnv will return a boolean indicating whether the app is runnning in dev
 // mode or not
 func IsDevEnv() bool {
@@ -23,3 +50,146 @@ func IsDevEnv() bool {
 
 	return true
 }
+
+// GetJWTTTL will return the duration in the environment variable
+// PRAELATUS_JWT_TTL if set and valid, otherwise return the default lifetime
+// of fifteen minutes for signed tokens, sessions last longer by using their
+// refresh token.
+func GetJWTTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("PRAELATUS_JWT_TTL"))
+	if err != nil || ttl <= 0 {
+		return 15 * time.Minute
+	}
+
+	return ttl
+}
+
+// GetRefreshTokenTTL will return the duration in the environment variable
+// PRAELATUS_REFRESH_TOKEN_TTL if set and valid, otherwise return the default
+// of thirty days for refresh tokens.
+func GetRefreshTokenTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("PRAELATUS_REFRESH_TOKEN_TTL"))
+	if err != nil || ttl <= 0 {
+		return 30 * 24 * time.Hour
+	}
+
+	return ttl
+}
+
+// GetRateLimit will return the number of requests per minute in the
+// environment variable PRAELATUS_RATE_LIMIT if set and valid, otherwise
+// return the default of 10 requests per minute for rate limited routes.
+func GetRateLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("PRAELATUS_RATE_LIMIT"))
+	if err != nil || limit <= 0 {
+		return 10
+	}
+
+	return limit
+}
+
+// GetRateBurst will return the environment variable PRAELATUS_RATE_BURST if
+// set and valid, otherwise return the default of 5 requests which may be made
+// at once before rate limiting starts.
+func GetRateBurst() int {
+	burst, err := strconv.Atoi(os.Getenv("PRAELATUS_RATE_BURST"))
+	if err != nil || burst <= 0 {
+		return 5
+	}
+
+	return burst
+}
+
+// GetLoginAttempts will return the environment variable
+// PRAELATUS_LOGIN_ATTEMPTS if set and valid, otherwise return the default of
+// 5 failed logins before an account is locked out.
+func GetLoginAttempts() int {
+	attempts, err := strconv.Atoi(os.Getenv("PRAELATUS_LOGIN_ATTEMPTS"))
+	if err != nil || attempts <= 0 {
+		return 5
+	}
+
+	return attempts
+}
+
+// GetLockoutWindow will return the duration in the environment variable
+// PRAELATUS_LOCKOUT_WINDOW if set and valid, otherwise return the default of
+// fifteen minutes for which a locked out account can't log in.
+func GetLockoutWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("PRAELATUS_LOCKOUT_WINDOW"))
+	if err != nil || window <= 0 {
+		return 15 * time.Minute
+	}
+
+	return window
+}
+
+// GetCORSOrigins will return the comma separated origins in the environment
+// variable PRAELATUS_CORS_ORIGINS, an origin of * allows every origin. If it
+// is not set no cross origin requests are allowed.
+func GetCORSOrigins() []string {
+	var origins []string
+
+	for _, o := range strings.Split(os.Getenv("PRAELATUS_CORS_ORIGINS"), ",") {
+		o = strings.TrimSpace(o)
+		if o != "" {
+			origins = append(origins, o)
+		}
+	}
+
+	return origins
+}
+
+// GetCORSCredentials will return a boolean indicating whether cross origin
+// requests may send credentials, set by PRAELATUS_CORS_CREDENTIALS.
+func GetCORSCredentials() bool {
+	return os.Getenv("PRAELATUS_CORS_CREDENTIALS") != ""
+}
+
+// GetUserCacheSize will return the number of users in the environment variable
+// PRAELATUS_USER_CACHE_SIZE if set and valid, otherwise return the default of
+// 1000 users kept in the user cache. A size of 0 disables the cache.
+func GetUserCacheSize() int {
+	size, err := strconv.Atoi(os.Getenv("PRAELATUS_USER_CACHE_SIZE"))
+	if err != nil || size < 0 {
+		return 1000
+	}
+
+	return size
+}
+
+// GetUserCacheTTL will return the duration in the environment variable
+// PRAELATUS_USER_CACHE_TTL if set and valid, otherwise return the default of
+// one minute for how long a cached user is used before it is fetched again.
+func GetUserCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("PRAELATUS_USER_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return time.Minute
+	}
+
+	return ttl
+}
+
+// GetJWTAlgorithm will return the environment variable PRAELATUS_JWT_ALG if
+// set, otherwise return the default algorithm HS256 for signing tokens.
+func GetJWTAlgorithm() string {
+	alg := os.Getenv("PRAELATUS_JWT_ALG")
+	if alg == "" {
+		return "HS256"
+	}
+
+	return strings.ToUpper(alg)
+}
+
+// GetJWTSecret will return the environment variable PRAELATUS_JWT_SECRET, the
+// shared secret HS256 tokens are signed with. There is no default.
+func GetJWTSecret() string {
+	return os.Getenv("PRAELATUS_JWT_SECRET")
+}
+
+// GetJWTKeyFile will return the environment variable PRAELATUS_JWT_KEY_FILE,
+// the path of the PEM encoded RSA private key RS256 tokens are signed with or
+// of a file holding the HS256 secret. There is no default.
+func GetJWTKeyFile() string {
+	return os.Getenv("PRAELATUS_JWT_KEY_FILE")
+}
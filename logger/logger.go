@@ -0,0 +1,43 @@
+// Package logger provides a small levelled logging interface so the api and
+// stores can have their log output captured in tests or sent somewhere other
+// than the standard logger.
+package logger
+
+import (
+	"log"
+
+	"github.com/praelatus/backend/config"
+)
+
+// Logger is used to log messages at different levels, the arguments are
+// handled the same as log.Println.
+type Logger interface {
+	Debug(v ...interface{})
+	Info(v ...interface{})
+	Error(v ...interface{})
+}
+
+// Std is a Logger which writes to the standard logger with the level as a
+// prefix, debug messages are only written in dev mode.
+type Std struct{}
+
+func (s Std) print(level string, v []interface{}) {
+	log.Println(append([]interface{}{level}, v...)...)
+}
+
+// Debug logs v if config.IsDevEnv is true
+func (s Std) Debug(v ...interface{}) {
+	if config.IsDevEnv() {
+		s.print("DEBUG", v)
+	}
+}
+
+// Info logs v
+func (s Std) Info(v ...interface{}) {
+	s.print("INFO", v)
+}
+
+// Error logs v
+func (s Std) Error(v ...interface{}) {
+	s.print("ERROR", v)
+}
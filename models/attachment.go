@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Attachment is the metadata for a file attached to a ticket, the contents of
+// the file are kept in a BlobStore under StorageKey.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	CreatedDate time.Time `json:"created_date"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"storage_key"`
+	UploadedBy  User      `json:"uploaded_by"`
+}
+
+func (a *Attachment) String() string {
+	return jsonString(a)
+}
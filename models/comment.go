@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"regexp"
+	"strings"
+	"time"
+)
 
 // Comment is a comment on an issue / ticket.
 type Comment struct {
@@ -9,8 +13,54 @@ type Comment struct {
 	CreatedDate time.Time `json:"created_date"`
 	Body        string    `json:"body"`
 	Author      User      `json:"author"`
+
+	// Reactions is the number of users who reacted to the comment with each
+	// emoji.
+	Reactions map[string]int `json:"reactions,omitempty"`
+
+	// Mentions are the users mentioned in the body, it is filled in when the
+	// comment is created.
+	Mentions []User `json:"mentions,omitempty"`
+
+	// UpdatedBy is the user editing the comment, it is recorded in the
+	// comment's revisions and never read from or written to json.
+	UpdatedBy User `json:"-"`
 }
 
 func (c *Comment) String() string {
 	return jsonString(c)
 }
+
+// mention matches an @ which is not preceded by a word character, so email
+// addresses are not treated as mentions, followed by a username.
+var mention = regexp.MustCompile(`(?:^|[^\w@])@(\w[\w.-]*)`)
+
+// ExtractMentions will return the usernames mentioned in body with @username
+// in the order they first appear, each username is only returned once.
+func ExtractMentions(body string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+
+	for _, m := range mention.FindAllStringSubmatch(body, -1) {
+		// punctuation at the end of a sentence is not part of the username
+		u := strings.TrimRight(m[1], ".-")
+		if !seen[u] {
+			seen[u] = true
+			usernames = append(usernames, u)
+		}
+	}
+
+	return usernames
+}
+
+// CommentRevision is the body of a comment before it was edited.
+type CommentRevision struct {
+	ID       int64     `json:"id"`
+	OldBody  string    `json:"old_body"`
+	EditedAt time.Time `json:"edited_at"`
+	Editor   User      `json:"editor"`
+}
+
+func (r *CommentRevision) String() string {
+	return jsonString(r)
+}
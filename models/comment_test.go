@@ -0,0 +1,29 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := map[string][]string{
+		"@user":                          {"user"},
+		"hey @user, take a look":         {"user"},
+		"thanks @user.":                  {"user"},
+		"@first.last please review":      {"first.last"},
+		"@one and @two and @one again":   {"one", "two"},
+		"(@user) and [@other]":           {"user", "other"},
+		"mail email@domain.com for help": nil,
+		"no mentions here":               nil,
+		"@ alone":                        nil,
+		"@@user":                         nil,
+		"line one\n@user on line two":    {"user"},
+	}
+
+	for body, expected := range tests {
+		got := ExtractMentions(body)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q: Expected %v Got %v", body, expected, got)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+// Event types describe what happened in an Event.
+const (
+	EventTicketCreated      = "ticket_created"
+	EventTicketUpdated      = "ticket_updated"
+	EventTicketTransitioned = "ticket_transitioned"
+	EventTicketAssigned     = "ticket_assigned"
+	EventCommentAdded       = "comment_added"
+	EventPasswordReset      = "password_reset"
+	EventEmailVerification  = "email_verification"
+)
+
+// Event is something that happened which users may want to be notified of.
+type Event struct {
+	Type    string   `json:"type"`
+	Actor   User     `json:"actor"`
+	Ticket  Ticket   `json:"ticket"`
+	Comment *Comment `json:"comment,omitempty"`
+
+	// ProjectKey is the key of the project the ticket belongs to, it's set
+	// for every ticket event.
+	ProjectKey string `json:"project_key,omitempty"`
+
+	// Data holds extra values specific to the event type, for example the
+	// token for a password reset, it is never written to json.
+	Data map[string]string `json:"-"`
+}
+
+func (e *Event) String() string {
+	return jsonString(e)
+}
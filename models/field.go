@@ -1,6 +1,12 @@
 package models
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
 
 // ErrInvalidDataType indicates that the field was created with an incorrect
 // data type
@@ -13,6 +19,8 @@ var DataTypes = []string{
 	"INT",
 	"DATE",
 	"OPT",
+	"BOOL",
+	"MULTI_OPT",
 }
 
 // Field is a ticket field
@@ -21,6 +29,10 @@ type Field struct {
 	Name     string      `json:"name"`
 	DataType string      `json:"data_type"`
 	Options  FieldOption `json:"options,omitempty"`
+
+	// Required is set when the field is added to a project and tickets of the
+	// types it was added for must have a value for it.
+	Required bool `json:"required,omitempty"`
 }
 
 // FieldOption is used as the value for FieldValues which are selects.
@@ -54,3 +66,149 @@ func (f *Field) IsValidDataType() bool {
 
 	return false
 }
+
+// FieldValueError is returned by ValidateField when a value does not match
+// the data type of it's field.
+type FieldValueError struct {
+	Name     string
+	DataType string
+	Value    interface{}
+}
+
+func (e *FieldValueError) Error() string {
+	if e.DataType == "OPT" || e.DataType == "MULTI_OPT" {
+		if fo, ok := e.Value.(FieldOption); ok {
+			return fmt.Sprintf("%q is not an option for field %s, expected one of %v",
+				fo.Selected, e.Name, fo.Options)
+		}
+	}
+
+	return fmt.Sprintf("%v is not a valid %s value for field %s",
+		e.Value, e.DataType, e.Name)
+}
+
+// ValidateField checks that the value of fv matches it's data type, a nil
+// value is always valid since it clears the field. INT values can be any
+// number without a fractional part and FLOAT any number, since values decoded
+// from json are float64. DATE values must be a time.Time or an RFC3339 string
+// OPT values a FieldOption whose Selected value is one of it's Options and
+// BOOL values a bool. MULTI_OPT values must be a list of strings, use
+// ValidateSelections to check them against the field's options.
+func ValidateField(fv FieldValue) error {
+	f := Field{DataType: fv.DataType}
+	if !f.IsValidDataType() {
+		return ErrInvalidDataType
+	}
+
+	if fv.Value == nil {
+		return nil
+	}
+
+	var ok bool
+
+	switch fv.DataType {
+	case "STRING":
+		_, ok = fv.Value.(string)
+	case "INT":
+		n, isNum := toFloat(fv.Value)
+		ok = isNum && n == math.Trunc(n)
+	case "FLOAT":
+		_, ok = toFloat(fv.Value)
+	case "DATE":
+		switch d := fv.Value.(type) {
+		case time.Time:
+			ok = true
+		case string:
+			_, err := time.Parse(time.RFC3339, d)
+			ok = err == nil
+		}
+	case "OPT":
+		var fo FieldOption
+		fo, ok = fv.Value.(FieldOption)
+		ok = ok && fo.isOption(fo.Selected)
+	case "BOOL":
+		_, ok = fv.Value.(bool)
+	case "MULTI_OPT":
+		_, ok = Selections(fv.Value)
+	}
+
+	if !ok {
+		return &FieldValueError{fv.Name, fv.DataType, fv.Value}
+	}
+
+	return nil
+}
+
+// ValidateSelections checks that a MULTI_OPT value is valid and that every
+// value selected is one of options.
+func ValidateSelections(fv FieldValue, options []string) error {
+	err := ValidateField(fv)
+	if err != nil || fv.Value == nil {
+		return err
+	}
+
+	fo := FieldOption{Options: options}
+	selected, _ := Selections(fv.Value)
+	for _, s := range selected {
+		if !fo.isOption(s) {
+			fo.Selected = s
+			return &FieldValueError{fv.Name, fv.DataType, fo}
+		}
+	}
+
+	return nil
+}
+
+// Selections returns the values selected in a MULTI_OPT value, values decoded
+// from json are a []interface{} so both it and []string are accepted.
+func Selections(v interface{}) ([]string, bool) {
+	switch s := v.(type) {
+	case []string:
+		return s, true
+	case []interface{}:
+		selected := make([]string, len(s))
+		for i := range s {
+			str, ok := s[i].(string)
+			if !ok {
+				return nil, false
+			}
+
+			selected[i] = str
+		}
+
+		return selected, true
+	}
+
+	return nil, false
+}
+
+func (fo FieldOption) isOption(opt string) bool {
+	for _, o := range fo.Options {
+		if o == opt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// toFloat converts any of the numeric types to a float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
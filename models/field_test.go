@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestValidateField(t *testing.T) {
+	priority := []string{"HIGH", "MEDIUM", "LOW"}
+
+	tests := []struct {
+		dataType string
+		value    interface{}
+		valid    bool
+	}{
+		{"STRING", "a string", true},
+		{"STRING", 3, false},
+		{"INT", 3, true},
+		{"INT", float64(3), true},
+		{"INT", json.Number("3"), true},
+		{"INT", 3.5, false},
+		{"INT", "3", false},
+		{"FLOAT", 3.5, true},
+		{"FLOAT", 3, true},
+		{"FLOAT", "3.5", false},
+		{"DATE", time.Now(), true},
+		{"DATE", "2017-01-02T15:04:05Z", true},
+		{"DATE", "January 2nd", false},
+		{"DATE", 20170102, false},
+		{"OPT", FieldOption{Selected: "HIGH", Options: priority}, true},
+		{"OPT", FieldOption{Selected: "URGENT", Options: priority}, false},
+		{"OPT", "HIGH", false},
+		{"BOOL", true, true},
+		{"BOOL", false, true},
+		{"BOOL", "true", false},
+		{"BOOL", 1, false},
+		{"MULTI_OPT", []string{"HIGH", "LOW"}, true},
+		{"MULTI_OPT", []interface{}{"HIGH", "LOW"}, true},
+		{"MULTI_OPT", []interface{}{"HIGH", 3}, false},
+		{"MULTI_OPT", "HIGH", false},
+		{"INT", nil, true},
+	}
+
+	for _, tc := range tests {
+		fv := FieldValue{Name: "Test Field", DataType: tc.dataType, Value: tc.value}
+
+		err := ValidateField(fv)
+		if tc.valid && err != nil {
+			t.Errorf("%s %v: Expected no error Got %v", tc.dataType, tc.value, err)
+		}
+
+		if !tc.valid {
+			if _, ok := err.(*FieldValueError); !ok {
+				t.Errorf("%s %v: Expected a FieldValueError Got %v",
+					tc.dataType, tc.value, err)
+			}
+		}
+	}
+
+	err := ValidateField(FieldValue{DataType: "NOT_A_TYPE", Value: 3})
+	if err != ErrInvalidDataType {
+		t.Errorf("Expected ErrInvalidDataType Got %v", err)
+	}
+}
+
+func TestValidateSelections(t *testing.T) {
+	components := []string{"api", "store", "ui"}
+
+	fv := FieldValue{Name: "Components", DataType: "MULTI_OPT",
+		Value: []string{"api", "store"}}
+
+	err := ValidateSelections(fv, components)
+	if err != nil {
+		t.Errorf("Expected no error Got %v", err)
+	}
+
+	fv.Value = []string{"api", "docs"}
+
+	err = ValidateSelections(fv, components)
+	fe, ok := err.(*FieldValueError)
+	if !ok {
+		t.Fatalf("Expected a FieldValueError Got %v", err)
+	}
+
+	if fo, _ := fe.Value.(FieldOption); fo.Selected != "docs" {
+		t.Errorf("Expected docs to be the invalid selection Got %v", fe.Value)
+	}
+}
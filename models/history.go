@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// HistoryEntry records a single change to a field on a ticket.
+type HistoryEntry struct {
+	ID          int64     `json:"id"`
+	CreatedDate time.Time `json:"created_date"`
+	Field       string    `json:"field"`
+	OldValue    string    `json:"old_value"`
+	NewValue    string    `json:"new_value"`
+	User        User      `json:"user"`
+}
+
+func (h *HistoryEntry) String() string {
+	return jsonString(h)
+}
+
+// Worklog records time spent working on a ticket, Seconds is the amount of
+// time logged.
+type Worklog struct {
+	ID          int64     `json:"id"`
+	CreatedDate time.Time `json:"created_date"`
+	Seconds     int64     `json:"seconds"`
+	Author      User      `json:"author"`
+}
+
+func (w *Worklog) String() string {
+	return jsonString(w)
+}
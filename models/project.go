@@ -12,6 +12,25 @@ const (
 	UserR                  = "USER"
 )
 
+// permissionRanks orders the permission levels, a level includes every level
+// ranked below it.
+var permissionRanks = map[PermissionLevel]int{
+	UserR:  1,
+	CoreR:  2,
+	AdminR: 3,
+}
+
+// Valid reports whether l is one of the permission levels.
+func (l PermissionLevel) Valid() bool {
+	return permissionRanks[l] > 0
+}
+
+// Includes reports whether a user with the permission level l has at least
+// the permission level other.
+func (l PermissionLevel) Includes(other PermissionLevel) bool {
+	return l.Valid() && permissionRanks[l] >= permissionRanks[other]
+}
+
 // Project is the model used to represent a project in the database.
 type Project struct {
 	ID          int64     `json:"id"`
@@ -21,6 +40,7 @@ type Project struct {
 	Homepage    string    `json:"homepage"`
 	IconURL     string    `json:"icon_url"`
 	Repo        string    `json:"repo,omitempty"`
+	Archived    bool      `json:"archived"`
 	Lead        User      `json:"lead"`
 	Team        User      `json:"team"`
 }
@@ -29,6 +49,12 @@ func (p *Project) String() string {
 	return jsonString(p)
 }
 
+// ProjectMember is a user who is a member of a project with the given role.
+type ProjectMember struct {
+	User User            `json:"user"`
+	Role PermissionLevel `json:"role"`
+}
+
 // Permission is used to control user / team access to projects.
 type Permission struct {
 	ID          int64           `json:"id"`
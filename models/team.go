@@ -1,9 +1,15 @@
 package models
 
+import (
+	"regexp"
+	"strings"
+)
+
 // Team maps directly to the teams database table.
 type Team struct {
 	ID      int64  `json:"id"`
 	Name    string `json:"name"`
+	URLSlug string `json:"url_slug"`
 	Lead    User   `json:"lead"`
 	Members []User `json:"members,omitempty"`
 }
@@ -11,3 +17,11 @@ type Team struct {
 func (t *Team) String() string {
 	return jsonString(t)
 }
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify will convert name into a lower case string containing only letters,
+// numbers and dashes which is suitable for use in a url.
+func Slugify(name string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
 
 // TicketType represents the type of ticket.
 type TicketType struct {
@@ -22,18 +27,146 @@ type Ticket struct {
 	Reporter    User         `json:"reporter"`
 	Assignee    User         `json:"assignee"`
 	Status      Status       `json:"status"`
+	ParentID    int64        `json:"parent_id,omitempty"`
+	Priority    Priority     `json:"priority"`
+
+	// DueDate is the zero time when the ticket has no due date.
+	DueDate time.Time `json:"due_date"`
+
+	// Version is incremented every time the ticket is saved, a save must
+	// send the version it read or it is rejected as stale.
+	Version int `json:"version"`
 
 	Comments []Comment `json:"comments,omitempty"`
+
+	// CommentCount is the number of comments on the ticket, it's filled in
+	// without loading the comments themselves. It's counted when the ticket
+	// is read rather than cached so it can't drift from the comments.
+	CommentCount int `json:"comment_count"`
+
+	// The time tracking fields are in seconds. TimeSpent only changes when
+	// work is logged, which also takes the time off RemainingEstimate.
+	OriginalEstimate  int64 `json:"original_estimate"`
+	TimeSpent         int64 `json:"time_spent"`
+	RemainingEstimate int64 `json:"remaining_estimate"`
+
+	// UpdatedBy is the user making a change to the ticket, it is recorded in
+	// the ticket history and never read from or written to json.
+	UpdatedBy User `json:"-"`
 }
 
 func (t *Ticket) String() string {
 	return jsonString(t)
 }
 
-// Status represents a ticket's current status.
+// Priority is how urgent a ticket is, higher priorities are more urgent.
+type Priority int
+
+// The priorities a ticket can have, PriorityNone is for tickets which
+// haven't been prioritised.
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityCritical
+)
+
+// Valid reports whether p is one of the defined priorities
+func (p Priority) Valid() bool {
+	return p >= PriorityNone && p <= PriorityCritical
+}
+
+// Link types describe how the source of a TicketLink relates to its target.
+const (
+	LinkBlocks     = "blocks"
+	LinkBlockedBy  = "blocked_by"
+	LinkRelatesTo  = "relates_to"
+	LinkDuplicates = "duplicates"
+)
+
+// LinkTypes holds the available link types
+var LinkTypes = []string{
+	LinkBlocks,
+	LinkBlockedBy,
+	LinkRelatesTo,
+	LinkDuplicates,
+}
+
+// inverseLinks maps a link type to the link type created on the target when
+// a link is added, link types not in the map have no inverse.
+var inverseLinks = map[string]string{
+	LinkBlocks:    LinkBlockedBy,
+	LinkBlockedBy: LinkBlocks,
+	LinkRelatesTo: LinkRelatesTo,
+}
+
+var (
+	// ErrInvalidLinkType indicates that a link was created with a link type
+	// we don't support
+	ErrInvalidLinkType = errors.New("Invalid link type for ticket link")
+	// ErrSelfLink indicates that a ticket was linked to itself
+	ErrSelfLink = errors.New("A ticket cannot be linked to itself")
+	// ErrInvalidKey indicates that a ticket key isn't a project key followed
+	// by a number
+	ErrInvalidKey = errors.New("Invalid ticket key, expected a project key followed by a number like ENG-42")
+)
+
+// ticketKey matches a project key followed by the ticket's number, the dash
+// between them is optional since keys are generated without one.
+var ticketKey = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*[0-9]+$`)
+
+// NormalizeKey returns the ticket key in the upper case form tickets are
+// stored with, or ErrInvalidKey if it isn't a valid key.
+func NormalizeKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if !ticketKey.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+
+	return strings.ToUpper(key), nil
+}
+
+// TicketLink represents a relationship between two tickets.
+type TicketLink struct {
+	ID        int64  `json:"id"`
+	LinkType  string `json:"link_type"`
+	SourceID  int64  `json:"source_id"`
+	TargetID  int64  `json:"target_id"`
+	TargetKey string `json:"target_key"`
+}
+
+func (l *TicketLink) String() string {
+	return jsonString(l)
+}
+
+// IsValidLinkType is used to verify that the given link type is one we can
+// support
+func IsValidLinkType(linkType string) bool {
+	for _, t := range LinkTypes {
+		if t == linkType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InverseLinkType returns the link type which should be added from the target
+// back to the source of a link, ok is false if there is none.
+func InverseLinkType(linkType string) (inverse string, ok bool) {
+	inverse, ok = inverseLinks[linkType]
+	return inverse, ok
+}
+
+// Status represents a ticket's current status, tickets in a Closed status
+// are finished with and are never overdue. Position orders the statuses as
+// columns on a board, lowest first.
 type Status struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Closed   bool   `json:"closed"`
+	Position int    `json:"position"`
 }
 
 // Label is a label used on tickets
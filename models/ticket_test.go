@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+		valid    bool
+	}{
+		{"ENG-42", "ENG-42", true},
+		{"eng-42", "ENG-42", true},
+		{"Eng42", "ENG42", true},
+		{" test-1 ", "TEST-1", true},
+		{"MY_PROJ-7", "MY_PROJ-7", true},
+		{"ENG-", "", false},
+		{"42", "", false},
+		{"-42", "", false},
+		{"ENG 42", "", false},
+		{"ENG-42'; --", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		key, err := NormalizeKey(test.key)
+		if test.valid && err != nil {
+			t.Errorf("Expected %q to be valid Got %s", test.key, err)
+		}
+
+		if !test.valid && err != ErrInvalidKey {
+			t.Errorf("Expected %q to be invalid Got %q", test.key, key)
+		}
+
+		if key != test.expected {
+			t.Errorf("Expected %q Got %q", test.expected, key)
+		}
+	}
+}
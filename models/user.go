@@ -3,7 +3,9 @@ package models
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"strings"
+	"unicode"
 
 	"log"
 
@@ -22,6 +24,44 @@ type User struct {
 	IsAdmin    bool     `json:"is_admin,omitempty"`
 	IsActive   bool     `json:"is_active,omitempty"`
 	Settings   Settings `json:"settings"`
+
+	// EmailVerified is set once the user has followed the verification
+	// token sent to their email when they signed up.
+	EmailVerified bool `json:"email_verified"`
+
+	// DisplayName, AvatarURL and Bio make up the user's profile, AvatarURL
+	// is shown instead of ProfilePic when it's set.
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Bio         string `json:"bio"`
+}
+
+// MaxProfileLength is the longest DisplayName or AvatarURL ValidateProfile
+// will accept
+const MaxProfileLength = 250
+
+var (
+	// ErrDisplayNameTooLong is returned by ValidateProfile when the display
+	// name is longer than MaxProfileLength
+	ErrDisplayNameTooLong = errors.New("display_name must be at most 250 characters")
+
+	// ErrAvatarURLTooLong is returned by ValidateProfile when the avatar url
+	// is longer than MaxProfileLength
+	ErrAvatarURLTooLong = errors.New("avatar_url must be at most 250 characters")
+)
+
+// ValidateProfile will check that the user's profile fits in the columns it
+// is stored in
+func (u *User) ValidateProfile() error {
+	if len([]rune(u.DisplayName)) > MaxProfileLength {
+		return ErrDisplayNameTooLong
+	}
+
+	if len([]rune(u.AvatarURL)) > MaxProfileLength {
+		return ErrAvatarURLTooLong
+	}
+
+	return nil
 }
 
 // CheckPw will verify if the given password matches for this user. Logs any
@@ -40,6 +80,54 @@ func (u *User) String() string {
 	return jsonString(u)
 }
 
+// SetPassword will encrypt the given password with bcrypt and set it as the
+// users password
+func (u *User) SetPassword(password string) error {
+	pw, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	u.Password = string(pw)
+	return nil
+}
+
+// MinPasswordLength is the shortest password ValidatePassword will accept
+const MinPasswordLength = 8
+
+var (
+	// ErrPasswordTooShort is returned by ValidatePassword when the password
+	// is shorter than MinPasswordLength
+	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
+
+	// ErrPasswordTooSimple is returned by ValidatePassword when the password
+	// does not mix letters with numbers or symbols
+	ErrPasswordTooSimple = errors.New("password must contain a letter and a number or symbol")
+)
+
+// ValidatePassword will check that the plaintext password is strong enough to
+// be set for a user
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+
+	var letter, other bool
+	for _, c := range password {
+		if unicode.IsLetter(c) {
+			letter = true
+		} else if !unicode.IsSpace(c) {
+			other = true
+		}
+	}
+
+	if !letter || !other {
+		return ErrPasswordTooSimple
+	}
+
+	return nil
+}
+
 // NewUser will create the user after encrypting the password with bcrypt
 func NewUser(username, password, fullName, email string, admin bool) (*User, error) {
 	pw, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
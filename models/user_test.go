@@ -0,0 +1,47 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	tests := map[string]error{
+		"":              ErrPasswordTooShort,
+		"abc123":        ErrPasswordTooShort,
+		"longenough":    ErrPasswordTooSimple,
+		"12345678":      ErrPasswordTooSimple,
+		"long enough":   ErrPasswordTooSimple,
+		"grumpy123":     nil,
+		"correct-horse": nil,
+	}
+
+	for pw, expected := range tests {
+		err := ValidatePassword(pw)
+		if err != expected {
+			t.Errorf("%q: Expected %v Got %v", pw, expected, err)
+		}
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	long := strings.Repeat("é", MaxProfileLength+1)
+
+	tests := []struct {
+		user     User
+		expected error
+	}{
+		{User{DisplayName: "Foo", AvatarURL: "https://example.com/foo.png"}, nil},
+		{User{DisplayName: strings.Repeat("é", MaxProfileLength)}, nil},
+		{User{DisplayName: long}, ErrDisplayNameTooLong},
+		{User{AvatarURL: long}, ErrAvatarURLTooLong},
+		{User{Bio: long}, nil},
+	}
+
+	for _, test := range tests {
+		err := test.user.ValidateProfile()
+		if err != test.expected {
+			t.Errorf("%v: Expected %v Got %v", test.user, test.expected, err)
+		}
+	}
+}
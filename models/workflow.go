@@ -1,9 +1,12 @@
 package models
 
-// Workflow is the container for issues and keeps track of available transitions
+// Workflow is the container for issues and keeps track of available
+// transitions, if TicketType is not set the workflow applies to all ticket
+// types in it's project
 type Workflow struct {
 	ID          int64                   `json:"id"`
 	Name        string                  `json:"name"`
+	TicketType  TicketType              `json:"ticket_type"`
 	Transitions map[string][]Transition `json:"transitions"`
 }
 
@@ -3,55 +3,36 @@ package mw
 import (
 	"context"
 	"crypto/rand"
-	"encoding/json"
-	"errors"
+	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	gcontext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/praelatus/backend/config"
 	"github.com/praelatus/backend/models"
 )
 
-// used to store our jwt secret key
-var secretKey []byte
-
-func init() {
-	if _, err := os.Stat("./.jwt_secret.key"); err == nil {
-		keyBytes, err := ioutil.ReadFile("./.jwt_secret.key")
-		if err != nil {
-			fmt.Println("Error reading keyfile", err)
-			os.Exit(1)
-		}
-
-		secretKey = keyBytes
-		return
-	}
-
-	b := make([]byte, 256)
-	_, err := rand.Read(b)
-	if err != nil {
-		fmt.Println("Error generating key", err)
-		os.Exit(1)
-	}
-
-	err = ioutil.WriteFile("./.jwt_secret.key", b, 0600)
-	if err != nil {
-		fmt.Println("Error writing keyfile", err)
-		os.Exit(1)
-	}
-
-	secretKey = b
+// isWebSocketUpgrade reports whether r is asking to be upgraded to a
+// websocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
 }
 
-// getToken will parse the token out of the headers for the given http.Request
+// getToken will parse the token out of the headers for the given http.Request,
+// browsers can't set headers when opening a websocket so for upgrades the
+// token can be sent in the token query parameter instead.
 func getToken(r *http.Request) string {
 	var tokenStr string
 
+	if isWebSocketUpgrade(r) && r.Header.Get("Authorization") == "" {
+		return r.URL.Query().Get("token")
+	}
+
 	authHeader := r.Header.Get("Authorization")
 
 	if len(authHeader) > 6 &&
@@ -68,61 +49,102 @@ func getToken(r *http.Request) string {
 	return tokenStr
 }
 
-// validateToken will validate the token with our jwt library and return the
-// corresponding user.
-func validateToken(token string) *models.User {
+// validateClaims will validate the token with our jwt library and return it's
+// claims.
+func validateClaims(token string) *Claims {
 	if token == "" {
 		return nil
 	}
 
-	tkn, err := jwt.Parse(token, func(tkn *jwt.Token) (interface{}, error) {
-		// Don't forget to validate the alg is what you expect:
-		if _, ok := tkn.Method.(*jwt.SigningMethodHMAC); !ok {
-			msg := fmt.Sprintf("Unexpected signing method: %v", tkn.Header["alg"])
-			return nil, errors.New(msg)
-		}
-
-		return secretKey, nil
-	})
+	claims := &Claims{}
+	tkn, err := jwt.ParseWithClaims(token, claims, verifyKey)
 
 	if err != nil {
 		log.Println("Parse error:", err)
 		return nil
 	}
 
-	if claims, ok := tkn.Claims.(jwt.MapClaims); ok && tkn.Valid {
-		e := claims.Valid()
-		if e != nil {
-			log.Println("Invalid claims:", e)
-			return nil
-		}
+	if !tkn.Valid {
+		fmt.Println("Claims invalid")
+		return nil
+	}
 
-		u := &models.User{}
+	e := claims.Valid()
+	if e != nil {
+		log.Println("Invalid claims:", e)
+		return nil
+	}
 
-		e = json.Unmarshal([]byte(claims["sub"].(string)), u)
-		if e != nil {
-			log.Println("Unable to unmarshal subject:", e)
-		}
+	if revoked.has(claims.Id) {
+		log.Println("Token has been revoked:", claims.Id)
+		return nil
+	}
 
-		return u
+	return claims
+}
+
+// validateToken will validate the token with our jwt library and return the
+// corresponding user.
+func validateToken(token string) *models.User {
+	claims := validateClaims(token)
+	if claims == nil {
+		return nil
 	}
 
-	fmt.Println("Claims invalid")
-	return nil
+	return claims.user()
+}
+
+// newTokenID will generate a random id to use as the jti claim of a token
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
 }
 
 // JWTSignUser will take the user and return a JWT token signed and with that
-// user set as the CurrentUser claim
+// user set as the CurrentUser claim, along with their admin flag and the
+// roles from UserRoles. The token will expire after the duration returned by
+// config.GetJWTTTL. ErrNoSigningKey is returned if ConfigureJWT hasn't been
+// called.
 func JWTSignUser(u models.User) (string, error) {
-	claims := jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(8 * time.Hour).Unix(),
-		Issuer:    "praelatus",
-		Subject:   u.String(),
+	return JWTSignUserContext(context.Background(), u)
+}
+
+// JWTSignUserContext is JWTSignUser with the context used to look up the
+// user's roles, such as the context of the request they signed in with.
+func JWTSignUserContext(ctx context.Context, u models.User) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
 	}
 
-	tkn := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Recorded before the roles are read so a change made while they're
+	// being read counts as newer than the token.
+	now := time.Now()
+	claims := Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(config.GetJWTTTL()).Unix(),
+			Issuer:    "praelatus",
+			Subject:   u.String(),
+		},
+		IsAdmin: u.IsAdmin,
+		RolesAt: now.UnixNano(),
+	}
+
+	if UserRoles != nil {
+		claims.Roles, err = UserRoles(ctx, u)
+		if err != nil {
+			return "", err
+		}
+	}
 
-	return tkn.SignedString(secretKey)
+	return signClaims(claims)
 }
 
 // GetUser will get the current user from the given context
@@ -134,21 +156,177 @@ func GetUser(ctx context.Context) *models.User {
 	return nil
 }
 
+// TokenExpiry will return when the token used to authenticate the given
+// http.Request expires
+func TokenExpiry(r *http.Request) (time.Time, error) {
+	token := getToken(r)
+	if token == "" {
+		return time.Time{}, ErrNoToken
+	}
+
+	claims, err := parseClaims(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	exp, _ := claims["exp"].(float64)
+	return time.Unix(int64(exp), 0), nil
+}
+
+// IsVerified reports whether the user for the request has verified their
+// email, anonymous users are never verified.
+func IsVerified(ctx context.Context) bool {
+	u := GetUser(ctx)
+	return u != nil && u.EmailVerified
+}
+
+// RequireAuth will respond with 401 unless there is a user for the request so
+// handlers it wraps can assume one is logged in, it must run inside Auth so
+// the user has been set.
+func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if GetUser(r.Context()) == nil {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":{"message":"you must be logged in",` +
+				`"code":"not_logged_in"}}`))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// RequireAdmin will respond with 401 unless there is a user for the request
+// and 403 unless they are a system administrator, it must run inside Auth so
+// the user has been set.
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !GetUser(r.Context()).IsAdmin {
+			w.WriteHeader(403)
+			w.Write([]byte(`{"error":{"message":"you must be a system administrator",` +
+				`"code":"forbidden"}}`))
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// RequireVerified will respond with 403 unless the user for the request has
+// verified their email, it must run inside Auth so the user has been set.
+func RequireVerified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !IsVerified(r.Context()) {
+			w.WriteHeader(403)
+			w.Write([]byte(`{"error":{"message":"you must verify your email first",` +
+				`"code":"email_not_verified"}}`))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// ProjectRole is used by RequireProjectRole to look up the role of a user in
+// the project with the given key, it should return an empty role for users who
+// are not members. It must be set before serving any routes which use
+// RequireProjectRole.
+var ProjectRole func(ctx context.Context, pkey string, u models.User) (models.PermissionLevel, error)
+
+// RequireProjectRole will respond with 403 unless the user for the request has
+// at least the given role in the project named by the pkey route variable,
+// system administrators are always allowed. It must run inside Auth so the
+// user has been set.
+func RequireProjectRole(role models.PermissionLevel) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			u := GetUser(r.Context())
+			if u == nil {
+				w.WriteHeader(403)
+				w.Write([]byte(`{"error":{"message":"you must be logged in",` +
+					`"code":"not_logged_in"}}`))
+				return
+			}
+
+			if !u.IsAdmin {
+				pkey := mux.Vars(r)["pkey"]
+
+				// Roles gained since the token was issued aren't in it's
+				// claims, so only a role which is enough skips the lookup.
+				if c := GetClaims(r.Context()); c != nil && c.Roles[pkey].Includes(role) {
+					next(w, r)
+					return
+				}
+
+				have, err := ProjectRole(r.Context(), pkey, *u)
+				if err != nil {
+					log.Println(err)
+					w.WriteHeader(500)
+					w.Write([]byte(`{"error":{"message":"failed to check project role",` +
+						`"code":"internal_error"}}`))
+					return
+				}
+
+				if !have.Includes(role) {
+					w.WriteHeader(403)
+					w.Write([]byte(`{"error":{"message":"you do not have permission ` +
+						`for this project","code":"forbidden"}}`))
+					return
+				}
+			}
+
+			next(w, r)
+		}
+	}
+}
+
 type contextKey string
 
 const currentUser contextKey = "currentUser"
 
+// withValue will return a copy of r with key set to val in it's context. mux
+// keeps the route variables in gorilla/context keyed by the request, so they
+// have to be copied over to the new request, done clears them from the copy.
+func withValue(r *http.Request, key, val interface{}) (rq *http.Request, done func()) {
+	rq = r.WithContext(context.WithValue(r.Context(), key, val))
+
+	for k, v := range gcontext.GetAll(r) {
+		gcontext.Set(rq, k, v)
+	}
+
+	return rq, func() { gcontext.Clear(rq) }
+}
+
 // Auth will check if the token for a request is valid and if so will add the
-// current user to the http.Request context
+// current user and the token's claims to the http.Request context
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var u *models.User
+		var claims *Claims
+
 		tkn := getToken(r)
 		if tkn != "" {
-			u = validateToken(tkn)
+			claims = validateClaims(tkn)
 		}
 
-		rq := r.WithContext(context.WithValue(r.Context(), currentUser, u))
+		if claims != nil {
+			u = claims.user()
+
+			// The user may have lost their roles since the token was
+			// issued, so they have to be looked up instead and they are
+			// no longer trusted as an admin.
+			if changedRoles.since(u.Username, claims.RolesAt) {
+				u.IsAdmin = false
+				claims = nil
+			}
+		}
+
+		rq, done := withValue(r, currentClaims, claims)
+		defer done()
+
+		rq, doneUser := withValue(rq, currentUser, u)
+		defer doneUser()
+
 		next.ServeHTTP(w, rq)
 	})
 }
@@ -3,10 +3,14 @@ package mw
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
 	"github.com/praelatus/backend/models"
 )
 
@@ -114,3 +118,326 @@ func TestGetUser(t *testing.T) {
 		t.Errorf("Expected %s Got %s", u.Username, tu.Username)
 	}
 }
+
+func TestRequireAuth(t *testing.T) {
+	h := RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name string
+		user *models.User
+		code int
+	}{
+		{"anonymous", nil, 401},
+		{"logged in", &models.User{Username: "testuser"}, 200},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), currentUser, test.user))
+		w := httptest.NewRecorder()
+
+		h(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d", test.name, test.code, w.Code)
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	h := RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name string
+		user *models.User
+		code int
+	}{
+		{"anonymous", nil, 401},
+		{"not an admin", &models.User{Username: "testuser"}, 403},
+		{"admin", &models.User{Username: "testadmin", IsAdmin: true}, 200},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), currentUser, test.user))
+		w := httptest.NewRecorder()
+
+		h(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d", test.name, test.code, w.Code)
+		}
+	}
+}
+
+func TestRequireVerified(t *testing.T) {
+	h := RequireVerified(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	tests := []struct {
+		name string
+		user *models.User
+		code int
+	}{
+		{"anonymous", nil, 403},
+		{"unverified", &models.User{Username: "testuser"}, 403},
+		{"verified", &models.User{Username: "testuser", EmailVerified: true}, 200},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), currentUser, test.user))
+		w := httptest.NewRecorder()
+
+		h(w, r)
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d", test.name, test.code, w.Code)
+		}
+	}
+}
+
+func TestRequireProjectRole(t *testing.T) {
+	old := ProjectRole
+	defer func() { ProjectRole = old }()
+
+	ProjectRole = func(ctx context.Context, pkey string, u models.User) (models.PermissionLevel, error) {
+		if pkey != "TEST" {
+			return "", nil
+		}
+
+		switch u.Username {
+		case "core":
+			return models.CoreR, nil
+		case "viewer":
+			return models.UserR, nil
+		case "broken":
+			return "", errors.New("connection refused")
+		}
+
+		return "", nil
+	}
+
+	tests := []struct {
+		name string
+		path string
+		user *models.User
+		code int
+	}{
+		{"anonymous", "/projects/TEST", nil, 403},
+		{"not a member", "/projects/TEST", &models.User{Username: "nobody"}, 403},
+		{"lower role", "/projects/TEST", &models.User{Username: "viewer"}, 403},
+		{"role", "/projects/TEST", &models.User{Username: "core"}, 200},
+		{"other project", "/projects/OTHER", &models.User{Username: "core"}, 403},
+		{"admin", "/projects/OTHER", &models.User{Username: "admin", IsAdmin: true}, 200},
+		{"lookup failed", "/projects/TEST", &models.User{Username: "broken"}, 500},
+		{"role claim", "/projects/OTHER", &models.User{Username: "claimed"}, 200},
+		{"lower role claim", "/projects/TEST", &models.User{Username: "claimed"}, 403},
+	}
+
+	claims := &Claims{Roles: map[string]models.PermissionLevel{
+		"OTHER": models.AdminR,
+		"TEST":  models.UserR,
+	}}
+
+	for _, test := range tests {
+		h := RequireProjectRole(models.CoreR)(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})
+
+		user := test.user
+		router := mux.NewRouter()
+		router.HandleFunc("/projects/{pkey}", func(w http.ResponseWriter, r *http.Request) {
+			rq, done := withValue(r, currentUser, user)
+			defer done()
+
+			if user != nil && user.Username == "claimed" {
+				var doneClaims func()
+				rq, doneClaims = withValue(rq, currentClaims, claims)
+				defer doneClaims()
+			}
+
+			h(w, rq)
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+
+		if w.Code != test.code {
+			t.Errorf("%s: Expected %d Got %d", test.name, test.code, w.Code)
+		}
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	u := models.User{Username: "testuser"}
+
+	claims := jwt.StandardClaims{
+		Id:        "expired",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+		Issuer:    "praelatus",
+		Subject:   u.String(),
+	}
+
+	token, e := signClaims(claims)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if tokenUser := validateToken(token); tokenUser != nil {
+		t.Errorf("Expected nil Got %s", tokenUser)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	u := models.User{Username: "testuser"}
+
+	token, e := JWTSignUser(u)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if validateToken(token) == nil {
+		t.Fatal("Expected a user got nil instead")
+	}
+
+	e = RevokeToken(token)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if tokenUser := validateToken(token); tokenUser != nil {
+		t.Errorf("Expected nil Got %s", tokenUser)
+	}
+
+	other, e := JWTSignUser(u)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if validateToken(other) == nil {
+		t.Error("Expected a user for a token that was not revoked")
+	}
+}
+
+func TestClaims(t *testing.T) {
+	old := UserRoles
+	defer func() { UserRoles = old }()
+
+	UserRoles = func(ctx context.Context, u models.User) (map[string]models.PermissionLevel, error) {
+		return map[string]models.PermissionLevel{"TEST": models.CoreR}, nil
+	}
+
+	u := models.User{Username: "claimsuser", IsAdmin: true}
+
+	token, e := JWTSignUser(u)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	claims := &Claims{}
+
+	_, e = jwt.ParseWithClaims(token, claims, verifyKey)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if !claims.IsAdmin {
+		t.Error("Expected the is_admin claim to be true")
+	}
+
+	if claims.Roles["TEST"] != models.CoreR || len(claims.Roles) != 1 {
+		t.Errorf("Expected the CORE role in TEST Got %v", claims.Roles)
+	}
+
+	if claims.IssuedAt == 0 || claims.RolesAt == 0 {
+		t.Error("Expected the iat and roles_at claims to be set")
+	}
+
+	if claims.user().Username != u.Username {
+		t.Errorf("Expected %s Got %s", u.Username, claims.Subject)
+	}
+}
+
+func TestAuthClaims(t *testing.T) {
+	u := models.User{Username: "changeduser"}
+
+	token, e := JWTSignUser(u)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var got *Claims
+	auth := Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetClaims(r.Context())
+	}))
+
+	serve := func() {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		auth.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve()
+
+	if got == nil || got.user().Username != u.Username {
+		t.Fatalf("Expected the claims of %s Got %v", u.Username, got)
+	}
+
+	RolesChanged(u)
+	serve()
+
+	if got != nil {
+		t.Errorf("Expected nil claims after the roles changed Got %v", got)
+	}
+
+	fresh, e := JWTSignUser(u)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	token = fresh
+	serve()
+
+	if got == nil {
+		t.Error("Expected the claims of a token issued after the roles changed")
+	}
+}
+
+func TestAuthDemotedAdmin(t *testing.T) {
+	u := models.User{Username: "demoteduser", IsAdmin: true}
+
+	token, e := JWTSignUser(u)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	h := Auth(RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	serve := func() int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve(); code != 200 {
+		t.Fatalf("Expected 200 for an admin Got %d", code)
+	}
+
+	u.IsAdmin = false
+	RolesChanged(u)
+
+	if code := serve(); code != 403 {
+		t.Errorf("Expected 403 for a demoted admin's old token Got %d", code)
+	}
+}
@@ -0,0 +1,99 @@
+package mw
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/praelatus/backend/config"
+	"github.com/praelatus/backend/models"
+)
+
+// Claims are the claims of the tokens signed by JWTSignUser, along with the
+// user as the subject they carry whether the user is a system administrator
+// and their role in each project they are a member of by the project's key so
+// authorization checks don't have to look them up.
+type Claims struct {
+	jwt.StandardClaims
+	IsAdmin bool                              `json:"is_admin"`
+	Roles   map[string]models.PermissionLevel `json:"roles"`
+
+	// RolesAt is the unix time in nanoseconds the claims were read at, the
+	// iat claim only records the second.
+	RolesAt int64 `json:"roles_at"`
+}
+
+// user returns the user in the subject of the claims
+func (c *Claims) user() *models.User {
+	u := &models.User{}
+
+	err := json.Unmarshal([]byte(c.Subject), u)
+	if err != nil {
+		log.Println("Unable to unmarshal subject:", err)
+	}
+
+	return u
+}
+
+// UserRoles is used by JWTSignUser to look up the roles claim of a user, the
+// roles are left out of the token if it's nil.
+var UserRoles func(ctx context.Context, u models.User) (map[string]models.PermissionLevel, error)
+
+// roleChanges holds when the roles of each user last changed by username.
+// Tokens issued before then have claims which may no longer be true.
+type roleChanges struct {
+	lock    sync.Mutex
+	entries map[string]time.Time
+}
+
+var changedRoles = &roleChanges{entries: make(map[string]time.Time)}
+
+func (rc *roleChanges) add(u models.User) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	// Every token issued before an old enough change has expired.
+	cutoff := time.Now().Add(-config.GetJWTTTL())
+	for name, at := range rc.entries {
+		if at.Before(cutoff) {
+			delete(rc.entries, name)
+		}
+	}
+
+	rc.entries[u.Username] = time.Now()
+}
+
+// since reports whether the roles of the user changed at or after rolesAt,
+// the RolesAt claim of a token.
+func (rc *roleChanges) since(username string, rolesAt int64) bool {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	at, ok := rc.entries[username]
+	return ok && at.UnixNano() >= rolesAt
+}
+
+// RolesChanged should be called with the user whenever their admin flag or
+// project roles change. The claims of the tokens issued to them before then
+// are no longer trusted, GetClaims returns nil for them and the user isn't
+// treated as an admin so their roles are looked up until they get a new
+// token from JWTSignUser.
+func RolesChanged(u models.User) {
+	changedRoles.add(u)
+}
+
+const currentClaims contextKey = "currentClaims"
+
+// GetClaims will get the claims of the token used to authenticate the request
+// from the given context, nil is returned if there is no token or it's claims
+// are out of date.
+func GetClaims(ctx context.Context) *Claims {
+	if c, ok := ctx.Value(currentClaims).(*Claims); ok {
+		return c
+	}
+
+	return nil
+}
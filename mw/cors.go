@@ -0,0 +1,70 @@
+package mw
+
+import (
+	"net/http"
+
+	"github.com/praelatus/backend/config"
+)
+
+const (
+	corsExposed = "X-Total-Count, ETag"
+	corsMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsHeaders = "Authorization, Content-Type, If-None-Match"
+	corsMaxAge  = "600"
+)
+
+// NewCORS will create a Middleware which allows cross origin requests from
+// the given origins, an origin of * allows any origin. Preflight requests are
+// answered directly and never reach next. If credentials is true browsers are
+// told they may send cookies and Authorization headers.
+func NewCORS(origins []string, credentials bool) Middleware {
+	allowed := make(map[string]bool)
+	for _, o := range origins {
+		allowed[o] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == "OPTIONS" &&
+				r.Header.Get("Access-Control-Request-Method") != ""
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+
+			if !allowed[origin] && !allowed["*"] {
+				if preflight {
+					w.WriteHeader(403)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", corsMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(204)
+				return
+			}
+
+			w.Header().Set("Access-Control-Expose-Headers", corsExposed)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CORS allows cross origin requests from config.GetCORSOrigins, it wraps the
+// whole router so preflight requests are answered for every route.
+var CORS = NewCORS(config.GetCORSOrigins(), config.GetCORSCredentials())
@@ -0,0 +1,59 @@
+package mw
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	h := NewCORS([]string{"https://app.example.com"}, true)(mockHandler{})
+
+	tests := []struct {
+		name    string
+		method  string
+		origin  string
+		code    int
+		allowed string
+		methods string
+	}{
+		{"allowed", "GET", "https://app.example.com", 200,
+			"https://app.example.com", ""},
+		{"disallowed", "GET", "https://evil.example.com", 200, "", ""},
+		{"preflight", "OPTIONS", "https://app.example.com", 204,
+			"https://app.example.com", corsMethods},
+		{"disallowed preflight", "OPTIONS", "https://evil.example.com", 403,
+			"", ""},
+		{"same origin", "GET", "", 200, "", ""},
+	}
+
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(tc.method, "/tickets", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+
+		if tc.method == "OPTIONS" {
+			r.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+
+		h.ServeHTTP(w, r)
+
+		if w.Code != tc.code {
+			t.Errorf("%s: Expected %d Got %d\n", tc.name, tc.code, w.Code)
+		}
+
+		if o := w.Header().Get("Access-Control-Allow-Origin"); o != tc.allowed {
+			t.Errorf("%s: Expected origin %q Got %q\n", tc.name, tc.allowed, o)
+		}
+
+		if m := w.Header().Get("Access-Control-Allow-Methods"); m != tc.methods {
+			t.Errorf("%s: Expected methods %q Got %q\n", tc.name, tc.methods, m)
+		}
+
+		creds := w.Header().Get("Access-Control-Allow-Credentials")
+		if tc.allowed != "" && creds != "true" {
+			t.Errorf("%s: Expected credentials to be allowed\n", tc.name)
+		}
+	}
+}
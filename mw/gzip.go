@@ -0,0 +1,142 @@
+package mw
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// compressedTypes are the content type prefixes which are already compressed
+// so gzipping them again would only cost cpu.
+var compressedTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"audio/",
+	"video/",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/pdf",
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of the request
+// allows a gzip encoded response.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(enc, ";")
+		name := strings.TrimSpace(parts[0])
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		if len(parts) > 1 && strings.Replace(parts[1], " ", "", -1) == "q=0" {
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// gzipResponseWriter compresses the body written to it with gzip, unless the
+// response has no body or is already compressed, it decides which when the
+// header is written.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer
+	method  string
+	decided bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.decide(code)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		// The content type has to be sniffed from the uncompressed body,
+		// net/http would sniff the gzipped bytes otherwise.
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+
+		w.WriteHeader(200)
+	}
+
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.gz.Write(b)
+}
+
+// decide will start compressing the response if it has a body which isn't
+// already compressed.
+func (w *gzipResponseWriter) decide(code int) {
+	w.decided = true
+
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+
+	if w.method == "HEAD" || code < 200 || code == 204 || code == 304 ||
+		h.Get("Content-Encoding") != "" {
+		return
+	}
+
+	ct := h.Get("Content-Type")
+	for _, t := range compressedTypes {
+		if strings.HasPrefix(ct, t) {
+			return
+		}
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+}
+
+// Flush sends what has been compressed so far, and anything buffered below
+// it, to the client so streamed responses aren't held back by the gzip writer.
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close will flush the compressed body, if there is one, to the client
+func (w *gzipResponseWriter) close() error {
+	if w.gz == nil {
+		return nil
+	}
+
+	return w.gz.Close()
+}
+
+// Gzip will compress responses with gzip for clients which send
+// Accept-Encoding: gzip, content types which are already compressed are sent
+// as is, as are websocket upgrades. It's part of the Default stack but can
+// wrap any handler.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsGzip(r) || isWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, method: r.Method}
+		defer gw.close()
+
+		next.ServeHTTP(gw, r)
+	})
+}
@@ -0,0 +1,162 @@
+package mw
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/praelatus/backend/config"
+)
+
+// minSecretLen is the shortest HS256 secret ConfigureJWT accepts, it matches
+// the size of the hash so the secret isn't the weak point.
+const minSecretLen = 32
+
+// ErrNoSigningKey is returned when signing or verifying a token before
+// ConfigureJWT has been called
+var ErrNoSigningKey = errors.New("no jwt signing key has been configured")
+
+// JWTOptions configures the algorithm and keys tokens are signed with
+type JWTOptions struct {
+	// Algorithm is HS256 or RS256, it defaults to HS256
+	Algorithm string
+
+	// Secret is the shared secret used by HS256
+	Secret []byte
+
+	// PrivateKey is the PEM encoded RSA private key used by RS256, tokens
+	// are verified with it's public key so other services can verify them
+	// without being able to sign any.
+	PrivateKey []byte
+}
+
+// signingKeys are the method and keys of the current JWTOptions
+type signingKeys struct {
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+var (
+	keysLock sync.RWMutex
+	keys     *signingKeys
+)
+
+// ConfigureJWT sets the algorithm and keys tokens are signed and verified
+// with, it must be called before serving any requests. Tokens signed before
+// the keys are changed are no longer accepted.
+func ConfigureJWT(opts JWTOptions) error {
+	var k signingKeys
+
+	switch opts.Algorithm {
+	case "", "HS256":
+		if len(opts.Secret) < minSecretLen {
+			return fmt.Errorf("the HS256 secret must be at least %d bytes", minSecretLen)
+		}
+
+		k = signingKeys{jwt.SigningMethodHS256, opts.Secret, opts.Secret}
+	case "RS256":
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(opts.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("invalid RS256 private key: %w", err)
+		}
+
+		k = signingKeys{jwt.SigningMethodRS256, priv, &priv.PublicKey}
+	default:
+		return fmt.Errorf("unsupported jwt algorithm %q, expected HS256 or RS256",
+			opts.Algorithm)
+	}
+
+	keysLock.Lock()
+	defer keysLock.Unlock()
+
+	keys = &k
+	return nil
+}
+
+// ConfigureJWTFromEnv calls ConfigureJWT with the algorithm and keys from
+// config, an error is returned if none are set rather than signing tokens
+// with a default.
+func ConfigureJWTFromEnv() error {
+	opts := JWTOptions{Algorithm: config.GetJWTAlgorithm()}
+	file := config.GetJWTKeyFile()
+
+	var key []byte
+	if file != "" {
+		var err error
+
+		key, err = ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("reading the jwt key file: %w", err)
+		}
+	}
+
+	switch opts.Algorithm {
+	case "RS256":
+		if file == "" {
+			return errors.New("PRAELATUS_JWT_KEY_FILE must be set for RS256")
+		}
+
+		opts.PrivateKey = key
+	default:
+		opts.Secret = []byte(config.GetJWTSecret())
+		if len(opts.Secret) == 0 {
+			opts.Secret = key
+		}
+
+		if len(opts.Secret) == 0 {
+			return errors.New("PRAELATUS_JWT_SECRET or PRAELATUS_JWT_KEY_FILE must be set")
+		}
+	}
+
+	return ConfigureJWT(opts)
+}
+
+// JWTPublicKey returns the RSA public key tokens are verified with, it's nil
+// unless RS256 is configured.
+func JWTPublicKey() *rsa.PublicKey {
+	keysLock.RLock()
+	defer keysLock.RUnlock()
+
+	if keys == nil {
+		return nil
+	}
+
+	pub, _ := keys.verify.(*rsa.PublicKey)
+	return pub
+}
+
+// signClaims signs the claims with the configured method and key
+func signClaims(claims jwt.Claims) (string, error) {
+	keysLock.RLock()
+	k := keys
+	keysLock.RUnlock()
+
+	if k == nil {
+		return "", ErrNoSigningKey
+	}
+
+	return jwt.NewWithClaims(k.method, claims).SignedString(k.sign)
+}
+
+// verifyKey is the jwt.Keyfunc used to parse tokens, tokens signed with any
+// method other than the configured one are rejected so an RS256 public key
+// can't be used as an HS256 secret.
+func verifyKey(tkn *jwt.Token) (interface{}, error) {
+	keysLock.RLock()
+	k := keys
+	keysLock.RUnlock()
+
+	if k == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	if tkn.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", tkn.Header["alg"])
+	}
+
+	return k.verify, nil
+}
@@ -0,0 +1,136 @@
+package mw
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/praelatus/backend/models"
+)
+
+// testJWTOptions are the options the tests in this package sign tokens with
+var testJWTOptions = JWTOptions{Secret: []byte("a test secret which is long enough")}
+
+func init() {
+	err := ConfigureJWT(testJWTOptions)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// rsaKeyPEM generates an RSA private key, PEM encoded the way openssl writes
+// them
+func rsaKeyPEM(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+}
+
+func TestConfigureJWT(t *testing.T) {
+	defer ConfigureJWT(testJWTOptions)
+
+	tests := []struct {
+		name string
+		opts JWTOptions
+		ok   bool
+	}{
+		{"hs256", JWTOptions{Algorithm: "HS256", Secret: testJWTOptions.Secret}, true},
+		{"default algorithm", JWTOptions{Secret: testJWTOptions.Secret}, true},
+		{"short secret", JWTOptions{Secret: []byte("short")}, false},
+		{"no secret", JWTOptions{Algorithm: "HS256"}, false},
+		{"rs256", JWTOptions{Algorithm: "RS256", PrivateKey: rsaKeyPEM(t)}, true},
+		{"rs256 without key", JWTOptions{Algorithm: "RS256"}, false},
+		{"rs256 invalid key", JWTOptions{Algorithm: "RS256", PrivateKey: []byte("nope")}, false},
+		{"unsupported algorithm", JWTOptions{Algorithm: "none"}, false},
+	}
+
+	for _, test := range tests {
+		err := ConfigureJWT(test.opts)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: Expected ok %v Got %v", test.name, test.ok, err)
+		}
+	}
+}
+
+func TestSignHS256(t *testing.T) {
+	defer ConfigureJWT(testJWTOptions)
+
+	err := ConfigureJWT(JWTOptions{Algorithm: "HS256", Secret: testJWTOptions.Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := JWTSignUser(models.User{Username: "testuser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := validateToken(token)
+	if u == nil || u.Username != "testuser" {
+		t.Errorf("Expected testuser Got %v", u)
+	}
+
+	if JWTPublicKey() != nil {
+		t.Error("Expected no public key for HS256")
+	}
+
+	// A token signed with another secret must not be accepted.
+	err = ConfigureJWT(JWTOptions{Secret: []byte("a different secret which is long enough")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u := validateToken(token); u != nil {
+		t.Errorf("Expected nil Got %v", u)
+	}
+}
+
+func TestSignRS256(t *testing.T) {
+	defer ConfigureJWT(testJWTOptions)
+
+	hs256, err := JWTSignUser(models.User{Username: "testuser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ConfigureJWT(JWTOptions{Algorithm: "RS256", PrivateKey: rsaKeyPEM(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := JWTSignUser(models.User{Username: "testuser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := validateToken(token)
+	if u == nil || u.Username != "testuser" {
+		t.Errorf("Expected testuser Got %v", u)
+	}
+
+	// Another service only holding the public key can verify the token.
+	pub := JWTPublicKey()
+	if pub == nil {
+		t.Fatal("Expected a public key for RS256")
+	}
+
+	tkn, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) {
+		return pub, nil
+	})
+	if err != nil || !tkn.Valid || tkn.Method.Alg() != "RS256" {
+		t.Errorf("Expected a valid RS256 token Got %v", err)
+	}
+
+	if u := validateToken(hs256); u != nil {
+		t.Errorf("Expected an HS256 token to be rejected Got %v", u)
+	}
+}
@@ -1,11 +1,42 @@
 package mw
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 )
 
+const requestID contextKey = "requestID"
+
+// GetRequestID will get the id of the current request from the given context
+func GetRequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestID).(string); ok {
+		return id
+	}
+
+	return ""
+}
+
+// newRequestID will generate a random version 4 uuid
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Println(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
 // LoggedResponseWriter wraps http.ResponseWriter so we can capture the status
 // code for logging
 type LoggedResponseWriter struct {
@@ -33,16 +64,46 @@ func (w *LoggedResponseWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
-// Logger will log a request and any information about the request, it should
-// be the first middleware in any chain.
+// Hijack lets websocket upgrades take over the connection, the status is
+// logged as 101 Switching Protocols.
+func (w *LoggedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer can't be hijacked")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+// Flush lets streamed responses reach the client before the handler is done.
+func (w *LoggedResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Logger will give a request an id, available from GetRequestID and the
+// X-Request-ID response header, and log the request and any information about
+// it once it is done, it should be the first middleware in any chain.
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		id := newRequestID()
+
+		w.Header().Set("X-Request-ID", id)
 		lrw := &LoggedResponseWriter{0, w}
 
-		next.ServeHTTP(lrw, r)
+		rq, done := withValue(r, requestID, id)
+		defer done()
+
+		next.ServeHTTP(lrw, rq)
 
-		log.Printf("|%s| [%d] %s %s",
+		log.Printf("%s |%s| [%d] %s %s", id,
 			r.Method, lrw.Status(), r.URL.Path, time.Since(start).String())
 	})
 }
@@ -1,8 +1,13 @@
 package mw
 
 import (
+	"bytes"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -15,7 +20,7 @@ func (m mockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func TestLogger(t *testing.T) {
 	m := mockHandler{}
-	log := Logger(m)
+	lg := Logger(m)
 
 	r, e := http.NewRequest("GET", "/", nil)
 	if e != nil {
@@ -24,5 +29,34 @@ func TestLogger(t *testing.T) {
 
 	w := httptest.NewRecorder()
 
-	log.ServeHTTP(w, r)
+	lg.ServeHTTP(w, r)
+}
+
+func TestLoggerRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	var ctxID string
+	h := Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctxID = GetRequestID(r.Context())
+		w.WriteHeader(404)
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/tickets", nil)
+
+	h.ServeHTTP(w, r)
+
+	id := w.Header().Get("X-Request-ID")
+	if len(id) != 36 || id != ctxID {
+		t.Errorf("Expected a uuid matching %q Got %q\n", ctxID, id)
+	}
+
+	line := buf.String()
+	if !strings.Contains(line, id) || !strings.Contains(line, "[404]") ||
+		!regexp.MustCompile(`/tickets \d+(\.\d+)?[nµm]?s\n$`).MatchString(line) {
+		t.Errorf("Expected the id, status and duration to be logged Got %q\n",
+			line)
+	}
 }
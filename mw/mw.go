@@ -6,7 +6,7 @@ import "net/http"
 // way
 type Middleware func(next http.Handler) http.Handler
 
-var defaultMW = []Middleware{Logger, Auth}
+var defaultMW = []Middleware{Gzip, Logger, Auth}
 
 // Default will add the default middleware stack to the given http.Handler and
 // return a handler with the full stack
@@ -0,0 +1,121 @@
+package mw
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/praelatus/backend/config"
+)
+
+// bucket is a token bucket for a single client, a request takes one token and
+// tokens are added back at the limiter's rate up to its burst size.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter holds a token bucket for every client ip seen recently.
+type limiter struct {
+	lock    sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	buckets map[string]*bucket
+	pruned  time.Time
+	now     func() time.Time
+}
+
+func newLimiter(perMinute, burst int) *limiter {
+	return &limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// refill will add the tokens earned since the bucket was last used
+func (l *limiter) refill(b *bucket, now time.Time) {
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+}
+
+// take will remove a token from the bucket for key, if the bucket is empty it
+// returns false and how long until a token is available.
+func (l *limiter) take(key string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+
+	// Buckets which have refilled completely are the same as new ones so
+	// they can be dropped.
+	if now.Sub(l.pruned) > time.Minute {
+		for k, b := range l.buckets {
+			l.refill(b, now)
+			if b.tokens >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+
+		l.pruned = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / l.rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// clientIP returns the ip of the connecting client, headers such as
+// X-Forwarded-For are ignored since the client can set them to anything.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// NewRateLimit will create a Middleware which allows each client ip to make
+// burst requests at once and perMinute requests a minute after that. Requests
+// over the limit get a 429 with a Retry-After header in seconds.
+func NewRateLimit(perMinute, burst int) Middleware {
+	l := newLimiter(perMinute, burst)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := l.take(clientIP(r))
+			if !ok {
+				retry := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"error":{"message":"too many requests",` +
+					`"code":"rate_limited"}}`))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimit limits requests using config.GetRateLimit and
+// config.GetRateBurst, it is opt in for routes such as logging in which need
+// protection from brute forcing.
+var RateLimit = NewRateLimit(config.GetRateLimit(), config.GetRateBurst())
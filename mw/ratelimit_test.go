@@ -0,0 +1,81 @@
+package mw
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	h := NewRateLimit(60, 3)(mockHandler{})
+
+	for i := 1; i <= 4; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/sessions", nil)
+
+		h.ServeHTTP(w, r)
+
+		if i <= 3 && w.Code != 200 {
+			t.Errorf("Expected request %d to succeed Got %d\n", i, w.Code)
+		}
+
+		if i == 4 {
+			if w.Code != 429 {
+				t.Errorf("Expected request %d to be limited Got %d\n", i, w.Code)
+			}
+
+			if w.Header().Get("Retry-After") != "1" {
+				t.Errorf("Expected Retry-After 1 Got %s\n",
+					w.Header().Get("Retry-After"))
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/sessions", nil)
+	r.RemoteAddr = "192.0.2.2:1234"
+
+	h.ServeHTTP(w, r)
+
+	if w.Code != 200 {
+		t.Errorf("Expected another client to succeed Got %d\n", w.Code)
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	now := time.Now()
+
+	l := newLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.take("client"); !ok {
+			t.Fatalf("Expected take %d to succeed\n", i)
+		}
+	}
+
+	ok, wait := l.take("client")
+	if ok || wait != time.Second {
+		t.Errorf("Expected to wait 1s Got %v %v\n", ok, wait)
+	}
+
+	now = now.Add(time.Second)
+
+	if ok, _ := l.take("client"); !ok {
+		t.Error("Expected a token after refilling")
+	}
+
+	if ok, _ := l.take("client"); ok {
+		t.Error("Expected only one token to refill")
+	}
+
+	now = now.Add(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.take("client")
+		if ok != (i < 2) {
+			t.Errorf("Expected the bucket to refill to 2 Got %v on take %d\n",
+				ok, i)
+		}
+	}
+}
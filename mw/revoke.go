@@ -0,0 +1,84 @@
+package mw
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ErrNoToken is returned when a request does not carry a token to revoke
+var ErrNoToken = errors.New("no token found on request")
+
+// blacklist holds the jti of every revoked token along with the time the
+// token would have expired, after which the entry is no longer needed.
+type blacklist struct {
+	lock    sync.Mutex
+	entries map[string]time.Time
+}
+
+var revoked = &blacklist{entries: make(map[string]time.Time)}
+
+func (b *blacklist) add(jti string, exp time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	for id, e := range b.entries {
+		if e.Before(now) {
+			delete(b.entries, id)
+		}
+	}
+
+	b.entries[jti] = exp
+}
+
+func (b *blacklist) has(jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	_, ok := b.entries[jti]
+	return ok
+}
+
+// parseClaims will verify the signature of token and return it's claims
+func parseClaims(token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, verifyKey)
+
+	return claims, err
+}
+
+// RevokeToken will add the jti of the given token to the revocation blacklist
+// so that the auth middleware no longer accepts it.
+func RevokeToken(token string) error {
+	if token == "" {
+		return ErrNoToken
+	}
+
+	claims, err := parseClaims(token)
+	if err != nil {
+		return err
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return errors.New("token has no jti claim")
+	}
+
+	exp, _ := claims["exp"].(float64)
+	revoked.add(jti, time.Unix(int64(exp), 0))
+	return nil
+}
+
+// RevokeRequestToken will revoke the token used to authenticate the given
+// http.Request
+func RevokeRequestToken(r *http.Request) error {
+	return RevokeToken(getToken(r))
+}
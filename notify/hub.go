@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"sync"
+
+	"github.com/praelatus/backend/models"
+)
+
+// subscriberBuffer is how many events a subscriber can fall behind by before
+// events for it are dropped.
+const subscriberBuffer = 16
+
+// Hub is a Notifier which passes ticket events on to the subscribers of the
+// event's project, it's used to stream changes to clients as they happen. A
+// subscriber which isn't keeping up misses events rather than blocking the
+// store operation which sent them.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan models.Event]struct{}
+}
+
+// NewHub returns a Hub with no subscribers
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[chan models.Event]struct{})}
+}
+
+// Notify sends the event to every subscriber of it's project, events which
+// aren't for a project are ignored.
+func (h *Hub) Notify(event models.Event) error {
+	if event.ProjectKey == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.subs[event.ProjectKey] {
+		select {
+		case c <- event:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns a channel which receives the events for the project with
+// the given key, cancel must be called once the events are no longer wanted.
+func (h *Hub) Subscribe(projectKey string) (events <-chan models.Event, cancel func()) {
+	c := make(chan models.Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subs[projectKey] == nil {
+		h.subs[projectKey] = make(map[chan models.Event]struct{})
+	}
+
+	h.subs[projectKey][c] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+
+	return c, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subs[projectKey], c)
+			if len(h.subs[projectKey]) == 0 {
+				delete(h.subs, projectKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"testing"
+
+	"github.com/praelatus/backend/models"
+)
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+
+	events, cancel := h.Subscribe("TEST")
+	other, cancelOther := h.Subscribe("OTHER")
+	defer cancelOther()
+
+	h.Notify(models.Event{Type: models.EventTicketCreated, ProjectKey: "TEST"})
+
+	select {
+	case e := <-events:
+		if e.Type != models.EventTicketCreated {
+			t.Errorf("Expected %s Got %s\n", models.EventTicketCreated, e.Type)
+		}
+	default:
+		t.Error("Expected an event for TEST")
+	}
+
+	select {
+	case e := <-other:
+		t.Errorf("Expected no event for OTHER Got %v\n", e)
+	default:
+	}
+
+	// A subscriber which isn't reading shouldn't block Notify.
+	for i := 0; i < subscriberBuffer*2; i++ {
+		h.Notify(models.Event{Type: models.EventTicketUpdated, ProjectKey: "TEST"})
+	}
+
+	if len(events) != subscriberBuffer {
+		t.Errorf("Expected %d buffered events Got %d\n", subscriberBuffer, len(events))
+	}
+
+	cancel()
+	cancel()
+
+	if _, ok := h.subs["TEST"]; ok {
+		t.Error("Expected cancel to remove the subscriber")
+	}
+}
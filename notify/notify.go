@@ -0,0 +1,55 @@
+// Package notify delivers events such as a ticket being created or a password
+// reset being requested to interested users, the actual delivery mechanism is
+// provided by an implementation of Notifier.
+package notify
+
+import (
+	"log"
+
+	"github.com/praelatus/backend/models"
+)
+
+// Notifier is used to send an event to the users interested in it
+type Notifier interface {
+	Notify(event models.Event) error
+}
+
+// Nop is a Notifier which discards every event
+type Nop struct{}
+
+// Notify does nothing and returns nil
+func (n Nop) Notify(event models.Event) error {
+	return nil
+}
+
+// Log is a Notifier which writes every event to the standard logger, the
+// event Data is left out since it may hold secrets such as reset tokens.
+type Log struct{}
+
+// Notify logs the event type, actor and ticket key
+func (l Log) Notify(event models.Event) error {
+	log.Printf("notify: %s by %s on %s\n", event.Type, event.Actor.Username,
+		event.Ticket.Key)
+	return nil
+}
+
+// Multi returns a Notifier which sends every event to each of notifiers in
+// turn, the first error is returned after they have all been tried.
+func Multi(notifiers ...Notifier) Notifier {
+	return multi(notifiers)
+}
+
+type multi []Notifier
+
+func (m multi) Notify(event models.Event) error {
+	var first error
+
+	for _, n := range m {
+		err := n.Notify(event)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
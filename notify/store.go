@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"context"
+	"log"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// Store wraps s so that ticket events are sent to n whenever a ticket is
+// created, updated or transitioned, a comment is added or a ticket's assignee
+// changes. Failing to notify is logged and does not fail the store operation.
+func Store(s store.Store, n Notifier) store.Store {
+	return notifyingStore{s, n}
+}
+
+type notifyingStore struct {
+	store.Store
+	n Notifier
+}
+
+func (s notifyingStore) Tickets() store.TicketStore {
+	return ticketStore{s.Store.Tickets(), s.Store.Projects(), s.n}
+}
+
+func (s notifyingStore) WithContext(ctx context.Context) store.Store {
+	return notifyingStore{s.Store.WithContext(ctx), s.n}
+}
+
+// WithTx holds back the events from fn until the transaction is committed,
+// none are sent if it's rolled back.
+func (s notifyingStore) WithTx(fn func(tx store.Store) error) error {
+	var events buffer
+
+	err := s.Store.WithTx(func(tx store.Store) error {
+		return fn(notifyingStore{tx, &events})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		err = s.n.Notify(e)
+		if err != nil {
+			log.Println("notify:", err)
+		}
+	}
+
+	return nil
+}
+
+// buffer is a Notifier which keeps the events it's sent
+type buffer []models.Event
+
+func (b *buffer) Notify(e models.Event) error {
+	*b = append(*b, e)
+	return nil
+}
+
+type ticketStore struct {
+	store.TicketStore
+	projects store.ProjectStore
+	n        Notifier
+}
+
+// notify sends e to the Notifier, looking up the ticket's project first if
+// the event doesn't have it.
+func (ts ticketStore) notify(e models.Event) {
+	if e.ProjectKey == "" {
+		p, err := ts.projects.GetProjectForTicket(e.Ticket.Key)
+		if err != nil {
+			log.Println("notify:", err)
+		}
+
+		e.ProjectKey = p.Key
+	}
+
+	err := ts.n.Notify(e)
+	if err != nil {
+		log.Println("notify:", err)
+	}
+}
+
+func (ts ticketStore) New(p models.Project, t *models.Ticket) error {
+	err := ts.TicketStore.New(p, t)
+	if err != nil {
+		return err
+	}
+
+	ts.notify(models.Event{
+		Type:       models.EventTicketCreated,
+		Actor:      t.Reporter,
+		Ticket:     *t,
+		ProjectKey: p.Key,
+	})
+
+	return nil
+}
+
+func (ts ticketStore) NewBatch(p models.Project, tickets []*models.Ticket) error {
+	err := ts.TicketStore.NewBatch(p, tickets)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tickets {
+		ts.notify(models.Event{
+			Type:       models.EventTicketCreated,
+			Actor:      t.Reporter,
+			Ticket:     *t,
+			ProjectKey: p.Key,
+		})
+	}
+
+	return nil
+}
+
+func (ts ticketStore) NewComment(t models.Ticket, c *models.Comment) error {
+	err := ts.TicketStore.NewComment(t, c)
+	if err != nil {
+		return err
+	}
+
+	cm := *c
+	ts.notify(models.Event{
+		Type:    models.EventCommentAdded,
+		Actor:   c.Author,
+		Ticket:  t,
+		Comment: &cm,
+	})
+
+	return nil
+}
+
+func (ts ticketStore) AssignTicket(t models.Ticket, assignee models.User) error {
+	err := ts.TicketStore.AssignTicket(t, assignee)
+	if err != nil {
+		return err
+	}
+
+	if assignee.ID == 0 && assignee.Username == "" {
+		return nil
+	}
+
+	after := models.Ticket{ID: t.ID, Key: t.Key}
+	err = ts.TicketStore.Get(&after)
+	if err != nil {
+		log.Println("notify:", err)
+		return nil
+	}
+
+	ts.notify(models.Event{
+		Type:   models.EventTicketAssigned,
+		Actor:  t.UpdatedBy,
+		Ticket: after,
+	})
+
+	return nil
+}
+
+// Save only sends the updated event, stores never change the assignee on
+// Save so assignments are sent by AssignTicket.
+func (ts ticketStore) Save(t models.Ticket) error {
+	err := ts.TicketStore.Save(t)
+	if err != nil {
+		return err
+	}
+
+	after := models.Ticket{ID: t.ID, Key: t.Key}
+	err = ts.TicketStore.Get(&after)
+	if err != nil {
+		log.Println("notify:", err)
+		return nil
+	}
+
+	ts.notify(models.Event{
+		Type:   models.EventTicketUpdated,
+		Actor:  t.UpdatedBy,
+		Ticket: after,
+	})
+
+	return nil
+}
+
+func (ts ticketStore) TransitionTicket(t models.Ticket, s models.Status) error {
+	err := ts.TicketStore.TransitionTicket(t, s)
+	if err != nil {
+		return err
+	}
+
+	ts.transitioned(t)
+	return nil
+}
+
+func (ts ticketStore) TransitionBatch(tickets []models.Ticket, s models.Status) error {
+	err := ts.TicketStore.TransitionBatch(tickets, s)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tickets {
+		ts.transitioned(t)
+	}
+
+	return nil
+}
+
+// transitioned sends the ticket_transitioned event for t
+func (ts ticketStore) transitioned(t models.Ticket) {
+	after := models.Ticket{ID: t.ID, Key: t.Key}
+
+	err := ts.TicketStore.Get(&after)
+	if err != nil {
+		log.Println("notify:", err)
+		return
+	}
+
+	ts.notify(models.Event{
+		Type:   models.EventTicketTransitioned,
+		Actor:  t.UpdatedBy,
+		Ticket: after,
+	})
+}
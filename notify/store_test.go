@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+type recorder struct {
+	events []models.Event
+}
+
+func (r *recorder) Notify(e models.Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+// stubTickets keeps a single ticket and stores whatever it is given, except
+// the assignee which like the real stores is only changed by AssignTicket
+type stubTickets struct {
+	store.TicketStore
+	stored models.Ticket
+}
+
+func (ts *stubTickets) Get(t *models.Ticket) error {
+	*t = ts.stored
+	return nil
+}
+
+func (ts *stubTickets) Save(t models.Ticket) error {
+	t.Assignee = ts.stored.Assignee
+	ts.stored = t
+	return nil
+}
+
+func (ts *stubTickets) AssignTicket(t models.Ticket, u models.User) error {
+	ts.stored.Assignee = u
+	return nil
+}
+
+func (ts *stubTickets) New(p models.Project, t *models.Ticket) error {
+	if t.Summary == "" {
+		return errors.New("summary required")
+	}
+
+	t.ID = 1
+	t.Key = p.Key + "-1"
+	ts.stored = *t
+	return nil
+}
+
+func (ts *stubTickets) NewComment(t models.Ticket, c *models.Comment) error {
+	c.ID = 1
+	return nil
+}
+
+func (ts *stubTickets) TransitionTicket(t models.Ticket, s models.Status) error {
+	ts.stored.Status = s
+	return nil
+}
+
+// stubProjects puts every ticket in the TEST project
+type stubProjects struct {
+	store.ProjectStore
+}
+
+func (ps stubProjects) GetProjectForTicket(key string) (models.Project, error) {
+	return models.Project{Key: "TEST"}, nil
+}
+
+type stubStore struct {
+	store.Store
+	tickets *stubTickets
+}
+
+func (s stubStore) Tickets() store.TicketStore {
+	return s.tickets
+}
+
+func (s stubStore) Projects() store.ProjectStore {
+	return stubProjects{}
+}
+
+// WithTx runs fn in a pretend transaction, there is nothing to roll back so
+// it only returns fn's error.
+func (s stubStore) WithTx(fn func(tx store.Store) error) error {
+	return fn(s)
+}
+
+func TestStoreEvents(t *testing.T) {
+	r := &recorder{}
+	s := Store(stubStore{tickets: &stubTickets{}}, r)
+
+	reporter := models.User{ID: 1, Username: "reporter"}
+	assignee := models.User{ID: 2, Username: "assignee"}
+	p := models.Project{Key: "TEST"}
+
+	tk := models.Ticket{Summary: "A ticket", Reporter: reporter}
+	err := s.Tickets().New(p, &tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Tickets().New(p, &models.Ticket{})
+	if err == nil {
+		t.Error("Expected an error for a ticket with no summary")
+	}
+
+	c := models.Comment{Body: "A comment", Author: assignee}
+	err = s.Tickets().NewComment(tk, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk.Summary = "Not reassigned"
+	err = s.Tickets().Save(tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Save doesn't change the assignee so only AssignTicket sends the event
+	tk.Assignee = assignee
+	tk.UpdatedBy = reporter
+	err = s.Tickets().Save(tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Tickets().AssignTicket(tk, assignee)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tk.UpdatedBy = assignee
+	err = s.Tickets().TransitionTicket(tk, models.Status{ID: 2, Name: "Done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		typ   string
+		actor string
+	}{
+		{models.EventTicketCreated, "reporter"},
+		{models.EventCommentAdded, "assignee"},
+		{models.EventTicketUpdated, ""},
+		{models.EventTicketUpdated, "reporter"},
+		{models.EventTicketAssigned, "reporter"},
+		{models.EventTicketTransitioned, "assignee"},
+	}
+
+	if len(r.events) != len(expected) {
+		t.Fatalf("Expected %d events Got %v\n", len(expected), r.events)
+	}
+
+	for i, e := range expected {
+		ev := r.events[i]
+
+		if ev.Type != e.typ || ev.Actor.Username != e.actor ||
+			ev.Ticket.Key != "TEST-1" || ev.ProjectKey != "TEST" {
+			t.Errorf("Expected %s by %s on TEST-1 Got %v\n", e.typ, e.actor, ev)
+		}
+	}
+
+	if r.events[1].Comment == nil || r.events[1].Comment.ID != c.ID {
+		t.Errorf("Expected comment %d Got %v\n", c.ID, r.events[1].Comment)
+	}
+
+	if r.events[4].Ticket.Assignee.ID != assignee.ID {
+		t.Errorf("Expected assignee %d Got %v\n", assignee.ID,
+			r.events[4].Ticket.Assignee)
+	}
+
+	if r.events[5].Ticket.Status.Name != "Done" {
+		t.Errorf("Expected the ticket to be Done Got %v\n", r.events[5].Ticket.Status)
+	}
+}
+
+func TestStoreWithTx(t *testing.T) {
+	r := &recorder{}
+	s := Store(stubStore{tickets: &stubTickets{}}, r)
+
+	p := models.Project{Key: "TEST"}
+
+	err := s.WithTx(func(tx store.Store) error {
+		err := tx.Tickets().New(p, &models.Ticket{Summary: "Rolled back"})
+		if err != nil {
+			return err
+		}
+
+		if len(r.events) != 0 {
+			t.Errorf("Expected no events before the commit Got %v\n", r.events)
+		}
+
+		return errors.New("rollback")
+	})
+	if err == nil || len(r.events) != 0 {
+		t.Errorf("Expected no events after a rollback Got %v\n", r.events)
+	}
+
+	err = s.WithTx(func(tx store.Store) error {
+		return tx.Tickets().New(p, &models.Ticket{Summary: "Committed"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.events) != 1 || r.events[0].Ticket.Summary != "Committed" {
+		t.Errorf("Expected the committed ticket's event Got %v\n", r.events)
+	}
+}
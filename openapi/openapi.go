@@ -0,0 +1,325 @@
+// Package openapi describes the routes of the api as an OpenAPI 3 document.
+// Routes are registered with values of their request and response bodies and
+// the schemas of those types are generated from their json tags.
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the version of the OpenAPI specification documents follow
+const Version = "3.0.3"
+
+// Route describes a single method and path of the api, Request and Response
+// are values of the body types and are nil if there is no body. A string
+// Response is documented as a text/plain body.
+type Route struct {
+	Method   string
+	Path     string
+	Summary  string
+	Request  interface{}
+	Response interface{}
+}
+
+// Spec collects the routes of an api so they can be described by Document
+type Spec struct {
+	Title   string
+	Version string
+
+	// Error is a value of the body sent with error responses, if set it's
+	// documented as the default response of every route.
+	Error interface{}
+
+	mu     sync.Mutex
+	routes []Route
+}
+
+// New returns a Spec with no routes
+func New(title, version string) *Spec {
+	return &Spec{Title: title, Version: version}
+}
+
+// Register adds a route to the spec, registering the same method and path
+// again replaces it.
+func (s *Spec) Register(method, path, summary string, request, response interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := Route{strings.ToUpper(method), path, summary, request, response}
+
+	for i := range s.routes {
+		if s.routes[i].Method == r.Method && s.routes[i].Path == r.Path {
+			s.routes[i] = r
+			return
+		}
+	}
+
+	s.routes = append(s.routes, r)
+}
+
+// Document is an OpenAPI document, only the parts used by Spec are included
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info is the title and version of the described api
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps the lower case methods of a path to their operations
+type PathItem map[string]Operation
+
+// Operation describes a single method of a path
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter describes a path parameter of an operation
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body sent to an operation
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body with a given content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas of the named types referenced by operations
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of JSON schema generated for Go types, an empty
+// schema allows any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// pathParam matches the mux style parameters of a path such as {username}
+var pathParam = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// Document returns the OpenAPI document describing the registered routes
+func (s *Spec) Document() Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := generator{schemas: make(map[string]*Schema)}
+	doc := Document{
+		OpenAPI: Version,
+		Info:    Info{s.Title, s.Version},
+		Paths:   make(map[string]PathItem),
+	}
+
+	for _, r := range s.routes {
+		// Regular expressions on mux parameters aren't part of the path.
+		path := pathParam.ReplaceAllString(r.Path, "{$1}")
+
+		op := Operation{
+			Summary:   r.Summary,
+			Responses: map[string]Response{"200": g.response("OK", r.Response)},
+		}
+
+		if s.Error != nil {
+			op.Responses["default"] = g.response("Error", s.Error)
+		}
+
+		for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     m[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+
+		if r.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {g.schema(r.Request)}},
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+// MarshalJSON encodes the spec as it's OpenAPI document
+func (s *Spec) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Document())
+}
+
+// generator builds schemas for Go values, exported named structs are put in
+// schemas and referenced so each is only described once.
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func (g *generator) response(description string, body interface{}) Response {
+	res := Response{Description: description}
+
+	switch body.(type) {
+	case nil:
+	case string:
+		res.Content = map[string]MediaType{"text/plain": {&Schema{Type: "string"}}}
+	default:
+		res.Content = map[string]MediaType{"application/json": {g.schema(body)}}
+	}
+
+	return res
+}
+
+func (g *generator) schema(v interface{}) *Schema {
+	return g.typeSchema(reflect.TypeOf(v))
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (g *generator) typeSchema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType):
+		// There's no telling what a custom marshaler sends.
+		return &Schema{}
+	case t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Uint:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+
+		return &Schema{Type: "array", Items: g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+
+	return &Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	name := t.Name()
+	if name == "" || !isExported(name) {
+		return g.objectSchema(t)
+	}
+
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := g.schemas[name]; ok {
+		return ref
+	}
+
+	// The placeholder stops types which refer to themselves recursing.
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.objectSchema(t)
+	return ref
+}
+
+func (g *generator) objectSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addProperties(s, t)
+	return s
+}
+
+// addProperties adds the fields of the struct type t to s the way
+// encoding/json would encode them, embedded structs without a tag have their
+// fields added to s.
+func (g *generator) addProperties(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.addProperties(s, ft)
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = g.typeSchema(f.Type)
+	}
+}
+
+func isExported(name string) bool {
+	return strings.ToUpper(name[:1]) == name[:1]
+}
@@ -0,0 +1,77 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type Node struct {
+	Name     string            `json:"name"`
+	Created  time.Time         `json:"created"`
+	Children []*Node           `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Secret   string            `json:"-"`
+	hidden   int
+}
+
+type nodeRequest struct {
+	Node  `json:"node"`
+	Count int64 `json:"count"`
+}
+
+func TestDocument(t *testing.T) {
+	s := New("Test", "1")
+	s.Register("post", "/nodes/{id:[0-9]+}", "Create a node", nodeRequest{}, Node{})
+	s.Register("GET", "/nodes/{id}/name", "Get a node's name", nil, "")
+
+	byt, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc Document
+
+	err = json.Unmarshal(byt, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op, ok := doc.Paths["/nodes/{id}"]["post"]
+	if !ok {
+		t.Fatalf("Expected post /nodes/{id} Got %v\n", doc.Paths)
+	}
+
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "id" {
+		t.Errorf("Expected the id parameter Got %v\n", op.Parameters)
+	}
+
+	req := op.RequestBody.Content["application/json"].Schema
+	if req.Properties["node"].Ref != "#/components/schemas/Node" ||
+		req.Properties["count"].Format != "int64" {
+		t.Errorf("Expected an inline request Got %v\n", req.Properties)
+	}
+
+	node := doc.Components.Schemas["Node"]
+	if node == nil {
+		t.Fatalf("Expected the Node schema Got %v\n", doc.Components.Schemas)
+	}
+
+	if len(node.Properties) != 4 {
+		t.Errorf("Expected 4 properties Got %v\n", node.Properties)
+	}
+
+	if node.Properties["created"].Format != "date-time" {
+		t.Errorf("Expected a date-time Got %v\n", node.Properties["created"])
+	}
+
+	if node.Properties["children"].Items.Ref != "#/components/schemas/Node" {
+		t.Errorf("Expected children to refer to Node Got %v\n",
+			node.Properties["children"].Items)
+	}
+
+	name := doc.Paths["/nodes/{id}/name"]["get"].Responses["200"]
+	if _, ok := name.Content["text/plain"]; !ok {
+		t.Errorf("Expected a text/plain response Got %v\n", name.Content)
+	}
+}
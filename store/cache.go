@@ -0,0 +1,241 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/praelatus/backend/models"
+)
+
+// CachedUsers wraps s so that Users().Get is served from a cache of up to
+// size users keyed by username, cached users expire after ttl. A size of 0
+// returns s unchanged.
+func CachedUsers(s Store, size int, ttl time.Duration) Store {
+	if size <= 0 {
+		return s
+	}
+
+	return cachedStore{s, newUserCache(size, ttl)}
+}
+
+type cachedStore struct {
+	Store
+	cache *userCache
+}
+
+func (s cachedStore) Users() UserStore {
+	return &CachedUserStore{s.Store.Users(), s.cache}
+}
+
+func (s cachedStore) WithContext(ctx context.Context) Store {
+	return cachedStore{s.Store.WithContext(ctx), s.cache}
+}
+
+// WithTx runs fn with a store whose users bypass the cache, the users it
+// saves or removes are only invalidated once the transaction is committed so
+// a rollback can't leave uncommitted users cached.
+func (s cachedStore) WithTx(fn func(tx Store) error) error {
+	var changed []models.User
+
+	err := s.Store.WithTx(func(tx Store) error {
+		return fn(txCachedStore{tx, &changed})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, u := range changed {
+		s.cache.invalidate(u)
+	}
+
+	return nil
+}
+
+// txCachedStore is the store given to the functions run by cachedStore.WithTx,
+// it records the users to invalidate in changed.
+type txCachedStore struct {
+	Store
+	changed *[]models.User
+}
+
+func (s txCachedStore) Users() UserStore {
+	return txUserStore{s.Store.Users(), s.changed}
+}
+
+func (s txCachedStore) WithContext(ctx context.Context) Store {
+	return txCachedStore{s.Store.WithContext(ctx), s.changed}
+}
+
+func (s txCachedStore) WithTx(fn func(tx Store) error) error {
+	return s.Store.WithTx(func(tx Store) error {
+		return fn(txCachedStore{tx, s.changed})
+	})
+}
+
+type txUserStore struct {
+	UserStore
+	changed *[]models.User
+}
+
+func (s txUserStore) Save(u models.User) error {
+	*s.changed = append(*s.changed, u)
+	return s.UserStore.Save(u)
+}
+
+func (s txUserStore) Remove(u models.User) error {
+	*s.changed = append(*s.changed, u)
+	return s.UserStore.Remove(u)
+}
+
+func (s txUserStore) VerifyEmail(token string) (models.User, error) {
+	u, err := s.UserStore.VerifyEmail(token)
+	if err == nil {
+		*s.changed = append(*s.changed, u)
+	}
+
+	return u, err
+}
+
+// CachedUserStore is a UserStore which caches the users returned by Get when
+// they are looked up by username, the cache is invalidated when a user is
+// saved, removed or verifies their email.
+type CachedUserStore struct {
+	UserStore
+	cache *userCache
+}
+
+// NewCachedUserStore returns a CachedUserStore in front of users which keeps
+// up to size users for ttl.
+func NewCachedUserStore(users UserStore, size int, ttl time.Duration) *CachedUserStore {
+	return &CachedUserStore{users, newUserCache(size, ttl)}
+}
+
+// Get will return the cached user if u is looked up by username only,
+// otherwise it gets the user from the wrapped store.
+func (s *CachedUserStore) Get(u *models.User) error {
+	if u.ID != 0 || u.Username == "" {
+		return s.UserStore.Get(u)
+	}
+
+	if cached, ok := s.cache.get(u.Username); ok {
+		*u = cached
+		return nil
+	}
+
+	err := s.UserStore.Get(u)
+	if err != nil {
+		return err
+	}
+
+	s.cache.set(*u)
+	return nil
+}
+
+// Save will save the user and remove it from the cache
+func (s *CachedUserStore) Save(u models.User) error {
+	defer s.cache.invalidate(u)
+	return s.UserStore.Save(u)
+}
+
+// Remove will remove the user and remove it from the cache
+func (s *CachedUserStore) Remove(u models.User) error {
+	defer s.cache.invalidate(u)
+	return s.UserStore.Remove(u)
+}
+
+// VerifyEmail will verify the email of the user the token was sent to and
+// remove them from the cache
+func (s *CachedUserStore) VerifyEmail(token string) (models.User, error) {
+	u, err := s.UserStore.VerifyEmail(token)
+	if err == nil {
+		s.cache.invalidate(u)
+	}
+
+	return u, err
+}
+
+// userCache is a least recently used cache of users with an expiry
+type userCache struct {
+	lock    sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type userEntry struct {
+	user    models.User
+	expires time.Time
+}
+
+func newUserCache(size int, ttl time.Duration) *userCache {
+	return &userCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *userCache) get(username string) (models.User, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	el, ok := c.entries[username]
+	if !ok {
+		return models.User{}, false
+	}
+
+	e := el.Value.(*userEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return models.User{}, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.user, true
+}
+
+func (c *userCache) set(u models.User) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.entries[u.Username]; ok {
+		c.remove(el)
+	}
+
+	c.entries[u.Username] = c.order.PushFront(&userEntry{
+		user:    u,
+		expires: time.Now().Add(c.ttl),
+	})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// invalidate removes the entries for the user by username and id, since a
+// save may have changed the username the id has to be checked as well.
+func (c *userCache) invalidate(u models.User) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+
+		e := el.Value.(*userEntry)
+		if (u.ID != 0 && e.user.ID == u.ID) ||
+			(u.Username != "" && e.user.Username == u.Username) {
+			c.remove(el)
+		}
+
+		el = next
+	}
+}
+
+func (c *userCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*userEntry).user.Username)
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/praelatus/backend/models"
+)
+
+// countingUsers counts the calls to Get which reach it
+type countingUsers struct {
+	UserStore
+	gets int
+}
+
+func (c *countingUsers) Get(u *models.User) error {
+	c.gets++
+
+	if u.Username == "missing" {
+		return ErrNotFound
+	}
+
+	u.ID = 1
+	u.Email = "foo@foo.com"
+	return nil
+}
+
+func (c *countingUsers) Save(u models.User) error {
+	return nil
+}
+
+func TestCachedUserStoreGet(t *testing.T) {
+	users := &countingUsers{}
+	s := NewCachedUserStore(users, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		u := models.User{Username: "foouser"}
+
+		err := s.Get(&u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if u.ID != 1 || u.Email != "foo@foo.com" {
+			t.Errorf("Expected foouser Got %v", u)
+		}
+	}
+
+	if users.gets != 1 {
+		t.Errorf("Expected 1 call to the wrapped store Got %d", users.gets)
+	}
+
+	err := s.Get(&models.User{Username: "missing"})
+	if err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v", err)
+	}
+
+	s.Get(&models.User{Username: "missing"})
+	if users.gets != 3 {
+		t.Errorf("Expected missing users not to be cached Got %d calls", users.gets)
+	}
+}
+
+func TestCachedUserStoreSaveInvalidates(t *testing.T) {
+	users := &countingUsers{}
+	s := NewCachedUserStore(users, 10, time.Minute)
+
+	s.Get(&models.User{Username: "foouser"})
+
+	err := s.Save(models.User{ID: 1, Username: "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Get(&models.User{Username: "foouser"})
+	if users.gets != 2 {
+		t.Errorf("Expected Save to invalidate foouser Got %d calls", users.gets)
+	}
+}
+
+func TestCachedUserStoreExpiry(t *testing.T) {
+	users := &countingUsers{}
+	s := NewCachedUserStore(users, 1, time.Millisecond)
+
+	s.Get(&models.User{Username: "foouser"})
+	time.Sleep(5 * time.Millisecond)
+	s.Get(&models.User{Username: "foouser"})
+
+	if users.gets != 2 {
+		t.Errorf("Expected the cached user to expire Got %d calls", users.gets)
+	}
+
+	s = NewCachedUserStore(users, 1, time.Minute)
+	users.gets = 0
+
+	s.Get(&models.User{Username: "foouser"})
+	s.Get(&models.User{Username: "baruser"})
+	s.Get(&models.User{Username: "foouser"})
+
+	if users.gets != 3 {
+		t.Errorf("Expected foouser to be evicted Got %d calls", users.gets)
+	}
+}
+
+// txStore runs WithTx functions on itself, as if every transaction was
+// committed unless the function fails.
+type txStore struct {
+	Store
+	users *countingUsers
+}
+
+func (s txStore) Users() UserStore {
+	return s.users
+}
+
+func (s txStore) WithTx(fn func(tx Store) error) error {
+	return fn(s)
+}
+
+func TestCachedStoreWithTx(t *testing.T) {
+	users := &countingUsers{}
+	s := CachedUsers(txStore{users: users}, 10, time.Minute)
+
+	get := func() {
+		err := s.Users().Get(&models.User{Username: "foouser"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get()
+
+	save := func(tx Store) error {
+		err := tx.Users().Save(models.User{ID: 1, Username: "foouser"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		get()
+		return errors.New("rolled back")
+	}
+
+	s.WithTx(save)
+
+	if users.gets != 1 {
+		t.Errorf("Expected the user to stay cached after a rollback Got %d calls", users.gets)
+	}
+
+	err := s.WithTx(func(tx Store) error {
+		save(tx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get()
+
+	if users.gets != 2 {
+		t.Errorf("Expected the user to be invalidated after a commit Got %d calls", users.gets)
+	}
+}
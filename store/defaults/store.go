@@ -5,12 +5,28 @@
 package defaults
 
 import (
+	"log"
+
 	"github.com/praelatus/backend/config"
 	"github.com/praelatus/backend/store"
+	"github.com/praelatus/backend/store/mem"
 	"github.com/praelatus/backend/store/pg"
+	"github.com/praelatus/backend/store/sqlite"
 )
 
-// Store returns the default store.Store implementation (postgres)
+// Store returns the store.Store implementation selected by
+// config.GetStoreBackend, either "postgres" (the default), "sqlite" or
+// "memory". The sqlite backend opens config.GetDbURL as a SQLite dsn.
 func Store() store.Store {
-	return pg.New(config.GetDbURL())
+	switch config.GetStoreBackend() {
+	case "postgres":
+		return pg.New(config.GetDbURL())
+	case "sqlite":
+		return sqlite.New(config.GetDbURL())
+	case "memory":
+		return mem.New()
+	default:
+		log.Panicln("Unknown store backend:", config.GetStoreBackend())
+		return nil
+	}
 }
@@ -0,0 +1,78 @@
+// Package localfs implements a store.BlobStore which keeps blobs as files
+// on the local filesystem.
+package localfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/praelatus/backend/store"
+)
+
+// BlobStore keeps blobs as files under a root directory
+type BlobStore struct {
+	root string
+}
+
+// New will return a BlobStore which stores files under the given directory,
+// creating it if necessary.
+func New(root string) (store.BlobStore, error) {
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlobStore{root}, nil
+}
+
+// path will return the location of key on disk, keys are cleaned so they
+// can't refer to anything outside of the root directory.
+func (bs *BlobStore) path(key string) string {
+	return filepath.Join(bs.root, filepath.Clean("/"+key))
+}
+
+// Put will write the contents of r to the file for key, replacing it if it
+// already exists.
+func (bs *BlobStore) Put(key string, r io.Reader) error {
+	p := bs.path(key)
+
+	err := os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Get will open the file for key, the caller is responsible for closing it.
+func (bs *BlobStore) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(bs.path(key))
+	if os.IsNotExist(err) {
+		return nil, store.ErrNotFound
+	}
+
+	return f, err
+}
+
+// Remove will delete the file for key, removing a key which doesn't exist is
+// not an error.
+func (bs *BlobStore) Remove(key string) error {
+	err := os.Remove(bs.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
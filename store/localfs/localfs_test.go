@@ -0,0 +1,77 @@
+package localfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/praelatus/backend/store"
+	"github.com/praelatus/backend/store/localfs"
+)
+
+func TestBlobStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "praelatus-blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bs, err := localfs.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bs.Put("tickets/1/test.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := bs.Get("tickets/1/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "hello" {
+		t.Errorf("Expected hello Got %s\n", b)
+	}
+
+	err = bs.Remove("tickets/1/test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = bs.Get("tickets/1/test.txt")
+	if err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", err)
+	}
+}
+
+func TestBlobStoreKeyEscape(t *testing.T) {
+	dir, err := ioutil.TempDir("", "praelatus-blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bs, err := localfs.New(dir + "/root")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bs.Put("../escaped.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(dir + "/escaped.txt")
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected the blob to stay inside the root Got %v\n", err)
+	}
+}
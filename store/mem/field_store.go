@@ -0,0 +1,297 @@
+package mem
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// FieldStore contains methods for storing and retrieving Fields in memory
+type FieldStore struct {
+	db *db
+}
+
+// findField returns the field matching f's ID or Name
+func (d *db) findField(f models.Field) (models.Field, bool) {
+	if field, ok := d.fields[f.ID]; ok {
+		return field, true
+	}
+
+	for _, field := range d.fields {
+		if f.Name != "" && field.Name == f.Name {
+			return field, true
+		}
+	}
+
+	return models.Field{}, false
+}
+
+// fieldNameTaken reports whether a field other than id has the name
+func (d *db) fieldNameTaken(id int64, name string) bool {
+	for fid, field := range d.fields {
+		if fid != id && field.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get retrieves a field by ID or Name
+func (fs *FieldStore) Get(f *models.Field) error {
+	fs.db.mu.RLock()
+	defer fs.db.mu.RUnlock()
+
+	field, ok := fs.db.findField(*f)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	*f = models.Field{ID: field.ID, Name: field.Name, DataType: field.DataType}
+	return nil
+}
+
+// GetAll will return all fields
+func (fs *FieldStore) GetAll() ([]models.Field, error) {
+	fs.db.mu.RLock()
+	defer fs.db.mu.RUnlock()
+
+	var fields []models.Field
+	ids := make([]int64, 0, len(fs.db.fields))
+	for id := range fs.db.fields {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		f := fs.db.fields[id]
+		fields = append(fields,
+			models.Field{ID: f.ID, Name: f.Name, DataType: f.DataType})
+	}
+
+	return fields, nil
+}
+
+// GetByProject retrieves all Fields associated with a project
+func (fs *FieldStore) GetByProject(p models.Project) ([]models.Field, error) {
+	fs.db.mu.RLock()
+	defer fs.db.mu.RUnlock()
+
+	var fields []models.Field
+
+	for _, pf := range fs.db.projectFields {
+		if fs.db.projects[pf.projectID].Key != p.Key {
+			continue
+		}
+
+		f := fs.db.fields[pf.fieldID]
+		fields = append(fields,
+			models.Field{ID: f.ID, Name: f.Name, DataType: f.DataType})
+	}
+
+	return fields, nil
+}
+
+// GetFieldsForType retrieves the Fields which apply to tickets of the given
+// type in a project, fields added to the project without a ticket type apply
+// to every type.
+func (fs *FieldStore) GetFieldsForType(p models.Project,
+	tt models.TicketType) ([]models.Field, error) {
+	fs.db.mu.RLock()
+	defer fs.db.mu.RUnlock()
+
+	pid, _ := fs.db.findProject(p)
+	return fs.db.fieldsForType(pid, tt.ID), nil
+}
+
+// fieldsForType returns the fields added to the project for the ticket type
+// ordered by ID, a field is required if any of it's rows require it.
+func (d *db) fieldsForType(projectID, typeID int64) []models.Field {
+	byID := make(map[int64]int)
+	fields := []models.Field{}
+
+	for _, pf := range d.projectFields {
+		if pf.projectID != projectID || (pf.typeID != 0 && pf.typeID != typeID) {
+			continue
+		}
+
+		if i, ok := byID[pf.fieldID]; ok {
+			fields[i].Required = fields[i].Required || pf.required
+			continue
+		}
+
+		f := d.fields[pf.fieldID]
+		byID[pf.fieldID] = len(fields)
+		fields = append(fields, models.Field{ID: f.ID, Name: f.Name,
+			DataType: f.DataType, Required: pf.required})
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].ID < fields[j].ID })
+	return fields
+}
+
+// AddToProject adds a field to a project's tickets
+func (fs *FieldStore) AddToProject(project models.Project, field *models.Field,
+	ticketTypes ...models.TicketType) error {
+	fs.db.mu.Lock()
+	defer fs.db.mu.Unlock()
+
+	if ticketTypes == nil {
+		fs.db.projectFields = append(fs.db.projectFields,
+			projectField{fieldID: field.ID, projectID: project.ID,
+				required: field.Required})
+		return nil
+	}
+
+	for _, typ := range ticketTypes {
+		fs.db.projectFields = append(fs.db.projectFields,
+			projectField{fieldID: field.ID, projectID: project.ID, typeID: typ.ID,
+				required: field.Required})
+	}
+
+	return nil
+}
+
+// Save updates an existing field
+func (fs *FieldStore) Save(field models.Field) error {
+	fs.db.mu.Lock()
+	defer fs.db.mu.Unlock()
+
+	old, ok := fs.db.fields[field.ID]
+	if !ok {
+		return nil
+	}
+
+	if fs.db.fieldNameTaken(field.ID, field.Name) {
+		return store.ErrDuplicateEntry
+	}
+
+	old.Name = field.Name
+	old.DataType = field.DataType
+	fs.db.fields[field.ID] = old
+	return nil
+}
+
+// New creates a new Field, the field's options are kept for OPT values.
+func (fs *FieldStore) New(field *models.Field) error {
+	fs.db.mu.Lock()
+	defer fs.db.mu.Unlock()
+
+	if fs.db.fieldNameTaken(0, field.Name) {
+		return store.ErrDuplicateEntry
+	}
+
+	field.ID = fs.db.nextID("fields")
+	fs.db.fields[field.ID] = *field
+	return nil
+}
+
+// Remove removes a field, fields with values on tickets are not removed.
+func (fs *FieldStore) Remove(field models.Field) error {
+	fs.db.mu.Lock()
+	defer fs.db.mu.Unlock()
+
+	name := fs.db.fields[field.ID].Name
+	for _, t := range fs.db.tickets {
+		for _, fv := range t.Fields {
+			if fv.Name == name {
+				return errors.New("that field is currently in use, refusing to delete")
+			}
+		}
+	}
+
+	delete(fs.db.fields, field.ID)
+	return nil
+}
+
+// GetFieldOptions returns the options of the field in the order they were
+// added
+func (fs *FieldStore) GetFieldOptions(fieldID int64) ([]string, error) {
+	fs.db.mu.RLock()
+	defer fs.db.mu.RUnlock()
+
+	f, ok := fs.db.fields[fieldID]
+	if !ok {
+		return []string{}, store.ErrNotFound
+	}
+
+	return append([]string{}, f.Options.Options...), nil
+}
+
+// AddFieldOption adds an option to the field, options are unique per field
+func (fs *FieldStore) AddFieldOption(fieldID int64, option string) error {
+	if option == "" {
+		return store.ErrMissingField
+	}
+
+	fs.db.mu.Lock()
+	defer fs.db.mu.Unlock()
+
+	f, ok := fs.db.fields[fieldID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	for _, opt := range f.Options.Options {
+		if opt == option {
+			return store.ErrDuplicateEntry
+		}
+	}
+
+	// The options are copied so clones of the db don't share them.
+	f.Options.Options = append(append([]string(nil), f.Options.Options...), option)
+	fs.db.fields[fieldID] = f
+	return nil
+}
+
+// RemoveFieldOption removes an option from the field, options selected on
+// any ticket are not removed.
+func (fs *FieldStore) RemoveFieldOption(fieldID int64, option string) error {
+	fs.db.mu.Lock()
+	defer fs.db.mu.Unlock()
+
+	f, ok := fs.db.fields[fieldID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	for _, t := range fs.db.tickets {
+		for _, fv := range t.Fields {
+			if fv.Name == f.Name && selects(fv, option) {
+				return store.ErrInUse
+			}
+		}
+	}
+
+	var options []string
+	for _, opt := range f.Options.Options {
+		if opt != option {
+			options = append(options, opt)
+		}
+	}
+
+	if len(options) == len(f.Options.Options) {
+		return store.ErrNotFound
+	}
+
+	f.Options.Options = options
+	fs.db.fields[fieldID] = f
+	return nil
+}
+
+// selects reports whether the OPT or MULTI_OPT value fv has option selected
+func selects(fv models.FieldValue, option string) bool {
+	if fo, ok := fv.Value.(models.FieldOption); ok {
+		return fo.Selected == option
+	}
+
+	selected, _ := models.Selections(fv.Value)
+	for _, s := range selected {
+		if s == option {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,97 @@
+package mem
+
+import (
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// LabelStore contains methods for storing and retrieving Labels in memory
+type LabelStore struct {
+	db *db
+}
+
+// Get gets a label by ID, or by name if the ID is not set
+func (ls *LabelStore) Get(l *models.Label) error {
+	ls.db.mu.RLock()
+	defer ls.db.mu.RUnlock()
+
+	if l.ID != 0 {
+		label, ok := ls.db.labels[l.ID]
+		if !ok {
+			return store.ErrNotFound
+		}
+
+		*l = label
+		return nil
+	}
+
+	for _, label := range ls.db.labels {
+		if label.Name == l.Name {
+			*l = label
+			return nil
+		}
+	}
+
+	return store.ErrNotFound
+}
+
+// GetAll gets all labels
+func (ls *LabelStore) GetAll() ([]models.Label, error) {
+	ls.db.mu.RLock()
+	defer ls.db.mu.RUnlock()
+
+	var labels []models.Label
+	ids := make([]int64, 0, len(ls.db.labels))
+	for id := range ls.db.labels {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		labels = append(labels, ls.db.labels[id])
+	}
+
+	return labels, nil
+}
+
+// New creates a new label
+func (ls *LabelStore) New(label *models.Label) error {
+	ls.db.mu.Lock()
+	defer ls.db.mu.Unlock()
+
+	label.ID = ls.db.nextID("labels")
+	ls.db.labels[label.ID] = *label
+	return nil
+}
+
+// Save updates a label
+func (ls *LabelStore) Save(label models.Label) error {
+	ls.db.mu.Lock()
+	defer ls.db.mu.Unlock()
+
+	if _, ok := ls.db.labels[label.ID]; ok {
+		ls.db.labels[label.ID] = label
+	}
+
+	return nil
+}
+
+// Remove removes a label and takes it off of any tickets
+func (ls *LabelStore) Remove(label models.Label) error {
+	ls.db.mu.Lock()
+	defer ls.db.mu.Unlock()
+
+	for id, t := range ls.db.tickets {
+		var labels []models.Label
+		for _, l := range t.Labels {
+			if l.ID != label.ID {
+				labels = append(labels, l)
+			}
+		}
+
+		t.Labels = labels
+		ls.db.tickets[id] = t
+	}
+
+	delete(ls.db.labels, label.ID)
+	return nil
+}
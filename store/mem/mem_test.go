@@ -0,0 +1,12 @@
+package mem_test
+
+import (
+	"testing"
+
+	"github.com/praelatus/backend/store/mem"
+	"github.com/praelatus/backend/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, mem.New())
+}
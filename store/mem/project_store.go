@@ -0,0 +1,361 @@
+package mem
+
+import (
+	"time"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// ProjectStore contains methods for storing and retrieving Projects in
+// memory
+type ProjectStore struct {
+	db *db
+}
+
+// findProject returns the id of the project matching p's ID or Key
+func (d *db) findProject(p models.Project) (int64, bool) {
+	if _, ok := d.projects[p.ID]; ok {
+		return p.ID, true
+	}
+
+	for id, project := range d.projects {
+		if p.Key != "" && project.Key == p.Key {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+// keyTaken reports whether a project other than id has the key
+func (d *db) keyTaken(id int64, key string) bool {
+	for pid, project := range d.projects {
+		if pid != id && project.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// project returns the project with it's lead filled in
+func (d *db) project(id int64) models.Project {
+	p := d.projects[id]
+	p.Lead = d.publicUser(p.Lead.ID)
+	return p
+}
+
+// Get gets a project by it's ID or Key
+func (ps *ProjectStore) Get(p *models.Project) error {
+	ps.db.mu.RLock()
+	defer ps.db.mu.RUnlock()
+
+	id, ok := ps.db.findProject(*p)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	*p = ps.db.project(id)
+	return nil
+}
+
+// GetAll returns all projects which aren't archived
+func (ps *ProjectStore) GetAll() ([]models.Project, error) {
+	return ps.getAll(false)
+}
+
+// GetAllIncludingArchived returns all projects including archived ones
+func (ps *ProjectStore) GetAllIncludingArchived() ([]models.Project, error) {
+	return ps.getAll(true)
+}
+
+func (ps *ProjectStore) getAll(archived bool) ([]models.Project, error) {
+	ps.db.mu.RLock()
+	defer ps.db.mu.RUnlock()
+
+	var projects []models.Project
+	ids := make([]int64, 0, len(ps.db.projects))
+	for id, p := range ps.db.projects {
+		if archived || !p.Archived {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range sortedIDs(ids) {
+		projects = append(projects, ps.db.project(id))
+	}
+
+	return projects, nil
+}
+
+// GetProjectForTicket returns the project of the ticket with the given key
+func (ps *ProjectStore) GetProjectForTicket(key string) (models.Project, error) {
+	ps.db.mu.RLock()
+	defer ps.db.mu.RUnlock()
+
+	tid, ok := ps.db.findTicket(models.Ticket{Key: key})
+	if !ok || key == "" {
+		return models.Project{}, store.ErrNotFound
+	}
+
+	return ps.db.project(ps.db.tickets[tid].projectID), nil
+}
+
+// New creates a new Project
+func (ps *ProjectStore) New(project *models.Project) error {
+	ps.db.mu.Lock()
+	defer ps.db.mu.Unlock()
+
+	if ps.db.keyTaken(0, project.Key) {
+		return store.ErrDuplicateEntry
+	}
+
+	project.ID = ps.db.nextID("projects")
+	project.CreatedDate = time.Now()
+	ps.db.projects[project.ID] = *project
+	return nil
+}
+
+// Save updates a Project
+func (ps *ProjectStore) Save(project models.Project) error {
+	ps.db.mu.Lock()
+	defer ps.db.mu.Unlock()
+
+	old, ok := ps.db.projects[project.ID]
+	if !ok {
+		return nil
+	}
+
+	if ps.db.keyTaken(project.ID, project.Key) {
+		return store.ErrDuplicateEntry
+	}
+
+	project.CreatedDate = old.CreatedDate
+	project.Archived = old.Archived
+	ps.db.projects[project.ID] = project
+	return nil
+}
+
+// ArchiveProject archives the project so it's hidden from GetAll and no new
+// tickets can be created in it.
+func (ps *ProjectStore) ArchiveProject(project models.Project) error {
+	return ps.setArchived(project, true)
+}
+
+// UnarchiveProject reverses ArchiveProject.
+func (ps *ProjectStore) UnarchiveProject(project models.Project) error {
+	return ps.setArchived(project, false)
+}
+
+func (ps *ProjectStore) setArchived(project models.Project, archived bool) error {
+	ps.db.mu.Lock()
+	defer ps.db.mu.Unlock()
+
+	id, ok := ps.db.findProject(project)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	p := ps.db.projects[id]
+	p.Archived = archived
+	ps.db.projects[id] = p
+	return nil
+}
+
+// Remove removes a Project along with all of it's workflows, the project's
+// tickets are only removed with it if cascade is true.
+func (ps *ProjectStore) Remove(project models.Project, cascade bool) error {
+	ps.db.mu.Lock()
+	defer ps.db.mu.Unlock()
+
+	id, ok := ps.db.findProject(project)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	project.ID = id
+
+	for _, t := range ps.db.tickets {
+		if t.projectID == project.ID && !cascade {
+			return store.ErrProjectHasTickets
+		}
+	}
+
+	var fields []projectField
+	for _, pf := range ps.db.projectFields {
+		if pf.projectID != project.ID {
+			fields = append(fields, pf)
+		}
+	}
+
+	ps.db.projectFields = fields
+
+	for id, t := range ps.db.tickets {
+		if t.projectID == project.ID {
+			ps.db.removeTicket(id)
+		}
+	}
+
+	for id, w := range ps.db.workflows {
+		if w.projectID == project.ID {
+			delete(ps.db.workflows, id)
+		}
+	}
+
+	delete(ps.db.projectMembers, project.ID)
+	delete(ps.db.counters, project.ID)
+	delete(ps.db.projects, project.ID)
+	return nil
+}
+
+// AddMember will add the user to the project with the given role, if the user
+// is already a member their role is changed instead.
+func (ps *ProjectStore) AddMember(p models.Project, u models.User,
+	role models.PermissionLevel) error {
+	ps.db.mu.Lock()
+	defer ps.db.mu.Unlock()
+
+	pid, ok := ps.db.findProject(p)
+	if !ok {
+		return nil
+	}
+
+	uid, ok := ps.db.findUser(u)
+	if !ok {
+		return nil
+	}
+
+	if ps.db.projectMembers[pid] == nil {
+		ps.db.projectMembers[pid] = make(map[int64]models.PermissionLevel)
+	}
+
+	ps.db.projectMembers[pid][uid] = role
+	return nil
+}
+
+// RemoveMember will remove the user from the project
+func (ps *ProjectStore) RemoveMember(p models.Project, u models.User) error {
+	ps.db.mu.Lock()
+	defer ps.db.mu.Unlock()
+
+	pid, _ := ps.db.findProject(p)
+	uid, _ := ps.db.findUser(u)
+
+	delete(ps.db.projectMembers[pid], uid)
+	return nil
+}
+
+// GetMembers will return the members of the project along with their roles
+func (ps *ProjectStore) GetMembers(p models.Project) ([]models.ProjectMember, error) {
+	ps.db.mu.RLock()
+	defer ps.db.mu.RUnlock()
+
+	members := []models.ProjectMember{}
+
+	pid, ok := ps.db.findProject(p)
+	if !ok {
+		return members, nil
+	}
+
+	ids := make([]int64, 0, len(ps.db.projectMembers[pid]))
+	for id := range ps.db.projectMembers[pid] {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		members = append(members, models.ProjectMember{
+			User: ps.db.publicUser(id),
+			Role: ps.db.projectMembers[pid][id],
+		})
+	}
+
+	return members, nil
+}
+
+// GetRole returns the role of the user in the project, store.ErrNotFound is
+// returned if the user is not a member.
+func (ps *ProjectStore) GetRole(p models.Project, u models.User) (models.PermissionLevel, error) {
+	ps.db.mu.RLock()
+	defer ps.db.mu.RUnlock()
+
+	pid, _ := ps.db.findProject(p)
+	uid, _ := ps.db.findUser(u)
+
+	role, ok := ps.db.projectMembers[pid][uid]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+
+	return role, nil
+}
+
+// GetRoles returns the roles of the user by the key of each project they are
+// a member or the lead of.
+func (ps *ProjectStore) GetRoles(u models.User) (map[string]models.PermissionLevel, error) {
+	ps.db.mu.RLock()
+	defer ps.db.mu.RUnlock()
+
+	roles := make(map[string]models.PermissionLevel)
+
+	uid, ok := ps.db.findUser(u)
+	if !ok {
+		return roles, nil
+	}
+
+	for pid, members := range ps.db.projectMembers {
+		if role, ok := members[uid]; ok {
+			roles[ps.db.projects[pid].Key] = role
+		}
+	}
+
+	for _, p := range ps.db.projects {
+		if p.Lead.ID == uid {
+			roles[p.Key] = models.AdminR
+		}
+	}
+
+	return roles, nil
+}
+
+// SuggestAssignee returns the active member of the project with the fewest
+// open tickets assigned to them across every project, ties go to the member
+// with the lowest id.
+func (ps *ProjectStore) SuggestAssignee(p models.Project) (models.User, error) {
+	ps.db.mu.RLock()
+	defer ps.db.mu.RUnlock()
+
+	pid, ok := ps.db.findProject(p)
+	if !ok {
+		return models.User{}, store.ErrNotFound
+	}
+
+	load := make(map[int64]int)
+	for _, t := range ps.db.tickets {
+		if t.Assignee.ID != 0 && !ps.db.statuses[t.Status.ID].Closed &&
+			ps.db.activeProject(t) {
+			load[t.Assignee.ID]++
+		}
+	}
+
+	ids := make([]int64, 0, len(ps.db.projectMembers[pid]))
+	for id := range ps.db.projectMembers[pid] {
+		if ps.db.users[id].IsActive {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return models.User{}, store.ErrNoMembers
+	}
+
+	ids = sortedIDs(ids)
+	best := ids[0]
+	for _, id := range ids[1:] {
+		if load[id] < load[best] {
+			best = id
+		}
+	}
+
+	return ps.db.publicUser(best), nil
+}
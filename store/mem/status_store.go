@@ -0,0 +1,154 @@
+package mem
+
+import (
+	"sort"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// StatusStore contains methods for storing and retrieving Statuses in memory
+type StatusStore struct {
+	db *db
+}
+
+// findStatus returns the status matching s's ID or Name
+func (d *db) findStatus(s models.Status) (models.Status, bool) {
+	if status, ok := d.statuses[s.ID]; ok {
+		return status, true
+	}
+
+	for _, status := range d.statuses {
+		if s.Name != "" && status.Name == s.Name {
+			return status, true
+		}
+	}
+
+	return models.Status{}, false
+}
+
+// Get gets a Status by it's ID or Name
+func (ss *StatusStore) Get(s *models.Status) error {
+	ss.db.mu.RLock()
+	defer ss.db.mu.RUnlock()
+
+	status, ok := ss.db.findStatus(*s)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	*s = status
+	return nil
+}
+
+// GetAll gets all statuses
+func (ss *StatusStore) GetAll() ([]models.Status, error) {
+	ss.db.mu.RLock()
+	defer ss.db.mu.RUnlock()
+
+	var statuses []models.Status
+	ids := make([]int64, 0, len(ss.db.statuses))
+	for id := range ss.db.statuses {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		statuses = append(statuses, ss.db.statuses[id])
+	}
+
+	return statuses, nil
+}
+
+// GetAllOrdered gets all statuses in board order
+func (ss *StatusStore) GetAllOrdered() ([]models.Status, error) {
+	statuses, err := ss.GetAll()
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Position < statuses[j].Position
+	})
+
+	return statuses, err
+}
+
+// New creates a new Status after every other status on the board
+func (ss *StatusStore) New(status *models.Status) error {
+	ss.db.mu.Lock()
+	defer ss.db.mu.Unlock()
+
+	status.Position = 0
+	for _, s := range ss.db.statuses {
+		if s.Position >= status.Position {
+			status.Position = s.Position + 1
+		}
+	}
+
+	status.ID = ss.db.nextID("statuses")
+	ss.db.statuses[status.ID] = *status
+	return nil
+}
+
+// Save updates a Status, it's position is only changed by Reorder
+func (ss *StatusStore) Save(status models.Status) error {
+	ss.db.mu.Lock()
+	defer ss.db.mu.Unlock()
+
+	if old, ok := ss.db.statuses[status.ID]; ok {
+		status.Position = old.Position
+		ss.db.statuses[status.ID] = status
+	}
+
+	return nil
+}
+
+// Reorder sets the positions of the statuses, store.ErrNotFound is returned
+// and nothing changed if any of the ids don't exist.
+func (ss *StatusStore) Reorder(ids []int64) error {
+	ss.db.mu.Lock()
+	defer ss.db.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := ss.db.statuses[id]; !ok {
+			return store.ErrNotFound
+		}
+	}
+
+	for id, s := range ss.db.statuses {
+		s.Position += len(ids)
+		ss.db.statuses[id] = s
+	}
+
+	for i, id := range ids {
+		s := ss.db.statuses[id]
+		s.Position = i
+		ss.db.statuses[id] = s
+	}
+
+	return nil
+}
+
+// Remove removes a status, store.ErrInUse is returned if any tickets or
+// workflows use it.
+func (ss *StatusStore) Remove(status models.Status) error {
+	ss.db.mu.Lock()
+	defer ss.db.mu.Unlock()
+
+	for _, t := range ss.db.tickets {
+		if t.Status.ID == status.ID {
+			return store.ErrInUse
+		}
+	}
+
+	for _, w := range ss.db.workflows {
+		for from, transitions := range w.Transitions {
+			for _, tr := range transitions {
+				if from == ss.db.statuses[status.ID].Name ||
+					tr.ToStatus.ID == status.ID {
+					return store.ErrInUse
+				}
+			}
+		}
+	}
+
+	delete(ss.db.statuses, status.ID)
+	return nil
+}
@@ -0,0 +1,443 @@
+// Package mem implements store.Store entirely in memory, it's intended for
+// tests and trying out Praelatus without setting up a database.
+package mem
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// Store implements store.Store with maps in memory, all of the sub stores
+// share the same data so removing a project removes it's tickets and so on.
+type Store struct {
+	users     *UserStore
+	teams     *TeamStore
+	labels    *LabelStore
+	fields    *FieldStore
+	tickets   *TicketStore
+	types     *TypeStore
+	projects  *ProjectStore
+	statuses  *StatusStore
+	workflows *WorkflowStore
+}
+
+// db holds every table of the in memory store, all access must hold mu.
+type db struct {
+	mu sync.RWMutex
+	tables
+}
+
+// tables are the contents of the in memory store, they are kept apart from
+// the mutex so a transaction can work on a copy of them.
+type tables struct {
+	ids map[string]int64
+
+	users     map[int64]models.User
+	teams     map[int64]models.Team
+	members   map[int64][]int64
+	labels    map[int64]models.Label
+	fields    map[int64]models.Field
+	projects  map[int64]models.Project
+	types     map[int64]models.TicketType
+	statuses  map[int64]models.Status
+	workflows map[int64]workflowRow
+
+	projectFields []projectField
+
+	// projectMembers maps a project id to the role of each of it's members
+	projectMembers map[int64]map[int64]models.PermissionLevel
+
+	// counters is the number of tickets ever created in each project, it's
+	// used to generate ticket keys.
+	counters map[int64]int
+
+	tickets     map[int64]ticketRow
+	comments    map[int64]commentRow
+	revisions   map[int64][]models.CommentRevision
+	watchers    map[int64]map[int64]bool
+	links       map[int64]models.TicketLink
+	history     map[int64][]models.HistoryEntry
+	worklogs    map[int64][]models.Worklog
+	attachments map[int64]attachmentRow
+
+	resets map[string]resetRow
+
+	// verifications are email verification tokens keyed by their hash
+	verifications map[string]resetRow
+
+	// refreshTokens are refresh tokens keyed by their hash
+	refreshTokens map[string]refreshRow
+
+	// loginAttempts are the failed logins of users by their id
+	loginAttempts map[int64]loginAttemptRow
+
+	// filters maps a user id to their saved filters by name
+	filters map[int64]map[string]store.SavedFilter
+
+	// reactions maps a comment id to the ids of the users who reacted with
+	// each emoji.
+	reactions map[int64]map[string]map[int64]bool
+}
+
+// projectField is a row of the field_tickettype_project table
+type projectField struct {
+	fieldID   int64
+	projectID int64
+	typeID    int64
+	required  bool
+}
+
+type workflowRow struct {
+	models.Workflow
+	projectID int64
+}
+
+type ticketRow struct {
+	models.Ticket
+	projectID int64
+}
+
+type commentRow struct {
+	models.Comment
+	ticketID int64
+}
+
+// resetRow is a password reset or email verification keyed by the hash of
+// it's token
+type resetRow struct {
+	userID  int64
+	expires time.Time
+	used    bool
+}
+
+// refreshRow is a refresh token, family is the hash of the first token of
+// the login it was rotated from
+type refreshRow struct {
+	resetRow
+	family string
+}
+
+// loginAttemptRow is a row of the login_attempts table
+type loginAttemptRow struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+type attachmentRow struct {
+	models.Attachment
+	ticketID int64
+}
+
+// New returns an empty in memory store
+func New() store.Store {
+	d := &db{tables: tables{
+		ids:           make(map[string]int64),
+		users:         make(map[int64]models.User),
+		teams:         make(map[int64]models.Team),
+		members:       make(map[int64][]int64),
+		labels:        make(map[int64]models.Label),
+		fields:        make(map[int64]models.Field),
+		projects:      make(map[int64]models.Project),
+		counters:      make(map[int64]int),
+		types:         make(map[int64]models.TicketType),
+		statuses:      make(map[int64]models.Status),
+		workflows:     make(map[int64]workflowRow),
+		tickets:       make(map[int64]ticketRow),
+		comments:      make(map[int64]commentRow),
+		revisions:     make(map[int64][]models.CommentRevision),
+		reactions:     make(map[int64]map[string]map[int64]bool),
+		watchers:      make(map[int64]map[int64]bool),
+		links:         make(map[int64]models.TicketLink),
+		history:       make(map[int64][]models.HistoryEntry),
+		worklogs:      make(map[int64][]models.Worklog),
+		filters:       make(map[int64]map[string]store.SavedFilter),
+		attachments:   make(map[int64]attachmentRow),
+		resets:        make(map[string]resetRow),
+		verifications: make(map[string]resetRow),
+		refreshTokens: make(map[string]refreshRow),
+		loginAttempts: make(map[int64]loginAttemptRow),
+
+		projectMembers: make(map[int64]map[int64]models.PermissionLevel),
+	}}
+
+	return storeOn(d)
+}
+
+// storeOn returns a Store whose sub stores all use d
+func storeOn(d *db) *Store {
+	return &Store{
+		users:     &UserStore{d},
+		teams:     &TeamStore{d},
+		labels:    &LabelStore{d},
+		fields:    &FieldStore{d},
+		tickets:   &TicketStore{d},
+		types:     &TypeStore{d},
+		projects:  &ProjectStore{d},
+		statuses:  &StatusStore{d},
+		workflows: &WorkflowStore{d},
+	}
+}
+
+// Users returns the underlying UserStore
+func (s *Store) Users() store.UserStore {
+	return s.users
+}
+
+// Teams returns the underlying TeamStore
+func (s *Store) Teams() store.TeamStore {
+	return s.teams
+}
+
+// Labels returns the underlying LabelStore
+func (s *Store) Labels() store.LabelStore {
+	return s.labels
+}
+
+// Fields returns the underlying FieldStore
+func (s *Store) Fields() store.FieldStore {
+	return s.fields
+}
+
+// Tickets returns the underlying TicketStore
+func (s *Store) Tickets() store.TicketStore {
+	return s.tickets
+}
+
+// Types returns the underlying TypeStore
+func (s *Store) Types() store.TypeStore {
+	return s.types
+}
+
+// Projects returns the underlying ProjectStore
+func (s *Store) Projects() store.ProjectStore {
+	return s.projects
+}
+
+// Statuses returns the underlying StatusStore
+func (s *Store) Statuses() store.StatusStore {
+	return s.statuses
+}
+
+// Workflows returns the underlying WorkflowStore
+func (s *Store) Workflows() store.WorkflowStore {
+	return s.workflows
+}
+
+// WithContext returns the Store itself, operations on memory finish without
+// waiting on anything so there is nothing for ctx to cancel.
+func (s *Store) WithContext(ctx context.Context) store.Store {
+	return s
+}
+
+// WithTx runs fn with a Store working on a copy of the tables, the copy
+// replaces them if fn returns nil and is discarded otherwise. The store is
+// locked until fn returns so fn must only use the Store it's given.
+func (s *Store) WithTx(fn func(tx store.Store) error) error {
+	d := s.users.db
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx := &db{tables: d.tables.clone()}
+
+	err := fn(storeOn(tx))
+	if err != nil {
+		return err
+	}
+
+	d.tables = tx.tables
+	return nil
+}
+
+// clone returns a copy of the tables which can be changed without changing t.
+// Rows are stored by value so only the maps and slices holding them are
+// copied.
+func (t tables) clone() tables {
+	c := t
+
+	c.ids = make(map[string]int64, len(t.ids))
+	for k, v := range t.ids {
+		c.ids[k] = v
+	}
+
+	c.users = make(map[int64]models.User, len(t.users))
+	for k, v := range t.users {
+		c.users[k] = v
+	}
+
+	c.teams = make(map[int64]models.Team, len(t.teams))
+	for k, v := range t.teams {
+		c.teams[k] = v
+	}
+
+	c.members = make(map[int64][]int64, len(t.members))
+	for k, v := range t.members {
+		c.members[k] = append([]int64(nil), v...)
+	}
+
+	c.labels = make(map[int64]models.Label, len(t.labels))
+	for k, v := range t.labels {
+		c.labels[k] = v
+	}
+
+	c.fields = make(map[int64]models.Field, len(t.fields))
+	for k, v := range t.fields {
+		c.fields[k] = v
+	}
+
+	c.projects = make(map[int64]models.Project, len(t.projects))
+	for k, v := range t.projects {
+		c.projects[k] = v
+	}
+
+	c.types = make(map[int64]models.TicketType, len(t.types))
+	for k, v := range t.types {
+		c.types[k] = v
+	}
+
+	c.statuses = make(map[int64]models.Status, len(t.statuses))
+	for k, v := range t.statuses {
+		c.statuses[k] = v
+	}
+
+	c.workflows = make(map[int64]workflowRow, len(t.workflows))
+	for k, v := range t.workflows {
+		c.workflows[k] = v
+	}
+
+	c.projectFields = append([]projectField(nil), t.projectFields...)
+
+	c.projectMembers = make(map[int64]map[int64]models.PermissionLevel,
+		len(t.projectMembers))
+	for k, v := range t.projectMembers {
+		roles := make(map[int64]models.PermissionLevel, len(v))
+		for uid, role := range v {
+			roles[uid] = role
+		}
+
+		c.projectMembers[k] = roles
+	}
+
+	c.counters = make(map[int64]int, len(t.counters))
+	for k, v := range t.counters {
+		c.counters[k] = v
+	}
+
+	c.tickets = make(map[int64]ticketRow, len(t.tickets))
+	for k, v := range t.tickets {
+		c.tickets[k] = v
+	}
+
+	c.comments = make(map[int64]commentRow, len(t.comments))
+	for k, v := range t.comments {
+		c.comments[k] = v
+	}
+
+	c.revisions = make(map[int64][]models.CommentRevision, len(t.revisions))
+	for k, v := range t.revisions {
+		c.revisions[k] = append([]models.CommentRevision(nil), v...)
+	}
+
+	c.watchers = make(map[int64]map[int64]bool, len(t.watchers))
+	for k, v := range t.watchers {
+		c.watchers[k] = copySet(v)
+	}
+
+	c.links = make(map[int64]models.TicketLink, len(t.links))
+	for k, v := range t.links {
+		c.links[k] = v
+	}
+
+	c.history = make(map[int64][]models.HistoryEntry, len(t.history))
+	for k, v := range t.history {
+		c.history[k] = append([]models.HistoryEntry(nil), v...)
+	}
+
+	c.worklogs = make(map[int64][]models.Worklog, len(t.worklogs))
+	for k, v := range t.worklogs {
+		c.worklogs[k] = append([]models.Worklog(nil), v...)
+	}
+
+	c.attachments = make(map[int64]attachmentRow, len(t.attachments))
+	for k, v := range t.attachments {
+		c.attachments[k] = v
+	}
+
+	c.resets = make(map[string]resetRow, len(t.resets))
+	for k, v := range t.resets {
+		c.resets[k] = v
+	}
+
+	c.verifications = make(map[string]resetRow, len(t.verifications))
+	for k, v := range t.verifications {
+		c.verifications[k] = v
+	}
+
+	c.refreshTokens = make(map[string]refreshRow, len(t.refreshTokens))
+	for k, v := range t.refreshTokens {
+		c.refreshTokens[k] = v
+	}
+
+	c.loginAttempts = make(map[int64]loginAttemptRow, len(t.loginAttempts))
+	for k, v := range t.loginAttempts {
+		c.loginAttempts[k] = v
+	}
+
+	c.filters = make(map[int64]map[string]store.SavedFilter, len(t.filters))
+	for k, v := range t.filters {
+		named := make(map[string]store.SavedFilter, len(v))
+		for name, f := range v {
+			named[name] = f
+		}
+
+		c.filters[k] = named
+	}
+
+	c.reactions = make(map[int64]map[string]map[int64]bool, len(t.reactions))
+	for k, v := range t.reactions {
+		emojis := make(map[string]map[int64]bool, len(v))
+		for emoji, users := range v {
+			emojis[emoji] = copySet(users)
+		}
+
+		c.reactions[k] = emojis
+	}
+
+	return c
+}
+
+// copySet returns a copy of a set of ids
+func copySet(s map[int64]bool) map[int64]bool {
+	c := make(map[int64]bool, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+
+	return c
+}
+
+// nextID works like a SERIAL column, returning the next id for the table.
+func (d *db) nextID(table string) int64 {
+	d.ids[table]++
+	return d.ids[table]
+}
+
+// sortedIDs returns the keys of a table in ascending order so results come
+// back in insertion order like they do from postgres.
+func sortedIDs(ids []int64) []int64 {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// publicUser returns the user with the given id without it's password, the
+// way users are returned when joined onto other models.
+func (d *db) publicUser(id int64) models.User {
+	u := d.users[id]
+	u.Password = ""
+	return u
+}
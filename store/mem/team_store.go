@@ -0,0 +1,233 @@
+package mem
+
+import (
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// TeamStore contains methods for storing and retrieving Teams in memory
+type TeamStore struct {
+	db *db
+}
+
+// team returns the team with it's lead and members filled in
+func (d *db) team(id int64) models.Team {
+	t := d.teams[id]
+	t.Lead = d.publicUser(t.Lead.ID)
+	t.Members = nil
+
+	for _, uid := range d.members[id] {
+		t.Members = append(t.Members, d.publicUser(uid))
+	}
+
+	return t
+}
+
+// slugTaken reports whether a team other than id has the url slug
+func (d *db) slugTaken(id int64, slug string) bool {
+	for tid, team := range d.teams {
+		if tid != id && team.URLSlug == slug {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get retrieves a team by ID, name or url slug
+func (ts *TeamStore) Get(t *models.Team) error {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	if _, ok := ts.db.teams[t.ID]; ok {
+		*t = ts.db.team(t.ID)
+		return nil
+	}
+
+	for id, team := range ts.db.teams {
+		if (t.Name != "" && team.Name == t.Name) ||
+			(t.URLSlug != "" && team.URLSlug == t.URLSlug) {
+			*t = ts.db.team(id)
+			return nil
+		}
+	}
+
+	return store.ErrNotFound
+}
+
+// GetAll retrieves all the teams
+func (ts *TeamStore) GetAll() ([]models.Team, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	var teams []models.Team
+	ids := make([]int64, 0, len(ts.db.teams))
+	for id := range ts.db.teams {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		teams = append(teams, ts.db.team(id))
+	}
+
+	return teams, nil
+}
+
+// GetForUser will get the teams the given user is a member of
+func (ts *TeamStore) GetForUser(u models.User) ([]models.Team, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	var teams []models.Team
+	var ids []int64
+
+	for id, members := range ts.db.members {
+		for _, uid := range members {
+			if uid == u.ID {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+
+	for _, id := range sortedIDs(ids) {
+		teams = append(teams, ts.db.team(id))
+	}
+
+	return teams, nil
+}
+
+// AddMembers will add users to the given team
+func (ts *TeamStore) AddMembers(t models.Team, users ...models.User) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	for _, u := range users {
+		ts.db.addMember(t.ID, u.ID)
+	}
+
+	return nil
+}
+
+// addMember adds uid to the members of the team if it isn't already one
+func (d *db) addMember(id, uid int64) {
+	for _, m := range d.members[id] {
+		if m == uid {
+			return
+		}
+	}
+
+	d.members[id] = append(d.members[id], uid)
+}
+
+// AddMember will add the user to the given team, adding a user who is
+// already a member does nothing.
+func (ts *TeamStore) AddMember(t models.Team, u models.User) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if _, ok := ts.db.teams[t.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	ts.db.addMember(t.ID, u.ID)
+	return nil
+}
+
+// RemoveMember will remove the user from the given team, returning
+// store.ErrRemoveLead if the user is the lead of the team.
+func (ts *TeamStore) RemoveMember(t models.Team, u models.User) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	team, ok := ts.db.teams[t.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if team.Lead.ID == u.ID {
+		return store.ErrRemoveLead
+	}
+
+	members := ts.db.members[t.ID][:0]
+	for _, m := range ts.db.members[t.ID] {
+		if m != u.ID {
+			members = append(members, m)
+		}
+	}
+
+	ts.db.members[t.ID] = members
+	return nil
+}
+
+// GetMembers will get the members for the given team.
+func (ts *TeamStore) GetMembers(t models.Team) ([]models.User, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	if _, ok := ts.db.teams[t.ID]; !ok {
+		return nil, store.ErrNotFound
+	}
+
+	members := []models.User{}
+	for _, uid := range ts.db.members[t.ID] {
+		members = append(members, ts.db.publicUser(uid))
+	}
+
+	return members, nil
+}
+
+// New adds a new team, if the team has no url slug one is generated from
+// it's name.
+func (ts *TeamStore) New(t *models.Team) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if t.URLSlug == "" {
+		t.URLSlug = models.Slugify(t.Name)
+	}
+
+	if ts.db.slugTaken(0, t.URLSlug) {
+		return store.ErrDuplicateEntry
+	}
+
+	t.ID = ts.db.nextID("teams")
+	ts.db.teams[t.ID] = models.Team{ID: t.ID, Name: t.Name, URLSlug: t.URLSlug, Lead: t.Lead}
+
+	for _, mem := range t.Members {
+		ts.db.addMember(t.ID, mem.ID)
+	}
+
+	return nil
+}
+
+// Save updates the name, url slug and lead of the team
+func (ts *TeamStore) Save(t models.Team) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if t.URLSlug == "" {
+		t.URLSlug = models.Slugify(t.Name)
+	}
+
+	if _, ok := ts.db.teams[t.ID]; !ok {
+		return nil
+	}
+
+	if ts.db.slugTaken(t.ID, t.URLSlug) {
+		return store.ErrDuplicateEntry
+	}
+
+	ts.db.teams[t.ID] = models.Team{ID: t.ID, Name: t.Name, URLSlug: t.URLSlug, Lead: t.Lead}
+	return nil
+}
+
+// Remove removes the team and it's memberships
+func (ts *TeamStore) Remove(t models.Team) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	delete(ts.db.members, t.ID)
+	delete(ts.db.teams, t.ID)
+	return nil
+}
@@ -0,0 +1,1924 @@
+package mem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// TicketStore contains methods for storing and retrieving Tickets in memory
+type TicketStore struct {
+	db *db
+}
+
+// errInvalidTicket mirrors the check constraints on the tickets table
+var errInvalidTicket = errors.New("tickets require a summary")
+
+// findTicket returns the id of the ticket matching t's ID or Key
+func (d *db) findTicket(t models.Ticket) (int64, bool) {
+	if _, ok := d.tickets[t.ID]; ok {
+		return t.ID, true
+	}
+
+	for id, tk := range d.tickets {
+		if t.Key != "" && tk.Key == t.Key {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+// ticket returns a copy of the ticket with the given id and it's users,
+// status, type and fields filled in.
+func (d *db) ticket(id int64) models.Ticket {
+	t := d.tickets[id].Ticket
+
+	t.Assignee = d.publicUser(t.Assignee.ID)
+	t.Reporter = d.publicUser(t.Reporter.ID)
+	t.Status = d.statuses[t.Status.ID]
+	t.Type = d.types[t.Type.ID]
+	t.UpdatedBy = models.User{}
+	t.Comments = nil
+	t.CommentCount = d.commentCount(id)
+	t.Labels = d.ticketLabels(id)
+
+	fields := make([]models.FieldValue, len(t.Fields))
+	for i, fv := range t.Fields {
+		if fo, ok := fv.Value.(models.FieldOption); ok {
+			f, _ := d.findField(models.Field{Name: fv.Name})
+			fv.Value = models.FieldOption{
+				Selected: fo.Selected,
+				Options:  append([]string(nil), f.Options.Options...),
+			}
+		}
+
+		if selected, ok := models.Selections(fv.Value); ok {
+			fv.Value = append([]string{}, selected...)
+		}
+
+		fields[i] = fv
+	}
+
+	if len(fields) == 0 {
+		fields = nil
+	}
+
+	t.Fields = fields
+	return t
+}
+
+// commentCount returns the number of comments on the ticket with the given id
+func (d *db) commentCount(id int64) int {
+	count := 0
+	for _, c := range d.comments {
+		if c.ticketID == id {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ticketLabels returns the labels on the ticket with the given id
+func (d *db) ticketLabels(id int64) []models.Label {
+	var labels []models.Label
+	for _, l := range d.tickets[id].Labels {
+		labels = append(labels, d.labels[l.ID])
+	}
+
+	return labels
+}
+
+// findTickets returns the tickets for which match returns true ordered by id
+func (d *db) findTickets(match func(ticketRow) bool) []models.Ticket {
+	var ids []int64
+	for id, t := range d.tickets {
+		if match(t) {
+			ids = append(ids, id)
+		}
+	}
+
+	var tickets []models.Ticket
+	for _, id := range sortedIDs(ids) {
+		tickets = append(tickets, d.ticket(id))
+	}
+
+	return tickets
+}
+
+// projectMatcher returns a match func for tickets in the given project
+func (d *db) projectMatcher(p models.Project) func(ticketRow) bool {
+	pid, ok := d.findProject(p)
+	return func(t ticketRow) bool {
+		return ok && t.projectID == pid
+	}
+}
+
+// activeProject is a match func for tickets whose project isn't archived
+func (d *db) activeProject(t ticketRow) bool {
+	return !d.projects[t.projectID].Archived
+}
+
+// checkReferences returns a store.ReferenceError for the first of the
+// ticket's reporter, assignee, status and type which doesn't exist, a ticket
+// doesn't need an assignee.
+func (d *db) checkReferences(t models.Ticket) error {
+	if _, ok := d.users[t.Reporter.ID]; !ok {
+		return store.ReferenceError{Field: "reporter"}
+	}
+
+	if _, ok := d.users[t.Assignee.ID]; !ok && t.Assignee.ID != 0 {
+		return store.ReferenceError{Field: "assignee"}
+	}
+
+	if _, ok := d.statuses[t.Status.ID]; !ok {
+		return store.ReferenceError{Field: "status"}
+	}
+
+	if _, ok := d.types[t.Type.ID]; !ok {
+		return store.ReferenceError{Field: "type"}
+	}
+
+	return nil
+}
+
+// checkParent returns store.ErrInvalidParent unless parentID is 0 or the id
+// of a ticket in the project which isn't the ticket with ticketID or one of
+// it's descendants
+func (d *db) checkParent(projectID, ticketID, parentID int64) error {
+	if parentID == 0 {
+		return nil
+	}
+
+	if p, ok := d.tickets[parentID]; !ok || p.projectID != projectID {
+		return store.ErrInvalidParent
+	}
+
+	// The seen set stops the walk if there already is a cycle.
+	seen := make(map[int64]bool)
+	for id := parentID; id != 0 && !seen[id]; id = d.tickets[id].ParentID {
+		if id == ticketID {
+			return store.ErrInvalidParent
+		}
+
+		seen[id] = true
+	}
+
+	return nil
+}
+
+// hasChildren reports whether any ticket has the ticket with id as it's
+// parent
+func (d *db) hasChildren(id int64) bool {
+	for _, t := range d.tickets {
+		if t.ParentID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeTicket deletes a ticket and everything which refers to it
+func (d *db) removeTicket(id int64) {
+	for cid, c := range d.comments {
+		if c.ticketID == id {
+			delete(d.revisions, cid)
+			delete(d.reactions, cid)
+			delete(d.comments, cid)
+		}
+	}
+
+	for aid, a := range d.attachments {
+		if a.ticketID == id {
+			delete(d.attachments, aid)
+		}
+	}
+
+	for lid, l := range d.links {
+		if l.SourceID == id || l.TargetID == id {
+			delete(d.links, lid)
+		}
+	}
+
+	delete(d.watchers, id)
+	delete(d.history, id)
+	delete(d.worklogs, id)
+	delete(d.tickets, id)
+}
+
+// ticketLess are the orderings tickets can be paged by, they match the
+// columns the postgres store allows.
+var ticketLess = map[string]func(a, b models.Ticket) bool{
+	"":             func(a, b models.Ticket) bool { return a.ID < b.ID },
+	"id":           func(a, b models.Ticket) bool { return a.ID < b.ID },
+	"key":          func(a, b models.Ticket) bool { return a.Key < b.Key },
+	"summary":      func(a, b models.Ticket) bool { return a.Summary < b.Summary },
+	"created_date": func(a, b models.Ticket) bool { return a.CreatedDate.Before(b.CreatedDate) },
+	"updated_date": func(a, b models.Ticket) bool { return a.UpdatedDate.Before(b.UpdatedDate) },
+}
+
+// fieldLess returns the ordering of tickets by their value for the custom
+// field f, tickets without a value come last whether or not desc is set.
+func fieldLess(f models.Field, desc bool) func(a, b models.Ticket) bool {
+	value := func(t models.Ticket) interface{} {
+		for _, fv := range t.Fields {
+			if fv.Name == f.Name {
+				return fv.Value
+			}
+		}
+
+		return nil
+	}
+
+	return func(a, b models.Ticket) bool {
+		av, bv := value(a), value(b)
+		if av == nil || bv == nil {
+			return av != nil && bv == nil
+		}
+
+		if desc {
+			return valueLess(bv, av)
+		}
+
+		return valueLess(av, bv)
+	}
+}
+
+// valueLess reports whether the field value a sorts before b, they are
+// expected to be values of the same field.
+func valueLess(a, b interface{}) bool {
+	switch av := a.(type) {
+	case string:
+		bv, _ := b.(string)
+		return av < bv
+	case bool:
+		bv, _ := b.(bool)
+		return !av && bv
+	case time.Time:
+		bv, _ := b.(time.Time)
+		return av.Before(bv)
+	case models.FieldOption:
+		bv, _ := b.(models.FieldOption)
+		return av.Selected < bv.Selected
+	}
+
+	return number(a) < number(b)
+}
+
+// number returns the numeric field value v as a float64
+func number(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case float64:
+		return n
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+
+	return 0
+}
+
+// page will order tickets as described by opts and return the requested page
+func (d *db) page(tickets []models.Ticket, opts store.PageOptions) ([]models.Ticket, int, error) {
+	less, ok := ticketLess[opts.OrderBy]
+	reverse := opts.Desc
+
+	if name, isField := store.OrderField(opts.OrderBy); isField {
+		f, found := d.findField(models.Field{Name: name})
+		if !found || name == "" || f.DataType == "MULTI_OPT" {
+			return nil, 0, store.ErrInvalidOrderBy
+		}
+
+		less, ok, reverse = fieldLess(f, opts.Desc), true, false
+	}
+
+	if !ok {
+		return nil, 0, store.ErrInvalidOrderBy
+	}
+
+	sort.SliceStable(tickets, func(i, j int) bool {
+		if reverse {
+			return less(tickets[j], tickets[i])
+		}
+
+		return less(tickets[i], tickets[j])
+	})
+
+	total := len(tickets)
+
+	if opts.Offset > 0 {
+		if opts.Offset > len(tickets) {
+			opts.Offset = len(tickets)
+		}
+
+		tickets = tickets[opts.Offset:]
+	}
+
+	if opts.Limit > 0 && opts.Limit < len(tickets) {
+		tickets = tickets[:opts.Limit]
+	}
+
+	if len(tickets) == 0 {
+		tickets = nil
+	}
+
+	return tickets, total, nil
+}
+
+// Get gets a Ticket by it's ID, or by it's Key when the ID is 0
+func (ts *TicketStore) Get(t *models.Ticket) error {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	by := models.Ticket{ID: t.ID}
+	if t.ID == 0 {
+		by.Key = t.Key
+	}
+
+	id, ok := ts.db.findTicket(by)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	*t = ts.db.ticket(id)
+	return nil
+}
+
+// GetByKey gets a Ticket by it's Key alone
+func (ts *TicketStore) GetByKey(key string) (models.Ticket, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	id, ok := ts.db.findTicket(models.Ticket{Key: key})
+	if !ok {
+		return models.Ticket{}, store.ErrNotFound
+	}
+
+	return ts.db.ticket(id), nil
+}
+
+// GetAll gets all the Tickets
+func (ts *TicketStore) GetAll() ([]models.Ticket, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	return ts.db.findTickets(ts.db.activeProject), nil
+}
+
+// GetAllPaged gets a page of Tickets as described by opts and the total
+// number of tickets
+func (ts *TicketStore) GetAllPaged(opts store.PageOptions) ([]models.Ticket, int, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	return ts.db.page(ts.db.findTickets(ts.db.activeProject), opts)
+}
+
+// GetAllByProject gets all the Tickets for the given project ordered by
+// created date in the direction dir
+func (ts *TicketStore) GetAllByProject(p models.Project, dir string) ([]models.Ticket, error) {
+	dir, err := store.SortDirection(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	tickets := ts.db.findTickets(ts.db.projectMatcher(p))
+
+	sort.SliceStable(tickets, func(i, j int) bool {
+		a, b := tickets[i], tickets[j]
+		if dir == "DESC" {
+			a, b = b, a
+		}
+
+		if a.CreatedDate.Equal(b.CreatedDate) {
+			return a.ID < b.ID
+		}
+
+		return a.CreatedDate.Before(b.CreatedDate)
+	})
+
+	return tickets, nil
+}
+
+// GetAllByProjectPaged gets a page of Tickets for the given project as
+// described by opts and the total number of tickets in the project
+func (ts *TicketStore) GetAllByProjectPaged(p models.Project,
+	opts store.PageOptions) ([]models.Ticket, int, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	return ts.db.page(ts.db.findTickets(ts.db.projectMatcher(p)), opts)
+}
+
+// Search will return the tickets whose summary or description contain every
+// word of query, ordered by how often the words appear. If p has an ID or Key
+// only tickets in that project are searched.
+func (ts *TicketStore) Search(query string, p models.Project) ([]models.Ticket, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	inProject := func(ticketRow) bool { return true }
+	if p.ID != 0 || p.Key != "" {
+		inProject = ts.db.projectMatcher(p)
+	}
+
+	rank := make(map[int64]int)
+	tickets := ts.db.findTickets(func(t ticketRow) bool {
+		if !inProject(t) || !ts.db.activeProject(t) {
+			return false
+		}
+
+		doc := strings.ToLower(t.Summary + " " + t.Description)
+		for _, w := range words {
+			c := strings.Count(doc, w)
+			if c == 0 {
+				return false
+			}
+
+			rank[t.ID] += c
+		}
+
+		return true
+	})
+
+	sort.SliceStable(tickets, func(i, j int) bool {
+		return rank[tickets[i].ID] > rank[tickets[j].ID]
+	})
+
+	return tickets, nil
+}
+
+// GetFiltered will return the tickets matching all of the set fields of f,
+// an empty filter returns all tickets.
+func (ts *TicketStore) GetFiltered(f store.TicketFilter) ([]models.Ticket, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	return ts.db.findTickets(func(t ticketRow) bool {
+		return ts.db.activeProject(t) && (f.ProjectKey == "" ||
+			ts.db.projects[t.projectID].Key == f.ProjectKey) &&
+			(f.StatusID == 0 || t.Status.ID == f.StatusID) &&
+			(f.TypeID == 0 || t.Type.ID == f.TypeID) &&
+			(f.AssigneeUsername == "" ||
+				ts.db.users[t.Assignee.ID].Username == f.AssigneeUsername)
+	}), nil
+}
+
+// SaveFilter will save the filter for the user, replacing the user's filter
+// with the same name if they have one.
+func (ts *TicketStore) SaveFilter(u models.User, f *store.SavedFilter) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if f.Name == "" {
+		return store.ErrMissingField
+	}
+
+	uid, ok := ts.db.findUser(u)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if ts.db.filters[uid] == nil {
+		ts.db.filters[uid] = make(map[string]store.SavedFilter)
+	}
+
+	if old, ok := ts.db.filters[uid][f.Name]; ok {
+		f.ID = old.ID
+	} else {
+		f.ID = ts.db.nextID("saved_filters")
+	}
+
+	ts.db.filters[uid][f.Name] = *f
+	return nil
+}
+
+// GetFilters will return the user's saved filters ordered by name
+func (ts *TicketStore) GetFilters(u models.User) ([]store.SavedFilter, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	uid, _ := ts.db.findUser(u)
+
+	var filters []store.SavedFilter
+	for _, f := range ts.db.filters[uid] {
+		filters = append(filters, f)
+	}
+
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].Name < filters[j].Name
+	})
+
+	return filters, nil
+}
+
+// DeleteFilter will remove the user's filter with the given name,
+// store.ErrNotFound is returned if they have no such filter.
+func (ts *TicketStore) DeleteFilter(u models.User, name string) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	uid, _ := ts.db.findUser(u)
+	if _, ok := ts.db.filters[uid][name]; !ok {
+		return store.ErrNotFound
+	}
+
+	delete(ts.db.filters[uid], name)
+	return nil
+}
+
+// RunFilter will return the tickets matching the user's filter with the given
+// name
+func (ts *TicketStore) RunFilter(name string, u models.User) ([]models.Ticket, error) {
+	ts.db.mu.RLock()
+	uid, _ := ts.db.findUser(u)
+	f, ok := ts.db.filters[uid][name]
+	ts.db.mu.RUnlock()
+
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	return ts.GetFiltered(f.Filter)
+}
+
+// fieldValueString is the value of a field as it's recorded in the history
+func fieldValueString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	if fo, ok := v.(models.FieldOption); ok {
+		return fo.Selected
+	}
+
+	return fmt.Sprint(v)
+}
+
+// validFieldValues returns an error from models.ValidateField if any of the
+// field values are invalid, OPT and MULTI_OPT values are checked against the
+// options of their field rather than the options sent with them.
+func (d *db) validFieldValues(fields []models.FieldValue) error {
+	for _, fv := range fields {
+		if fv.DataType == "MULTI_OPT" {
+			f, _ := d.findField(models.Field{Name: fv.Name})
+
+			err := models.ValidateSelections(fv, f.Options.Options)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if fo, ok := fv.Value.(models.FieldOption); ok {
+			f, _ := d.findField(models.Field{Name: fv.Name})
+			fo.Options = f.Options.Options
+			fv.Value = fo
+		}
+
+		err := models.ValidateField(fv)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordHistory will add an entry to the ticket history if the value of the
+// field changed.
+func (d *db) recordHistory(t models.Ticket, field, oldVal, newVal string) {
+	if oldVal == newVal {
+		return
+	}
+
+	var u models.User
+	if t.UpdatedBy.ID != 0 {
+		u = d.publicUser(t.UpdatedBy.ID)
+	}
+
+	d.history[t.ID] = append(d.history[t.ID], models.HistoryEntry{
+		ID:          d.nextID("ticket_history"),
+		CreatedDate: time.Now(),
+		Field:       field,
+		OldValue:    oldVal,
+		NewValue:    newVal,
+		User:        u,
+	})
+}
+
+// Save will update the summary, description and field values of an existing
+// ticket.
+func (ts *TicketStore) Save(ticket models.Ticket) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	id, ok := ts.db.findTicket(ticket)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	err := ts.db.validFieldValues(ticket.Fields)
+	if err != nil {
+		return err
+	}
+
+	err = store.ValidateTicket(ticket)
+	if err != nil {
+		return err
+	}
+
+	ticket.ID = id
+	stored := ts.db.tickets[id]
+
+	if ticket.Version != stored.Version {
+		return store.ErrStaleObject
+	}
+
+	if ticket.ParentID == id {
+		return store.ErrInvalidParent
+	}
+
+	err = ts.db.checkParent(stored.projectID, id, ticket.ParentID)
+	if err != nil {
+		return err
+	}
+
+	ts.db.recordHistory(ticket, "summary", stored.Summary, ticket.Summary)
+	ts.db.recordHistory(ticket, "description", stored.Description,
+		ticket.Description)
+	ts.db.recordHistory(ticket, "original_estimate",
+		strconv.FormatInt(stored.OriginalEstimate, 10),
+		strconv.FormatInt(ticket.OriginalEstimate, 10))
+	ts.db.recordHistory(ticket, "remaining_estimate",
+		strconv.FormatInt(stored.RemainingEstimate, 10),
+		strconv.FormatInt(ticket.RemainingEstimate, 10))
+	ts.db.recordHistory(ticket, "due_date", store.HistoryDate(stored.DueDate),
+		store.HistoryDate(ticket.DueDate))
+	ts.db.recordHistory(ticket, "priority", strconv.Itoa(int(stored.Priority)),
+		strconv.Itoa(int(ticket.Priority)))
+
+	stored.Summary = ticket.Summary
+	stored.Description = ticket.Description
+	stored.OriginalEstimate = ticket.OriginalEstimate
+	stored.RemainingEstimate = ticket.RemainingEstimate
+	stored.DueDate = ticket.DueDate
+	stored.Priority = ticket.Priority
+	stored.ParentID = ticket.ParentID
+	stored.UpdatedDate = time.Now()
+	stored.Version++
+
+	fields := append([]models.FieldValue(nil), stored.Fields...)
+	for _, fv := range ticket.Fields {
+		i := 0
+		for i < len(fields) && fields[i].Name != fv.Name {
+			i++
+		}
+
+		// A field the ticket has no value for yet is given one.
+		if i == len(fields) {
+			ts.db.recordHistory(ticket, fv.Name, "", fieldValueString(fv.Value))
+			fv.ID = ts.db.nextID("field_values")
+			fields = append(fields, fv)
+			continue
+		}
+
+		ts.db.recordHistory(ticket, fv.Name,
+			fieldValueString(fields[i].Value), fieldValueString(fv.Value))
+		fv.ID = fields[i].ID
+		fields[i] = fv
+	}
+
+	stored.Fields = fields
+	ts.db.tickets[id] = stored
+	return nil
+}
+
+// Remove will remove the ticket along with it's comments, watchers, links,
+// history and attachments. Tickets which still have children can not be
+// removed.
+func (ts *TicketStore) Remove(ticket models.Ticket) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	id, ok := ts.db.findTicket(ticket)
+	if !ok {
+		return nil
+	}
+
+	if ts.db.hasChildren(id) {
+		return store.ErrHasChildren
+	}
+
+	ts.db.removeTicket(id)
+	return nil
+}
+
+// GetChildren will get the sub tasks of the given ticket
+func (ts *TicketStore) GetChildren(t models.Ticket) ([]models.Ticket, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	id, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil, nil
+	}
+
+	return ts.db.findTickets(func(tk ticketRow) bool {
+		return tk.ParentID == id
+	}), nil
+}
+
+// getByUser gets the tickets for which user returns the id of the user
+// matching u, an empty slice is returned when there are none.
+func (ts *TicketStore) getByUser(u models.User, user func(ticketRow) int64) []models.Ticket {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	tickets := []models.Ticket{}
+
+	id, ok := ts.db.findUser(u)
+	if !ok {
+		return tickets
+	}
+
+	return append(tickets, ts.db.findTickets(func(t ticketRow) bool {
+		return user(t) == id
+	})...)
+}
+
+// GetByAssignee gets the tickets assigned to the given user
+func (ts *TicketStore) GetByAssignee(u models.User) ([]models.Ticket, error) {
+	return ts.getByUser(u, func(t ticketRow) int64 { return t.Assignee.ID }), nil
+}
+
+// GetByReporter gets the tickets reported by the given user
+func (ts *TicketStore) GetByReporter(u models.User) ([]models.Ticket, error) {
+	return ts.getByUser(u, func(t ticketRow) int64 { return t.Reporter.ID }), nil
+}
+
+// GetRecentlyUpdated gets the tickets in the project, the most recently
+// updated first
+func (ts *TicketStore) GetRecentlyUpdated(p models.Project, limit int) ([]models.Ticket, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	inProject := ts.db.projectMatcher(p)
+
+	tickets := ts.db.findTickets(func(t ticketRow) bool {
+		return inProject(t) && ts.db.activeProject(t)
+	})
+
+	sort.SliceStable(tickets, func(i, j int) bool {
+		a, b := tickets[i], tickets[j]
+		if a.UpdatedDate.Equal(b.UpdatedDate) {
+			return a.ID > b.ID
+		}
+
+		return a.UpdatedDate.After(b.UpdatedDate)
+	})
+
+	if limit > 0 && limit < len(tickets) {
+		tickets = tickets[:limit]
+	}
+
+	return tickets, nil
+}
+
+// GetOverdue gets the tickets in the project which are past their due date
+// and not closed, the longest overdue first
+func (ts *TicketStore) GetOverdue(p models.Project) ([]models.Ticket, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	now := time.Now()
+	inProject := ts.db.projectMatcher(p)
+
+	tickets := ts.db.findTickets(func(t ticketRow) bool {
+		return inProject(t) && ts.db.activeProject(t) && !t.DueDate.IsZero() &&
+			t.DueDate.Before(now) && !ts.db.statuses[t.Status.ID].Closed
+	})
+
+	sort.SliceStable(tickets, func(i, j int) bool {
+		a, b := tickets[i], tickets[j]
+		if a.DueDate.Equal(b.DueDate) {
+			return a.ID < b.ID
+		}
+
+		return a.DueDate.Before(b.DueDate)
+	})
+
+	return tickets, nil
+}
+
+// CountByStatus counts the tickets in the project for each status name, the
+// statuses used by the project's workflows are included even when no tickets
+// have them.
+func (ts *TicketStore) CountByStatus(p models.Project) (map[string]int, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	pid, ok := ts.db.findProject(p)
+	if !ok {
+		return make(map[string]int), nil
+	}
+
+	return ts.db.countByStatus(pid), nil
+}
+
+// CountAllByStatus counts the tickets of every project, including archived
+// ones, for each status name keyed by the project's key
+func (ts *TicketStore) CountAllByStatus() (map[string]map[string]int, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	counts := make(map[string]map[string]int)
+	for pid, p := range ts.db.projects {
+		if c := ts.db.countByStatus(pid); len(c) > 0 {
+			counts[p.Key] = c
+		}
+	}
+
+	return counts, nil
+}
+
+// countByStatus counts the tickets in the project with pid for each status
+// name, including the statuses of the project's workflows without tickets
+func (d *db) countByStatus(pid int64) map[string]int {
+	counts := make(map[string]int)
+
+	for _, w := range d.workflows {
+		if w.projectID != pid {
+			continue
+		}
+
+		for from, transitions := range w.Transitions {
+			if s, ok := d.findStatus(models.Status{Name: from}); ok {
+				counts[s.Name] += 0
+			}
+
+			for _, tr := range transitions {
+				if s, ok := d.findStatus(tr.ToStatus); ok {
+					counts[s.Name] += 0
+				}
+			}
+		}
+	}
+
+	for _, t := range d.tickets {
+		if t.projectID == pid {
+			counts[d.statuses[t.Status.ID].Name]++
+		}
+	}
+
+	return counts
+}
+
+// New will add a new Ticket to the given project
+func (ts *TicketStore) New(project models.Project, ticket *models.Ticket) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if ticket.Summary == "" {
+		return errInvalidTicket
+	}
+
+	pid, ok := ts.db.findProject(project)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if ts.db.projects[pid].Archived {
+		return store.ErrProjectArchived
+	}
+
+	err := ts.db.validFieldValues(ticket.Fields)
+	if err != nil {
+		return err
+	}
+
+	if !store.HasRequiredFields(*ticket, ts.db.fieldsForType(pid, ticket.Type.ID)) {
+		return store.ErrMissingRequiredField
+	}
+
+	err = ts.db.checkParent(pid, 0, ticket.ParentID)
+	if err != nil {
+		return err
+	}
+
+	err = ts.db.checkReferences(*ticket)
+	if err != nil {
+		return err
+	}
+
+	err = store.ValidateTicket(*ticket)
+	if err != nil {
+		return err
+	}
+
+	ts.db.newTicket(pid, ticket)
+	return nil
+}
+
+// newTicket stores a new ticket in the project, giving it the next key for
+// the project and it and it's field values an ID.
+func (d *db) newTicket(projectID int64, t *models.Ticket) {
+	// A key can already be taken when one project's key is another's
+	// followed by a number, those keys are skipped.
+	for {
+		d.counters[projectID]++
+		t.Key = d.projects[projectID].Key + strconv.Itoa(d.counters[projectID])
+
+		if _, taken := d.findTicket(models.Ticket{Key: t.Key}); !taken {
+			break
+		}
+	}
+	t.ID = d.nextID("tickets")
+	t.Version = 1
+	t.TimeSpent = 0
+	t.CreatedDate = time.Now()
+	t.UpdatedDate = t.CreatedDate
+
+	for i := range t.Fields {
+		t.Fields[i].ID = d.nextID("field_values")
+	}
+
+	stored := *t
+	stored.Fields = append([]models.FieldValue(nil), t.Fields...)
+	stored.Labels = nil
+	stored.Comments = nil
+	stored.UpdatedBy = models.User{}
+
+	d.tickets[t.ID] = ticketRow{stored, projectID}
+}
+
+// NewBatch will add all of the tickets to the given project, each ticket is
+// given the next ticket key for the project in order. If any ticket is
+// invalid none of them are added.
+func (ts *TicketStore) NewBatch(project models.Project, tickets []*models.Ticket) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	pid, ok := ts.db.findProject(project)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if ts.db.projects[pid].Archived {
+		return store.ErrProjectArchived
+	}
+
+	for _, t := range tickets {
+		if t.Summary == "" {
+			return errInvalidTicket
+		}
+
+		err := ts.db.validFieldValues(t.Fields)
+		if err != nil {
+			return err
+		}
+
+		if !store.HasRequiredFields(*t, ts.db.fieldsForType(pid, t.Type.ID)) {
+			return store.ErrMissingRequiredField
+		}
+
+		err = ts.db.checkParent(pid, 0, t.ParentID)
+		if err != nil {
+			return err
+		}
+
+		err = ts.db.checkReferences(*t)
+		if err != nil {
+			return err
+		}
+
+		err = store.ValidateTicket(*t)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, t := range tickets {
+		ts.db.newTicket(pid, t)
+	}
+
+	return nil
+}
+
+// NextTicketKey will return the key the next ticket created in the project
+// will get, the key is not reserved so it's only a preview. New assigns keys
+// itself.
+func (ts *TicketStore) NextTicketKey(p models.Project) string {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	pid, ok := ts.db.findProject(p)
+	if !ok {
+		return p.Key + strconv.Itoa(1)
+	}
+
+	return ts.db.projects[pid].Key + strconv.Itoa(ts.db.counters[pid]+1)
+}
+
+// GetComments will return all comments for a ticket ordered by when they
+// were created
+func (ts *TicketStore) GetComments(t models.Ticket) ([]models.Comment, error) {
+	comments, _, err := ts.GetCommentsPaged(t, store.PageOptions{})
+	return comments, err
+}
+
+// GetCommentsPaged will return a page of the comments for a ticket, ordered
+// by when they were created, and the total number of comments on the ticket.
+// Comments can only be ordered by created_date.
+func (ts *TicketStore) GetCommentsPaged(t models.Ticket, opts store.PageOptions) ([]models.Comment, int, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	var comments []models.Comment
+
+	if opts.OrderBy != "" && opts.OrderBy != "created_date" {
+		return nil, 0, store.ErrInvalidOrderBy
+	}
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return comments, 0, nil
+	}
+
+	var ids []int64
+	for id, c := range ts.db.comments {
+		if c.ticketID == tid {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range sortedIDs(ids) {
+		comments = append(comments, ts.db.comment(id))
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool {
+		if opts.Desc {
+			return comments[j].CreatedDate.Before(comments[i].CreatedDate)
+		}
+
+		return comments[i].CreatedDate.Before(comments[j].CreatedDate)
+	})
+
+	total := len(comments)
+
+	if opts.Offset > 0 {
+		if opts.Offset > len(comments) {
+			opts.Offset = len(comments)
+		}
+
+		comments = comments[opts.Offset:]
+	}
+
+	if opts.Limit > 0 && opts.Limit < len(comments) {
+		comments = comments[:opts.Limit]
+	}
+
+	if len(comments) == 0 {
+		comments = nil
+	}
+
+	return comments, total, nil
+}
+
+// comment returns a copy of the comment with the given id and it's author and
+// reactions filled in.
+func (d *db) comment(id int64) models.Comment {
+	c := d.comments[id].Comment
+	c.Author = d.publicUser(c.Author.ID)
+	c.Reactions = d.reactionCounts(id)
+	if len(c.Reactions) == 0 {
+		c.Reactions = nil
+	}
+
+	return c
+}
+
+// GetComment will get a single comment and it's author by the comment's ID
+func (ts *TicketStore) GetComment(c *models.Comment) error {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	if _, ok := ts.db.comments[c.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	*c = ts.db.comment(c.ID)
+	return nil
+}
+
+// NewComment will add a new Comment to the ticket, the users mentioned in the
+// body are set as the comment's Mentions.
+func (ts *TicketStore) NewComment(t models.Ticket, c *models.Comment) error {
+	var err error
+
+	c.Mentions, err = store.ResolveMentions(&UserStore{ts.db}, c.Body)
+	if err != nil {
+		return err
+	}
+
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	id, ok := ts.db.findTicket(t)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	t.ID = id
+	stored := ts.db.tickets[id]
+	stored.UpdatedDate = time.Now()
+	ts.db.tickets[id] = stored
+
+	c.ID = ts.db.nextID("comments")
+	c.CreatedDate = stored.UpdatedDate
+	c.UpdatedDate = stored.UpdatedDate
+
+	row := commentRow{*c, t.ID}
+	row.Mentions = nil
+	ts.db.comments[c.ID] = row
+	return nil
+}
+
+// SaveComment will update the body of a comment, the author is never
+// changed. If the body changed the previous body is recorded as a revision
+// edited by c.UpdatedBy.
+func (ts *TicketStore) SaveComment(c models.Comment) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	stored, ok := ts.db.comments[c.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if stored.Body != c.Body {
+		var editor models.User
+		if c.UpdatedBy.ID != 0 {
+			editor = ts.db.publicUser(c.UpdatedBy.ID)
+		}
+
+		ts.db.revisions[c.ID] = append(ts.db.revisions[c.ID], models.CommentRevision{
+			ID:       ts.db.nextID("comment_revisions"),
+			OldBody:  stored.Body,
+			EditedAt: time.Now(),
+			Editor:   editor,
+		})
+	}
+
+	stored.Body = c.Body
+	stored.UpdatedDate = time.Now()
+	ts.db.comments[c.ID] = stored
+	return nil
+}
+
+// GetCommentHistory will return the revisions of the comment oldest first
+func (ts *TicketStore) GetCommentHistory(c models.Comment) ([]models.CommentRevision, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	return append([]models.CommentRevision(nil), ts.db.revisions[c.ID]...), nil
+}
+
+// reactionCounts returns the number of users who reacted to the comment with
+// each emoji
+func (d *db) reactionCounts(id int64) map[string]int {
+	counts := make(map[string]int)
+	for emoji, users := range d.reactions[id] {
+		if len(users) > 0 {
+			counts[emoji] = len(users)
+		}
+	}
+
+	return counts
+}
+
+// AddReaction will add the emoji reaction from the user to the comment, a
+// user can only react with each emoji once so adding it again returns
+// store.ErrDuplicateEntry.
+func (ts *TicketStore) AddReaction(c models.Comment, u models.User, emoji string) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if _, ok := ts.db.comments[c.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	if ts.db.reactions[c.ID] == nil {
+		ts.db.reactions[c.ID] = make(map[string]map[int64]bool)
+	}
+
+	users := ts.db.reactions[c.ID][emoji]
+	if users == nil {
+		users = make(map[int64]bool)
+		ts.db.reactions[c.ID][emoji] = users
+	}
+
+	if users[u.ID] {
+		return store.ErrDuplicateEntry
+	}
+
+	users[u.ID] = true
+	return nil
+}
+
+// RemoveReaction will remove the emoji reaction from the user on the comment
+func (ts *TicketStore) RemoveReaction(c models.Comment, u models.User, emoji string) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	delete(ts.db.reactions[c.ID][emoji], u.ID)
+	return nil
+}
+
+// GetReactions will return the number of users who reacted to the comment
+// with each emoji
+func (ts *TicketStore) GetReactions(c models.Comment) (map[string]int, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	return ts.db.reactionCounts(c.ID), nil
+}
+
+// RemoveComment will remove a comment along with it's revisions and
+// reactions
+func (ts *TicketStore) RemoveComment(c models.Comment) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if row, ok := ts.db.comments[c.ID]; ok {
+		ts.db.touchTicket(row.ticketID)
+	}
+
+	delete(ts.db.revisions, c.ID)
+	delete(ts.db.reactions, c.ID)
+	delete(ts.db.comments, c.ID)
+	return nil
+}
+
+// findLabel returns the label matching l's ID or Name
+func (d *db) findLabel(l models.Label) (models.Label, bool) {
+	if label, ok := d.labels[l.ID]; ok {
+		return label, true
+	}
+
+	for _, label := range d.labels {
+		if l.Name != "" && label.Name == l.Name {
+			return label, true
+		}
+	}
+
+	return models.Label{}, false
+}
+
+// AddLabel will add the label, found by ID or name, to the ticket. Adding a
+// label which the ticket already has does nothing.
+func (ts *TicketStore) AddLabel(t models.Ticket, label models.Label) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil
+	}
+
+	label, ok = ts.db.findLabel(label)
+	if !ok {
+		return nil
+	}
+
+	stored := ts.db.tickets[tid]
+	for _, l := range stored.Labels {
+		if l.ID == label.ID {
+			return nil
+		}
+	}
+
+	stored.Labels = append(stored.Labels, models.Label{ID: label.ID})
+	sort.Slice(stored.Labels, func(i, j int) bool {
+		return stored.Labels[i].ID < stored.Labels[j].ID
+	})
+
+	stored.UpdatedDate = time.Now()
+	ts.db.tickets[tid] = stored
+	return nil
+}
+
+// RemoveLabel will remove the label, found by ID or name, from the ticket
+func (ts *TicketStore) RemoveLabel(t models.Ticket, label models.Label) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil
+	}
+
+	label, ok = ts.db.findLabel(label)
+	if !ok {
+		return nil
+	}
+
+	stored := ts.db.tickets[tid]
+
+	var labels []models.Label
+	for _, l := range stored.Labels {
+		if l.ID != label.ID {
+			labels = append(labels, l)
+		}
+	}
+
+	if len(labels) != len(stored.Labels) {
+		stored.UpdatedDate = time.Now()
+	}
+
+	stored.Labels = labels
+	ts.db.tickets[tid] = stored
+	return nil
+}
+
+// touchTicket bumps the updated date of the ticket with the id
+func (d *db) touchTicket(id int64) {
+	if t, ok := d.tickets[id]; ok {
+		t.UpdatedDate = time.Now()
+		d.tickets[id] = t
+	}
+}
+
+// GetLabels will return all of the labels on the ticket
+func (ts *TicketStore) GetLabels(t models.Ticket) ([]models.Label, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil, nil
+	}
+
+	return ts.db.ticketLabels(tid), nil
+}
+
+// AddWatcher will add the user as a watcher of the ticket, adding a user who
+// is already watching the ticket does nothing.
+func (ts *TicketStore) AddWatcher(t models.Ticket, u models.User) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil
+	}
+
+	if ts.db.watchers[tid] == nil {
+		ts.db.watchers[tid] = make(map[int64]bool)
+	}
+
+	ts.db.watchers[tid][u.ID] = true
+	return nil
+}
+
+// RemoveWatcher will remove the user from the watchers of the ticket
+func (ts *TicketStore) RemoveWatcher(t models.Ticket, u models.User) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if ok {
+		delete(ts.db.watchers[tid], u.ID)
+	}
+
+	return nil
+}
+
+// GetWatchers will return all of the users watching the ticket
+func (ts *TicketStore) GetWatchers(t models.Ticket) ([]models.User, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	var users []models.User
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return users, nil
+	}
+
+	var ids []int64
+	for uid := range ts.db.watchers[tid] {
+		ids = append(ids, uid)
+	}
+
+	for _, uid := range sortedIDs(ids) {
+		users = append(users, ts.db.publicUser(uid))
+	}
+
+	return users, nil
+}
+
+// hasLink reports whether the link already exists
+func (d *db) hasLink(srcID, dstID int64, linkType string) bool {
+	for _, l := range d.links {
+		if l.SourceID == srcID && l.TargetID == dstID && l.LinkType == linkType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LinkTickets will link src to dst with the given link type, if the link type
+// has an inverse (for example blocks and blocked_by) the inverse link from dst
+// to src is created as well.
+func (ts *TicketStore) LinkTickets(src, dst models.Ticket, linkType string) error {
+	if !models.IsValidLinkType(linkType) {
+		return models.ErrInvalidLinkType
+	}
+
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	srcID, ok := ts.db.findTicket(src)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	dstID, ok := ts.db.findTicket(dst)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if srcID == dstID {
+		return models.ErrSelfLink
+	}
+
+	inverse, hasInverse := models.InverseLinkType(linkType)
+	if ts.db.hasLink(srcID, dstID, linkType) ||
+		(hasInverse && ts.db.hasLink(dstID, srcID, inverse)) {
+		return store.ErrDuplicateEntry
+	}
+
+	id := ts.db.nextID("ticket_links")
+	ts.db.links[id] = models.TicketLink{
+		ID:       id,
+		LinkType: linkType,
+		SourceID: srcID,
+		TargetID: dstID,
+	}
+
+	if hasInverse {
+		id = ts.db.nextID("ticket_links")
+		ts.db.links[id] = models.TicketLink{
+			ID:       id,
+			LinkType: inverse,
+			SourceID: dstID,
+			TargetID: srcID,
+		}
+	}
+
+	return nil
+}
+
+// Unlink will remove the link of the given type from src to dst along with
+// it's inverse link if there is one.
+func (ts *TicketStore) Unlink(src, dst models.Ticket, linkType string) error {
+	if !models.IsValidLinkType(linkType) {
+		return models.ErrInvalidLinkType
+	}
+
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	srcID, ok := ts.db.findTicket(src)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	dstID, ok := ts.db.findTicket(dst)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	inverse, hasInverse := models.InverseLinkType(linkType)
+
+	for id, l := range ts.db.links {
+		if (l.SourceID == srcID && l.TargetID == dstID && l.LinkType == linkType) ||
+			(hasInverse && l.SourceID == dstID && l.TargetID == srcID &&
+				l.LinkType == inverse) {
+			delete(ts.db.links, id)
+		}
+	}
+
+	return nil
+}
+
+// GetLinks will return all of the links from the given ticket to other
+// tickets
+func (ts *TicketStore) GetLinks(t models.Ticket) ([]models.TicketLink, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	var links []models.TicketLink
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return links, nil
+	}
+
+	var ids []int64
+	for id, l := range ts.db.links {
+		if l.SourceID == tid {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range sortedIDs(ids) {
+		l := ts.db.links[id]
+		l.TargetKey = ts.db.tickets[l.TargetID].Key
+		links = append(links, l)
+	}
+
+	return links, nil
+}
+
+// transitionsFor will return the transitions available to the ticket with
+// the given id in it's current status from the workflows for it's project
+// and type.
+func (d *db) transitionsFor(tid int64) []models.Transition {
+	t := d.tickets[tid]
+	from := d.statuses[t.Status.ID].Name
+
+	byStatus := make(map[int64]models.Transition)
+	for _, w := range d.workflows {
+		if w.projectID != t.projectID ||
+			(w.TicketType.ID != 0 && w.TicketType.ID != t.Type.ID) {
+			continue
+		}
+
+		for _, tr := range w.Transitions[from] {
+			if old, ok := byStatus[tr.ToStatus.ID]; !ok || tr.ID < old.ID {
+				tr.ToStatus = d.statuses[tr.ToStatus.ID]
+				tr.Hooks = nil
+				byStatus[tr.ToStatus.ID] = tr
+			}
+		}
+	}
+
+	var ids []int64
+	for id := range byStatus {
+		ids = append(ids, id)
+	}
+
+	var transitions []models.Transition
+	for _, id := range sortedIDs(ids) {
+		transitions = append(transitions, byStatus[id])
+	}
+
+	return transitions
+}
+
+// GetTransitions will return the transitions which are available to the
+// ticket from it's current status
+func (ts *TicketStore) GetTransitions(t models.Ticket) ([]models.Transition, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil, nil
+	}
+
+	return ts.db.transitionsFor(tid), nil
+}
+
+// TransitionTicket will move the ticket to the given status, if the workflow
+// for the ticket does not allow moving from it's current status to toStatus
+// store.ErrInvalidTransition is returned.
+func (ts *TicketStore) TransitionTicket(t models.Ticket, toStatus models.Status) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, to, err := ts.db.transition(t, toStatus)
+	if err != nil {
+		return err
+	}
+
+	t.ID = tid
+	ts.db.moveTicket(t, to)
+	return nil
+}
+
+// TransitionBatch will move all of the tickets to toStatus, if any of the
+// transitions are invalid none of the tickets are moved.
+func (ts *TicketStore) TransitionBatch(tickets []models.Ticket, toStatus models.Status) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	moved := make([]models.Ticket, len(tickets))
+	statuses := make([]models.Status, len(tickets))
+
+	for i, t := range tickets {
+		tid, to, err := ts.db.transition(t, toStatus)
+		if err != nil {
+			return err
+		}
+
+		t.ID = tid
+		moved[i], statuses[i] = t, to
+	}
+
+	for i, t := range moved {
+		ts.db.moveTicket(t, statuses[i])
+	}
+
+	return nil
+}
+
+// transition returns the id of the ticket and the status it would be moved
+// to, or store.ErrInvalidTransition if it's workflow doesn't allow the move.
+func (d *db) transition(t models.Ticket, toStatus models.Status) (int64, models.Status, error) {
+	tid, ok := d.findTicket(t)
+	if !ok {
+		return 0, models.Status{}, store.ErrNotFound
+	}
+
+	for _, tr := range d.transitionsFor(tid) {
+		if (toStatus.ID != 0 && tr.ToStatus.ID == toStatus.ID) ||
+			(toStatus.ID == 0 && tr.ToStatus.Name == toStatus.Name) {
+			return tid, tr.ToStatus, nil
+		}
+	}
+
+	return tid, models.Status{}, store.ErrInvalidTransition
+}
+
+// moveTicket sets the status of the ticket with t's ID and records the change
+// in it's history.
+func (d *db) moveTicket(t models.Ticket, to models.Status) {
+	stored := d.tickets[t.ID]
+	from := d.statuses[stored.Status.ID].Name
+
+	stored.Status = to
+	stored.UpdatedDate = time.Now()
+	d.tickets[t.ID] = stored
+
+	d.recordHistory(t, "status", from, to.Name)
+}
+
+// AssignTicket will assign the ticket to the given user, or unassign it if
+// assignee is the zero user, and record the change in the ticket history.
+func (ts *TicketStore) AssignTicket(t models.Ticket, assignee models.User) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	var to models.User
+	if assignee.ID != 0 || assignee.Username != "" {
+		uid, ok := ts.db.findUser(assignee)
+		if !ok || !ts.db.users[uid].IsActive {
+			return store.ErrNotFound
+		}
+
+		to = ts.db.publicUser(uid)
+	}
+
+	stored := ts.db.tickets[tid]
+	from := ts.db.users[stored.Assignee.ID].Username
+
+	stored.Assignee = to
+	stored.UpdatedDate = time.Now()
+	stored.Version++
+	ts.db.tickets[tid] = stored
+
+	t.ID = tid
+	ts.db.recordHistory(t, "assignee", from, to.Username)
+	return nil
+}
+
+// LogWork will add a worklog for the ticket and update it's time spent and
+// remaining estimate.
+func (ts *TicketStore) LogWork(t models.Ticket, seconds int64, u models.User) error {
+	if seconds <= 0 {
+		return store.ErrInvalidDuration
+	}
+
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	if _, ok := ts.db.users[u.ID]; !ok {
+		return store.ErrInvalidReference
+	}
+
+	stored := ts.db.tickets[tid]
+	stored.TimeSpent += seconds
+	stored.RemainingEstimate -= seconds
+	if stored.RemainingEstimate < 0 {
+		stored.RemainingEstimate = 0
+	}
+
+	stored.UpdatedDate = time.Now()
+	stored.Version++
+	ts.db.tickets[tid] = stored
+
+	ts.db.worklogs[tid] = append(ts.db.worklogs[tid], models.Worklog{
+		ID:          ts.db.nextID("worklogs"),
+		CreatedDate: time.Now(),
+		Seconds:     seconds,
+		Author:      models.User{ID: u.ID},
+	})
+
+	return nil
+}
+
+// GetWorklogs will return the work logged on the ticket, oldest first
+func (ts *TicketStore) GetWorklogs(t models.Ticket) ([]models.Worklog, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil, nil
+	}
+
+	var worklogs []models.Worklog
+	for _, wl := range ts.db.worklogs[tid] {
+		wl.Author = ts.db.publicUser(wl.Author.ID)
+		worklogs = append(worklogs, wl)
+	}
+
+	return worklogs, nil
+}
+
+// TotalTimeSpent will return the sum of the work logged on the ticket in
+// seconds, store.ErrNotFound is returned if the ticket doesn't exist.
+func (ts *TicketStore) TotalTimeSpent(t models.Ticket) (int64, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+
+	var total int64
+	for _, wl := range ts.db.worklogs[tid] {
+		total += wl.Seconds
+	}
+
+	return total, nil
+}
+
+// GetHistory will return the changes made to the ticket, oldest first
+func (ts *TicketStore) GetHistory(t models.Ticket) ([]models.HistoryEntry, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return nil, nil
+	}
+
+	return append([]models.HistoryEntry(nil), ts.db.history[tid]...), nil
+}
+
+// AddAttachment will add the metadata for an attachment to the ticket, the
+// contents of the attachment should already be in a store.BlobStore under
+// a.StorageKey.
+func (ts *TicketStore) AddAttachment(t models.Ticket, a *models.Attachment) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	for _, att := range ts.db.attachments {
+		if att.StorageKey == a.StorageKey {
+			return store.ErrDuplicateEntry
+		}
+	}
+
+	a.ID = ts.db.nextID("attachments")
+	a.CreatedDate = time.Now()
+	ts.db.attachments[a.ID] = attachmentRow{*a, tid}
+	return nil
+}
+
+// GetAttachments will return the metadata for all of the attachments on the
+// ticket
+func (ts *TicketStore) GetAttachments(t models.Ticket) ([]models.Attachment, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	var attachments []models.Attachment
+
+	tid, ok := ts.db.findTicket(t)
+	if !ok {
+		return attachments, nil
+	}
+
+	var ids []int64
+	for id, a := range ts.db.attachments {
+		if a.ticketID == tid {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range sortedIDs(ids) {
+		a := ts.db.attachments[id].Attachment
+		if a.UploadedBy.ID != 0 {
+			a.UploadedBy = ts.db.publicUser(a.UploadedBy.ID)
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	return attachments, nil
+}
+
+// RemoveAttachment will remove the metadata for the attachment, removing the
+// contents from the store.BlobStore is left to the caller.
+func (ts *TicketStore) RemoveAttachment(a models.Attachment) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	delete(ts.db.attachments, a.ID)
+	return nil
+}
+
+// GetCommentsForTickets will return the comments of the tickets with the
+// given ids keyed by ticket id, each ticket's comments are ordered by when
+// they were created.
+func (ts *TicketStore) GetCommentsForTickets(ids []int64) (map[int64][]models.Comment, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	comments := make(map[int64][]models.Comment)
+
+	want := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+
+	var cids []int64
+	for id, c := range ts.db.comments {
+		if want[c.ticketID] {
+			cids = append(cids, id)
+		}
+	}
+
+	for _, id := range sortedIDs(cids) {
+		tid := ts.db.comments[id].ticketID
+		comments[tid] = append(comments[tid], ts.db.comment(id))
+	}
+
+	for _, cs := range comments {
+		sort.SliceStable(cs, func(i, j int) bool {
+			return cs[i].CreatedDate.Before(cs[j].CreatedDate)
+		})
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,119 @@
+package mem
+
+import (
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// TypeStore contains methods for storing and retrieving Ticket Types in
+// memory
+type TypeStore struct {
+	db *db
+}
+
+// findType returns the id of the ticket type matching tt's ID or Name
+func (d *db) findType(tt models.TicketType) (int64, bool) {
+	if _, ok := d.types[tt.ID]; ok {
+		return tt.ID, true
+	}
+
+	for id, typ := range d.types {
+		if tt.Name != "" && typ.Name == tt.Name {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+// Get gets a ticket type by it's ID or name
+func (ts *TypeStore) Get(tt *models.TicketType) error {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	id, ok := ts.db.findType(*tt)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	*tt = ts.db.types[id]
+	return nil
+}
+
+// GetAll gets all ticket types
+func (ts *TypeStore) GetAll() ([]models.TicketType, error) {
+	ts.db.mu.RLock()
+	defer ts.db.mu.RUnlock()
+
+	var types []models.TicketType
+	ids := make([]int64, 0, len(ts.db.types))
+	for id := range ts.db.types {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		types = append(types, ts.db.types[id])
+	}
+
+	return types, nil
+}
+
+// New creates a new ticket type
+func (ts *TypeStore) New(tt *models.TicketType) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if _, taken := ts.db.findType(models.TicketType{Name: tt.Name}); taken {
+		return store.ErrDuplicateEntry
+	}
+
+	tt.ID = ts.db.nextID("ticket_types")
+	ts.db.types[tt.ID] = *tt
+	return nil
+}
+
+// Save updates a ticket type, store.ErrNotFound is returned if there is no
+// type with it's ID.
+func (ts *TypeStore) Save(tt models.TicketType) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	if _, ok := ts.db.types[tt.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	if id, taken := ts.db.findType(models.TicketType{Name: tt.Name}); taken && id != tt.ID {
+		return store.ErrDuplicateEntry
+	}
+
+	ts.db.types[tt.ID] = tt
+	return nil
+}
+
+// Remove removes a ticket type, store.ErrInUse is returned if any tickets,
+// workflows or project fields use it.
+func (ts *TypeStore) Remove(tt models.TicketType) error {
+	ts.db.mu.Lock()
+	defer ts.db.mu.Unlock()
+
+	for _, t := range ts.db.tickets {
+		if t.Type.ID == tt.ID {
+			return store.ErrInUse
+		}
+	}
+
+	for _, w := range ts.db.workflows {
+		if w.TicketType.ID == tt.ID {
+			return store.ErrInUse
+		}
+	}
+
+	for _, pf := range ts.db.projectFields {
+		if pf.typeID == tt.ID {
+			return store.ErrInUse
+		}
+	}
+
+	delete(ts.db.types, tt.ID)
+	return nil
+}
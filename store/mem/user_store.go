@@ -0,0 +1,440 @@
+package mem
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// UserStore contains methods for storing and retrieving Users in memory
+type UserStore struct {
+	db *db
+}
+
+// findUser returns the id of the user matching u's ID or Username
+func (d *db) findUser(u models.User) (int64, bool) {
+	if _, ok := d.users[u.ID]; ok {
+		return u.ID, true
+	}
+
+	for id, usr := range d.users {
+		if u.Username != "" && usr.Username == u.Username {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+// usernameTaken reports whether a user other than id has the username
+func (d *db) usernameTaken(id int64, username string) bool {
+	for uid, usr := range d.users {
+		if uid != id && usr.Username == username {
+			return true
+		}
+	}
+
+	return false
+}
+
+// emailTaken reports whether a user other than id has the email, ignoring
+// case
+func (d *db) emailTaken(id int64, email string) bool {
+	if email == "" {
+		return false
+	}
+
+	for uid, usr := range d.users {
+		if uid != id && strings.EqualFold(usr.Email, email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get retrieves the user by ID or username, users which have been removed
+// are not returned.
+func (s *UserStore) Get(u *models.User) error {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	id, ok := s.db.findUser(*u)
+	if !ok || !s.db.users[id].IsActive {
+		return store.ErrNotFound
+	}
+
+	*u = s.db.users[id]
+	return nil
+}
+
+// GetByEmail retrieves the active user with the email, emails are compared
+// ignoring case.
+func (s *UserStore) GetByEmail(email string) (models.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, usr := range s.db.users {
+		if usr.IsActive && strings.EqualFold(usr.Email, email) {
+			return usr, nil
+		}
+	}
+
+	return models.User{}, store.ErrNotFound
+}
+
+// GetAll retrieves all active users
+func (s *UserStore) GetAll() ([]models.User, error) {
+	return s.getAll(false), nil
+}
+
+// GetAllIncludingInactive retrieves all users including those which have
+// been removed
+func (s *UserStore) GetAllIncludingInactive() ([]models.User, error) {
+	return s.getAll(true), nil
+}
+
+// GetAllFiltered returns a page of the active users whose username or display
+// name contains q, ignoring case, along with the total number of matches.
+func (s *UserStore) GetAllFiltered(q string, opts store.PageOptions) ([]models.User, int, error) {
+	if opts.OrderBy != "" && opts.OrderBy != "id" && opts.OrderBy != "username" {
+		return nil, 0, store.ErrInvalidOrderBy
+	}
+
+	q = strings.ToLower(q)
+	users := []models.User{}
+
+	for _, u := range s.getAll(false) {
+		if strings.Contains(strings.ToLower(u.Username), q) ||
+			strings.Contains(strings.ToLower(u.DisplayName), q) {
+			users = append(users, u)
+		}
+	}
+
+	if opts.OrderBy == "username" {
+		sort.SliceStable(users, func(i, j int) bool {
+			return users[i].Username < users[j].Username
+		})
+	}
+
+	if opts.Desc {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	total := len(users)
+
+	if opts.Offset > 0 {
+		if opts.Offset > len(users) {
+			opts.Offset = len(users)
+		}
+
+		users = users[opts.Offset:]
+	}
+
+	if opts.Limit > 0 && opts.Limit < len(users) {
+		users = users[:opts.Limit]
+	}
+
+	return users, total, nil
+}
+
+func (s *UserStore) getAll(inactive bool) []models.User {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	users := []models.User{}
+	ids := make([]int64, 0, len(s.db.users))
+	for id := range s.db.users {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		if u := s.db.users[id]; u.IsActive || inactive {
+			users = append(users, u)
+		}
+	}
+
+	return users
+}
+
+// Remove will deactivate the given user
+func (s *UserStore) Remove(u models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if id, ok := s.db.findUser(u); ok {
+		usr := s.db.users[id]
+		usr.IsActive = false
+		s.db.users[id] = usr
+
+		for h, r := range s.db.refreshTokens {
+			if r.userID == id {
+				r.used = true
+				s.db.refreshTokens[h] = r
+			}
+		}
+	}
+
+	return nil
+}
+
+// Save will update the given user, the password is only changed if it is
+// set on u.
+func (s *UserStore) Save(u models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	old, ok := s.db.users[u.ID]
+	if !ok {
+		return nil
+	}
+
+	if s.db.usernameTaken(u.ID, u.Username) || s.db.emailTaken(u.ID, u.Email) {
+		return store.ErrDuplicateEntry
+	}
+
+	if u.Password == "" {
+		u.Password = old.Password
+	}
+
+	u.IsActive = old.IsActive
+	u.EmailVerified = old.EmailVerified && old.Email == u.Email
+	s.db.users[u.ID] = u
+	return nil
+}
+
+// New will create the user
+func (s *UserStore) New(u *models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.db.usernameTaken(0, u.Username) || s.db.emailTaken(0, u.Email) {
+		return store.ErrDuplicateEntry
+	}
+
+	u.ID = s.db.nextID("users")
+	u.IsActive = true
+	s.db.users[u.ID] = *u
+	return nil
+}
+
+// CreatePasswordReset will store a hash of the given reset token for the user
+// which can be consumed once before expires.
+func (s *UserStore) CreatePasswordReset(u models.User, token string, expires time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[u.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	h := store.HashToken(token)
+	if _, ok := s.db.resets[h]; ok {
+		return store.ErrDuplicateEntry
+	}
+
+	s.db.resets[h] = resetRow{userID: u.ID, expires: expires}
+	return nil
+}
+
+// ConsumePasswordReset will mark the reset token for the user as used,
+// returning store.ErrInvalidToken if it does not exist or was already used
+// and store.ErrTokenExpired if it has expired.
+func (s *UserStore) ConsumePasswordReset(u models.User, token string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	h := store.HashToken(token)
+	r, ok := s.db.resets[h]
+	if !ok || r.used || r.userID != u.ID {
+		return store.ErrInvalidToken
+	}
+
+	if r.expires.Before(time.Now()) {
+		return store.ErrTokenExpired
+	}
+
+	r.used = true
+	s.db.resets[h] = r
+	return nil
+}
+
+// CreateEmailVerification will store a hash of the given verification token
+// for the user which can be used once before expires.
+func (s *UserStore) CreateEmailVerification(u models.User, token string, expires time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[u.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	h := store.HashToken(token)
+	if _, ok := s.db.verifications[h]; ok {
+		return store.ErrDuplicateEntry
+	}
+
+	s.db.verifications[h] = resetRow{userID: u.ID, expires: expires}
+	return nil
+}
+
+// VerifyEmail will mark the email of the user the token was created for as
+// verified and return them, returning store.ErrInvalidToken if the token
+// does not exist or was already used and store.ErrTokenExpired if it has
+// expired.
+func (s *UserStore) VerifyEmail(token string) (models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	h := store.HashToken(token)
+	r, ok := s.db.verifications[h]
+	if !ok || r.used {
+		return models.User{}, store.ErrInvalidToken
+	}
+
+	if r.expires.Before(time.Now()) {
+		return models.User{}, store.ErrTokenExpired
+	}
+
+	r.used = true
+	s.db.verifications[h] = r
+
+	u := s.db.users[r.userID]
+	u.EmailVerified = true
+	s.db.users[r.userID] = u
+	return u, nil
+}
+
+// CreateRefreshToken will store a hash of the refresh token for the user as
+// the first of a new family of tokens.
+func (s *UserStore) CreateRefreshToken(u models.User, token string, expires time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[u.ID]; !ok {
+		return store.ErrNotFound
+	}
+
+	h := store.HashToken(token)
+	if _, ok := s.db.refreshTokens[h]; ok {
+		return store.ErrDuplicateEntry
+	}
+
+	s.db.refreshTokens[h] = refreshRow{resetRow{userID: u.ID, expires: expires}, h}
+	return nil
+}
+
+// RotateRefreshToken will mark the token as used and store next in the same
+// family, returning the user the token belongs to. It returns
+// store.ErrInvalidToken if the token does not exist, store.ErrTokenExpired if
+// it has expired and store.ErrTokenReused, after revoking the family, if it
+// was already used.
+func (s *UserStore) RotateRefreshToken(token, next string, expires time.Time) (models.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	h := store.HashToken(token)
+	r, ok := s.db.refreshTokens[h]
+	if !ok {
+		return models.User{}, store.ErrInvalidToken
+	}
+
+	if r.used {
+		s.revokeFamily(r.family)
+		return models.User{}, store.ErrTokenReused
+	}
+
+	if r.expires.Before(time.Now()) {
+		return models.User{}, store.ErrTokenExpired
+	}
+
+	if !s.db.users[r.userID].IsActive {
+		return models.User{}, store.ErrInvalidToken
+	}
+
+	nh := store.HashToken(next)
+	if _, ok := s.db.refreshTokens[nh]; ok {
+		return models.User{}, store.ErrDuplicateEntry
+	}
+
+	r.used = true
+	s.db.refreshTokens[h] = r
+	s.db.refreshTokens[nh] = refreshRow{resetRow{userID: r.userID, expires: expires}, r.family}
+	return s.db.users[r.userID], nil
+}
+
+// RevokeRefreshToken will mark every token in the family of token as used,
+// returning store.ErrInvalidToken if the token does not exist or belongs to
+// another user.
+func (s *UserStore) RevokeRefreshToken(u models.User, token string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	r, ok := s.db.refreshTokens[store.HashToken(token)]
+	if !ok || r.userID != u.ID {
+		return store.ErrInvalidToken
+	}
+
+	s.revokeFamily(r.family)
+	return nil
+}
+
+// revokeFamily marks every refresh token in family as used, s.db.mu must be
+// held.
+func (s *UserStore) revokeFamily(family string) {
+	for h, r := range s.db.refreshTokens {
+		if r.family == family {
+			r.used = true
+			s.db.refreshTokens[h] = r
+		}
+	}
+}
+
+// LoginLockedUntil will return when the last lockout of the user ends, the
+// zero time if they have never been locked out.
+func (s *UserStore) LoginLockedUntil(u models.User) (time.Time, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.loginAttempts[u.ID].lockedUntil, nil
+}
+
+// RecordFailedLogin will count a failed login for the user, locking them out
+// until lockUntil and starting the count again on the attempts'th failure.
+func (s *UserStore) RecordFailedLogin(u models.User, attempts int, lockUntil time.Time) (time.Time, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[u.ID]; !ok {
+		return time.Time{}, store.ErrNotFound
+	}
+
+	a := s.db.loginAttempts[u.ID]
+	a.failures++
+
+	if a.failures < attempts {
+		s.db.loginAttempts[u.ID] = a
+		return time.Time{}, nil
+	}
+
+	s.db.loginAttempts[u.ID] = loginAttemptRow{lockedUntil: lockUntil}
+	return lockUntil, nil
+}
+
+// ResetFailedLogins will clear the failed logins counted for the user
+func (s *UserStore) ResetFailedLogins(u models.User) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if a, ok := s.db.loginAttempts[u.ID]; ok {
+		a.failures = 0
+		s.db.loginAttempts[u.ID] = a
+	}
+
+	return nil
+}
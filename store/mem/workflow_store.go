@@ -0,0 +1,174 @@
+package mem
+
+import (
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// WorkflowStore contains methods for storing and retrieving Workflows in
+// memory
+type WorkflowStore struct {
+	db *db
+}
+
+// copyTransitions returns a copy of the transitions so callers can't modify
+// the stored workflow through the map.
+func copyTransitions(transitions map[string][]models.Transition) map[string][]models.Transition {
+	c := make(map[string][]models.Transition, len(transitions))
+
+	for from, trs := range transitions {
+		for _, t := range trs {
+			t.Hooks = append([]models.Hook(nil), t.Hooks...)
+			c[from] = append(c[from], t)
+		}
+	}
+
+	return c
+}
+
+// workflow returns a copy of the workflow with the given id
+func (d *db) workflow(id int64) models.Workflow {
+	w := d.workflows[id].Workflow
+	w.Transitions = copyTransitions(w.Transitions)
+	return w
+}
+
+// setTransitions will store the transitions of w, giving any new
+// transitions and hooks an ID.
+func (d *db) setTransitions(projectID int64, w *models.Workflow) error {
+	transitions := make(map[string][]models.Transition, len(w.Transitions))
+
+	for from, trs := range w.Transitions {
+		if _, ok := d.findStatus(models.Status{Name: from}); !ok {
+			return store.ErrNotFound
+		}
+
+		for i := range trs {
+			t := &trs[i]
+
+			to, ok := d.findStatus(models.Status{ID: t.ToStatus.ID})
+			if !ok {
+				return store.ErrNotFound
+			}
+
+			t.ToStatus = to
+			if t.ID == 0 {
+				t.ID = d.nextID("transitions")
+			}
+
+			for j := range t.Hooks {
+				if t.Hooks[j].ID == 0 {
+					t.Hooks[j].ID = d.nextID("hooks")
+				}
+			}
+		}
+
+		transitions[from] = trs
+	}
+
+	stored := *w
+	stored.Transitions = copyTransitions(transitions)
+	d.workflows[w.ID] = workflowRow{stored, projectID}
+	return nil
+}
+
+// Get gets a workflow by it's ID or Name
+func (ws *WorkflowStore) Get(w *models.Workflow) error {
+	ws.db.mu.RLock()
+	defer ws.db.mu.RUnlock()
+
+	if _, ok := ws.db.workflows[w.ID]; ok {
+		*w = ws.db.workflow(w.ID)
+		return nil
+	}
+
+	for id, wk := range ws.db.workflows {
+		if w.Name != "" && wk.Name == w.Name {
+			*w = ws.db.workflow(id)
+			return nil
+		}
+	}
+
+	return store.ErrNotFound
+}
+
+// GetAll gets all the workflows
+func (ws *WorkflowStore) GetAll() ([]models.Workflow, error) {
+	ws.db.mu.RLock()
+	defer ws.db.mu.RUnlock()
+
+	var workflows []models.Workflow
+	ids := make([]int64, 0, len(ws.db.workflows))
+	for id := range ws.db.workflows {
+		ids = append(ids, id)
+	}
+
+	for _, id := range sortedIDs(ids) {
+		workflows = append(workflows, ws.db.workflow(id))
+	}
+
+	return workflows, nil
+}
+
+// GetByProject gets all the workflows for the given project
+func (ws *WorkflowStore) GetByProject(p models.Project) ([]models.Workflow, error) {
+	ws.db.mu.RLock()
+	defer ws.db.mu.RUnlock()
+
+	var workflows []models.Workflow
+
+	pid, ok := ws.db.findProject(p)
+	if !ok {
+		return []models.Workflow{}, nil
+	}
+
+	var ids []int64
+	for id, w := range ws.db.workflows {
+		if w.projectID == pid {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range sortedIDs(ids) {
+		workflows = append(workflows, ws.db.workflow(id))
+	}
+
+	return workflows, nil
+}
+
+// New creates a new workflow for the given project
+func (ws *WorkflowStore) New(p models.Project, workflow *models.Workflow) error {
+	ws.db.mu.Lock()
+	defer ws.db.mu.Unlock()
+
+	workflow.ID = ws.db.nextID("workflows")
+	err := ws.db.setTransitions(p.ID, workflow)
+	if err != nil {
+		workflow.ID = 0
+	}
+
+	return err
+}
+
+// Save updates a workflow
+func (ws *WorkflowStore) Save(w models.Workflow) error {
+	ws.db.mu.Lock()
+	defer ws.db.mu.Unlock()
+
+	old, ok := ws.db.workflows[w.ID]
+	if !ok {
+		return nil
+	}
+
+	w.Transitions = copyTransitions(w.Transitions)
+	return ws.db.setTransitions(old.projectID, &w)
+}
+
+// Remove removes a workflow
+func (ws *WorkflowStore) Remove(w models.Workflow) error {
+	ws.db.mu.Lock()
+	defer ws.db.mu.Unlock()
+
+	delete(ws.db.workflows, w.ID)
+	return nil
+}
@@ -9,7 +9,7 @@ import (
 // LabelStore contains methods for storing and retrieving Labels from a
 // Postgres DB
 type LabelStore struct {
-	db *sql.DB
+	db *ctxDB
 }
 
 // Get gets a label from the database
@@ -2,12 +2,20 @@ package migrations
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 )
 
+// ErrNoMigrations is returned by Rollback when no migrations have been
+// applied.
+var ErrNoMigrations = errors.New("no migrations have been applied")
+
+// schema is a single migration, q applies it and down reverts it.
 type schema struct {
 	v    int
 	q    string
+	down string
 	name string
 }
 
@@ -21,54 +29,213 @@ var schemas = []schema{
 	v7schema,
 	v8schema,
 	v9schema,
+	v10schema,
+	v11schema,
+	v12schema,
+	v13schema,
+	v14schema,
+	v15schema,
+	v16schema,
+	v17schema,
+	v18schema,
+	v19schema,
+	v20schema,
+	v21schema,
+	v22schema,
+	v23schema,
+	v24schema,
+	v25schema,
+	v26schema,
+	v27schema,
+	v28schema,
+	v29schema,
+	v30schema,
+	v31schema,
+	v32schema,
+	v33schema,
+	v34schema,
+	v35schema,
+	v36schema,
+	v37schema,
+	v38schema,
+	v39schema,
+	v40schema,
+	v41schema,
+	v42schema,
 }
 
-// SchemaVersion will find the schema version for the given database
+const migrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version      integer PRIMARY KEY,
+	name         varchar(250) NOT NULL,
+	applied_date timestamp DEFAULT current_timestamp
+);
+`
+
+// SchemaVersion will find the version of the last migration applied to the
+// given database, 0 if there are none.
 func SchemaVersion(db *sql.DB) int {
 	var v int
 
-	rw, err := db.Query("SELECT schema_version FROM database_information WHERE id = 1")
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").
+		Scan(&v)
 	if err != nil {
 		log.Printf("Error retrieving schema version, assuming 0: %s\n", err.Error())
 		return 0
 	}
-	defer rw.Close()
 
-	rw.Next()
-	err = rw.Scan(&v)
+	return v
+}
+
+// adoptLegacyVersion will record the migrations applied before
+// schema_migrations existed, when only the latest version was kept in the
+// database_information table, as applied.
+func adoptLegacyVersion(db *sql.DB) error {
+	var legacy bool
+
+	err := db.QueryRow(`SELECT to_regclass('database_information') IS NOT NULL
+						AND NOT EXISTS (SELECT 1 FROM schema_migrations)`).
+		Scan(&legacy)
+	if err != nil || !legacy {
+		return err
+	}
+
+	var version int
+
+	err = db.QueryRow(`SELECT schema_version FROM database_information 
+					   WHERE id = 1`).Scan(&version)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
 	if err != nil {
-		log.Printf("Error scanning schema version, assuming 0: %s\n", err.Error())
-		return 0
+		return err
 	}
 
-	return v
+	for _, schema := range schemas {
+		if schema.v > version {
+			break
+		}
+
+		_, err = db.Exec(`INSERT INTO schema_migrations (version, name) 
+						  VALUES ($1, $2)`, schema.v, schema.name)
+		if err != nil {
+			return err
+		}
+	}
 
+	return nil
 }
 
-// RunMigrations will run all database migrations depending on the version
-// returned from the database_information table.
-func RunMigrations(db *sql.DB) error {
-	version := SchemaVersion(db)
-	log.Printf("Current database version %d\n", version)
+// Migrate will apply every migration which is not yet recorded in the
+// schema_migrations table, in order. Each migration is applied and recorded
+// in a single transaction so a failed migration is never half applied.
+func Migrate(db *sql.DB) error {
+	_, err := db.Exec(migrationsTable)
+	if err != nil {
+		return err
+	}
+
+	err = adoptLegacyVersion(db)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Current database version %d\n", SchemaVersion(db))
+
+	applied := make(map[int]bool)
+
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var v int
+
+		err = rows.Scan(&v)
+		if err != nil {
+			return err
+		}
+
+		applied[v] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
 
 	for _, schema := range schemas {
-		version = SchemaVersion(db)
-
-		if version < schema.v {
-			log.Printf("Migrating database to version %d: %s\n", schema.v, schema.name)
-			_, err := db.Exec(schema.q)
-			if err != nil {
-				return err
-			}
-
-			_, err = db.Exec(`UPDATE database_information 
-							  SET (schema_version) = ($1)
-							  WHERE id = 1;`, schema.v)
-			if err != nil {
-				return err
-			}
+		if applied[schema.v] {
+			continue
+		}
+
+		log.Printf("Migrating database to version %d: %s\n", schema.v, schema.name)
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(schema.q)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) 
+						  VALUES ($1, $2)`, schema.v, schema.name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+// Rollback will revert the last applied migration and remove it from the
+// schema_migrations table, ErrNoMigrations is returned if there are none.
+func Rollback(db *sql.DB) error {
+	version := SchemaVersion(db)
+	if version == 0 {
+		return ErrNoMigrations
+	}
+
+	for _, schema := range schemas {
+		if schema.v != version {
+			continue
+		}
+
+		log.Printf("Rolling back database version %d: %s\n", schema.v, schema.name)
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(schema.down)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1",
+			schema.v)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	return fmt.Errorf("unknown schema version %d", version)
+}
@@ -0,0 +1,143 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/praelatus/backend/config"
+)
+
+// freshDB returns a connection whose search_path is a new, empty, postgres
+// schema which is dropped when the test finishes.
+func freshDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("postgres", config.GetDbURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The search_path is set per connection so only one is used.
+	db.SetMaxOpenConns(1)
+
+	name := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+
+	_, err = db.Exec("CREATE SCHEMA " + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Exec("DROP SCHEMA " + name + " CASCADE")
+		db.Close()
+	})
+
+	_, err = db.Exec("SET search_path TO " + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// schemaShape returns the columns, constraints and indexes of the schema in
+// the search_path so the schemas left by migrations can be compared.
+func schemaShape(t *testing.T, db *sql.DB) []string {
+	rows, err := db.Query(`
+		SELECT table_name || '.' || column_name || ' ' || data_type
+		FROM information_schema.columns WHERE table_schema = current_schema()
+		UNION ALL
+		SELECT table_name || ' ' || constraint_name || ' ' || constraint_type
+		FROM information_schema.table_constraints
+		WHERE table_schema = current_schema()
+		-- NOT NULL constraints are named after oids which differ by schema.
+		AND constraint_name NOT LIKE '%_not_null'
+		UNION ALL
+		SELECT tablename || ' ' || indexname
+		FROM pg_indexes WHERE schemaname = current_schema()
+		ORDER BY 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	var shape []string
+	for rows.Next() {
+		var s string
+
+		err = rows.Scan(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shape = append(shape, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return shape
+}
+
+func TestMigrateAndRollback(t *testing.T) {
+	db := freshDB(t)
+	latest := schemas[len(schemas)-1].v
+
+	err := Migrate(db)
+	if err != nil {
+		t.Fatal("Migrate failed:", err)
+	}
+
+	if v := SchemaVersion(db); v != latest {
+		t.Fatalf("Expected version %d got %d", latest, v)
+	}
+
+	migrated := schemaShape(t, db)
+
+	err = Rollback(db)
+	if err != nil {
+		t.Fatal("Rollback failed:", err)
+	}
+
+	if v := SchemaVersion(db); v != latest-1 {
+		t.Errorf("Expected version %d after rollback got %d", latest-1, v)
+	}
+
+	// The rollback should leave the schema as it was before the latest
+	// migration was applied.
+	previous := freshDB(t)
+
+	_, err = previous.Exec(migrationsTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, schema := range schemas[:len(schemas)-1] {
+		_, err = previous.Exec(schema.q)
+		if err != nil {
+			t.Fatalf("Applying version %d failed: %s", schema.v, err)
+		}
+	}
+
+	if got, expected := schemaShape(t, db), schemaShape(t, previous); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the rollback to leave\n%v\ngot\n%v", expected, got)
+	}
+
+	// Migrating again should only re-apply the migration rolled back.
+	err = Migrate(db)
+	if err != nil {
+		t.Fatal("Migrate after rollback failed:", err)
+	}
+
+	if v := SchemaVersion(db); v != latest {
+		t.Errorf("Expected version %d got %d", latest, v)
+	}
+
+	if !reflect.DeepEqual(schemaShape(t, db), migrated) {
+		t.Error("Expected migrating again to restore the latest schema")
+	}
+}
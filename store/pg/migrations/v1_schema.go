@@ -10,7 +10,11 @@ CREATE TABLE IF NOT EXISTS database_information (
 INSERT INTO database_information (schema_version) VALUES (1);
 `
 
-var v1schema = schema{1, dbInfo, "add db info"}
+const dbInfoDown = `
+DROP TABLE IF EXISTS database_information;
+`
+
+var v1schema = schema{1, dbInfo, dbInfoDown, "add db info"}
 
 const users = `
 CREATE TABLE IF NOT EXISTS users (
@@ -25,7 +29,11 @@ CREATE TABLE IF NOT EXISTS users (
     profile_picture varchar(250)
 );`
 
-var v2schema = schema{2, users, "create user tables"}
+const usersDown = `
+DROP TABLE IF EXISTS users;
+`
+
+var v2schema = schema{2, users, usersDown, "create user tables"}
 
 const teams = `
 CREATE TABLE IF NOT EXISTS teams (
@@ -42,7 +50,12 @@ CREATE TABLE IF NOT EXISTS teams_users (
 	user_id integer REFERENCES users (id) NOT NULL
 );`
 
-var v3schema = schema{3, teams, "create team tables"}
+const teamsDown = `
+DROP TABLE IF EXISTS teams_users;
+DROP TABLE IF EXISTS teams;
+`
+
+var v3schema = schema{3, teams, teamsDown, "create team tables"}
 
 const projects = `
 CREATE TABLE IF NOT EXISTS projects (
@@ -57,7 +70,11 @@ CREATE TABLE IF NOT EXISTS projects (
     lead_id			integer REFERENCES users (id) NOT NULL
 );`
 
-var v4schema = schema{4, projects, "create project tables"}
+const projectsDown = `
+DROP TABLE IF EXISTS projects;
+`
+
+var v4schema = schema{4, projects, projectsDown, "create project tables"}
 
 const workflows = `
 CREATE TABLE IF NOT EXISTS statuses (
@@ -91,7 +108,14 @@ CREATE TABLE IF NOT EXISTS hooks (
 );
 `
 
-var v5schema = schema{5, workflows, "create workflow tables"}
+const workflowsDown = `
+DROP TABLE IF EXISTS hooks;
+DROP TABLE IF EXISTS transitions;
+DROP TABLE IF EXISTS workflows;
+DROP TABLE IF EXISTS statuses;
+`
+
+var v5schema = schema{5, workflows, workflowsDown, "create workflow tables"}
 
 const tickets = `
 CREATE TABLE IF NOT EXISTS fields (
@@ -153,7 +177,16 @@ CREATE TABLE IF NOT EXISTS field_tickettype_project (
 );
 `
 
-var v6schema = schema{6, tickets, "create ticket tables"}
+const ticketsDown = `
+DROP TABLE IF EXISTS field_tickettype_project;
+DROP TABLE IF EXISTS field_options;
+DROP TABLE IF EXISTS field_values;
+DROP TABLE IF EXISTS tickets;
+DROP TABLE IF EXISTS ticket_types;
+DROP TABLE IF EXISTS fields;
+`
+
+var v6schema = schema{6, tickets, ticketsDown, "create ticket tables"}
 
 const comments = `
 CREATE TABLE IF NOT EXISTS comments (
@@ -165,7 +198,11 @@ CREATE TABLE IF NOT EXISTS comments (
 	ticket_id integer REFERENCES tickets (id) NOT NULL
 );`
 
-var v7schema = schema{7, comments, "add comments table"}
+const commentsDown = `
+DROP TABLE IF EXISTS comments;
+`
+
+var v7schema = schema{7, comments, commentsDown, "add comments table"}
 
 const labels = `
 CREATE TABLE IF NOT EXISTS labels (
@@ -179,7 +216,12 @@ CREATE TABLE IF NOT EXISTS tickets_labels (
 	PRIMARY KEY(label_id, ticket_id)
 );`
 
-var v8schema = schema{8, labels, "add labels tables"}
+const labelsDown = `
+DROP TABLE IF EXISTS tickets_labels;
+DROP TABLE IF EXISTS labels;
+`
+
+var v8schema = schema{8, labels, labelsDown, "add labels tables"}
 
 const permissions = `
 CREATE TABLE IF NOT EXISTS permissions (
@@ -194,4 +236,543 @@ CREATE TABLE IF NOT EXISTS permissions (
 );
 `
 
-var v9schema = schema{9, permissions, "add permission tables"}
+const permissionsDown = `
+DROP TABLE IF EXISTS permissions;
+`
+
+var v9schema = schema{9, permissions, permissionsDown, "add permission tables"}
+
+const ticketSearch = `
+CREATE INDEX IF NOT EXISTS tickets_search_idx ON tickets
+USING GIN (to_tsvector('english', summary || ' ' || description));
+`
+
+const ticketSearchDown = `
+DROP INDEX IF EXISTS tickets_search_idx;
+`
+
+var v10schema = schema{10, ticketSearch, ticketSearchDown, "add ticket search index"}
+
+const watchers = `
+CREATE TABLE IF NOT EXISTS ticket_watchers (
+	ticket_id integer REFERENCES tickets (id),
+	user_id   integer REFERENCES users (id),
+	PRIMARY KEY(ticket_id, user_id)
+);`
+
+const watchersDown = `
+DROP TABLE IF EXISTS ticket_watchers;
+`
+
+var v11schema = schema{11, watchers, watchersDown, "add ticket watchers table"}
+
+const links = `
+CREATE TYPE link_type AS ENUM ('blocks', 'blocked_by', 'relates_to', 'duplicates');
+
+CREATE TABLE IF NOT EXISTS ticket_links (
+	id        SERIAL PRIMARY KEY,
+	link_type link_type NOT NULL,
+
+	source_id integer REFERENCES tickets (id) NOT NULL,
+	target_id integer REFERENCES tickets (id) NOT NULL,
+	UNIQUE(source_id, target_id, link_type)
+);`
+
+const linksDown = `
+DROP TABLE IF EXISTS ticket_links;
+DROP TYPE IF EXISTS link_type;
+`
+
+var v12schema = schema{12, links, linksDown, "add ticket links table"}
+
+const workflowTypes = `
+ALTER TABLE workflows ADD COLUMN ticket_type_id integer REFERENCES ticket_types (id);
+`
+
+const workflowTypesDown = `
+ALTER TABLE workflows DROP COLUMN IF EXISTS ticket_type_id;
+`
+
+var v13schema = schema{13, workflowTypes, workflowTypesDown, "add ticket types to workflows"}
+
+const ticketHistory = `
+CREATE TABLE IF NOT EXISTS ticket_history (
+    id SERIAL PRIMARY KEY,
+    created_date timestamp DEFAULT current_timestamp,
+    field varchar(250) NOT NULL,
+    old_value text,
+    new_value text,
+
+    ticket_id integer REFERENCES tickets (id) NOT NULL,
+    user_id integer REFERENCES users (id)
+);
+`
+
+const ticketHistoryDown = `
+DROP TABLE IF EXISTS ticket_history;
+`
+
+var v14schema = schema{14, ticketHistory, ticketHistoryDown, "add ticket history"}
+
+const attachments = `
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    created_date timestamp DEFAULT current_timestamp,
+    filename varchar(250) NOT NULL,
+    content_type varchar(250) NOT NULL,
+    size bigint NOT NULL,
+    storage_key varchar(500) NOT NULL UNIQUE,
+
+    ticket_id integer REFERENCES tickets (id) NOT NULL,
+    uploaded_by integer REFERENCES users (id)
+);
+`
+
+const attachmentsDown = `
+DROP TABLE IF EXISTS attachments;
+`
+
+var v15schema = schema{15, attachments, attachmentsDown, "add attachments"}
+
+const ticketCounter = `
+ALTER TABLE projects ADD COLUMN ticket_counter integer NOT NULL DEFAULT 0;
+
+UPDATE projects SET ticket_counter = (SELECT COUNT(id) FROM tickets 
+                                      WHERE project_id = projects.id);
+`
+
+const ticketCounterDown = `
+ALTER TABLE projects DROP COLUMN IF EXISTS ticket_counter;
+`
+
+var v16schema = schema{16, ticketCounter, ticketCounterDown, "add ticket counter to projects"}
+
+const passwordResets = `
+CREATE TABLE IF NOT EXISTS password_resets (
+    id SERIAL PRIMARY KEY,
+    created_date timestamp DEFAULT current_timestamp,
+    expires_date timestamp with time zone NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    used boolean DEFAULT false,
+
+    user_id integer REFERENCES users (id) NOT NULL
+);
+`
+
+const passwordResetsDown = `
+DROP TABLE IF EXISTS password_resets;
+`
+
+var v17schema = schema{17, passwordResets, passwordResetsDown, "add password resets"}
+
+const teamSlugs = `
+ALTER TABLE teams ADD COLUMN url_slug varchar(250);
+
+UPDATE teams SET url_slug = trim(both '-' from 
+                                 regexp_replace(lower(name), '[^a-z0-9]+', '-', 'g'));
+
+UPDATE teams SET url_slug = url_slug || '-' || id
+WHERE EXISTS (SELECT 1 FROM teams AS other 
+              WHERE other.url_slug = teams.url_slug 
+              AND other.id < teams.id);
+
+ALTER TABLE teams ALTER COLUMN url_slug SET NOT NULL;
+ALTER TABLE teams ADD CONSTRAINT teams_url_slug_key UNIQUE (url_slug);
+`
+
+const teamSlugsDown = `
+ALTER TABLE teams DROP COLUMN IF EXISTS url_slug;
+`
+
+var v18schema = schema{18, teamSlugs, teamSlugsDown, "add url slugs to teams"}
+
+const ticketParents = `
+ALTER TABLE tickets ADD COLUMN parent_id integer REFERENCES tickets (id);
+`
+
+const ticketParentsDown = `
+ALTER TABLE tickets DROP COLUMN IF EXISTS parent_id;
+`
+
+var v19schema = schema{19, ticketParents, ticketParentsDown, "add parent tickets"}
+
+const commentRevisions = `
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id SERIAL PRIMARY KEY,
+    old_body text,
+    edited_at timestamp DEFAULT current_timestamp,
+
+    comment_id integer REFERENCES comments (id) NOT NULL,
+    editor_id integer REFERENCES users (id)
+);
+`
+
+const commentRevisionsDown = `
+DROP TABLE IF EXISTS comment_revisions;
+`
+
+var v20schema = schema{20, commentRevisions, commentRevisionsDown, "add comment revisions"}
+
+const commentReactions = `
+CREATE TABLE IF NOT EXISTS comment_reactions (
+    id SERIAL PRIMARY KEY,
+    emoji varchar(64) NOT NULL,
+
+    comment_id integer REFERENCES comments (id) NOT NULL,
+    user_id integer REFERENCES users (id) NOT NULL,
+
+    UNIQUE (comment_id, user_id, emoji)
+);
+`
+
+const commentReactionsDown = `
+DROP TABLE IF EXISTS comment_reactions;
+`
+
+var v21schema = schema{21, commentReactions, commentReactionsDown, "add comment reactions"}
+
+const ticketVersions = `
+ALTER TABLE tickets ADD COLUMN version integer NOT NULL DEFAULT 1;
+`
+
+const ticketVersionsDown = `
+ALTER TABLE tickets DROP COLUMN IF EXISTS version;
+`
+
+var v22schema = schema{22, ticketVersions, ticketVersionsDown, "add ticket versions"}
+
+const fieldBoolValues = `
+ALTER TABLE field_values ADD COLUMN bln_value boolean;
+`
+
+const fieldBoolValuesDown = `
+ALTER TABLE field_values DROP COLUMN IF EXISTS bln_value;
+`
+
+var v23schema = schema{23, fieldBoolValues, fieldBoolValuesDown, "add bool field values"}
+
+const fieldMultiOptValues = `
+ALTER TABLE fields ALTER COLUMN data_type TYPE varchar(10);
+ALTER TABLE field_values ALTER COLUMN data_type TYPE varchar(10);
+ALTER TABLE field_values ADD COLUMN mlt_value jsonb;
+`
+
+const fieldMultiOptValuesDown = `
+ALTER TABLE field_values DROP COLUMN IF EXISTS mlt_value;
+ALTER TABLE field_values ALTER COLUMN data_type TYPE varchar(6);
+ALTER TABLE fields ALTER COLUMN data_type TYPE varchar(6);
+`
+
+var v24schema = schema{24, fieldMultiOptValues, fieldMultiOptValuesDown, "add multi option field values"}
+
+const requiredFields = `
+ALTER TABLE field_tickettype_project ADD COLUMN required boolean NOT NULL DEFAULT false;
+`
+
+const requiredFieldsDown = `
+ALTER TABLE field_tickettype_project DROP COLUMN IF EXISTS required;
+`
+
+var v25schema = schema{25, requiredFields, requiredFieldsDown, "add required project fields"}
+
+const emailVerifications = `
+ALTER TABLE users ADD COLUMN email_verified boolean NOT NULL DEFAULT false;
+
+-- Users created before verification existed are trusted.
+UPDATE users SET email_verified = true;
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+    id SERIAL PRIMARY KEY,
+    created_date timestamp DEFAULT current_timestamp,
+    expires_date timestamp with time zone NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    used boolean DEFAULT false,
+
+    user_id integer REFERENCES users (id) NOT NULL
+);
+`
+
+const emailVerificationsDown = `
+DROP TABLE IF EXISTS email_verifications;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
+`
+
+var v26schema = schema{26, emailVerifications, emailVerificationsDown, "add email verification"}
+
+const userProfiles = `
+ALTER TABLE users ADD COLUMN display_name varchar(250) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url varchar(250) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN bio text NOT NULL DEFAULT '';
+`
+
+const userProfilesDown = `
+ALTER TABLE users DROP COLUMN IF EXISTS bio;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
+`
+
+var v27schema = schema{27, userProfiles, userProfilesDown, "add user profiles"}
+
+const projectMembers = `
+CREATE TABLE IF NOT EXISTS project_members (
+	project_id integer REFERENCES projects (id) NOT NULL,
+	user_id    integer REFERENCES users (id) NOT NULL,
+	role       varchar(10) NOT NULL,
+	PRIMARY KEY(project_id, user_id)
+);
+`
+
+const projectMembersDown = `
+DROP TABLE IF EXISTS project_members;
+`
+
+var v28schema = schema{28, projectMembers, projectMembersDown, "add project members"}
+
+const uniqueTicketKeys = `
+-- Tickets which share a key with an older ticket are given the next free keys
+-- of their project instead of being lost, base is the highest number a key of
+-- the project could already have.
+WITH bases AS (
+    SELECT p.id, p.key, GREATEST(p.ticket_counter,
+        COALESCE(MAX(substring(t.key FROM '-([0-9]+)$')::integer), 0)) AS base
+    FROM projects AS p
+    LEFT JOIN tickets AS t ON t.project_id = p.id
+    GROUP BY p.id
+), dups AS (
+    SELECT t.id, t.project_id,
+           ROW_NUMBER() OVER (PARTITION BY t.project_id ORDER BY t.id) AS n
+    FROM tickets AS t
+    WHERE EXISTS (SELECT 1 FROM tickets AS o WHERE o.key = t.key AND o.id < t.id)
+), renumbered AS (
+    UPDATE tickets AS t SET key = b.key || '-' || (b.base + d.n)
+    FROM dups AS d
+    JOIN bases AS b ON b.id = d.project_id
+    WHERE t.id = d.id
+    RETURNING t.project_id
+)
+UPDATE projects AS p SET ticket_counter = b.base + c.n
+FROM bases AS b,
+     (SELECT project_id, COUNT(*) AS n FROM renumbered GROUP BY project_id) AS c
+WHERE p.id = b.id AND p.id = c.project_id;
+
+ALTER TABLE tickets ADD CONSTRAINT tickets_key_key UNIQUE (key);
+`
+
+const uniqueTicketKeysDown = `
+ALTER TABLE tickets DROP CONSTRAINT IF EXISTS tickets_key_key;
+`
+
+var v29schema = schema{29, uniqueTicketKeys, uniqueTicketKeysDown, "make ticket keys unique"}
+
+const timeTracking = `
+ALTER TABLE tickets ADD COLUMN original_estimate bigint NOT NULL DEFAULT 0 
+    CHECK (original_estimate >= 0);
+ALTER TABLE tickets ADD COLUMN time_spent bigint NOT NULL DEFAULT 0;
+ALTER TABLE tickets ADD COLUMN remaining_estimate bigint NOT NULL DEFAULT 0 
+    CHECK (remaining_estimate >= 0);
+
+CREATE TABLE IF NOT EXISTS worklogs (
+    id SERIAL PRIMARY KEY,
+    created_date timestamp DEFAULT current_timestamp,
+    seconds bigint NOT NULL CHECK (seconds > 0),
+
+    ticket_id integer REFERENCES tickets (id) NOT NULL,
+    author_id integer REFERENCES users (id) NOT NULL
+);
+`
+
+const timeTrackingDown = `
+DROP TABLE IF EXISTS worklogs;
+ALTER TABLE tickets DROP COLUMN IF EXISTS original_estimate;
+ALTER TABLE tickets DROP COLUMN IF EXISTS time_spent;
+ALTER TABLE tickets DROP COLUMN IF EXISTS remaining_estimate;
+`
+
+var v30schema = schema{30, timeTracking, timeTrackingDown, "add time tracking to tickets"}
+
+const dueDates = `
+ALTER TABLE tickets ADD COLUMN due_date timestamp;
+ALTER TABLE tickets ADD COLUMN priority integer NOT NULL DEFAULT 0 
+    CHECK (priority BETWEEN 0 AND 4);
+
+ALTER TABLE statuses ADD COLUMN closed boolean NOT NULL DEFAULT false;
+
+-- Done is the closed status seeded before there was a closed flag, without
+-- it every existing ticket would count as open.
+UPDATE statuses SET closed = true WHERE name = 'Done';
+`
+
+const dueDatesDown = `
+ALTER TABLE statuses DROP COLUMN IF EXISTS closed;
+ALTER TABLE tickets DROP COLUMN IF EXISTS priority;
+ALTER TABLE tickets DROP COLUMN IF EXISTS due_date;
+`
+
+var v31schema = schema{31, dueDates, dueDatesDown, "add due dates and priorities to tickets"}
+
+const savedFilters = `
+CREATE TABLE IF NOT EXISTS saved_filters (
+    id SERIAL PRIMARY KEY,
+    created_date timestamp DEFAULT current_timestamp,
+    name varchar(250) NOT NULL CHECK (name <> ''),
+    filter_json jsonb NOT NULL,
+
+    user_id integer REFERENCES users (id) NOT NULL,
+    UNIQUE (user_id, name)
+);
+`
+
+const savedFiltersDown = `
+DROP TABLE IF EXISTS saved_filters;
+`
+
+var v32schema = schema{32, savedFilters, savedFiltersDown, "add saved filters"}
+
+const uniqueTypeNames = `
+ALTER TABLE ticket_types ADD CONSTRAINT ticket_types_name_key UNIQUE (name);
+`
+
+const uniqueTypeNamesDown = `
+ALTER TABLE ticket_types DROP CONSTRAINT IF EXISTS ticket_types_name_key;
+`
+
+var v33schema = schema{33, uniqueTypeNames, uniqueTypeNamesDown, "make ticket type names unique"}
+
+const statusPositions = `
+ALTER TABLE statuses ADD COLUMN position integer NOT NULL DEFAULT 0;
+UPDATE statuses SET position = id;
+`
+
+const statusPositionsDown = `
+ALTER TABLE statuses DROP COLUMN IF EXISTS position;
+`
+
+var v34schema = schema{34, statusPositions, statusPositionsDown, "add board positions to statuses"}
+
+const uniqueFieldOptions = `
+DELETE FROM field_options AS a
+USING field_options AS b
+WHERE a.field_id = b.field_id AND a.option = b.option AND a.id > b.id;
+
+ALTER TABLE field_options ADD CONSTRAINT field_options_field_id_option_key
+UNIQUE (field_id, option);
+`
+
+const uniqueFieldOptionsDown = `
+ALTER TABLE field_options DROP CONSTRAINT IF EXISTS field_options_field_id_option_key;
+`
+
+var v35schema = schema{35, uniqueFieldOptions, uniqueFieldOptionsDown, "make field options unique per field"}
+
+const refreshTokens = `
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    created_date timestamp DEFAULT current_timestamp,
+    expires_date timestamp with time zone NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    family varchar(64) NOT NULL,
+    used boolean DEFAULT false,
+
+    user_id integer REFERENCES users (id) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family);
+`
+
+const refreshTokensDown = `
+DROP TABLE IF EXISTS refresh_tokens;
+`
+
+var v36schema = schema{36, refreshTokens, refreshTokensDown, "add refresh tokens"}
+
+const loginAttempts = `
+CREATE TABLE IF NOT EXISTS login_attempts (
+    user_id integer PRIMARY KEY REFERENCES users (id),
+    failures integer NOT NULL DEFAULT 0,
+    locked_until timestamp with time zone
+);
+`
+
+const loginAttemptsDown = `
+DROP TABLE IF EXISTS login_attempts;
+`
+
+var v37schema = schema{37, loginAttempts, loginAttemptsDown, "add login attempts"}
+
+const uniqueEmails = `
+-- Users which share an email with an older user have theirs cleared so the
+-- index can be made, they can set a new one.
+UPDATE users AS a SET email = '', email_verified = false
+FROM users AS b
+WHERE lower(a.email) = lower(b.email) AND a.email <> '' AND a.id > b.id;
+
+-- Users without an email don't conflict with each other.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (lower(email))
+WHERE email <> '';
+`
+
+const uniqueEmailsDown = `
+DROP INDEX IF EXISTS users_email_idx;
+`
+
+var v38schema = schema{38, uniqueEmails, uniqueEmailsDown, "make user emails unique"}
+
+const archivedProjects = `
+ALTER TABLE projects ADD COLUMN archived boolean NOT NULL DEFAULT false;
+`
+
+const archivedProjectsDown = `
+ALTER TABLE projects DROP COLUMN IF EXISTS archived;
+`
+
+var v39schema = schema{39, archivedProjects, archivedProjectsDown, "add archived projects"}
+
+const upperCaseKeys = `
+-- Ticket routes upper case the keys they're given so mixed case keys can't be
+-- found. A key which would collide with an existing upper case key is left as
+-- it is rather than failing the migration.
+UPDATE projects AS p SET key = upper(p.key)
+WHERE p.key <> upper(p.key)
+AND NOT EXISTS (SELECT 1 FROM projects AS o WHERE o.key = upper(p.key));
+
+UPDATE tickets AS t SET key = upper(t.key)
+WHERE t.key <> upper(t.key)
+AND NOT EXISTS (SELECT 1 FROM tickets AS o WHERE o.key = upper(t.key));
+`
+
+// The keys' original case isn't kept so there is nothing to undo.
+const upperCaseKeysDown = ``
+
+var v40schema = schema{40, upperCaseKeys, upperCaseKeysDown, "upper case project and ticket keys"}
+
+const uniqueFieldValues = `
+-- A ticket has one value for each field so Save can upsert them, only the
+-- newest of any duplicates is kept.
+DELETE FROM field_values AS a USING field_values AS b
+WHERE a.ticket_id = b.ticket_id AND a.field_id = b.field_id AND a.id < b.id;
+
+ALTER TABLE field_values ADD CONSTRAINT field_values_ticket_id_field_id_key
+UNIQUE (ticket_id, field_id);
+`
+
+const uniqueFieldValuesDown = `
+ALTER TABLE field_values DROP CONSTRAINT IF EXISTS field_values_ticket_id_field_id_key;
+`
+
+var v41schema = schema{41, uniqueFieldValues, uniqueFieldValuesDown, "make field values unique per ticket"}
+
+const uniqueTeamMembers = `
+-- Concurrent adds could make a user a member more than once, the first is
+-- kept.
+DELETE FROM teams_users AS a USING teams_users AS b
+WHERE a.team_id = b.team_id AND a.user_id = b.user_id AND a.id > b.id;
+
+ALTER TABLE teams_users ADD CONSTRAINT teams_users_team_id_user_id_key
+UNIQUE (team_id, user_id);
+`
+
+const uniqueTeamMembersDown = `
+ALTER TABLE teams_users DROP CONSTRAINT IF EXISTS teams_users_team_id_user_id_key;
+`
+
+var v42schema = schema{42, uniqueTeamMembers, uniqueTeamMembersDown, "make team members unique"}
@@ -7,6 +7,7 @@ import (
 	"github.com/praelatus/backend/config"
 	"github.com/praelatus/backend/store"
 	"github.com/praelatus/backend/store/pg"
+	"github.com/praelatus/backend/store/storetest"
 )
 
 var s store.Store
@@ -30,3 +31,7 @@ func failIfErr(testName string, t *testing.T, e error) {
 		t.Error(testName, " failed with error: ", e)
 	}
 }
+
+func TestStoreSuite(t *testing.T) {
+	storetest.Run(t, s)
+}
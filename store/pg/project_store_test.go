@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
 )
 
 func TestProjectGet(t *testing.T) {
@@ -54,6 +55,13 @@ func TestProjectSave(t *testing.T) {
 
 func TestProjectRemove(t *testing.T) {
 	p := &models.Project{ID: 2}
-	e := s.Projects().Remove(*p)
+	e := s.Projects().Remove(*p, true)
 	failIfErr("Project Remove", t, e)
 }
+
+func TestProjectRemoveWithTickets(t *testing.T) {
+	e := s.Projects().Remove(models.Project{Key: "TEST"}, false)
+	if e != store.ErrProjectHasTickets {
+		t.Errorf("Expected ErrProjectHasTickets Got %v\n", e)
+	}
+}
@@ -2,39 +2,55 @@ package pg
 
 import (
 	"database/sql"
-	"errors"
 
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
 )
 
 // StatusStore contains methods for storing and retrieving Statuses from a
 // Postgres DB
 type StatusStore struct {
-	db *sql.DB
+	db *ctxDB
 }
 
 // Get gets a Status by it's ID in a postgres DB
 func (ss *StatusStore) Get(s *models.Status) error {
 	var row *sql.Row
 
-	row = ss.db.QueryRow(`SELECT id, name 
-						  FROM statuses 
+	row = ss.db.QueryRow(`SELECT id, name, closed, position
+						  FROM statuses
 						  WHERE id = $1
 						  OR name = $2`, s.ID, s.Name)
 
-	err := row.Scan(&s.ID, &s.Name)
+	err := row.Scan(&s.ID, &s.Name, &s.Closed, &s.Position)
 	return handlePqErr(err)
 }
 
 // GetAll gets all the labess from the database
 func (ss *StatusStore) GetAll() ([]models.Status, error) {
+	return ss.query("SELECT id, name, closed, position FROM statuses ORDER BY id")
+}
+
+// GetAllOrdered gets all the statuses from the database in board order
+func (ss *StatusStore) GetAllOrdered() ([]models.Status, error) {
+	return ss.query(`SELECT id, name, closed, position FROM statuses
+					 ORDER BY position, id`)
+}
+
+func (ss *StatusStore) query(q string) ([]models.Status, error) {
 	var statuses []models.Status
-	rows, err := ss.db.Query("SELECT * FROM statuses;")
+
+	rows, err := ss.db.Query(q)
+	if err != nil {
+		return statuses, handlePqErr(err)
+	}
+
+	defer rows.Close()
 
 	for rows.Next() {
 		var s models.Status
 
-		err := rows.Scan(&s.ID, &s.Name)
+		err := rows.Scan(&s.ID, &s.Name, &s.Closed, &s.Position)
 		if err != nil {
 			return statuses, handlePqErr(err)
 		}
@@ -42,26 +58,66 @@ func (ss *StatusStore) GetAll() ([]models.Status, error) {
 		statuses = append(statuses, s)
 	}
 
-	return statuses, handlePqErr(err)
+	return statuses, handlePqErr(rows.Err())
 }
 
-// New creates a new Status in the postgres DB
+// New creates a new Status in the postgres DB, it's placed after every other
+// status on the board.
 func (ss *StatusStore) New(status *models.Status) error {
-	err := ss.db.QueryRow(`INSERT INTO statuses (name) VALUES ($1)
-						   RETURNING id;`,
-		status.Name).
-		Scan(&status.ID)
+	err := ss.db.QueryRow(`INSERT INTO statuses (name, closed, position)
+						   SELECT $1, $2, COALESCE(MAX(position) + 1, 0)
+						   FROM statuses
+						   RETURNING id, position;`,
+		status.Name, status.Closed).
+		Scan(&status.ID, &status.Position)
 
 	return handlePqErr(err)
 }
 
-// Save updates a Status in the postgres DB
+// Save updates a Status in the postgres DB, it's position is only changed by
+// Reorder.
 func (ss *StatusStore) Save(status models.Status) error {
-	_, err := ss.db.Exec(`UPDATE statuses SET (name) = ($1);`, status.Name)
+	_, err := ss.db.Exec(`UPDATE statuses SET (name, closed) = ($1, $2)
+						  WHERE id = $3;`, status.Name, status.Closed, status.ID)
 	return handlePqErr(err)
 }
 
-// Remove removes a status from the database.
+// Reorder sets the positions of the statuses in one transaction,
+// store.ErrNotFound is returned and nothing changed if any of the ids don't
+// exist.
+func (ss *StatusStore) Reorder(ids []int64) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return handlePqErr(err)
+	}
+
+	// Moving every status past the new positions first keeps the ones not
+	// in ids after them in their current order.
+	_, err = tx.Exec(`UPDATE statuses SET position = position + $1`, len(ids))
+	if err != nil {
+		tx.Rollback()
+		return handlePqErr(err)
+	}
+
+	for i, id := range ids {
+		res, err := tx.Exec(`UPDATE statuses SET position = $1 WHERE id = $2`,
+			i, id)
+		if err != nil {
+			tx.Rollback()
+			return handlePqErr(err)
+		}
+
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return store.ErrNotFound
+		}
+	}
+
+	return handlePqErr(tx.Commit())
+}
+
+// Remove removes a status from the database, store.ErrInUse is returned if
+// any tickets or transitions use it.
 func (ss *StatusStore) Remove(status models.Status) error {
 	var c int
 
@@ -79,7 +135,7 @@ func (ss *StatusStore) Remove(status models.Status) error {
 
 	if c > 0 {
 		tx.Rollback()
-		return errors.New("that type is currently in use, refusing to delete")
+		return store.ErrInUse
 	}
 
 	err = tx.QueryRow(`SELECT COUNT(id) FROM transitions
@@ -92,7 +148,7 @@ func (ss *StatusStore) Remove(status models.Status) error {
 
 	if c > 0 {
 		tx.Rollback()
-		return errors.New("that type is currently in use, refusing to delete")
+		return store.ErrInUse
 	}
 
 	_, err = tx.Exec("DELETE FROM statuses WHERE id = $1", status.ID)
@@ -1,21 +1,155 @@
 package pg
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 
 	"github.com/lib/pq"
+	"github.com/praelatus/backend/logger"
 	"github.com/praelatus/backend/store"
 	"github.com/praelatus/backend/store/pg/migrations"
 )
 
+// Log is used by the postgres stores to log errors.
+var Log logger.Logger = logger.Std{}
+
 type rowScanner interface {
 	Scan(dest ...interface{}) error
 }
 
+// sqlExecutor is implemented by *sql.DB and *sql.Tx as well as *ctxDB and
+// *ctxTx so queries can be shared between methods which do and don't run in a
+// transaction.
+type sqlExecutor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ctxDB runs every query with ctx so the queries made for a request are
+// cancelled along with it.
+type ctxDB struct {
+	*sql.DB
+	ctx context.Context
+
+	// tx is set for the stores given to a WithTx function, every query is
+	// run in it instead of on the DB.
+	tx *sql.Tx
+}
+
+// Query runs a query with the ctxDB's context
+func (db *ctxDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if db.tx != nil {
+		return db.tx.QueryContext(db.ctx, query, args...)
+	}
+
+	return db.QueryContext(db.ctx, query, args...)
+}
+
+// QueryRow runs a query expected to return a single row with the ctxDB's
+// context
+func (db *ctxDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	if db.tx != nil {
+		return db.tx.QueryRowContext(db.ctx, query, args...)
+	}
+
+	return db.QueryRowContext(db.ctx, query, args...)
+}
+
+// Exec runs a query which returns no rows with the ctxDB's context
+func (db *ctxDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if db.tx != nil {
+		return db.tx.ExecContext(db.ctx, query, args...)
+	}
+
+	return db.ExecContext(db.ctx, query, args...)
+}
+
+// savepoint is the name of the savepoints made by Begin inside of a WithTx
+// transaction. They are always released or rolled back in the reverse of the
+// order they were made so they can share a name.
+const savepoint = "store_tx"
+
+// Begin starts a transaction which is rolled back if the context is cancelled
+// before it's committed. If the ctxDB is already in a transaction a savepoint
+// is made instead, so rolling back only undoes what came after it.
+func (db *ctxDB) Begin() (*ctxTx, error) {
+	if db.tx != nil {
+		_, err := db.tx.ExecContext(db.ctx, "SAVEPOINT "+savepoint)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ctxTx{db.tx, db.ctx, true}, nil
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ctxTx{tx, db.ctx, false}, nil
+}
+
+// ctxTx runs every query in the transaction with ctx, without it a query
+// blocked on a lock would hold up the rollback when ctx is cancelled.
+type ctxTx struct {
+	*sql.Tx
+	ctx context.Context
+
+	// nested is true when the ctxTx is a savepoint in an outer transaction
+	nested bool
+}
+
+// Query runs a query in the transaction with it's context
+func (tx *ctxTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(tx.ctx, query, args...)
+}
+
+// QueryRow runs a query expected to return a single row in the transaction
+// with it's context
+func (tx *ctxTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.QueryRowContext(tx.ctx, query, args...)
+}
+
+// Exec runs a query which returns no rows in the transaction with it's context
+func (tx *ctxTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.ExecContext(tx.ctx, query, args...)
+}
+
+// Commit commits the transaction, or releases it's savepoint if it's nested
+func (tx *ctxTx) Commit() error {
+	if tx.nested {
+		_, err := tx.Exec("RELEASE SAVEPOINT " + savepoint)
+		return err
+	}
+
+	return tx.Tx.Commit()
+}
+
+// Rollback rolls back the transaction, or everything since it's savepoint if
+// it's nested. The savepoint is released as well so it can't be mistaken for
+// an outer one with the same name.
+func (tx *ctxTx) Rollback() error {
+	if !tx.nested {
+		return tx.Tx.Rollback()
+	}
+
+	_, err := tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("RELEASE SAVEPOINT " + savepoint)
+	return err
+}
+
 // Store implements the store.Store and store.SQLStore interface for a postgres DB.
 type Store struct {
 	db        *sql.DB
+	conn      *ctxDB
 	replicas  []sql.DB
 	users     *UserStore
 	projects  *ProjectStore
@@ -38,21 +172,9 @@ func New(conn string, replicas ...string) store.Store {
 		log.Panicln("Error connection:", err)
 	}
 
-	s := &Store{
-		db:        d,
-		replicas:  []sql.DB{},
-		users:     &UserStore{d},
-		projects:  &ProjectStore{d},
-		fields:    &FieldStore{d},
-		tickets:   &TicketStore{d},
-		labels:    &LabelStore{d},
-		workflows: &WorkflowStore{d},
-		types:     &TypeStore{d},
-		statuses:  &StatusStore{d},
-		teams:     &TeamStore{d},
-	}
-
-	err = migrations.RunMigrations(s.db)
+	s := newStore(d, context.Background(), nil)
+
+	err = migrations.Migrate(s.db)
 	if err != nil {
 		log.Panicln("Error migrating:", err)
 	}
@@ -60,6 +182,51 @@ func New(conn string, replicas ...string) store.Store {
 	return s
 }
 
+// newStore returns a Store whose queries on d are run with ctx, in tx if it
+// isn't nil.
+func newStore(d *sql.DB, ctx context.Context, tx *sql.Tx) *Store {
+	db := &ctxDB{d, ctx, tx}
+
+	return &Store{
+		db:        d,
+		conn:      db,
+		replicas:  []sql.DB{},
+		users:     &UserStore{db},
+		projects:  &ProjectStore{db},
+		fields:    &FieldStore{db},
+		tickets:   &TicketStore{db},
+		labels:    &LabelStore{db},
+		workflows: &WorkflowStore{db},
+		types:     &TypeStore{db},
+		statuses:  &StatusStore{db},
+		teams:     &TeamStore{db},
+	}
+}
+
+// WithContext returns a copy of the store whose queries are run with ctx, they
+// return the context's error if it's cancelled before they finish.
+func (pg *Store) WithContext(ctx context.Context) store.Store {
+	return newStore(pg.db, ctx, pg.conn.tx)
+}
+
+// WithTx runs fn with a copy of the store whose queries are all made in one
+// transaction, it's committed if fn returns nil and rolled back otherwise. If
+// the store is already in a transaction fn runs in a savepoint of it.
+func (pg *Store) WithTx(fn func(tx store.Store) error) error {
+	tx, err := pg.conn.Begin()
+	if err != nil {
+		return handlePqErr(err)
+	}
+
+	err = fn(newStore(pg.db, pg.conn.ctx, tx.Tx))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return handlePqErr(tx.Commit())
+}
+
 // Users returns the underlying UserStore for a postgres DB
 func (pg *Store) Users() store.UserStore {
 	return pg.users
@@ -120,17 +287,32 @@ func toPqErr(e error) *pq.Error {
 	return nil
 }
 
+// pqErrors maps the names of the postgres error codes handlePqErr translates
+// to the store error returned for them.
+var pqErrors = map[string]error{
+	"unique_violation":      store.ErrDuplicateEntry,
+	"foreign_key_violation": store.ErrInvalidReference,
+	"not_null_violation":    store.ErrMissingField,
+}
+
+// handlePqErr translates the errors returned by database/sql and pq into the
+// store errors. sql.ErrNoRows becomes store.ErrNotFound and constraint
+// violations wrap both the store error and the pq.Error, so they can be
+// checked with errors.Is and the details are still available with errors.As.
 func handlePqErr(e error) error {
+	if e == sql.ErrNoRows {
+		return store.ErrNotFound
+	}
+
 	pqe := toPqErr(e)
 	if pqe == nil {
 		return e
 	}
 
-	log.Printf("pq error [%v] %s\n", pqe.Code, pqe.Message)
+	Log.Error("pq error", pqe.Code, pqe.Message)
 
-	// fmt.Println("PQ ERROR CODE:", pqe.Code)
-	if pqe.Code == "23505" {
-		return store.ErrDuplicateEntry
+	if known, ok := pqErrors[pqe.Code.Name()]; ok {
+		return fmt.Errorf("%w: %w", known, e)
 	}
 
 	return e
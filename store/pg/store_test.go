@@ -0,0 +1,45 @@
+package pg
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/praelatus/backend/store"
+)
+
+func TestHandlePqErr(t *testing.T) {
+	tests := []struct {
+		code     pq.ErrorCode
+		expected error
+	}{
+		{"23505", store.ErrDuplicateEntry},
+		{"23503", store.ErrInvalidReference},
+		{"23502", store.ErrMissingField},
+	}
+
+	for _, test := range tests {
+		pqe := &pq.Error{Code: test.code, Message: "synthetic"}
+		err := handlePqErr(pqe)
+
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v for %s Got %v", test.expected, test.code, err)
+		}
+
+		var wrapped *pq.Error
+		if !errors.As(err, &wrapped) || wrapped != pqe {
+			t.Errorf("Expected the pq.Error to be kept for %s Got %v", test.code, err)
+		}
+	}
+
+	if err := handlePqErr(sql.ErrNoRows); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v", err)
+	}
+
+	// Errors handlePqErr doesn't know are returned unchanged.
+	other := &pq.Error{Code: "42601", Message: "syntax error"}
+	if err := handlePqErr(other); err != other {
+		t.Errorf("Expected the syntax error unchanged Got %v", err)
+	}
+}
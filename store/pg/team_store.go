@@ -5,19 +5,31 @@ import (
 	"encoding/json"
 
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
 )
 
 // TeamStore contains methods for storing and retrieving Teams from a Postgres
 // DB
 type TeamStore struct {
-	db *sql.DB
+	db *ctxDB
 }
 
-func intoTeam(db *sql.DB, row rowScanner, t *models.Team) error {
+func intoTeam(db *ctxDB, row rowScanner, t *models.Team) error {
+	err := scanTeam(row, t)
+	if err != nil {
+		return err
+	}
+
+	return getTeamMembers(db, t)
+}
+
+// scanTeam will scan the team and it's lead into t, the members are left to
+// getTeamMembers.
+func scanTeam(row rowScanner, t *models.Team) error {
 	var u models.User
 	var ujson json.RawMessage
 
-	err := row.Scan(&t.ID, &t.Name, &ujson)
+	err := row.Scan(&t.ID, &t.Name, &t.URLSlug, &ujson)
 	if err != nil {
 		return err
 	}
@@ -27,8 +39,14 @@ func intoTeam(db *sql.DB, row rowScanner, t *models.Team) error {
 		return err
 	}
 
+	u.Password = ""
 	t.Lead = u
+	t.Members = nil
+	return nil
+}
 
+// getTeamMembers will set the members of the team
+func getTeamMembers(db *ctxDB, t *models.Team) error {
 	rows, err := db.Query(`SELECT u.id, u.username, u.email, 
 								  u.full_name, u.gravatar, u.profile_picture,
 								  u.is_admin
@@ -54,72 +72,102 @@ func intoTeam(db *sql.DB, row rowScanner, t *models.Team) error {
 		t.Members = append(t.Members, u)
 	}
 
-	return nil
+	return rows.Err()
 }
 
-// Get retrieves a team from the database based on ID
+// teamsFromRows will scan every team in rows and then get their members, the
+// rows are closed first since in a transaction every query shares one
+// connection.
+func teamsFromRows(db *ctxDB, rows *sql.Rows) ([]models.Team, error) {
+	var teams []models.Team
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Team
+
+		err := scanTeam(rows, &t)
+		if err != nil {
+			return teams, err
+		}
+
+		teams = append(teams, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return teams, err
+	}
+
+	rows.Close()
+
+	for i := range teams {
+		err := getTeamMembers(db, &teams[i])
+		if err != nil {
+			return teams, err
+		}
+	}
+
+	return teams, nil
+}
+
+// Get retrieves a team from the database based on ID, name or url slug
 func (ts *TeamStore) Get(t *models.Team) error {
-	row := ts.db.QueryRow(`SELECT t.id, t.name, row_to_json(lead.*) as lead
+	row := ts.db.QueryRow(`SELECT t.id, t.name, t.url_slug, 
+								  row_to_json(lead.*) as lead
 							  FROM teams AS t
 							  JOIN users AS lead ON lead.id = t.lead_id
 							  WHERE t.id = $1
-							  OR t.name = $2;`, t.ID, t.Name)
+							  OR t.name = $2
+							  OR t.url_slug = $3;`, t.ID, t.Name, t.URLSlug)
 
 	err := intoTeam(ts.db, row, t)
 	return handlePqErr(err)
 }
 
 // GetMembers will get the members for the given team.
-func (ts *TeamStore) GetMembers(t *models.Team) error {
-	rows, err := ts.db.Query(`SELECT u.id, username, password, email, full_name, 
-									 gravatar, profile_picture, is_admin
-							  FROM teams_users AS tu
-							  JOIN users AS u ON tu.user_id = u.id
-							  WHERE tu.team_id = $1`, t.ID)
+func (ts *TeamStore) GetMembers(t models.Team) ([]models.User, error) {
+	members := []models.User{}
+
+	rows, err := ts.db.Query(`SELECT `+userColumns+`
+							  FROM users
+							  WHERE id IN (SELECT user_id FROM teams_users
+										   WHERE team_id = $1)
+							  ORDER BY id`, t.ID)
 	if err != nil {
-		return handlePqErr(err)
+		return members, handlePqErr(err)
 	}
 
 	defer rows.Close()
 
 	for rows.Next() {
-		var u *models.User
+		var u models.User
 
-		err = intoUser(rows, u)
+		err = intoUser(rows, &u)
 		if err != nil {
-			return handlePqErr(err)
+			return members, handlePqErr(err)
 		}
 
-		t.Members = append(t.Members, *u)
+		u.Password = ""
+		members = append(members, u)
 	}
 
-	return nil
+	return members, handlePqErr(rows.Err())
 }
 
 // GetAll retrieves all the teams from the db
 func (ts *TeamStore) GetAll() ([]models.Team, error) {
 	var teams []models.Team
 
-	rows, err := ts.db.Query(`SELECT t.id, t.name, row_to_json(lead.*) AS lead
+	rows, err := ts.db.Query(`SELECT t.id, t.name, t.url_slug, 
+									 row_to_json(lead.*) AS lead
 							  FROM teams AS t
-							  JOIN users AS lead ON lead.id = t.lead_id`)
+							  JOIN users AS lead ON lead.id = t.lead_id
+							  ORDER BY t.id`)
 	if err != nil {
 		return teams, handlePqErr(err)
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		t := &models.Team{}
-
-		err := intoTeam(ts.db, rows, t)
-		if err != nil {
-			return teams, handlePqErr(err)
-		}
-
-		teams = append(teams, *t)
-	}
-
+	teams, err = teamsFromRows(ts.db, rows)
 	return teams, handlePqErr(err)
 }
 
@@ -127,7 +175,7 @@ func (ts *TeamStore) GetAll() ([]models.Team, error) {
 func (ts *TeamStore) GetForUser(u models.User) ([]models.Team, error) {
 	var teams []models.Team
 
-	rows, err := ts.db.Query(`SELECT t.id, t.name, row_to_json(lead.*)
+	rows, err := ts.db.Query(`SELECT t.id, t.name, t.url_slug, row_to_json(lead.*)
 							FROM teams_users
 							JOIN teams AS t ON t.id = teams_users.team_id
 							JOIN users as u ON u.id = teams_users.user_id
@@ -137,20 +185,7 @@ func (ts *TeamStore) GetForUser(u models.User) ([]models.Team, error) {
 		return teams, err
 	}
 
-	defer rows.Close()
-
-	for rows.Next() {
-		t := &models.Team{}
-
-		err = intoTeam(ts.db, rows, t)
-		if err != nil {
-			return teams, err
-		}
-
-		teams = append(teams, *t)
-	}
-
-	return teams, nil
+	return teamsFromRows(ts.db, rows)
 }
 
 // AddMembers will add users to the given team
@@ -160,22 +195,57 @@ func (ts *TeamStore) AddMembers(t models.Team, users ...models.User) error {
 	}
 
 	for _, u := range users {
-		_, err := ts.db.Exec(`INSERT INTO teams_users (team_id, user_id)
-							  VALUES ($1, $2)`, t.ID, u.ID)
+		err := ts.AddMember(t, u)
 		if err != nil {
-			return handlePqErr(err)
+			return err
 		}
 	}
 
 	return nil
 }
 
-// New adds a new team to the database.
+// AddMember will add the user to the given team, adding a user who is
+// already a member does nothing.
+func (ts *TeamStore) AddMember(t models.Team, u models.User) error {
+	_, err := ts.db.Exec(`INSERT INTO teams_users (team_id, user_id)
+						  VALUES ($1, $2) ON CONFLICT (team_id, user_id) DO NOTHING`,
+		t.ID, u.ID)
+
+	return handlePqErr(err)
+}
+
+// RemoveMember will remove the user from the given team, returning
+// store.ErrRemoveLead if the user is the lead of the team.
+func (ts *TeamStore) RemoveMember(t models.Team, u models.User) error {
+	var leadID sql.NullInt64
+
+	err := ts.db.QueryRow(`SELECT lead_id FROM teams WHERE id = $1`, t.ID).
+		Scan(&leadID)
+	if err != nil {
+		return handlePqErr(err)
+	}
+
+	if leadID.Int64 == u.ID {
+		return store.ErrRemoveLead
+	}
+
+	_, err = ts.db.Exec(`DELETE FROM teams_users 
+						 WHERE team_id = $1 AND user_id = $2`, t.ID, u.ID)
+
+	return handlePqErr(err)
+}
+
+// New adds a new team to the database, if the team has no url slug one is
+// generated from it's name.
 func (ts *TeamStore) New(t *models.Team) error {
+	if t.URLSlug == "" {
+		t.URLSlug = models.Slugify(t.Name)
+	}
+
 	err := ts.db.QueryRow(`INSERT INTO teams 
-						  (name, lead_id) VALUES ($1, $2)
+						  (name, url_slug, lead_id) VALUES ($1, $2, $3)
 						  RETURNING id;`,
-		t.Name, t.Lead.ID).
+		t.Name, t.URLSlug, t.Lead.ID).
 		Scan(&t.ID)
 	if err != nil {
 		return handlePqErr(err)
@@ -183,7 +253,8 @@ func (ts *TeamStore) New(t *models.Team) error {
 
 	for _, mem := range t.Members {
 		_, err = ts.db.Exec(`INSERT INTO teams_users
-					         (team_id, user_id) VALUES ($1, $2)`, t.ID, mem.ID)
+					         (team_id, user_id) VALUES ($1, $2)
+							 ON CONFLICT (team_id, user_id) DO NOTHING`, t.ID, mem.ID)
 	}
 
 	return handlePqErr(err)
@@ -191,10 +262,14 @@ func (ts *TeamStore) New(t *models.Team) error {
 
 // Save updates a t to the database.
 func (ts *TeamStore) Save(t models.Team) error {
+	if t.URLSlug == "" {
+		t.URLSlug = models.Slugify(t.Name)
+	}
+
 	_, err := ts.db.Exec(`UPDATE teams SET 
-					     (name, lead_id) = ($1, $2)
-						 WHERE id = $3;`,
-		t.Name, t.Lead.ID, t.ID)
+					     (name, url_slug, lead_id) = ($1, $2, $3)
+						 WHERE id = $4;`,
+		t.Name, t.URLSlug, t.Lead.ID, t.ID)
 	return handlePqErr(err)
 }
 
@@ -1,9 +1,11 @@
 package pg_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
 )
 
 func TestTeamGet(t *testing.T) {
@@ -58,3 +60,64 @@ func TestTeamRemove(t *testing.T) {
 	e := s.Teams().Remove(models.Team{ID: 2})
 	failIfErr("Team Remove", t, e)
 }
+
+func TestTeamNew(t *testing.T) {
+	team := &models.Team{
+		Name: "Test Team New",
+		Lead: models.User{ID: 1},
+	}
+
+	e := s.Teams().New(team)
+	failIfErr("Team New", t, e)
+
+	got := &models.Team{URLSlug: "test-team-new"}
+	e = s.Teams().Get(got)
+	failIfErr("Team New", t, e)
+
+	if got.ID != team.ID {
+		t.Errorf("Expected team %d Got %d\n", team.ID, got.ID)
+	}
+
+	if got.Lead.Username == "" || got.Lead.Password != "" {
+		t.Errorf("Expected the lead to be populated Got %v\n", got.Lead)
+	}
+
+	e = s.Teams().New(&models.Team{Name: "Test Team New", Lead: models.User{ID: 1}})
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected ErrDuplicateEntry Got %v\n", e)
+	}
+
+	e = s.Teams().Remove(*team)
+	failIfErr("Team New", t, e)
+}
+
+func TestTeamMembers(t *testing.T) {
+	team := models.Team{ID: 1}
+	e := s.Teams().Get(&team)
+	failIfErr("Team Members", t, e)
+
+	e = s.Teams().AddMember(team, models.User{ID: 2})
+	failIfErr("Team Members", t, e)
+
+	e = s.Teams().AddMember(team, models.User{ID: 2})
+	failIfErr("Team Members", t, e)
+
+	members, e := s.Teams().GetMembers(team)
+	failIfErr("Team Members", t, e)
+
+	count := 0
+	for _, m := range members {
+		if m.ID == 2 {
+			count++
+		}
+	}
+
+	if count != 1 {
+		t.Errorf("Expected user 2 to be a member once Got %d\n", count)
+	}
+
+	e = s.Teams().RemoveMember(team, team.Lead)
+	if e != store.ErrRemoveLead {
+		t.Errorf("Expected ErrRemoveLead Got %v\n", e)
+	}
+}
@@ -0,0 +1,180 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/praelatus/backend/config"
+	"github.com/praelatus/backend/models"
+)
+
+const (
+	benchTickets   = 500
+	benchOptFields = 3
+)
+
+// benchTicketIDs will create (if necessary) a project with 500 tickets each
+// having 3 option fields and return the ids of those tickets.
+func benchTicketIDs(b *testing.B, db *sql.DB) []int64 {
+	var pid int64
+
+	err := db.QueryRow(`SELECT id FROM projects WHERE key = 'BENCH'`).Scan(&pid)
+	if err == sql.ErrNoRows {
+		err = db.QueryRow(`INSERT INTO projects (name, key, lead_id)
+						   VALUES ('Benchmark Project', 'BENCH', 1)
+						   RETURNING id`).Scan(&pid)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		var fids []int64
+		for i := 0; i < benchOptFields; i++ {
+			var fid int64
+
+			err = db.QueryRow(`INSERT INTO fields (name, data_type)
+							   VALUES ($1, 'OPT') RETURNING id`,
+				fmt.Sprintf("Bench Option %d", i)).Scan(&fid)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			_, err = db.Exec(`INSERT INTO field_options (field_id, option)
+							  VALUES ($1, 'HIGH'), ($1, 'MEDIUM'), ($1, 'LOW')`, fid)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			fids = append(fids, fid)
+		}
+
+		for i := 0; i < benchTickets; i++ {
+			var tid int64
+
+			err = db.QueryRow(`INSERT INTO tickets
+							   (summary, description, project_id, assignee_id,
+							   reporter_id, ticket_type_id, status_id, key)
+							   VALUES ('Benchmark ticket', '', $1, 1, 1, 1, 1, $2)
+							   RETURNING id`, pid, fmt.Sprintf("BENCH%d", i+1)).
+				Scan(&tid)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for _, fid := range fids {
+				_, err = db.Exec(`INSERT INTO field_values
+								  (ticket_id, field_id, data_type, opt_value)
+								  VALUES ($1, $2, 'OPT', 'LOW')`, tid, fid)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	} else if err != nil {
+		b.Fatal(err)
+	}
+
+	var ids []int64
+
+	rows, err := db.Query(`SELECT id FROM tickets WHERE project_id = $1`, pid)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+
+		err = rows.Scan(&id)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// populateFieldsPerOption is the previous implementation of populateFields
+// which queries the values once for every ticket and the options once for
+// every OPT field value.
+func populateFieldsPerOption(db *ctxDB, tickets []*models.Ticket) error {
+	for _, t := range tickets {
+		err := populateTicketPerOption(db, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func populateTicketPerOption(db *ctxDB, t *models.Ticket) error {
+	rows, err := db.Query(`
+		SELECT fv.id, f.name, f.data_type, fv.opt_value, f.id
+		FROM field_values AS fv
+		JOIN fields AS f ON f.id = fv.field_id
+		WHERE fv.ticket_id = $1`, t.ID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		fv := models.FieldValue{}
+		var o sql.NullString
+		var fID int64
+
+		err = rows.Scan(&fv.ID, &fv.Name, &fv.DataType, &o, &fID)
+		if err != nil {
+			return err
+		}
+
+		fo := models.FieldOption{Selected: o.String}
+
+		err = getOpts(db, fID, &fo)
+		if err != nil {
+			return err
+		}
+
+		fv.Value = fo
+		t.Fields = append(t.Fields, fv)
+	}
+
+	return nil
+}
+
+func benchmarkPopulate(b *testing.B, populate func(*ctxDB, []*models.Ticket) error) {
+	db, err := sql.Open("postgres", config.GetDbURL())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	ids := benchTicketIDs(b, db)
+	cdb := &ctxDB{DB: db, ctx: context.Background()}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tickets := make([]*models.Ticket, len(ids))
+		for i, id := range ids {
+			tickets[i] = &models.Ticket{ID: id}
+		}
+
+		err = populate(cdb, tickets)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPopulateFieldsPerOption(b *testing.B) {
+	benchmarkPopulate(b, populateFieldsPerOption)
+}
+
+func BenchmarkPopulateFieldsBatched(b *testing.B) {
+	benchmarkPopulate(b, populateFields)
+}
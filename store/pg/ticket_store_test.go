@@ -1,9 +1,14 @@
 package pg_test
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+	"github.com/praelatus/backend/store/pg"
 )
 
 func TestTicketGet(t *testing.T) {
@@ -26,7 +31,7 @@ func TestTicketGetAll(t *testing.T) {
 }
 
 func TestTicketGetAllByProject(t *testing.T) {
-	tks, e := s.Tickets().GetAllByProject(models.Project{ID: 1})
+	tks, e := s.Tickets().GetAllByProject(models.Project{ID: 1}, "")
 	failIfErr("Ticket Get All By Project", t, e)
 
 	if tks == nil || len(tks) == 0 {
@@ -34,6 +39,104 @@ func TestTicketGetAllByProject(t *testing.T) {
 	}
 }
 
+func TestTicketGetAllPaged(t *testing.T) {
+	all, e := s.Tickets().GetAll()
+	failIfErr("Ticket Get All Paged", t, e)
+
+	tks, total, e := s.Tickets().GetAllPaged(store.PageOptions{
+		Limit:   5,
+		Offset:  2,
+		OrderBy: "id",
+	})
+	failIfErr("Ticket Get All Paged", t, e)
+
+	if total != len(all) {
+		t.Errorf("Expected total %d Got %d\n", len(all), total)
+	}
+
+	if len(tks) != 5 {
+		t.Errorf("Expected 5 tickets Got %d\n", len(tks))
+	}
+
+	for i := 1; i < len(tks); i++ {
+		if tks[i-1].ID > tks[i].ID {
+			t.Errorf("Expected tickets ordered by id Got %d before %d\n",
+				tks[i-1].ID, tks[i].ID)
+		}
+	}
+
+	_, _, e = s.Tickets().GetAllPaged(store.PageOptions{OrderBy: "1; DROP TABLE tickets"})
+	if e != store.ErrInvalidOrderBy {
+		t.Errorf("Expected ErrInvalidOrderBy Got %v\n", e)
+	}
+}
+
+func TestTicketGetAllByProjectPaged(t *testing.T) {
+	tks, total, e := s.Tickets().GetAllByProjectPaged(models.Project{ID: 1},
+		store.PageOptions{Limit: 10})
+	failIfErr("Ticket Get All By Project Paged", t, e)
+
+	if len(tks) == 0 || len(tks) > 10 {
+		t.Errorf("Expected between 1 and 10 tickets Got %d\n", len(tks))
+	}
+
+	if total < len(tks) {
+		t.Errorf("Expected total of at least %d Got %d\n", len(tks), total)
+	}
+}
+
+func TestTicketSearch(t *testing.T) {
+	p := models.Project{ID: 1, Key: "TEST"}
+	tks := []models.Ticket{
+		{
+			Summary:     "Zebra giraffe migration",
+			Description: "The zebra and giraffe herds are migrating.",
+		},
+		{
+			Summary:     "Zebra sighting",
+			Description: "Spotted near a giraffe.",
+		},
+		{
+			Summary:     "Unrelated ticket",
+			Description: "Nothing to see here.",
+		},
+	}
+
+	for i := range tks {
+		tks[i].Reporter = models.User{ID: 1}
+		tks[i].Assignee = models.User{ID: 1}
+		tks[i].Status = models.Status{ID: 1}
+		tks[i].Type = models.TicketType{ID: 1}
+
+		e := s.Tickets().New(p, &tks[i])
+		failIfErr("Ticket Search", t, e)
+	}
+
+	res, e := s.Tickets().Search("zebra giraffe", p)
+	failIfErr("Ticket Search", t, e)
+
+	if len(res) < 2 {
+		t.Fatalf("Expected at least 2 results Got %d\n", len(res))
+	}
+
+	if res[0].Summary != tks[0].Summary {
+		t.Errorf("Expected %s first Got %s\n", tks[0].Summary, res[0].Summary)
+	}
+
+	for _, tk := range res {
+		if tk.Summary == tks[2].Summary {
+			t.Errorf("Expected %s to not match\n", tk.Summary)
+		}
+	}
+
+	res, e = s.Tickets().Search("zebra giraffe", models.Project{ID: 2})
+	failIfErr("Ticket Search", t, e)
+
+	if len(res) != 0 {
+		t.Errorf("Expected no results in another project Got %d\n", len(res))
+	}
+}
+
 func TestTicketGetComments(t *testing.T) {
 	tk := models.Ticket{ID: 1}
 	c, e := s.Tickets().GetComments(tk)
@@ -80,8 +183,516 @@ func TestTicketSave(t *testing.T) {
 	}
 }
 
+func TestTicketWithTx(t *testing.T) {
+	tx, e := s.(store.SQLStore).Conn().Begin()
+	failIfErr("Ticket With Tx", t, e)
+
+	tk := models.Ticket{ID: 3}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket With Tx", t, e)
+
+	original := tk.Summary
+	tk.Summary = "Never committed"
+
+	e = s.Tickets().(*pg.TicketStore).WithTx(tx).Save(tk)
+	failIfErr("Ticket With Tx", t, e)
+
+	e = tx.Rollback()
+	failIfErr("Ticket With Tx", t, e)
+
+	tk = models.Ticket{ID: 3}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket With Tx", t, e)
+
+	if tk.Summary != original {
+		t.Errorf("Expected: %s Got: %s\n", original, tk.Summary)
+	}
+}
+
+func TestTicketSaveRollback(t *testing.T) {
+	tk := models.Ticket{ID: 4}
+	e := s.Tickets().Get(&tk)
+	failIfErr("Ticket Save Rollback", t, e)
+
+	original := tk.Summary
+
+	tk.Summary = "This save should be rolled back"
+	tk.Fields = []models.FieldValue{
+		{Name: "Story Points", DataType: "INT", Value: 3},
+		{Name: "Broken", DataType: "NOT_A_TYPE", Value: 3},
+	}
+
+	e = s.Tickets().Save(tk)
+	if e == nil {
+		t.Error("Expected an error saving an invalid field got nil")
+	}
+
+	tk = models.Ticket{ID: 4}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket Save Rollback", t, e)
+
+	if tk.Summary != original {
+		t.Errorf("Expected: %s Got: %s\n", original, tk.Summary)
+	}
+}
+
+func TestTicketWatchers(t *testing.T) {
+	tk := models.Ticket{ID: 1}
+	u := models.User{ID: 1}
+
+	e := s.Tickets().AddWatcher(tk, u)
+	failIfErr("Ticket Watchers", t, e)
+
+	e = s.Tickets().AddWatcher(tk, u)
+	failIfErr("Ticket Watchers", t, e)
+
+	watchers, e := s.Tickets().GetWatchers(tk)
+	failIfErr("Ticket Watchers", t, e)
+
+	var c int
+	for _, w := range watchers {
+		if w.ID == u.ID {
+			c++
+		}
+	}
+
+	if c != 1 {
+		t.Errorf("Expected user to be watching once Got %d\n", c)
+	}
+
+	e = s.Tickets().RemoveWatcher(tk, u)
+	failIfErr("Ticket Watchers", t, e)
+
+	watchers, e = s.Tickets().GetWatchers(tk)
+	failIfErr("Ticket Watchers", t, e)
+
+	for _, w := range watchers {
+		if w.ID == u.ID {
+			t.Error("Expected user to no longer be watching")
+		}
+	}
+}
+
+func TestTicketLinks(t *testing.T) {
+	src := models.Ticket{ID: 5}
+	dst := models.Ticket{ID: 6}
+
+	e := s.Tickets().LinkTickets(src, dst, models.LinkBlocks)
+	failIfErr("Ticket Links", t, e)
+
+	links, e := s.Tickets().GetLinks(dst)
+	failIfErr("Ticket Links", t, e)
+
+	var found bool
+	for _, l := range links {
+		if l.LinkType == models.LinkBlockedBy && l.TargetID == src.ID {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("Expected an inverse blocked_by link Got %v\n", links)
+	}
+
+	e = s.Tickets().LinkTickets(src, src, models.LinkRelatesTo)
+	if e != models.ErrSelfLink {
+		t.Errorf("Expected ErrSelfLink Got %v\n", e)
+	}
+
+	e = s.Tickets().LinkTickets(src, dst, "fixes")
+	if e != models.ErrInvalidLinkType {
+		t.Errorf("Expected ErrInvalidLinkType Got %v\n", e)
+	}
+
+	e = s.Tickets().Unlink(src, dst, models.LinkBlocks)
+	failIfErr("Ticket Links", t, e)
+
+	links, e = s.Tickets().GetLinks(dst)
+	failIfErr("Ticket Links", t, e)
+
+	for _, l := range links {
+		if l.TargetID == src.ID {
+			t.Errorf("Expected the inverse link to be removed Got %v\n", l)
+		}
+	}
+}
+
+func TestTicketTransition(t *testing.T) {
+	p := models.Project{ID: 1, Key: "TEST"}
+	tk := &models.Ticket{
+		Summary:     "Transition ticket",
+		Description: "A ticket for transition tests",
+		Reporter:    models.User{ID: 1},
+		Assignee:    models.User{ID: 1},
+		Status:      models.Status{ID: 1},
+		Type:        models.TicketType{ID: 1},
+	}
+
+	e := s.Tickets().New(p, tk)
+	failIfErr("Ticket Transition", t, e)
+
+	transitions, e := s.Tickets().GetTransitions(*tk)
+	failIfErr("Ticket Transition", t, e)
+
+	if len(transitions) != 1 || transitions[0].ToStatus.ID != 2 {
+		t.Errorf("Expected a single transition to status 2 Got %v\n", transitions)
+	}
+
+	e = s.Tickets().TransitionTicket(*tk, models.Status{ID: 3})
+	if e != store.ErrInvalidTransition {
+		t.Errorf("Expected ErrInvalidTransition Got %v\n", e)
+	}
+
+	e = s.Tickets().TransitionTicket(*tk, models.Status{ID: 2})
+	failIfErr("Ticket Transition", t, e)
+
+	e = s.Tickets().Get(tk)
+	failIfErr("Ticket Transition", t, e)
+
+	if tk.Status.ID != 2 {
+		t.Errorf("Expected status 2 Got %d\n", tk.Status.ID)
+	}
+}
+
+func TestTicketHistory(t *testing.T) {
+	p := models.Project{ID: 1, Key: "TEST"}
+	tk := &models.Ticket{
+		Summary:     "History ticket",
+		Description: "A ticket for history tests",
+		Reporter:    models.User{ID: 1},
+		Assignee:    models.User{ID: 1},
+		Status:      models.Status{ID: 1},
+		Type:        models.TicketType{ID: 1},
+	}
+
+	e := s.Tickets().New(p, tk)
+	failIfErr("Ticket History", t, e)
+
+	tk.Summary = "Changed history ticket"
+	tk.UpdatedBy = models.User{ID: 1}
+
+	e = s.Tickets().Save(*tk)
+	failIfErr("Ticket History", t, e)
+
+	e = s.Tickets().TransitionTicket(*tk, models.Status{ID: 2})
+	failIfErr("Ticket History", t, e)
+
+	history, e := s.Tickets().GetHistory(*tk)
+	failIfErr("Ticket History", t, e)
+
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries Got %d\n", len(history))
+	}
+
+	if history[0].Field != "summary" || history[0].NewValue != tk.Summary {
+		t.Errorf("Expected a summary change Got %v\n", history[0])
+	}
+
+	if history[1].Field != "status" || history[1].OldValue != "Backlog" {
+		t.Errorf("Expected a status change from Backlog Got %v\n", history[1])
+	}
+
+	if history[1].User.ID != 1 {
+		t.Errorf("Expected change by user 1 Got %d\n", history[1].User.ID)
+	}
+}
+
+func TestTicketAttachments(t *testing.T) {
+	tk := models.Ticket{ID: 2}
+	a := &models.Attachment{
+		Filename:    "screenshot.png",
+		ContentType: "image/png",
+		Size:        1024,
+		StorageKey:  "tickets/2/screenshot.png",
+		UploadedBy:  models.User{ID: 1},
+	}
+
+	e := s.Tickets().AddAttachment(tk, a)
+	failIfErr("Ticket Attachments", t, e)
+
+	if a.ID == 0 {
+		t.Errorf("Expected attachment to have an ID Got 0\n")
+	}
+
+	attachments, e := s.Tickets().GetAttachments(tk)
+	failIfErr("Ticket Attachments", t, e)
+
+	if len(attachments) != 1 {
+		t.Fatalf("Expected 1 attachment Got %d\n", len(attachments))
+	}
+
+	got := attachments[0]
+	if got.ID != a.ID || got.Filename != a.Filename ||
+		got.ContentType != a.ContentType || got.Size != a.Size ||
+		got.StorageKey != a.StorageKey || got.UploadedBy.ID != 1 {
+		t.Errorf("Expected %v Got %v\n", a, got)
+	}
+
+	e = s.Tickets().RemoveAttachment(*a)
+	failIfErr("Ticket Attachments", t, e)
+
+	attachments, e = s.Tickets().GetAttachments(tk)
+	failIfErr("Ticket Attachments", t, e)
+
+	if len(attachments) != 0 {
+		t.Errorf("Expected no attachments Got %d\n", len(attachments))
+	}
+}
+
 func TestTicketRemove(t *testing.T) {
 	tk := models.Ticket{ID: 3}
 	e := s.Tickets().Remove(tk)
 	failIfErr("Ticket save", t, e)
 }
+
+func TestTicketNewWithFields(t *testing.T) {
+	p := models.Project{ID: 1, Key: "TEST"}
+	tk := &models.Ticket{
+		Summary:     "New ticket with fields",
+		Description: "A ticket for field value inserts",
+		Reporter:    models.User{ID: 1},
+		Assignee:    models.User{ID: 1},
+		Status:      models.Status{ID: 1},
+		Type:        models.TicketType{ID: 1},
+		Fields: []models.FieldValue{
+			{Name: "Story Points", DataType: "INT", Value: 5},
+			{
+				Name:     "Priority",
+				DataType: "OPT",
+				Value:    models.FieldOption{Selected: "HIGH"},
+			},
+		},
+	}
+
+	e := s.Tickets().New(p, tk)
+	failIfErr("Ticket New With Fields", t, e)
+
+	for _, fv := range tk.Fields {
+		if fv.ID == 0 {
+			t.Errorf("Expected %s to have an ID Got 0\n", fv.Name)
+		}
+	}
+
+	var c int
+
+	e = s.(store.SQLStore).Conn().
+		QueryRow(`SELECT COUNT(id) FROM field_values WHERE ticket_id = $1`, tk.ID).
+		Scan(&c)
+	failIfErr("Ticket New With Fields", t, e)
+
+	if c != 2 {
+		t.Errorf("Expected 2 field values Got %d\n", c)
+	}
+}
+
+func TestTicketGetWithFields(t *testing.T) {
+	p := models.Project{ID: 1, Key: "TEST"}
+	tk := &models.Ticket{
+		Summary:     "Ticket with fields",
+		Description: "A ticket for field value tests",
+		Reporter:    models.User{ID: 1},
+		Assignee:    models.User{ID: 1},
+		Status:      models.Status{ID: 1},
+		Type:        models.TicketType{ID: 1},
+	}
+
+	e := s.Tickets().New(p, tk)
+	failIfErr("Ticket Get With Fields", t, e)
+
+	fields := []models.Field{
+		{Name: "Populate INT", DataType: "INT"},
+		{Name: "Populate STRING", DataType: "STRING"},
+		{Name: "Populate DATE", DataType: "DATE"},
+		{Name: "Populate OPT", DataType: "OPT"},
+	}
+
+	for i := range fields {
+		e = s.Fields().New(&fields[i])
+		if errors.Is(e, store.ErrDuplicateEntry) {
+			e = s.Fields().Get(&fields[i])
+		}
+
+		failIfErr("Ticket Get With Fields", t, e)
+	}
+
+	db := s.(store.SQLStore).Conn()
+	due := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	_, e = db.Exec(`INSERT INTO field_values 
+					(ticket_id, field_id, name, data_type, int_value)
+					VALUES ($1, $2, $3, $4, $5)`,
+		tk.ID, fields[0].ID, fields[0].Name, fields[0].DataType, 5)
+	failIfErr("Ticket Get With Fields", t, e)
+
+	_, e = db.Exec(`INSERT INTO field_values 
+					(ticket_id, field_id, name, data_type, str_value)
+					VALUES ($1, $2, $3, $4, $5)`,
+		tk.ID, fields[1].ID, fields[1].Name, fields[1].DataType, "a string")
+	failIfErr("Ticket Get With Fields", t, e)
+
+	_, e = db.Exec(`INSERT INTO field_values 
+					(ticket_id, field_id, name, data_type, dte_value)
+					VALUES ($1, $2, $3, $4, $5)`,
+		tk.ID, fields[2].ID, fields[2].Name, fields[2].DataType, due)
+	failIfErr("Ticket Get With Fields", t, e)
+
+	_, e = db.Exec(`INSERT INTO field_options (field_id, option)
+					VALUES ($1, 'HIGH'), ($1, 'LOW')`, fields[3].ID)
+	failIfErr("Ticket Get With Fields", t, e)
+
+	_, e = db.Exec(`INSERT INTO field_values 
+					(ticket_id, field_id, name, data_type, opt_value)
+					VALUES ($1, $2, $3, $4, $5)`,
+		tk.ID, fields[3].ID, fields[3].Name, fields[3].DataType, "HIGH")
+	failIfErr("Ticket Get With Fields", t, e)
+
+	got := &models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(got)
+	failIfErr("Ticket Get With Fields", t, e)
+
+	if len(got.Fields) != 4 {
+		t.Fatalf("Expected 4 fields Got %d\n", len(got.Fields))
+	}
+
+	for _, fv := range got.Fields {
+		switch fv.DataType {
+		case "INT":
+			if v, ok := fv.Value.(int); !ok || v != 5 {
+				t.Errorf("Expected int 5 Got %#v\n", fv.Value)
+			}
+		case "STRING":
+			if v, ok := fv.Value.(string); !ok || v != "a string" {
+				t.Errorf("Expected string \"a string\" Got %#v\n", fv.Value)
+			}
+		case "DATE":
+			if v, ok := fv.Value.(time.Time); !ok || !v.Equal(due) {
+				t.Errorf("Expected time %s Got %#v\n", due, fv.Value)
+			}
+		case "OPT":
+			v, ok := fv.Value.(models.FieldOption)
+			if !ok || v.Selected != "HIGH" || len(v.Options) != 2 {
+				t.Errorf("Expected option HIGH Got %#v\n", fv.Value)
+			}
+		default:
+			t.Errorf("Unexpected data type %s\n", fv.DataType)
+		}
+	}
+}
+
+func TestTicketMultiOptField(t *testing.T) {
+	field := models.Field{Name: "Components", DataType: "MULTI_OPT"}
+	e := s.Fields().New(&field)
+	if errors.Is(e, store.ErrDuplicateEntry) {
+		e = s.Fields().Get(&field)
+	}
+
+	failIfErr("Ticket Multi Opt Field", t, e)
+
+	db := s.(store.SQLStore).Conn()
+
+	_, e = db.Exec(`INSERT INTO field_options (field_id, option)
+					VALUES ($1, 'api'), ($1, 'store'), ($1, 'ui')`, field.ID)
+	failIfErr("Ticket Multi Opt Field", t, e)
+
+	p := models.Project{ID: 1, Key: "TEST"}
+	tk := &models.Ticket{
+		Summary:     "Ticket with components",
+		Description: "A ticket for multi option field tests",
+		Reporter:    models.User{ID: 1},
+		Assignee:    models.User{ID: 1},
+		Status:      models.Status{ID: 1},
+		Type:        models.TicketType{ID: 1},
+		Fields: []models.FieldValue{
+			{Name: field.Name, DataType: "MULTI_OPT", Value: []string{"api", "ui"}},
+		},
+	}
+
+	e = s.Tickets().New(p, tk)
+	failIfErr("Ticket Multi Opt Field", t, e)
+
+	got := &models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(got)
+	failIfErr("Ticket Multi Opt Field", t, e)
+
+	if len(got.Fields) != 1 {
+		t.Fatalf("Expected 1 field Got %d\n", len(got.Fields))
+	}
+
+	v, ok := got.Fields[0].Value.([]string)
+	if !ok || len(v) != 2 || v[0] != "api" || v[1] != "ui" {
+		t.Errorf("Expected [api ui] Got %#v\n", got.Fields[0].Value)
+	}
+
+	tk.Fields[0].Value = []string{"api", "docs"}
+	e = s.Tickets().New(p, tk)
+	if _, ok := e.(*models.FieldValueError); !ok {
+		t.Errorf("Expected a FieldValueError Got %v\n", e)
+	}
+}
+
+func TestTicketWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+
+	_, e := s.WithContext(ctx).Tickets().GetAll()
+	if e != context.Canceled {
+		t.Errorf("Expected context.Canceled Got %v\n", e)
+	}
+
+	tk := models.Ticket{ID: 1}
+	e = s.WithContext(ctx).Tickets().Get(&tk)
+	if e != context.Canceled {
+		t.Errorf("Expected context.Canceled Got %v\n", e)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("Expected the cancelled calls to return promptly took %s\n",
+			time.Since(start))
+	}
+}
+
+func TestTicketGetFieldError(t *testing.T) {
+	p := models.Project{ID: 1, Key: "TEST"}
+	tk := &models.Ticket{
+		Summary:     "Ticket with a broken field",
+		Description: "A ticket whose fields can't be loaded",
+		Reporter:    models.User{ID: 1},
+		Assignee:    models.User{ID: 1},
+		Status:      models.Status{ID: 1},
+		Type:        models.TicketType{ID: 1},
+	}
+
+	e := s.Tickets().New(p, tk)
+	failIfErr("Ticket Get Field Error", t, e)
+
+	field := models.Field{Name: "Broken Components", DataType: "MULTI_OPT"}
+	e = s.Fields().New(&field)
+	if errors.Is(e, store.ErrDuplicateEntry) {
+		e = s.Fields().Get(&field)
+	}
+
+	failIfErr("Ticket Get Field Error", t, e)
+
+	// An object can't be decoded as the list of selected options.
+	db := s.(store.SQLStore).Conn()
+	_, e = db.Exec(`INSERT INTO field_values 
+					(ticket_id, field_id, name, data_type, mlt_value)
+					VALUES ($1, $2, $3, $4, '{"selected": "api"}')`,
+		tk.ID, field.ID, field.Name, field.DataType)
+	failIfErr("Ticket Get Field Error", t, e)
+
+	e = s.Tickets().Get(&models.Ticket{ID: tk.ID})
+	if e == nil {
+		t.Error("Expected the field error to be returned Got nil")
+	}
+
+	_, e = s.Tickets().GetAllByProject(p, "")
+	if e == nil {
+		t.Error("Expected the field error to be returned Got nil")
+	}
+
+	e = s.Tickets().Remove(*tk)
+	failIfErr("Ticket Get Field Error", t, e)
+}
@@ -1,15 +1,13 @@
 package pg
 
 import (
-	"database/sql"
-	"errors"
-
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
 )
 
 // TypeStore is used to store ticket types in a postgres database
 type TypeStore struct {
-	db *sql.DB
+	db *ctxDB
 }
 
 // Get will get a ticket type by either name or id whichver is provided in tt
@@ -26,11 +24,14 @@ func (ts *TypeStore) GetAll() ([]models.TicketType, error) {
 	var typs []models.TicketType
 
 	rows, err := ts.db.Query(`SELECT tt.id, tt.name 
-							  FROM ticket_types AS tt`)
+							  FROM ticket_types AS tt
+							  ORDER BY tt.id`)
 	if err != nil {
 		return typs, handlePqErr(err)
 	}
 
+	defer rows.Close()
+
 	for rows.Next() {
 		var tt models.TicketType
 
@@ -42,7 +43,7 @@ func (ts *TypeStore) GetAll() ([]models.TicketType, error) {
 		typs = append(typs, tt)
 	}
 
-	return typs, nil
+	return typs, handlePqErr(rows.Err())
 }
 
 // New will add a new TicketType to the postgres DB
@@ -53,33 +54,45 @@ func (ts *TypeStore) New(tt *models.TicketType) error {
 	return handlePqErr(row.Scan(&tt.ID))
 }
 
-// Save will add a new TicketType to the postgres DB
+// Save will update a TicketType in the postgres DB, store.ErrNotFound is
+// returned if there is no type with it's ID.
 func (ts *TypeStore) Save(tt models.TicketType) error {
-	_, err := ts.db.Exec(`UPDATE ticket_types 
-						  SET (name) = ($1)
-						  WHERE id = $2`, tt.Name, tt.ID)
+	err := ts.db.QueryRow(`UPDATE ticket_types 
+						   SET (name) = ROW($1)
+						   WHERE id = $2
+						   RETURNING id`, tt.Name, tt.ID).Scan(&tt.ID)
 	return handlePqErr(err)
 }
 
-// Remove remoevs a ticket type from the database.
-func (ts *TypeStore) Remove(tt models.TicketType) error {
-	var c int
+// typeReferences count the rows using the ticket type with the id $1, a type
+// can't be removed while any of them are above 0.
+var typeReferences = []string{
+	`SELECT COUNT(id) FROM tickets WHERE ticket_type_id = $1`,
+	`SELECT COUNT(id) FROM workflows WHERE ticket_type_id = $1`,
+	`SELECT COUNT(*) FROM field_tickettype_project WHERE ticket_type_id = $1`,
+}
 
+// Remove removes a ticket type from the database, store.ErrInUse is returned
+// if any tickets, workflows or project fields use it.
+func (ts *TypeStore) Remove(tt models.TicketType) error {
 	tx, err := ts.db.Begin()
 	if err != nil {
 		return handlePqErr(err)
 	}
 
-	err = tx.QueryRow(`SELECT COUNT(id) FROM tickets
-					   WHERE ticket_type_id = $1`, tt.ID).Scan(&c)
-	if err != nil {
-		tx.Rollback()
-		return handlePqErr(err)
-	}
+	for _, q := range typeReferences {
+		var c int
 
-	if c > 0 {
-		tx.Rollback()
-		return errors.New("that type is currently in use, refusing to delete")
+		err = tx.QueryRow(q, tt.ID).Scan(&c)
+		if err != nil {
+			tx.Rollback()
+			return handlePqErr(err)
+		}
+
+		if c > 0 {
+			tx.Rollback()
+			return store.ErrInUse
+		}
 	}
 
 	_, err = tx.Exec("DELETE FROM ticket_types WHERE id = $1", tt.ID)
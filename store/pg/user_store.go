@@ -2,44 +2,137 @@ package pg
 
 import (
 	"database/sql"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
 )
 
 // UserStore contains methods for storing and retrieving Users from a Postgres
 // DB
 type UserStore struct {
-	db *sql.DB
+	db *ctxDB
 }
 
+// userColumns are the columns of users scanned by intoUser
+const userColumns = `id, username, password, email, full_name, gravatar,
+					 profile_picture, is_admin, is_active, email_verified,
+					 display_name, avatar_url, bio`
+
 func intoUser(row rowScanner, u *models.User) error {
 	return row.Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.FullName,
-		&u.Gravatar, &u.ProfilePic, &u.IsAdmin)
+		&u.Gravatar, &u.ProfilePic, &u.IsAdmin, &u.IsActive, &u.EmailVerified,
+		&u.DisplayName, &u.AvatarURL, &u.Bio)
 }
 
-// Get retrieves the user by row id
+// Get retrieves the user by row id or username, users which have been
+// removed are not returned.
 func (s *UserStore) Get(u *models.User) error {
 	var row *sql.Row
 
-	row = s.db.QueryRow(`SELECT id, username, password, email, full_name, 
-								gravatar, profile_picture, is_admin 
+	row = s.db.QueryRow(`SELECT `+userColumns+`
 						 FROM users
-						 WHERE id = $1
-						 OR username = $2`, u.ID, u.Username)
+						 WHERE (id = $1 OR username = $2)
+						 AND is_active`, u.ID, u.Username)
 
 	return handlePqErr(intoUser(row, u))
 }
 
-// GetAll retrieves all users from the database.
+// GetByEmail retrieves the active user with the email, emails are compared
+// ignoring case.
+func (s *UserStore) GetByEmail(email string) (models.User, error) {
+	var u models.User
+
+	row := s.db.QueryRow(`SELECT `+userColumns+`
+						  FROM users
+						  WHERE lower(email) = lower($1) AND is_active`, email)
+
+	return u, handlePqErr(intoUser(row, &u))
+}
+
+// GetAll retrieves all active users from the database.
 func (s *UserStore) GetAll() ([]models.User, error) {
-	users := []models.User{}
-	rows, err := s.db.Query(`SELECT id, username, password, email, full_name, 
-								    gravatar, profile_picture, is_admin 
-							 FROM users`)
+	return s.getAll(false)
+}
+
+// GetAllIncludingInactive retrieves all users from the database including
+// those which have been removed.
+func (s *UserStore) GetAllIncludingInactive() ([]models.User, error) {
+	return s.getAll(true)
+}
+
+func (s *UserStore) getAll(inactive bool) ([]models.User, error) {
+	rows, err := s.db.Query(`SELECT `+userColumns+`
+							 FROM users
+							 WHERE is_active OR $1
+							 ORDER BY id`, inactive)
+	if err != nil {
+		return []models.User{}, handlePqErr(err)
+	}
+
+	return usersFromRows(rows)
+}
+
+// userOrderColumns maps the PageOptions.OrderBy values users can be ordered by
+// to their column
+var userOrderColumns = map[string]string{
+	"":         "id",
+	"id":       "id",
+	"username": "username",
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetAllFiltered returns a page of the active users whose username or display
+// name contains q, ignoring case, along with the total number of matches.
+func (s *UserStore) GetAllFiltered(q string, opts store.PageOptions) ([]models.User, int, error) {
+	var total int
+
+	orderBy, ok := userOrderColumns[opts.OrderBy]
+	if !ok {
+		return nil, 0, store.ErrInvalidOrderBy
+	}
+
+	where := ` FROM users
+			   WHERE is_active
+			   AND (username ILIKE $1 OR display_name ILIKE $1)`
+	args := []interface{}{"%" + likeEscaper.Replace(q) + "%"}
+
+	err := s.db.QueryRow("SELECT COUNT(id)"+where, args...).Scan(&total)
 	if err != nil {
-		return users, handlePqErr(err)
+		return nil, 0, handlePqErr(err)
 	}
 
+	query := "SELECT " + userColumns + where + " ORDER BY " + orderBy + " " +
+		opts.Direction()
+
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	if opts.Offset > 0 {
+		args = append(args, opts.Offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, total, handlePqErr(err)
+	}
+
+	users, err := usersFromRows(rows)
+	return users, total, err
+}
+
+func usersFromRows(rows *sql.Rows) ([]models.User, error) {
+	users := []models.User{}
+	defer rows.Close()
+
 	for rows.Next() {
 		var u models.User
 
@@ -54,30 +147,58 @@ func (s *UserStore) GetAll() ([]models.User, error) {
 	return users, nil
 }
 
-// Remove will update the given user into the database.
+// Remove will deactivate the given user, the row is kept so the tickets and
+// comments for the user are not lost. Their refresh tokens are revoked so
+// they can't get new access tokens.
 func (s *UserStore) Remove(u models.User) error {
-	_, err := s.db.Exec(`UPDATE users 
-						 SET (is_active) = (false)
-						 WHERE id = $1;`, u.ID)
-	return handlePqErr(err)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return handlePqErr(err)
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_tokens SET used = true
+					  WHERE user_id IN (SELECT id FROM users
+										WHERE id = $1 OR username = $2)`,
+		u.ID, u.Username)
+	if err != nil {
+		tx.Rollback()
+		return handlePqErr(err)
+	}
+
+	_, err = tx.Exec(`UPDATE users
+					  SET is_active = false
+					  WHERE id = $1
+					  OR username = $2`, u.ID, u.Username)
+	if err != nil {
+		tx.Rollback()
+		return handlePqErr(err)
+	}
+
+	return handlePqErr(tx.Commit())
 }
 
 // Save will update the given user into the database.
 func (s *UserStore) Save(u models.User) error {
 	if u.Password == "" {
 		_, err := s.db.Exec(`UPDATE users SET 
-							 (username, email, full_name, is_admin) 
-							 = ($1, $2, $3, $4) WHERE id = $5;`,
-			u.Username, u.Email, u.FullName, u.IsAdmin, u.ID)
+							 (username, email, full_name, is_admin,
+							  display_name, avatar_url, bio, email_verified) 
+							 = ($1, $2, $3, $4, $5, $6, $7,
+								email_verified AND email = $2) WHERE id = $8;`,
+			u.Username, u.Email, u.FullName, u.IsAdmin,
+			u.DisplayName, u.AvatarURL, u.Bio, u.ID)
 
 		return handlePqErr(err)
 	}
 
 	_, err := s.db.Exec(`UPDATE users SET 
-						 (username, password, email, full_name, is_admin) 
-						 = ($1, $2, $3, $4, $5) 
-						 WHERE id = $6;`,
-		u.Username, u.Password, u.Email, u.FullName, u.IsAdmin, u.ID)
+						 (username, password, email, full_name, is_admin,
+						  display_name, avatar_url, bio, email_verified) 
+						 = ($1, $2, $3, $4, $5, $6, $7, $8,
+							email_verified AND email = $3) 
+						 WHERE id = $9;`,
+		u.Username, u.Password, u.Email, u.FullName, u.IsAdmin,
+		u.DisplayName, u.AvatarURL, u.Bio, u.ID)
 
 	return handlePqErr(err)
 }
@@ -85,12 +206,318 @@ func (s *UserStore) Save(u models.User) error {
 // New will create the user in the database
 func (s *UserStore) New(u *models.User) error {
 	err := s.db.QueryRow(`INSERT INTO users
-		(username, password, email, full_name, profile_picture, gravatar, is_admin) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		(username, password, email, full_name, profile_picture, gravatar, is_admin,
+		 email_verified, display_name, avatar_url, bio) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id;`,
-		u.Username, u.Password, u.Email, u.ProfilePic,
-		u.Gravatar, u.FullName, u.IsAdmin).
+		u.Username, u.Password, u.Email, u.FullName, u.ProfilePic,
+		u.Gravatar, u.IsAdmin, u.EmailVerified, u.DisplayName, u.AvatarURL,
+		u.Bio).
 		Scan(&u.ID)
 
 	return handlePqErr(err)
 }
+
+// CreatePasswordReset will store a hash of the given reset token for the user
+// which can be consumed once before expires.
+func (s *UserStore) CreatePasswordReset(u models.User, token string, expires time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO password_resets
+						 (user_id, token_hash, expires_date)
+						 VALUES ($1, $2, $3)`,
+		u.ID, store.HashToken(token), expires)
+
+	return handlePqErr(err)
+}
+
+// ConsumePasswordReset will mark the reset token for the user as used,
+// returning store.ErrInvalidToken if it does not exist or was already used
+// and store.ErrTokenExpired if it has expired.
+func (s *UserStore) ConsumePasswordReset(u models.User, token string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return handlePqErr(err)
+	}
+
+	var id int64
+	var used, expired bool
+
+	err = tx.QueryRow(`SELECT id, used, expires_date < current_timestamp
+					   FROM password_resets
+					   WHERE user_id = $1 AND token_hash = $2
+					   FOR UPDATE`, u.ID, store.HashToken(token)).
+		Scan(&id, &used, &expired)
+	if err == sql.ErrNoRows || used {
+		tx.Rollback()
+		return store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return handlePqErr(err)
+	}
+
+	if expired {
+		tx.Rollback()
+		return store.ErrTokenExpired
+	}
+
+	_, err = tx.Exec(`UPDATE password_resets SET used = true WHERE id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return handlePqErr(err)
+	}
+
+	return handlePqErr(tx.Commit())
+}
+
+// CreateEmailVerification will store a hash of the given verification token
+// for the user which can be used once before expires.
+func (s *UserStore) CreateEmailVerification(u models.User, token string, expires time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO email_verifications
+						 (user_id, token_hash, expires_date)
+						 VALUES ($1, $2, $3)`,
+		u.ID, store.HashToken(token), expires)
+
+	return handlePqErr(err)
+}
+
+// VerifyEmail will mark the email of the user the token was created for as
+// verified and return them, returning store.ErrInvalidToken if the token
+// does not exist or was already used and store.ErrTokenExpired if it has
+// expired.
+func (s *UserStore) VerifyEmail(token string) (models.User, error) {
+	var u models.User
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return u, handlePqErr(err)
+	}
+
+	var id int64
+	var used, expired bool
+
+	err = tx.QueryRow(`SELECT id, user_id, used, expires_date < current_timestamp
+					   FROM email_verifications
+					   WHERE token_hash = $1
+					   FOR UPDATE`, store.HashToken(token)).
+		Scan(&id, &u.ID, &used, &expired)
+	if err == sql.ErrNoRows || used {
+		tx.Rollback()
+		return u, store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return u, handlePqErr(err)
+	}
+
+	if expired {
+		tx.Rollback()
+		return u, store.ErrTokenExpired
+	}
+
+	_, err = tx.Exec(`UPDATE email_verifications SET used = true WHERE id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return u, handlePqErr(err)
+	}
+
+	row := tx.QueryRow(`UPDATE users SET email_verified = true WHERE id = $1
+						RETURNING `+userColumns, u.ID)
+
+	err = intoUser(row, &u)
+	if err != nil {
+		tx.Rollback()
+		return u, handlePqErr(err)
+	}
+
+	return u, handlePqErr(tx.Commit())
+}
+
+// CreateRefreshToken will store a hash of the refresh token for the user as
+// the first of a new family of tokens.
+func (s *UserStore) CreateRefreshToken(u models.User, token string, expires time.Time) error {
+	h := store.HashToken(token)
+
+	_, err := s.db.Exec(`INSERT INTO refresh_tokens
+						 (user_id, token_hash, family, expires_date)
+						 VALUES ($1, $2, $3, $4)`,
+		u.ID, h, h, expires)
+
+	return handlePqErr(err)
+}
+
+// RotateRefreshToken will mark the token as used and store next in the same
+// family, returning the user the token belongs to. It returns
+// store.ErrInvalidToken if the token does not exist, store.ErrTokenExpired if
+// it has expired and store.ErrTokenReused, after revoking the family, if it
+// was already used.
+func (s *UserStore) RotateRefreshToken(token, next string, expires time.Time) (models.User, error) {
+	var u models.User
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return u, handlePqErr(err)
+	}
+
+	var id int64
+	var family string
+	var used, expired bool
+
+	err = tx.QueryRow(`SELECT id, user_id, family, used,
+					   expires_date < current_timestamp
+					   FROM refresh_tokens
+					   WHERE token_hash = $1
+					   FOR UPDATE`, store.HashToken(token)).
+		Scan(&id, &u.ID, &family, &used, &expired)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return u, store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return u, handlePqErr(err)
+	}
+
+	if used {
+		// The revocation is committed so the thief can't use the token
+		// which replaced this one either.
+		_, err = tx.Exec(`UPDATE refresh_tokens SET used = true
+						  WHERE family = $1`, family)
+		if err != nil {
+			tx.Rollback()
+			return u, handlePqErr(err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return u, handlePqErr(err)
+		}
+
+		return u, store.ErrTokenReused
+	}
+
+	if expired {
+		tx.Rollback()
+		return u, store.ErrTokenExpired
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_tokens SET used = true WHERE id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return u, handlePqErr(err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO refresh_tokens
+					  (user_id, token_hash, family, expires_date)
+					  VALUES ($1, $2, $3, $4)`,
+		u.ID, store.HashToken(next), family, expires)
+	if err != nil {
+		tx.Rollback()
+		return u, handlePqErr(err)
+	}
+
+	// Removed users can't get new tokens.
+	row := tx.QueryRow(`SELECT `+userColumns+` FROM users
+						WHERE id = $1 AND is_active`, u.ID)
+
+	err = intoUser(row, &u)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.User{}, store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return u, handlePqErr(err)
+	}
+
+	return u, handlePqErr(tx.Commit())
+}
+
+// RevokeRefreshToken will mark every token in the family of token as used,
+// returning store.ErrInvalidToken if the token does not exist or belongs to
+// another user.
+func (s *UserStore) RevokeRefreshToken(u models.User, token string) error {
+	res, err := s.db.Exec(`UPDATE refresh_tokens SET used = true
+						   WHERE family = (SELECT family FROM refresh_tokens
+										   WHERE token_hash = $1
+										   AND user_id = $2)`,
+		store.HashToken(token), u.ID)
+	if err != nil {
+		return handlePqErr(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return handlePqErr(err)
+	}
+
+	if n == 0 {
+		return store.ErrInvalidToken
+	}
+
+	return nil
+}
+
+// LoginLockedUntil will return when the last lockout of the user ends, the
+// zero time if they have never been locked out.
+func (s *UserStore) LoginLockedUntil(u models.User) (time.Time, error) {
+	var until pq.NullTime
+
+	err := s.db.QueryRow(`SELECT locked_until FROM login_attempts
+						  WHERE user_id = $1`, u.ID).
+		Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, handlePqErr(err)
+	}
+
+	return until.Time, nil
+}
+
+// RecordFailedLogin will count a failed login for the user, locking them out
+// until lockUntil and starting the count again on the attempts'th failure.
+func (s *UserStore) RecordFailedLogin(u models.User, attempts int, lockUntil time.Time) (time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return time.Time{}, handlePqErr(err)
+	}
+
+	var failures int
+
+	err = tx.QueryRow(`INSERT INTO login_attempts (user_id, failures)
+					   VALUES ($1, 1)
+					   ON CONFLICT (user_id) DO UPDATE
+					   SET failures = login_attempts.failures + 1
+					   RETURNING failures`, u.ID).
+		Scan(&failures)
+	if err != nil {
+		tx.Rollback()
+		return time.Time{}, handlePqErr(err)
+	}
+
+	if failures < attempts {
+		return time.Time{}, handlePqErr(tx.Commit())
+	}
+
+	_, err = tx.Exec(`UPDATE login_attempts SET failures = 0, locked_until = $2
+					  WHERE user_id = $1`, u.ID, lockUntil)
+	if err != nil {
+		tx.Rollback()
+		return time.Time{}, handlePqErr(err)
+	}
+
+	return lockUntil, handlePqErr(tx.Commit())
+}
+
+// ResetFailedLogins will clear the failed logins counted for the user
+func (s *UserStore) ResetFailedLogins(u models.User) error {
+	_, err := s.db.Exec(`UPDATE login_attempts SET failures = 0
+						 WHERE user_id = $1`, u.ID)
+	return handlePqErr(err)
+}
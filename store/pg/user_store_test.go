@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
 )
 
 func TestUserGet(t *testing.T) {
@@ -48,4 +49,24 @@ func TestUserRemove(t *testing.T) {
 	u := models.User{ID: 3}
 	e := s.Users().Remove(u)
 	failIfErr("User Remove", t, e)
+
+	users, e := s.Users().GetAll()
+	failIfErr("User Remove", t, e)
+
+	for _, usr := range users {
+		if usr.ID == u.ID {
+			t.Errorf("Expected user %d to not be returned by GetAll\n", u.ID)
+		}
+	}
+
+	var active bool
+
+	e = s.(store.SQLStore).Conn().
+		QueryRow(`SELECT is_active FROM users WHERE id = $1`, u.ID).
+		Scan(&active)
+	failIfErr("User Remove", t, e)
+
+	if active {
+		t.Errorf("Expected user %d to be inactive\n", u.ID)
+	}
 }
@@ -10,17 +10,17 @@ import (
 // WorkflowStore contains methods for saving/retrieving workflows from a
 // postgres DB
 type WorkflowStore struct {
-	db *sql.DB
+	db *ctxDB
 }
 
 // Get gets a workflow from the database
 func (ws *WorkflowStore) Get(w *models.Workflow) error {
-	row := ws.db.QueryRow(`SELECT w.id, w.name 
+	row := ws.db.QueryRow(`SELECT w.id, w.name, COALESCE(w.ticket_type_id, 0)
 						   FROM workflows AS w
 						   JOIN projects AS p ON w.project_id = p.id
 						   WHERE w.id = $1 OR w.name = $2`, w.ID, w.Name)
 
-	err := row.Scan(&w.ID, &w.Name)
+	err := row.Scan(&w.ID, &w.Name, &w.TicketType.ID)
 	if err != nil {
 		return handlePqErr(err)
 	}
@@ -32,7 +32,7 @@ func (ws *WorkflowStore) Get(w *models.Workflow) error {
 func (ws *WorkflowStore) getHooks(t *models.Transition) error {
 	rows, err := ws.db.Query(`SELECT h.id, endpoint, method, body
 						   FROM hooks AS h
-						   JOIN transitions AS t ON t.id = h.transition_id`)
+						   WHERE h.transition_id = $1`, t.ID)
 	if err != nil {
 		return err
 	}
@@ -57,7 +57,8 @@ func (ws *WorkflowStore) getTransitions(w *models.Workflow) error {
 									 row_to_json(to_s.*)
 							  FROM transitions AS t
 							  JOIN statuses AS from_s ON from_s.id = t.from_status
-							  JOIN statuses AS to_s ON to_s.id = t.to_status`)
+							  JOIN statuses AS to_s ON to_s.id = t.to_status
+							  WHERE t.workflow_id = $1`, w.ID)
 
 	if err != nil {
 		return handlePqErr(err)
@@ -96,20 +97,32 @@ func (ws *WorkflowStore) getTransitions(w *models.Workflow) error {
 func workflowsFromRows(rows *sql.Rows, ws *WorkflowStore) ([]models.Workflow, error) {
 	var workflows []models.Workflow
 
+	defer rows.Close()
+
 	for rows.Next() {
 		w := models.Workflow{}
 
-		err := rows.Scan(&w.ID, &w.Name)
+		err := rows.Scan(&w.ID, &w.Name, &w.TicketType.ID)
 		if err != nil {
 			return workflows, handlePqErr(err)
 		}
 
-		err = ws.getTransitions(&w)
+		workflows = append(workflows, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return workflows, handlePqErr(err)
+	}
+
+	// In a transaction every query shares one connection, so the rows have
+	// to be closed before the transitions are queried.
+	rows.Close()
+
+	for i := range workflows {
+		err := ws.getTransitions(&workflows[i])
 		if err != nil {
 			return workflows, handlePqErr(err)
 		}
-
-		workflows = append(workflows, w)
 	}
 
 	return workflows, nil
@@ -117,7 +130,8 @@ func workflowsFromRows(rows *sql.Rows, ws *WorkflowStore) ([]models.Workflow, er
 
 // GetAll gets all the workflows from the database
 func (ws *WorkflowStore) GetAll() ([]models.Workflow, error) {
-	rows, err := ws.db.Query("SELECT id, name FROM workflows;")
+	rows, err := ws.db.Query(`SELECT id, name, COALESCE(ticket_type_id, 0) 
+							  FROM workflows;`)
 	if err != nil {
 		return nil, handlePqErr(err)
 	}
@@ -127,7 +141,7 @@ func (ws *WorkflowStore) GetAll() ([]models.Workflow, error) {
 
 // GetByProject gets all the workflows for the given project
 func (ws *WorkflowStore) GetByProject(p models.Project) ([]models.Workflow, error) {
-	rows, err := ws.db.Query(`SELECT w.id, w.name 
+	rows, err := ws.db.Query(`SELECT w.id, w.name, COALESCE(w.ticket_type_id, 0)
 							  FROM workflows AS w
 							  JOIN projects AS p ON p.id = w.project_id
 							  WHERE p.id = $1
@@ -147,9 +161,10 @@ func (ws *WorkflowStore) New(p models.Project, workflow *models.Workflow) error
 	}
 
 	err = tx.QueryRow(`INSERT INTO workflows 
-					   (name, project_id) VALUES ($1, $2)
+					   (name, project_id, ticket_type_id) 
+					   VALUES ($1, $2, NULLIF($3, 0))
 					   RETURNING id;`,
-		workflow.Name, p.ID).
+		workflow.Name, p.ID, workflow.TicketType.ID).
 		Scan(&workflow.ID)
 	if err != nil {
 		tx.Rollback()
@@ -170,7 +185,7 @@ func (ws *WorkflowStore) New(p models.Project, workflow *models.Workflow) error
 			err = tx.QueryRow(`INSERT INTO transitions
 							  (name, workflow_id, from_status, to_status)
 							  VALUES ($1, $2, $3, $4)
-							  RETURNING id`, t.Name, workflow.ID, t.ToStatus.ID, fromID).
+							  RETURNING id`, t.Name, workflow.ID, fromID, t.ToStatus.ID).
 				Scan(&t.ID)
 			if err != nil {
 				tx.Rollback()
@@ -206,7 +221,9 @@ func (ws *WorkflowStore) Save(w models.Workflow) error {
 		return handlePqErr(err)
 	}
 
-	_, err = tx.Exec(`UPDATE workflows SET (name) = ($1) WHERE id = $2`, w.Name, w.ID)
+	_, err = tx.Exec(`UPDATE workflows SET (name, ticket_type_id) 
+					  = ($1, NULLIF($2, 0)) WHERE id = $3`,
+		w.Name, w.TicketType.ID, w.ID)
 	if err != nil {
 		tx.Rollback()
 		return handlePqErr(err)
@@ -226,7 +243,7 @@ func (ws *WorkflowStore) Save(w models.Workflow) error {
 			_, err = tx.Exec(`UPDATE transitions SET
 							  (name, workflow_id, from_status, to_status)
 							  = ($1, $2, $3, $4)
-							  WHERE id = $5`, t.Name, w.ID, t.ToStatus.ID, fromID, t.ID)
+							  WHERE id = $5`, t.Name, w.ID, fromID, t.ToStatus.ID, t.ID)
 			if err != nil {
 				tx.Rollback()
 				return handlePqErr(err)
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -50,7 +51,7 @@ func SeedLabels(s Store) error {
 
 	for _, l := range labels {
 		e := s.Labels().New(&l)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 	}
@@ -63,7 +64,6 @@ func SeedTickets(s Store) error {
 	fmt.Println("Seeding tickets")
 	for i := 0; i < 50; i++ {
 		t := &models.Ticket{
-			Key:         s.Tickets().NextTicketKey(models.Project{ID: 1}),
 			Summary:     "This is a test ticket. #" + strconv.Itoa(i),
 			Description: "No really, this is just a test",
 			Reporter:    models.User{ID: 1},
@@ -74,11 +74,13 @@ func SeedTickets(s Store) error {
 			},
 			Fields: []models.FieldValue{
 				models.FieldValue{
-					Name:  "Story Points",
-					Value: rand.Int(),
+					Name:     "Story Points",
+					DataType: "INT",
+					Value:    rand.Intn(20),
 				},
 				models.FieldValue{
-					Name: "Priority",
+					Name:     "Priority",
+					DataType: "OPT",
 					Value: models.FieldOption{
 						Selected: []string{"HIGH", "MEDIUM", "LOW"}[rand.Intn(3)],
 						Options:  []string{"HIGH", "MEDIUM", "LOW"},
@@ -89,7 +91,7 @@ func SeedTickets(s Store) error {
 		}
 
 		e := s.Tickets().New(models.Project{ID: 1}, t)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 	}
@@ -107,7 +109,8 @@ func SeedStatuses(s Store) error {
 			Name: "In Progress",
 		},
 		models.Status{
-			Name: "Done",
+			Name:   "Done",
+			Closed: true,
 		},
 		models.Status{
 			Name: "For Saving",
@@ -120,7 +123,7 @@ func SeedStatuses(s Store) error {
 	fmt.Println("Seeding statuses")
 	for _, st := range statuses {
 		e := s.Statuses().New(&st)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 	}
@@ -148,11 +151,11 @@ func SeedComments(s Store) error {
 			}
 
 			e := s.Tickets().NewComment(tk, c)
-			if e != nil && e != ErrDuplicateEntry {
+			if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 				return e
 			}
 
-			if e == ErrDuplicateEntry {
+			if errors.Is(e, ErrDuplicateEntry) {
 				return nil
 			}
 		}
@@ -194,20 +197,20 @@ func SeedFields(s Store) error {
 	fmt.Println("Seeding fields")
 	for _, f := range fields {
 		e := s.Fields().New(&f)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 
-		if e == ErrDuplicateEntry {
+		if errors.Is(e, ErrDuplicateEntry) {
 			return nil
 		}
 
 		e = s.Fields().AddToProject(models.Project{ID: 1}, &f)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 
-		if e == ErrDuplicateEntry {
+		if errors.Is(e, ErrDuplicateEntry) {
 			return nil
 		}
 	}
@@ -233,11 +236,11 @@ func SeedProjects(s Store) error {
 	fmt.Println("Seeding projects")
 	for _, p := range projects {
 		e := s.Projects().New(&p)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 
-		if e == ErrDuplicateEntry {
+		if errors.Is(e, ErrDuplicateEntry) {
 			return nil
 		}
 	}
@@ -275,11 +278,11 @@ func SeedTeams(s Store) error {
 		team.Lead = models.User{ID: 1}
 
 		e := s.Teams().New(&team)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 
-		if e == ErrDuplicateEntry {
+		if errors.Is(e, ErrDuplicateEntry) {
 			return nil
 		}
 	}
@@ -310,11 +313,11 @@ func SeedTicketTypes(s Store) error {
 	fmt.Println("Seeding ticket types")
 	for _, t := range types {
 		e := s.Types().New(&t)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 
-		if e == ErrDuplicateEntry {
+		if errors.Is(e, ErrDuplicateEntry) {
 			return nil
 		}
 	}
@@ -343,12 +346,14 @@ func SeedUsers(s Store) error {
 
 	fmt.Println("Seeding users")
 	for _, u := range users {
+		u.EmailVerified = true
+
 		e := s.Users().New(&u)
-		if e != nil && e != ErrDuplicateEntry {
+		if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 			return e
 		}
 
-		if e == ErrDuplicateEntry {
+		if errors.Is(e, ErrDuplicateEntry) {
 			return nil
 		}
 	}
@@ -395,22 +400,22 @@ func SeedWorkflows(s Store) error {
 
 	fmt.Println("Seeding workflows")
 	e := s.Workflows().New(p1, &wk1)
-	if e != nil && e != ErrDuplicateEntry {
+	if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 		return e
 	}
 
 	e = s.Workflows().New(p1, &wk1)
-	if e != nil && e != ErrDuplicateEntry {
+	if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 		return e
 	}
 
 	e = s.Workflows().New(p2, &wk1)
-	if e != nil && e != ErrDuplicateEntry {
+	if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 		return e
 	}
 
 	e = s.Workflows().New(p2, &wk1)
-	if e != nil && e != ErrDuplicateEntry {
+	if e != nil && !errors.Is(e, ErrDuplicateEntry) {
 		return e
 	}
 
@@ -0,0 +1,297 @@
+package sqlite
+
+import (
+	"errors"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// FieldStore contains methods for storing and retrieving Fields and
+// FieldValues in a SQLite Database
+type FieldStore struct {
+	db *ctxDB
+}
+
+// Get retrieves a models.Field by ID or name
+func (fs *FieldStore) Get(f *models.Field) error {
+	row := fs.db.QueryRow(`SELECT id, name, data_type FROM fields
+						   WHERE id = ?1 OR name = ?2`, f.ID, f.Name)
+	err := row.Scan(&f.ID, &f.Name, &f.DataType)
+
+	return handleSqliteErr(err)
+}
+
+// GetAll will return all fields from the DB
+func (fs *FieldStore) GetAll() ([]models.Field, error) {
+	var fields []models.Field
+
+	rows, err := fs.db.Query("SELECT id, name, data_type FROM fields ORDER BY id")
+	if err != nil {
+		return fields, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var f models.Field
+
+		err = rows.Scan(&f.ID, &f.Name, &f.DataType)
+		if err != nil {
+			return fields, handleSqliteErr(err)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, handleSqliteErr(rows.Err())
+}
+
+// GetByProject retrieves all Fields associated with a project
+func (fs *FieldStore) GetByProject(p models.Project) ([]models.Field, error) {
+	var fields []models.Field
+
+	rows, err := fs.db.Query(`
+		SELECT fields.id, fields.name, fields.data_type
+		FROM fields
+		JOIN field_tickettype_project AS ftp
+		ON fields.id = ftp.field_id
+		JOIN projects AS p
+		ON p.id = ftp.project_id
+		WHERE p.key = ?1`, p.Key)
+	if err != nil {
+		return fields, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var f models.Field
+
+		err = rows.Scan(&f.ID, &f.Name, &f.DataType)
+		if err != nil {
+			return fields, handleSqliteErr(err)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, handleSqliteErr(rows.Err())
+}
+
+// GetFieldsForType retrieves the Fields which apply to tickets of the given
+// type in a project, fields added to the project without a ticket type apply
+// to every type.
+func (fs *FieldStore) GetFieldsForType(p models.Project,
+	tt models.TicketType) ([]models.Field, error) {
+	return fieldsForType(fs.db, p, tt)
+}
+
+func fieldsForType(q sqlExecutor, p models.Project,
+	tt models.TicketType) ([]models.Field, error) {
+	fields := []models.Field{}
+
+	// MAX of the 0 or 1 stored for required stands in for postgres' bool_or.
+	rows, err := q.Query(`
+		SELECT f.id, f.name, f.data_type, MAX(ftp.required)
+		FROM fields AS f
+		JOIN field_tickettype_project AS ftp ON f.id = ftp.field_id
+		JOIN projects AS p ON p.id = ftp.project_id
+		WHERE (p.id = ?1 OR p.key = ?2)
+		AND (ftp.ticket_type_id = ?3 OR ftp.ticket_type_id IS NULL)
+		GROUP BY f.id
+		ORDER BY f.id`, p.ID, p.Key, tt.ID)
+	if err != nil {
+		return fields, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var f models.Field
+
+		err = rows.Scan(&f.ID, &f.Name, &f.DataType, &f.Required)
+		if err != nil {
+			return fields, handleSqliteErr(err)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, handleSqliteErr(rows.Err())
+}
+
+// AddToProject adds a field to a project's tickets
+func (fs *FieldStore) AddToProject(project models.Project, field *models.Field,
+	ticketTypes ...models.TicketType) error {
+
+	if ticketTypes == nil {
+		_, err := fs.db.Exec(`INSERT INTO field_tickettype_project
+							  (field_id, project_id, required) VALUES (?1, ?2, ?3)`,
+			field.ID, project.ID, field.Required)
+		return handleSqliteErr(err)
+	}
+
+	for _, typ := range ticketTypes {
+		_, err := fs.db.Exec(`INSERT INTO field_tickettype_project
+							  (field_id, project_id, ticket_type_id, required)
+							  VALUES (?1, ?2, ?3, ?4)`,
+			field.ID, project.ID, typ.ID, field.Required)
+		if err != nil {
+			return handleSqliteErr(err)
+		}
+	}
+
+	return nil
+}
+
+// Save updates an existing field in the database.
+func (fs *FieldStore) Save(field models.Field) error {
+	_, err := fs.db.Exec(`UPDATE fields SET
+						  (name, data_type) = (?1, ?2) WHERE id = ?3`,
+		field.Name, field.DataType, field.ID)
+
+	return handleSqliteErr(err)
+}
+
+// New creates a new Field in the database.
+func (fs *FieldStore) New(field *models.Field) error {
+	err := fs.db.QueryRow(`INSERT INTO fields
+						   (name, data_type) VALUES (?1, ?2)
+						   RETURNING id`,
+		field.Name, field.DataType).
+		Scan(&field.ID)
+
+	return handleSqliteErr(err)
+}
+
+// Remove removes a field which has no values from the database.
+func (fs *FieldStore) Remove(field models.Field) error {
+	var c int
+
+	tx, err := fs.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`SELECT COUNT(id) FROM field_values
+					   WHERE field_id = ?1`, field.ID).Scan(&c)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if c > 0 {
+		tx.Rollback()
+		return errors.New("that field is currently in use, refusing to delete")
+	}
+
+	_, err = tx.Exec("DELETE FROM field_options WHERE field_id = ?1", field.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec("DELETE FROM field_tickettype_project WHERE field_id = ?1",
+		field.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec("DELETE FROM fields WHERE id = ?1", field.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// GetFieldOptions returns the options of the field in the order they were
+// added, store.ErrNotFound is returned if the field doesn't exist.
+func (fs *FieldStore) GetFieldOptions(fieldID int64) ([]string, error) {
+	options := []string{}
+
+	err := fs.db.QueryRow("SELECT id FROM fields WHERE id = ?1", fieldID).
+		Scan(&fieldID)
+	if err != nil {
+		return options, handleSqliteErr(err)
+	}
+
+	rows, err := fs.db.Query(`SELECT option FROM field_options
+							  WHERE field_id = ?1
+							  ORDER BY id`, fieldID)
+	if err != nil {
+		return options, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var opt string
+
+		err = rows.Scan(&opt)
+		if err != nil {
+			return options, handleSqliteErr(err)
+		}
+
+		options = append(options, opt)
+	}
+
+	return options, handleSqliteErr(rows.Err())
+}
+
+// AddFieldOption adds an option to the field, store.ErrNotFound is returned
+// if the field doesn't exist and store.ErrDuplicateEntry if it already has
+// the option.
+func (fs *FieldStore) AddFieldOption(fieldID int64, option string) error {
+	if option == "" {
+		return store.ErrMissingField
+	}
+
+	err := fs.db.QueryRow(`INSERT INTO field_options (field_id, option)
+						   SELECT id, ?2 FROM fields WHERE id = ?1
+						   RETURNING id`, fieldID, option).Scan(&fieldID)
+	return handleSqliteErr(err)
+}
+
+// RemoveFieldOption removes an option from the field, store.ErrInUse is
+// returned if any ticket has it selected and store.ErrNotFound if the field
+// doesn't have the option.
+func (fs *FieldStore) RemoveFieldOption(fieldID int64, option string) error {
+	var c int
+
+	tx, err := fs.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`SELECT COUNT(id) FROM field_values
+					   WHERE field_id = ?1
+					   AND (opt_value = ?2 OR EXISTS (SELECT 1 FROM json_each(mlt_value) WHERE value = ?2))`,
+		fieldID, option).Scan(&c)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if c > 0 {
+		tx.Rollback()
+		return store.ErrInUse
+	}
+
+	res, err := tx.Exec(`DELETE FROM field_options
+						 WHERE field_id = ?1 AND option = ?2`, fieldID, option)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return store.ErrNotFound
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
@@ -0,0 +1,382 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// ProjectStore contains methods for storing and retrieving Projects from a
+// SQLite DB
+type ProjectStore struct {
+	db *ctxDB
+}
+
+// projectSelect is the select shared by every query which returns projects
+// through intoProject.
+var projectSelect = `SELECT p.id, p.created_date, p.name, p.key,
+							COALESCE(p.homepage, ''), COALESCE(p.icon_url, ''),
+							COALESCE(p.repo, ''), p.archived, ` + joinedUserColumns("lead") + `
+					 FROM projects AS p
+					 JOIN users AS lead ON lead.id = p.lead_id `
+
+func intoProject(row rowScanner, p *models.Project) error {
+	l := &p.Lead
+
+	return row.Scan(&p.ID, &p.CreatedDate, &p.Name, &p.Key,
+		&p.Homepage, &p.IconURL, &p.Repo, &p.Archived, &l.ID, &l.Username, &l.Password,
+		&l.Email, &l.FullName, &l.Gravatar, &l.ProfilePic, &l.IsAdmin,
+		&l.IsActive, &l.EmailVerified, &l.DisplayName, &l.AvatarURL, &l.Bio)
+}
+
+// Get gets a project by it's ID or key in a SQLite DB.
+func (ps *ProjectStore) Get(p *models.Project) error {
+	row := ps.db.QueryRow(projectSelect+`WHERE p.id = ?1 OR p.key = ?2`,
+		p.ID, p.Key)
+
+	err := intoProject(row, p)
+	return handleSqliteErr(err)
+}
+
+// GetAll returns all projects which aren't archived
+func (ps *ProjectStore) GetAll() ([]models.Project, error) {
+	return ps.getAll(`WHERE NOT p.archived `)
+}
+
+// GetAllIncludingArchived returns all projects including archived ones
+func (ps *ProjectStore) GetAllIncludingArchived() ([]models.Project, error) {
+	return ps.getAll(``)
+}
+
+func (ps *ProjectStore) getAll(where string) ([]models.Project, error) {
+	var projects []models.Project
+
+	rows, err := ps.db.Query(projectSelect + where + `ORDER BY p.id`)
+	if err != nil {
+		return projects, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Project
+
+		err = intoProject(rows, &p)
+		if err != nil {
+			return projects, handleSqliteErr(err)
+		}
+
+		projects = append(projects, p)
+	}
+
+	return projects, handleSqliteErr(rows.Err())
+}
+
+// GetProjectForTicket returns the project of the ticket with the given key
+func (ps *ProjectStore) GetProjectForTicket(key string) (models.Project, error) {
+	var p models.Project
+
+	row := ps.db.QueryRow(projectSelect+`JOIN tickets AS t ON t.project_id = p.id
+										 WHERE t.key = ?1`, key)
+
+	err := intoProject(row, &p)
+	return p, handleSqliteErr(err)
+}
+
+// New creates a new Project in the database.
+func (ps *ProjectStore) New(project *models.Project) error {
+	err := ps.db.QueryRow(`INSERT INTO projects
+						   (name, key, repo, homepage, icon_url, lead_id)
+						   VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+						   RETURNING id`,
+		project.Name, project.Key, project.Repo, project.Homepage,
+		project.IconURL, project.Lead.ID).
+		Scan(&project.ID)
+
+	return handleSqliteErr(err)
+}
+
+// Save updates a Project in the database.
+func (ps *ProjectStore) Save(project models.Project) error {
+	_, err := ps.db.Exec(`UPDATE projects SET
+						  (name, key, repo, homepage, icon_url, lead_id)
+						  = (?1, ?2, ?3, ?4, ?5, ?6)
+						  WHERE id = ?7`,
+		project.Name, project.Key, project.Repo, project.Homepage,
+		project.IconURL, project.Lead.ID, project.ID)
+
+	return handleSqliteErr(err)
+}
+
+// ArchiveProject archives the project so it's hidden from GetAll and no new
+// tickets can be created in it.
+func (ps *ProjectStore) ArchiveProject(project models.Project) error {
+	return ps.setArchived(project, true)
+}
+
+// UnarchiveProject reverses ArchiveProject.
+func (ps *ProjectStore) UnarchiveProject(project models.Project) error {
+	return ps.setArchived(project, false)
+}
+
+func (ps *ProjectStore) setArchived(project models.Project, archived bool) error {
+	res, err := ps.db.Exec(`UPDATE projects SET archived = ?3
+							WHERE id = ?1 OR key = ?2`,
+		project.ID, project.Key, archived)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	if n == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// projectRemovals are run in order by Remove to delete everything belonging
+// to the project with the id ?1 before the project itself.
+var projectRemovals = []string{
+	`DELETE FROM field_tickettype_project WHERE project_id = ?1`,
+	`DELETE FROM permissions WHERE project_id = ?1`,
+	`DELETE FROM project_members WHERE project_id = ?1`,
+	`DELETE FROM field_values
+	 WHERE ticket_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM tickets_labels
+	 WHERE ticket_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM attachments
+	 WHERE ticket_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM ticket_history
+	 WHERE ticket_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM worklogs
+	 WHERE ticket_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM comment_revisions
+	 WHERE comment_id IN (SELECT c.id FROM comments AS c
+						  JOIN tickets AS t ON t.id = c.ticket_id
+						  WHERE t.project_id = ?1)`,
+	`DELETE FROM comment_reactions
+	 WHERE comment_id IN (SELECT c.id FROM comments AS c
+						  JOIN tickets AS t ON t.id = c.ticket_id
+						  WHERE t.project_id = ?1)`,
+	`DELETE FROM comments
+	 WHERE ticket_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM ticket_watchers
+	 WHERE ticket_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM ticket_links
+	 WHERE source_id IN (SELECT id FROM tickets WHERE project_id = ?1)
+	 OR target_id IN (SELECT id FROM tickets WHERE project_id = ?1)`,
+	`DELETE FROM tickets WHERE project_id = ?1`,
+	`DELETE FROM hooks
+	 WHERE transition_id IN (SELECT tr.id FROM transitions AS tr
+							 JOIN workflows AS w ON w.id = tr.workflow_id
+							 WHERE w.project_id = ?1)`,
+	`DELETE FROM transitions
+	 WHERE workflow_id IN (SELECT id FROM workflows WHERE project_id = ?1)`,
+	`DELETE FROM workflows WHERE project_id = ?1`,
+	`DELETE FROM projects WHERE id = ?1`,
+}
+
+// Remove removes a Project from the database. If cascade is true all of the
+// project's tickets are removed with it, otherwise store.ErrProjectHasTickets
+// is returned when the project has any tickets.
+func (ps *ProjectStore) Remove(project models.Project, cascade bool) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`SELECT id FROM projects WHERE id = ?1 OR key = ?2`,
+		project.ID, project.Key).Scan(&project.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	var tickets int
+
+	err = tx.QueryRow(`SELECT COUNT(id) FROM tickets WHERE project_id = ?1`,
+		project.ID).Scan(&tickets)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if tickets > 0 && !cascade {
+		tx.Rollback()
+		return store.ErrProjectHasTickets
+	}
+
+	for _, q := range projectRemovals {
+		_, err = tx.Exec(q, project.ID)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// AddMember will add the user to the project with the given role, if the user
+// is already a member their role is changed instead.
+func (ps *ProjectStore) AddMember(p models.Project, u models.User,
+	role models.PermissionLevel) error {
+	_, err := ps.db.Exec(`INSERT INTO project_members (project_id, user_id, role)
+						  SELECT p.id, u.id, ?3
+						  FROM projects AS p, users AS u
+						  WHERE (p.id = ?1 OR p.key = ?2)
+						  AND (u.id = ?4 OR u.username = ?5)
+						  ON CONFLICT (project_id, user_id)
+						  DO UPDATE SET role = excluded.role`,
+		p.ID, p.Key, role, u.ID, u.Username)
+	return handleSqliteErr(err)
+}
+
+// RemoveMember will remove the user from the project
+func (ps *ProjectStore) RemoveMember(p models.Project, u models.User) error {
+	_, err := ps.db.Exec(`DELETE FROM project_members
+						  WHERE project_id = (SELECT id FROM projects
+											  WHERE id = ?1 OR key = ?2)
+						  AND user_id = (SELECT id FROM users
+										 WHERE id = ?3 OR username = ?4)`,
+		p.ID, p.Key, u.ID, u.Username)
+	return handleSqliteErr(err)
+}
+
+// GetMembers will return the members of the project along with their roles
+func (ps *ProjectStore) GetMembers(p models.Project) ([]models.ProjectMember, error) {
+	members := []models.ProjectMember{}
+
+	rows, err := ps.db.Query(`SELECT `+joinedUserColumns("u")+`, pm.role
+							  FROM project_members AS pm
+							  JOIN users AS u ON u.id = pm.user_id
+							  WHERE pm.project_id = (SELECT id FROM projects
+													 WHERE id = ?1 OR key = ?2)
+							  ORDER BY u.id`, p.ID, p.Key)
+	if err != nil {
+		return members, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.ProjectMember
+		u := &m.User
+
+		err = rows.Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.FullName,
+			&u.Gravatar, &u.ProfilePic, &u.IsAdmin, &u.IsActive, &u.EmailVerified,
+			&u.DisplayName, &u.AvatarURL, &u.Bio, &m.Role)
+		if err != nil {
+			return members, handleSqliteErr(err)
+		}
+
+		members = append(members, m)
+	}
+
+	return members, handleSqliteErr(rows.Err())
+}
+
+// GetRole returns the role of the user in the project, store.ErrNotFound is
+// returned if the user is not a member.
+func (ps *ProjectStore) GetRole(p models.Project, u models.User) (models.PermissionLevel, error) {
+	var role models.PermissionLevel
+
+	err := ps.db.QueryRow(`SELECT pm.role FROM project_members AS pm
+						   JOIN projects AS p ON p.id = pm.project_id
+						   JOIN users AS u ON u.id = pm.user_id
+						   WHERE (p.id = ?1 OR p.key = ?2)
+						   AND (u.id = ?3 OR u.username = ?4)`,
+		p.ID, p.Key, u.ID, u.Username).Scan(&role)
+	return role, handleSqliteErr(err)
+}
+
+// GetRoles returns the roles of the user by the key of each project they are
+// a member or the lead of.
+func (ps *ProjectStore) GetRoles(u models.User) (map[string]models.PermissionLevel, error) {
+	rows, err := ps.db.Query(`SELECT p.key, pm.role FROM project_members AS pm
+							  JOIN projects AS p ON p.id = pm.project_id
+							  JOIN users AS u ON u.id = pm.user_id
+							  WHERE u.id = ?1 OR u.username = ?2
+							  UNION ALL
+							  SELECT p.key, 'ADMIN' FROM projects AS p
+							  JOIN users AS u ON u.id = p.lead_id
+							  WHERE u.id = ?1 OR u.username = ?2`, u.ID, u.Username)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	roles := make(map[string]models.PermissionLevel)
+
+	for rows.Next() {
+		var key string
+		var role models.PermissionLevel
+
+		err = rows.Scan(&key, &role)
+		if err != nil {
+			return nil, handleSqliteErr(err)
+		}
+
+		// Leading a project makes the user an admin whatever their role.
+		if roles[key] != models.AdminR {
+			roles[key] = role
+		}
+	}
+
+	return roles, handleSqliteErr(rows.Err())
+}
+
+// SuggestAssignee returns the active member of the project with the fewest
+// open tickets assigned to them across every project, ties go to the member
+// with the lowest id.
+func (ps *ProjectStore) SuggestAssignee(p models.Project) (models.User, error) {
+	var u models.User
+
+	err := ps.db.QueryRow(`SELECT `+joinedUserColumns("u")+`
+						   FROM users AS u
+						   LEFT JOIN (SELECT t.assignee_id, COUNT(t.id) AS open
+									  FROM tickets AS t
+									  JOIN statuses AS s ON s.id = t.status_id
+									  JOIN projects AS p ON p.id = t.project_id
+									  WHERE NOT s.closed AND NOT p.archived
+									  GROUP BY t.assignee_id)
+									  AS workload ON workload.assignee_id = u.id
+						   WHERE u.is_active
+						   AND u.id IN (SELECT user_id FROM project_members
+										WHERE project_id = (SELECT id FROM projects
+															WHERE id = ?1 OR key = ?2))
+						   ORDER BY COALESCE(workload.open, 0), u.id
+						   LIMIT 1`, p.ID, p.Key).
+		Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.FullName,
+			&u.Gravatar, &u.ProfilePic, &u.IsAdmin, &u.IsActive, &u.EmailVerified,
+			&u.DisplayName, &u.AvatarURL, &u.Bio)
+	if err == sql.ErrNoRows {
+		err = ps.noMembers(p)
+	}
+
+	return u, handleSqliteErr(err)
+}
+
+// noMembers returns store.ErrNoMembers if the project exists, otherwise
+// sql.ErrNoRows
+func (ps *ProjectStore) noMembers(p models.Project) error {
+	var exists bool
+
+	err := ps.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM projects
+										  WHERE id = ?1 OR key = ?2)`,
+		p.ID, p.Key).Scan(&exists)
+	if err == nil && exists {
+		return store.ErrNoMembers
+	} else if err == nil {
+		return sql.ErrNoRows
+	}
+
+	return err
+}
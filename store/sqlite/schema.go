@@ -0,0 +1,435 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// schemaVersion is stored in PRAGMA user_version once the schema has been
+// created, it should be incremented along with a migration when the schema
+// changes. Every statement in schema is safe to run again so additions to it
+// are applied to older databases as well, changes which can't be written that
+// way go in alterations.
+const schemaVersion = 15
+
+// alterations are run on databases created before their version, after
+// schema. New databases already have the change from schema so they are
+// never run on them.
+var alterations = []struct {
+	version int
+	stmt    string
+}{
+	{3, `ALTER TABLE tickets ADD COLUMN original_estimate bigint NOT NULL 
+		 DEFAULT 0 CHECK (original_estimate >= 0);
+		 ALTER TABLE tickets ADD COLUMN time_spent bigint NOT NULL DEFAULT 0;
+		 ALTER TABLE tickets ADD COLUMN remaining_estimate bigint NOT NULL 
+		 DEFAULT 0 CHECK (remaining_estimate >= 0);`},
+	{4, `ALTER TABLE tickets ADD COLUMN due_date timestamp;
+		 ALTER TABLE tickets ADD COLUMN priority integer NOT NULL DEFAULT 0 
+		 CHECK (priority BETWEEN 0 AND 4);
+		 ALTER TABLE statuses ADD COLUMN closed boolean NOT NULL DEFAULT false;
+		 UPDATE statuses SET closed = 1 WHERE name = 'Done';`},
+	{7, `ALTER TABLE statuses ADD COLUMN position integer NOT NULL DEFAULT 0;
+		 UPDATE statuses SET position = id;`},
+	{12, `ALTER TABLE projects ADD COLUMN archived boolean NOT NULL DEFAULT 0;`},
+	{13, `UPDATE projects SET key = upper(key) WHERE key <> upper(key)
+		 AND NOT EXISTS (SELECT 1 FROM projects AS o WHERE o.key = upper(projects.key));
+		 UPDATE tickets SET key = upper(key) WHERE key <> upper(key)
+		 AND NOT EXISTS (SELECT 1 FROM tickets AS o WHERE o.key = upper(tickets.key));`},
+}
+
+// schema is the postgres schema, as of the latest migration in
+// store/pg/migrations, translated for SQLite. Booleans are stored as 0 and 1,
+// json as text and enums as text with a CHECK constraint.
+const schema = `
+CREATE TABLE IF NOT EXISTS users (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    username        varchar(40) UNIQUE NOT NULL,
+    password        varchar(250) NOT NULL,
+    email           varchar(250) NOT NULL,
+    full_name       varchar(250) NOT NULL,
+    is_admin        boolean DEFAULT false,
+    is_active       boolean DEFAULT true,
+    gravatar        varchar(250),
+    profile_picture varchar(250),
+    email_verified  boolean NOT NULL DEFAULT false,
+    display_name    varchar(250) NOT NULL DEFAULT '',
+    avatar_url      varchar(250) NOT NULL DEFAULT '',
+    bio             text NOT NULL DEFAULT ''
+);
+
+-- Users which share an email with an older user have theirs cleared so the
+-- index can be made on older databases, they can set a new one.
+UPDATE users SET email = '', email_verified = 0
+WHERE email <> '' AND EXISTS (SELECT 1 FROM users AS o
+                              WHERE lower(o.email) = lower(users.email)
+                              AND o.id < users.id);
+
+-- Users without an email don't conflict with each other.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (lower(email))
+WHERE email <> '';
+
+CREATE TABLE IF NOT EXISTS teams (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    name     varchar(40) NOT NULL,
+    url_slug varchar(250) NOT NULL UNIQUE,
+
+    lead_id integer REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS teams_users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    team_id integer REFERENCES teams (id) NOT NULL,
+    user_id integer REFERENCES users (id) NOT NULL
+);
+
+-- Older databases could add a member more than once, the first is kept.
+DELETE FROM teams_users
+WHERE EXISTS (SELECT 1 FROM teams_users AS o
+              WHERE o.team_id = teams_users.team_id
+              AND o.user_id = teams_users.user_id
+              AND o.id < teams_users.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS teams_users_team_id_user_id_idx
+ON teams_users (team_id, user_id);
+
+CREATE TABLE IF NOT EXISTS projects (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date   timestamp DEFAULT current_timestamp,
+    name           varchar(250) NOT NULL,
+    key            varchar(40) NOT NULL UNIQUE,
+    repo           varchar(250),
+    homepage       varchar(250),
+    icon_url       varchar(250),
+    ticket_counter integer NOT NULL DEFAULT 0,
+    archived       boolean NOT NULL DEFAULT 0,
+
+    lead_id integer REFERENCES users (id) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id integer REFERENCES projects (id) NOT NULL,
+    user_id    integer REFERENCES users (id) NOT NULL,
+    role       varchar(10) NOT NULL,
+    PRIMARY KEY(project_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS statuses (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    name     varchar(250) NOT NULL,
+    closed   boolean NOT NULL DEFAULT false,
+    position integer NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS ticket_types (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    name      varchar(250),
+    icon_path varchar(250)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ticket_types_name_idx ON ticket_types (name);
+
+CREATE TABLE IF NOT EXISTS workflows (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name varchar(250),
+
+    project_id     integer REFERENCES projects (id),
+    ticket_type_id integer REFERENCES ticket_types (id)
+);
+
+CREATE TABLE IF NOT EXISTS transitions (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name varchar(250),
+
+    workflow_id integer REFERENCES workflows (id),
+    from_status integer REFERENCES statuses (id),
+    to_status   integer REFERENCES statuses (id)
+);
+
+CREATE TABLE IF NOT EXISTS hooks (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    endpoint varchar(250),
+    method   varchar(10),
+    body     text,
+
+    transition_id integer REFERENCES transitions (id)
+);
+
+CREATE TABLE IF NOT EXISTS fields (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    name      varchar(250) UNIQUE,
+
+    data_type varchar(10)
+);
+
+CREATE TABLE IF NOT EXISTS tickets (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    updated_date timestamp DEFAULT current_timestamp,
+    created_date timestamp DEFAULT current_timestamp,
+    key          varchar(250) NOT NULL CHECK (key <> ''),
+    summary      varchar(250) NOT NULL CHECK (summary <> ''),
+    description  text NOT NULL,
+    version      integer NOT NULL DEFAULT 1,
+
+    original_estimate  bigint NOT NULL DEFAULT 0 CHECK (original_estimate >= 0),
+    time_spent         bigint NOT NULL DEFAULT 0,
+    remaining_estimate bigint NOT NULL DEFAULT 0 CHECK (remaining_estimate >= 0),
+    due_date           timestamp,
+    priority           integer NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 4),
+
+    project_id     integer REFERENCES projects (id) NOT NULL,
+    assignee_id    integer REFERENCES users (id),
+    reporter_id    integer REFERENCES users (id) NOT NULL,
+    ticket_type_id integer REFERENCES ticket_types (id) NOT NULL,
+    status_id      integer REFERENCES statuses (id) NOT NULL,
+    parent_id      integer REFERENCES tickets (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tickets_key_idx ON tickets (key);
+
+CREATE TABLE IF NOT EXISTS field_values (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    name      varchar(250),
+    data_type varchar(10),
+
+    int_value integer,
+    flt_value real,
+    str_value varchar(250),
+    dte_value timestamp,
+    opt_value varchar(100),
+    bln_value boolean,
+    mlt_value text,
+
+    ticket_id integer REFERENCES tickets (id),
+    field_id  integer REFERENCES fields (id)
+);
+
+-- A ticket has one value for each field, older databases keep the newest.
+DELETE FROM field_values
+WHERE EXISTS (SELECT 1 FROM field_values AS n
+              WHERE n.ticket_id = field_values.ticket_id
+              AND n.field_id = field_values.field_id
+              AND n.id > field_values.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS field_values_ticket_id_field_id_idx
+ON field_values (ticket_id, field_id);
+
+CREATE TABLE IF NOT EXISTS field_options (
+    id     INTEGER PRIMARY KEY AUTOINCREMENT,
+    option varchar(100),
+
+    field_id integer REFERENCES fields (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS field_options_field_id_option_idx
+ON field_options (field_id, option);
+
+CREATE TABLE IF NOT EXISTS field_tickettype_project (
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    required boolean NOT NULL DEFAULT false,
+
+    field_id       integer REFERENCES fields (id),
+    ticket_type_id integer REFERENCES ticket_types (id),
+    project_id     integer REFERENCES projects (id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    updated_date timestamp DEFAULT current_timestamp,
+    created_date timestamp DEFAULT current_timestamp,
+    body         text,
+
+    author_id integer REFERENCES users (id) NOT NULL,
+    ticket_id integer REFERENCES tickets (id) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    old_body  text,
+    edited_at timestamp DEFAULT current_timestamp,
+
+    comment_id integer REFERENCES comments (id) NOT NULL,
+    editor_id  integer REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS comment_reactions (
+    id    INTEGER PRIMARY KEY AUTOINCREMENT,
+    emoji varchar(64) NOT NULL,
+
+    comment_id integer REFERENCES comments (id) NOT NULL,
+    user_id    integer REFERENCES users (id) NOT NULL,
+
+    UNIQUE (comment_id, user_id, emoji)
+);
+
+CREATE TABLE IF NOT EXISTS labels (
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name varchar(255)
+);
+
+CREATE TABLE IF NOT EXISTS tickets_labels (
+    label_id  integer REFERENCES labels (id),
+    ticket_id integer REFERENCES tickets (id),
+    PRIMARY KEY(label_id, ticket_id)
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    updated_date timestamp,
+    created_date timestamp DEFAULT current_timestamp,
+    level        varchar(50),
+
+    project_id integer REFERENCES projects (id),
+    team_id    integer REFERENCES teams (id),
+    user_id    integer REFERENCES users (id) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ticket_watchers (
+    ticket_id integer REFERENCES tickets (id),
+    user_id   integer REFERENCES users (id),
+    PRIMARY KEY(ticket_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_links (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_type varchar(20) NOT NULL
+              CHECK (link_type IN ('blocks', 'blocked_by', 'relates_to', 'duplicates')),
+
+    source_id integer REFERENCES tickets (id) NOT NULL,
+    target_id integer REFERENCES tickets (id) NOT NULL,
+    UNIQUE(source_id, target_id, link_type)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_history (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date timestamp DEFAULT current_timestamp,
+    field        varchar(250) NOT NULL,
+    old_value    text,
+    new_value    text,
+
+    ticket_id integer REFERENCES tickets (id) NOT NULL,
+    user_id   integer REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date timestamp DEFAULT current_timestamp,
+    filename     varchar(250) NOT NULL,
+    content_type varchar(250) NOT NULL,
+    size         bigint NOT NULL,
+    storage_key  varchar(500) NOT NULL UNIQUE,
+
+    ticket_id   integer REFERENCES tickets (id) NOT NULL,
+    uploaded_by integer REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS worklogs (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date timestamp DEFAULT current_timestamp,
+    seconds      bigint NOT NULL CHECK (seconds > 0),
+
+    ticket_id integer REFERENCES tickets (id) NOT NULL,
+    author_id integer REFERENCES users (id) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS saved_filters (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date timestamp DEFAULT current_timestamp,
+    name         varchar(250) NOT NULL CHECK (name <> ''),
+    filter_json  text NOT NULL,
+
+    user_id integer REFERENCES users (id) NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date timestamp DEFAULT current_timestamp,
+    expires_date timestamp NOT NULL,
+    token_hash   varchar(64) NOT NULL UNIQUE,
+    used         boolean DEFAULT false,
+
+    user_id integer REFERENCES users (id) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date timestamp DEFAULT current_timestamp,
+    expires_date timestamp NOT NULL,
+    token_hash   varchar(64) NOT NULL UNIQUE,
+    used         boolean DEFAULT false,
+
+    user_id integer REFERENCES users (id) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_date timestamp DEFAULT current_timestamp,
+    expires_date timestamp NOT NULL,
+    token_hash   varchar(64) NOT NULL UNIQUE,
+    family       varchar(64) NOT NULL,
+    used         boolean DEFAULT false,
+
+    user_id integer REFERENCES users (id) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    user_id      integer PRIMARY KEY REFERENCES users (id),
+    failures     integer NOT NULL DEFAULT 0,
+    locked_until timestamp
+);
+`
+
+// createSchema will create the schema in a new database or bring one with an
+// older schemaVersion up to date, databases which already have it are left
+// alone.
+func createSchema(db *sql.DB) error {
+	var version int
+
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Current database version %d\n", version)
+
+	if version >= schemaVersion {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(schema)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, a := range alterations {
+		if version == 0 || version >= a.version {
+			continue
+		}
+
+		_, err = tx.Exec(a.stmt)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// PRAGMA doesn't accept query parameters.
+	_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,85 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+	"github.com/praelatus/backend/store/sqlite"
+	"github.com/praelatus/backend/store/storetest"
+)
+
+// newStore returns a store on a new database in the test's temp directory
+func newStore(t *testing.T) store.Store {
+	dsn := "file:" + filepath.Join(t.TempDir(), "praelatus.db") +
+		"?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+
+	return sqlite.New(dsn)
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, newStore(t))
+}
+
+func TestTicketStoreWithTx(t *testing.T) {
+	s := newStore(t)
+
+	u := models.User{Username: "txuser", Password: "test", Email: "tx@example.com"}
+	st := models.Status{Name: "Open"}
+	typ := models.TicketType{Name: "Task"}
+
+	for _, err := range []error{
+		s.Users().New(&u),
+		s.Statuses().New(&st),
+		s.Types().New(&typ),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := models.Project{Name: "Tx Project", Key: "TX", Lead: u}
+
+	err := s.Projects().New(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := s.(store.SQLStore).Conn()
+	tickets := s.Tickets().(*sqlite.TicketStore)
+
+	for _, commit := range []bool{false, true} {
+		tx, err := conn.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tk := models.Ticket{Summary: "Tx ticket", Reporter: u, Status: st, Type: typ}
+
+		err = tickets.WithTx(tx).New(p, &tk)
+		if err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.Tickets().Get(&models.Ticket{Key: tk.Key})
+		if commit && err != nil {
+			t.Errorf("Expected the committed ticket Got %v", err)
+		}
+
+		if !commit && err != store.ErrNotFound {
+			t.Errorf("Expected the rolled back ticket to be missing Got %v", err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// StatusStore contains methods for storing and retrieving Statuses from a
+// SQLite DB
+type StatusStore struct {
+	db *ctxDB
+}
+
+// Get gets a Status by it's ID or name in a SQLite DB
+func (ss *StatusStore) Get(s *models.Status) error {
+	row := ss.db.QueryRow(`SELECT id, name, closed, position
+						   FROM statuses
+						   WHERE id = ?1
+						   OR name = ?2`, s.ID, s.Name)
+
+	err := row.Scan(&s.ID, &s.Name, &s.Closed, &s.Position)
+	return handleSqliteErr(err)
+}
+
+// GetAll gets all the statuses from the database
+func (ss *StatusStore) GetAll() ([]models.Status, error) {
+	return ss.query("SELECT id, name, closed, position FROM statuses ORDER BY id")
+}
+
+// GetAllOrdered gets all the statuses from the database in board order
+func (ss *StatusStore) GetAllOrdered() ([]models.Status, error) {
+	return ss.query(`SELECT id, name, closed, position FROM statuses
+					 ORDER BY position, id`)
+}
+
+func (ss *StatusStore) query(q string) ([]models.Status, error) {
+	var statuses []models.Status
+
+	rows, err := ss.db.Query(q)
+	if err != nil {
+		return statuses, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.Status
+
+		err := rows.Scan(&s.ID, &s.Name, &s.Closed, &s.Position)
+		if err != nil {
+			return statuses, handleSqliteErr(err)
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	return statuses, handleSqliteErr(rows.Err())
+}
+
+// New creates a new Status in the SQLite DB, it's placed after every other
+// status on the board.
+func (ss *StatusStore) New(status *models.Status) error {
+	err := ss.db.QueryRow(`INSERT INTO statuses (name, closed, position)
+						   SELECT ?1, ?2, COALESCE(MAX(position) + 1, 0)
+						   FROM statuses
+						   RETURNING id, position`,
+		status.Name, status.Closed).
+		Scan(&status.ID, &status.Position)
+
+	return handleSqliteErr(err)
+}
+
+// Save updates a Status in the SQLite DB, it's position is only changed by
+// Reorder.
+func (ss *StatusStore) Save(status models.Status) error {
+	_, err := ss.db.Exec(`UPDATE statuses SET (name, closed) = (?1, ?2)
+						  WHERE id = ?3`, status.Name, status.Closed, status.ID)
+	return handleSqliteErr(err)
+}
+
+// Reorder sets the positions of the statuses in one transaction,
+// store.ErrNotFound is returned and nothing changed if any of the ids don't
+// exist.
+func (ss *StatusStore) Reorder(ids []int64) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	// Moving every status past the new positions first keeps the ones not
+	// in ids after them in their current order.
+	_, err = tx.Exec(`UPDATE statuses SET position = position + ?1`, len(ids))
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	for i, id := range ids {
+		res, err := tx.Exec(`UPDATE statuses SET position = ?1 WHERE id = ?2`,
+			i, id)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return store.ErrNotFound
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// Remove removes a status which no tickets or transitions use from the
+// database, store.ErrInUse is returned otherwise.
+func (ss *StatusStore) Remove(status models.Status) error {
+	var c int
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`SELECT COUNT(id) FROM tickets
+					   WHERE status_id = ?1`, status.ID).Scan(&c)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if c > 0 {
+		tx.Rollback()
+		return store.ErrInUse
+	}
+
+	err = tx.QueryRow(`SELECT COUNT(id) FROM transitions
+					   WHERE from_status = ?1
+					   OR to_status = ?1`, status.ID).Scan(&c)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if c > 0 {
+		tx.Rollback()
+		return store.ErrInUse
+	}
+
+	_, err = tx.Exec("DELETE FROM statuses WHERE id = ?1", status.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
@@ -0,0 +1,346 @@
+// Package sqlite implements store.Store for a SQLite database, it's intended
+// for single node and self hosted deployments which don't want to run
+// postgres.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/praelatus/backend/logger"
+	"github.com/praelatus/backend/store"
+)
+
+// Log is used by the sqlite stores to log errors.
+var Log logger.Logger = logger.Std{}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// sqlExecutor is implemented by *sql.DB and *sql.Tx as well as *ctxDB and
+// *ctxTx so queries can be shared between methods which do and don't run in a
+// transaction.
+type sqlExecutor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ctxDB runs every query with ctx so the queries made for a request are
+// cancelled along with it.
+type ctxDB struct {
+	*sql.DB
+	ctx context.Context
+
+	// tx is set for the stores given to a WithTx function, every query is
+	// run in it instead of on the DB.
+	tx *sql.Tx
+}
+
+// Query runs a query with the ctxDB's context
+func (db *ctxDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if db.tx != nil {
+		return db.tx.QueryContext(db.ctx, query, args...)
+	}
+
+	return db.QueryContext(db.ctx, query, args...)
+}
+
+// QueryRow runs a query expected to return a single row with the ctxDB's
+// context
+func (db *ctxDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	if db.tx != nil {
+		return db.tx.QueryRowContext(db.ctx, query, args...)
+	}
+
+	return db.QueryRowContext(db.ctx, query, args...)
+}
+
+// Exec runs a query which returns no rows with the ctxDB's context
+func (db *ctxDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if db.tx != nil {
+		return db.tx.ExecContext(db.ctx, query, args...)
+	}
+
+	return db.ExecContext(db.ctx, query, args...)
+}
+
+// savepoint is the name of the savepoints made by Begin inside of a WithTx
+// transaction. They are always released or rolled back in the reverse of the
+// order they were made so they can share a name.
+const savepoint = "store_tx"
+
+// Begin starts a transaction which is rolled back if the context is cancelled
+// before it's committed. If the ctxDB is already in a transaction a savepoint
+// is made instead, so rolling back only undoes what came after it.
+func (db *ctxDB) Begin() (*ctxTx, error) {
+	if db.tx != nil {
+		_, err := db.tx.ExecContext(db.ctx, "SAVEPOINT "+savepoint)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ctxTx{db.tx, db.ctx, true}, nil
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ctxTx{tx, db.ctx, false}, nil
+}
+
+// ctxTx runs every query in the transaction with ctx.
+type ctxTx struct {
+	*sql.Tx
+	ctx context.Context
+
+	// nested is true when the ctxTx is a savepoint in an outer transaction
+	nested bool
+}
+
+// Query runs a query in the transaction with it's context
+func (tx *ctxTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(tx.ctx, query, args...)
+}
+
+// QueryRow runs a query expected to return a single row in the transaction
+// with it's context
+func (tx *ctxTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.QueryRowContext(tx.ctx, query, args...)
+}
+
+// Exec runs a query which returns no rows in the transaction with it's context
+func (tx *ctxTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.ExecContext(tx.ctx, query, args...)
+}
+
+// Commit commits the transaction, or releases it's savepoint if it's nested
+func (tx *ctxTx) Commit() error {
+	if tx.nested {
+		_, err := tx.Exec("RELEASE SAVEPOINT " + savepoint)
+		return err
+	}
+
+	return tx.Tx.Commit()
+}
+
+// Rollback rolls back the transaction, or everything since it's savepoint if
+// it's nested. The savepoint is released as well so it can't be mistaken for
+// an outer one with the same name.
+func (tx *ctxTx) Rollback() error {
+	if !tx.nested {
+		return tx.Tx.Rollback()
+	}
+
+	_, err := tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("RELEASE SAVEPOINT " + savepoint)
+	return err
+}
+
+// Store implements the store.Store and store.SQLStore interface for a SQLite
+// DB.
+type Store struct {
+	db        *sql.DB
+	conn      *ctxDB
+	users     *UserStore
+	projects  *ProjectStore
+	fields    *FieldStore
+	workflows *WorkflowStore
+	tickets   *TicketStore
+	types     *TypeStore
+	labels    *LabelStore
+	statuses  *StatusStore
+	teams     *TeamStore
+}
+
+// New opens the SQLite database at dsn, creating the schema if the database
+// is new, and returns a store that's connected.
+//
+// The dsn must turn on foreign keys with ?_foreign_keys=on, SQLite doesn't
+// enforce them otherwise and the stores rely on them to reject tickets and
+// comments for missing users, projects and so on. New panics if they are
+// off. Pass _txlock=immediate and a _busy_timeout as well when the store is
+// shared by concurrent requests, for example:
+//
+//	file:praelatus.db?_foreign_keys=on&_txlock=immediate&_busy_timeout=5000
+//
+// Without them a transaction which reads before it writes fails with
+// "database is locked" instead of waiting for the other writer. Every
+// connection opens the same file, so an in memory database can't be used.
+func New(dsn string) store.Store {
+	d, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		log.Panicln("Error connection:", err)
+	}
+
+	var fks bool
+
+	err = d.QueryRow("PRAGMA foreign_keys").Scan(&fks)
+	if err != nil {
+		log.Panicln("Error connection:", err)
+	}
+
+	if !fks {
+		log.Panicln("Foreign keys are off, add ?_foreign_keys=on to", dsn)
+	}
+
+	err = createSchema(d)
+	if err != nil {
+		log.Panicln("Error creating schema:", err)
+	}
+
+	return newStore(d, context.Background(), nil)
+}
+
+// newStore returns a Store whose queries on d are run with ctx, in tx if it
+// isn't nil.
+func newStore(d *sql.DB, ctx context.Context, tx *sql.Tx) *Store {
+	db := &ctxDB{d, ctx, tx}
+
+	return &Store{
+		db:        d,
+		conn:      db,
+		users:     &UserStore{db},
+		projects:  &ProjectStore{db},
+		fields:    &FieldStore{db},
+		tickets:   &TicketStore{db},
+		labels:    &LabelStore{db},
+		workflows: &WorkflowStore{db},
+		types:     &TypeStore{db},
+		statuses:  &StatusStore{db},
+		teams:     &TeamStore{db},
+	}
+}
+
+// WithContext returns a copy of the store whose queries are run with ctx, they
+// return the context's error if it's cancelled before they finish.
+func (s *Store) WithContext(ctx context.Context) store.Store {
+	return newStore(s.db, ctx, s.conn.tx)
+}
+
+// WithTx runs fn with a copy of the store whose queries are all made in one
+// transaction, it's committed if fn returns nil and rolled back otherwise. If
+// the store is already in a transaction fn runs in a savepoint of it.
+func (s *Store) WithTx(fn func(tx store.Store) error) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = fn(newStore(s.db, s.conn.ctx, tx.Tx))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// Users returns the underlying UserStore for a SQLite DB
+func (s *Store) Users() store.UserStore {
+	return s.users
+}
+
+// Teams returns the underlying TeamStore for a SQLite DB
+func (s *Store) Teams() store.TeamStore {
+	return s.teams
+}
+
+// Fields returns the underlying FieldStore for a SQLite DB
+func (s *Store) Fields() store.FieldStore {
+	return s.fields
+}
+
+// Tickets returns the underlying TicketStore for a SQLite DB
+func (s *Store) Tickets() store.TicketStore {
+	return s.tickets
+}
+
+// Types returns the underlying TypeStore for a SQLite DB
+func (s *Store) Types() store.TypeStore {
+	return s.types
+}
+
+// Projects returns the underlying ProjectStore for a SQLite DB
+func (s *Store) Projects() store.ProjectStore {
+	return s.projects
+}
+
+// Statuses returns the underlying StatusStore for a SQLite DB
+func (s *Store) Statuses() store.StatusStore {
+	return s.statuses
+}
+
+// Workflows returns the underlying WorkflowStore for a SQLite DB
+func (s *Store) Workflows() store.WorkflowStore {
+	return s.workflows
+}
+
+// Labels returns the underlying LabelStore for a SQLite DB
+func (s *Store) Labels() store.LabelStore {
+	return s.labels
+}
+
+// Conn implementes store.SQLStore for a SQLite DB
+func (s *Store) Conn() *sql.DB {
+	return s.db
+}
+
+// limitClause returns the LIMIT and OFFSET clause for opts along with args
+// with their values appended. SQLite only allows an OFFSET after a LIMIT, so
+// a limit of -1, meaning no limit, is used when there is only an offset.
+func limitClause(opts store.PageOptions, args []interface{}) (string, []interface{}) {
+	if opts.Limit <= 0 && opts.Offset <= 0 {
+		return "", args
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	args = append(args, limit, opts.Offset)
+	return " LIMIT ?" + strconv.Itoa(len(args)-1) +
+		" OFFSET ?" + strconv.Itoa(len(args)), args
+}
+
+// handleSqliteErr translates sql.ErrNoRows to store.ErrNotFound and constraint
+// errors to the matching store error, wrapped along with the original.
+func handleSqliteErr(e error) error {
+	if e == sql.ErrNoRows {
+		return store.ErrNotFound
+	}
+
+	se, ok := e.(sqlite3.Error)
+	if !ok {
+		return e
+	}
+
+	Log.Error("sqlite error", se.ExtendedCode, se.Error())
+
+	if known, ok := sqliteErrors[se.ExtendedCode]; ok {
+		return fmt.Errorf("%w: %w", known, e)
+	}
+
+	return e
+}
+
+// sqliteErrors maps the SQLite constraint errors handleSqliteErr translates to
+// the store error returned for them.
+var sqliteErrors = map[sqlite3.ErrNoExtended]error{
+	sqlite3.ErrConstraintUnique:     store.ErrDuplicateEntry,
+	sqlite3.ErrConstraintPrimaryKey: store.ErrDuplicateEntry,
+	sqlite3.ErrConstraintForeignKey: store.ErrInvalidReference,
+	sqlite3.ErrConstraintNotNull:    store.ErrMissingField,
+}
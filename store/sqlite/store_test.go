@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/praelatus/backend/store"
+)
+
+func TestHandleSqliteErr(t *testing.T) {
+	tests := []struct {
+		code     sqlite3.ErrNoExtended
+		expected error
+	}{
+		{sqlite3.ErrConstraintUnique, store.ErrDuplicateEntry},
+		{sqlite3.ErrConstraintPrimaryKey, store.ErrDuplicateEntry},
+		{sqlite3.ErrConstraintForeignKey, store.ErrInvalidReference},
+		{sqlite3.ErrConstraintNotNull, store.ErrMissingField},
+	}
+
+	for _, test := range tests {
+		se := sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: test.code}
+		err := handleSqliteErr(se)
+
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v for %d Got %v", test.expected, test.code, err)
+		}
+
+		var wrapped sqlite3.Error
+		if !errors.As(err, &wrapped) || wrapped.ExtendedCode != test.code {
+			t.Errorf("Expected the sqlite3.Error to be kept for %d Got %v",
+				test.code, err)
+		}
+	}
+
+	if err := handleSqliteErr(sql.ErrNoRows); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// TeamStore contains methods for storing and retrieving Teams from a SQLite
+// DB
+type TeamStore struct {
+	db *ctxDB
+}
+
+// teamSelect is the select shared by every query which returns teams through
+// intoTeam.
+var teamSelect = `SELECT t.id, t.name, t.url_slug, ` + joinedUserColumns("lead") + `
+				  FROM teams AS t
+				  JOIN users AS lead ON lead.id = t.lead_id `
+
+func intoTeam(db *ctxDB, row rowScanner, t *models.Team) error {
+	l := &t.Lead
+
+	err := row.Scan(&t.ID, &t.Name, &t.URLSlug, &l.ID, &l.Username,
+		&l.Password, &l.Email, &l.FullName, &l.Gravatar, &l.ProfilePic,
+		&l.IsAdmin, &l.IsActive, &l.EmailVerified, &l.DisplayName,
+		&l.AvatarURL, &l.Bio)
+	if err != nil {
+		return err
+	}
+
+	t.Members = nil
+
+	rows, err := db.Query(`SELECT u.id, u.username, u.email,
+								  u.full_name, COALESCE(u.gravatar, ''),
+								  COALESCE(u.profile_picture, ''), u.is_admin
+						   FROM teams_users AS tu
+						   JOIN users AS u ON tu.user_id = u.id
+						   WHERE tu.team_id = ?1
+						   ORDER BY tu.id`, t.ID)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+
+		err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.FullName,
+			&u.Gravatar, &u.ProfilePic, &u.IsAdmin)
+		if err != nil {
+			return err
+		}
+
+		t.Members = append(t.Members, u)
+	}
+
+	return rows.Err()
+}
+
+func teamsFromRows(db *ctxDB, rows *sql.Rows) ([]models.Team, error) {
+	var teams []models.Team
+
+	defer rows.Close()
+
+	for rows.Next() {
+		t := models.Team{}
+
+		err := intoTeam(db, rows, &t)
+		if err != nil {
+			return teams, handleSqliteErr(err)
+		}
+
+		teams = append(teams, t)
+	}
+
+	return teams, handleSqliteErr(rows.Err())
+}
+
+// Get retrieves a team from the database based on ID, name or url slug
+func (ts *TeamStore) Get(t *models.Team) error {
+	row := ts.db.QueryRow(teamSelect+`WHERE t.id = ?1
+									  OR t.name = ?2
+									  OR t.url_slug = ?3`, t.ID, t.Name, t.URLSlug)
+
+	err := intoTeam(ts.db, row, t)
+	return handleSqliteErr(err)
+}
+
+// GetMembers will get the members for the given team.
+func (ts *TeamStore) GetMembers(t models.Team) ([]models.User, error) {
+	members := []models.User{}
+
+	rows, err := ts.db.Query(`SELECT `+userColumns+`
+							  FROM users
+							  WHERE id IN (SELECT user_id FROM teams_users
+										   WHERE team_id = ?1)
+							  ORDER BY id`, t.ID)
+	if err != nil {
+		return members, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+
+		err = intoUser(rows, &u)
+		if err != nil {
+			return members, handleSqliteErr(err)
+		}
+
+		u.Password = ""
+		members = append(members, u)
+	}
+
+	return members, handleSqliteErr(rows.Err())
+}
+
+// GetAll retrieves all the teams from the db
+func (ts *TeamStore) GetAll() ([]models.Team, error) {
+	rows, err := ts.db.Query(teamSelect + `ORDER BY t.id`)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return teamsFromRows(ts.db, rows)
+}
+
+// GetForUser will get the given users associated teams
+func (ts *TeamStore) GetForUser(u models.User) ([]models.Team, error) {
+	rows, err := ts.db.Query(teamSelect+`WHERE t.id IN (SELECT team_id
+														FROM teams_users
+														WHERE user_id = ?1)
+										 ORDER BY t.id`, u.ID)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return teamsFromRows(ts.db, rows)
+}
+
+// AddMembers will add users to the given team
+func (ts *TeamStore) AddMembers(t models.Team, users ...models.User) error {
+	for _, u := range users {
+		err := ts.AddMember(t, u)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddMember will add the user to the given team, adding a user who is
+// already a member does nothing.
+func (ts *TeamStore) AddMember(t models.Team, u models.User) error {
+	_, err := ts.db.Exec(`INSERT INTO teams_users (team_id, user_id)
+						  VALUES (?1, ?2) ON CONFLICT (team_id, user_id) DO NOTHING`,
+		t.ID, u.ID)
+
+	return handleSqliteErr(err)
+}
+
+// RemoveMember will remove the user from the given team, returning
+// store.ErrRemoveLead if the user is the lead of the team.
+func (ts *TeamStore) RemoveMember(t models.Team, u models.User) error {
+	var leadID sql.NullInt64
+
+	err := ts.db.QueryRow(`SELECT lead_id FROM teams WHERE id = ?1`, t.ID).
+		Scan(&leadID)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	if leadID.Int64 == u.ID {
+		return store.ErrRemoveLead
+	}
+
+	_, err = ts.db.Exec(`DELETE FROM teams_users
+						 WHERE team_id = ?1 AND user_id = ?2`, t.ID, u.ID)
+
+	return handleSqliteErr(err)
+}
+
+// New adds a new team and it's members to the database, if the team has no
+// url slug one is generated from it's name.
+func (ts *TeamStore) New(t *models.Team) error {
+	if t.URLSlug == "" {
+		t.URLSlug = models.Slugify(t.Name)
+	}
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`INSERT INTO teams
+					   (name, url_slug, lead_id) VALUES (?1, ?2, ?3)
+					   RETURNING id`,
+		t.Name, t.URLSlug, t.Lead.ID).
+		Scan(&t.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	for _, mem := range t.Members {
+		_, err = tx.Exec(`INSERT INTO teams_users
+						  (team_id, user_id) VALUES (?1, ?2)
+						  ON CONFLICT (team_id, user_id) DO NOTHING`, t.ID, mem.ID)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// Save updates a team in the database.
+func (ts *TeamStore) Save(t models.Team) error {
+	if t.URLSlug == "" {
+		t.URLSlug = models.Slugify(t.Name)
+	}
+
+	_, err := ts.db.Exec(`UPDATE teams SET
+						  (name, url_slug, lead_id) = (?1, ?2, ?3)
+						  WHERE id = ?4`,
+		t.Name, t.URLSlug, t.Lead.ID, t.ID)
+	return handleSqliteErr(err)
+}
+
+// Remove removes a team and it's memberships from the database.
+func (ts *TeamStore) Remove(t models.Team) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM teams_users WHERE team_id = ?1`, t.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM teams WHERE id = ?1`, t.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
@@ -0,0 +1,2532 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// TicketStore contains methods for storing and retrieving Tickets from
+// SQLite DB
+type TicketStore struct {
+	db *ctxDB
+}
+
+// WithTx returns a TicketStore whose queries are run in tx, committing or
+// rolling it back is left to the caller. Methods which need a transaction of
+// their own make a savepoint in tx instead.
+func (ts *TicketStore) WithTx(tx *sql.Tx) *TicketStore {
+	return &TicketStore{&ctxDB{ts.db.DB, ts.db.ctx, tx}}
+}
+
+func getOpts(db sqlExecutor, fid int64, fo *models.FieldOption) error {
+	rows, err := db.Query(`SELECT option FROM field_options
+						   WHERE field_id = ?1
+						   ORDER BY id`, fid)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var opt string
+
+		err = rows.Scan(&opt)
+		if err != nil {
+			return err
+		}
+
+		fo.Options = append(fo.Options, opt)
+	}
+
+	return nil
+}
+
+// getOptsBatch retrieves the options for all of the given fields in a single
+// query, grouped by field id.
+func getOptsBatch(db *ctxDB, fids []int64) (map[int64][]string, error) {
+	opts := make(map[int64][]string)
+	if len(fids) == 0 {
+		return opts, nil
+	}
+
+	args := make([]interface{}, len(fids))
+	params := make([]string, len(fids))
+	for i, fid := range fids {
+		args[i] = fid
+		params[i] = "?" + strconv.Itoa(i+1)
+	}
+
+	rows, err := db.Query(`SELECT field_id, option FROM field_options
+						   WHERE field_id IN (`+strings.Join(params, ", ")+`)
+						   ORDER BY id`, args...)
+	if err != nil {
+		return opts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fid int64
+		var opt string
+
+		err = rows.Scan(&fid, &opt)
+		if err != nil {
+			return opts, err
+		}
+
+		opts[fid] = append(opts[fid], opt)
+	}
+
+	return opts, rows.Err()
+}
+
+func populateFields(db *ctxDB, t *models.Ticket) error {
+	rows, err := db.Query(`
+		SELECT fv.id, f.name, f.data_type, 
+			   fv.int_value, fv.flt_value, fv.str_value, 
+			   fv.opt_value, fv.dte_value, fv.bln_value, fv.mlt_value, f.id
+		FROM field_values AS fv
+		JOIN fields AS f ON f.id = fv.field_id
+		WHERE fv.ticket_id = ?1`, t.ID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	// optFields maps the index of an OPT value in t.Fields to its field id so
+	// the options can be filled in with one query once all rows are read.
+	optFields := make(map[int]int64)
+	var fids []int64
+
+	for rows.Next() {
+		// We need to be able to scan in all the values then determine which
+		// actually goes into the model. Only the column matching the field's
+		// data type will be set, the rest come back as NULL.
+		fv := models.FieldValue{}
+		var i sql.NullInt64
+		var f sql.NullFloat64
+		var s, o sql.NullString
+		var d sql.NullTime
+		var b sql.NullBool
+		var m []byte
+		var fID int64
+
+		err = rows.Scan(&fv.ID, &fv.Name, &fv.DataType, &i, &f, &s, &o, &d, &b,
+			&m, &fID)
+		if err != nil {
+			return err
+		}
+
+		// By Odin's Beard I can't think of a better way to wrangle this mess.
+		switch fv.DataType {
+		case "FLOAT":
+			fv.Value = f.Float64
+		case "INT":
+			fv.Value = int(i.Int64)
+		case "STRING":
+			fv.Value = s.String
+		case "DATE":
+			fv.Value = d.Time
+		case "BOOL":
+			fv.Value = b.Bool
+		case "MULTI_OPT":
+			selected := []string{}
+			if m != nil {
+				err = json.Unmarshal(m, &selected)
+				if err != nil {
+					return err
+				}
+			}
+
+			fv.Value = selected
+		case "OPT":
+			fv.Value = models.FieldOption{Selected: o.String}
+
+			optFields[len(t.Fields)] = fID
+			fids = append(fids, fID)
+		default:
+			fv.Value = nil
+		}
+
+		t.Fields = append(t.Fields, fv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// Fill out the options and defaults.
+	opts, err := getOptsBatch(db, fids)
+	if err != nil {
+		return err
+	}
+
+	for i, fID := range optFields {
+		fo := t.Fields[i].Value.(models.FieldOption)
+		fo.Options = opts[fID]
+		t.Fields[i].Value = fo
+	}
+
+	return nil
+}
+
+func intoTicket(row rowScanner, db *ctxDB, t *models.Ticket) error {
+	a, r := &t.Assignee, &t.Reporter
+	var due sql.NullTime
+
+	err := row.Scan(&t.ID, &t.Key, &t.CreatedDate, &t.UpdatedDate, &t.Summary,
+		&t.Description, &a.ID, &a.Username, &a.Password, &a.Email, &a.FullName,
+		&a.Gravatar, &a.ProfilePic, &a.IsAdmin, &a.IsActive, &a.EmailVerified,
+		&a.DisplayName, &a.AvatarURL, &a.Bio, &r.ID, &r.Username, &r.Password,
+		&r.Email, &r.FullName, &r.Gravatar, &r.ProfilePic, &r.IsAdmin,
+		&r.IsActive, &r.EmailVerified, &r.DisplayName, &r.AvatarURL, &r.Bio,
+		&t.Status.ID, &t.Status.Name, &t.Status.Closed, &t.Type.ID,
+		&t.Type.Name, &t.ParentID, &t.Version, &t.OriginalEstimate,
+		&t.TimeSpent, &t.RemainingEstimate, &due, &t.Priority, &t.CommentCount)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	t.DueDate = due.Time
+
+	err = populateFields(db, t)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	t.Labels, err = getLabels(db, *t)
+	return handleSqliteErr(err)
+}
+
+// getLabels will return the labels on the given ticket
+func getLabels(db *ctxDB, t models.Ticket) ([]models.Label, error) {
+	var labels []models.Label
+
+	rows, err := db.Query(`SELECT l.id, l.name FROM labels AS l
+						   JOIN tickets_labels AS tl ON tl.label_id = l.id
+						   JOIN tickets AS t ON t.id = tl.ticket_id
+						   WHERE t.id = ?1 OR t.key = ?2
+						   ORDER BY l.id`, t.ID, t.Key)
+	if err != nil {
+		return labels, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.Label
+
+		err = rows.Scan(&l.ID, &l.Name)
+		if err != nil {
+			return labels, err
+		}
+
+		labels = append(labels, l)
+	}
+
+	return labels, rows.Err()
+}
+
+// ticketColumns and ticketJoins make up the select shared by every query
+// which returns tickets through intoTicket.
+var ticketColumns = `SELECT t.id, t.key, t.created_date,
+							  t.updated_date, t.summary, t.description, ` +
+	joinedUserColumns("a") + `, ` + joinedUserColumns("r") + `,
+							  s.id, s.name, s.closed, tt.id, COALESCE(tt.name, ''),
+							  COALESCE(t.parent_id, 0) AS parent_id,
+							  t.version, t.original_estimate, t.time_spent,
+							  t.remaining_estimate, t.due_date, t.priority,
+							  (SELECT COUNT(*) FROM comments
+							   WHERE ticket_id = t.id) AS comment_count `
+
+const ticketJoins = `FROM tickets AS t 
+					 LEFT JOIN users AS a ON a.id = t.assignee_id
+					 JOIN users AS r ON r.id = t.reporter_id
+					 JOIN projects AS p ON p.id = t.project_id
+					 JOIN statuses AS s ON s.id = t.status_id
+					 JOIN ticket_types AS tt ON tt.id = t.ticket_type_id `
+
+var ticketSelect = ticketColumns + ticketJoins
+
+// ticketOrderColumns is the whitelist of columns tickets can be ordered by,
+// anything else is rejected so it is never interpolated into a query.
+var ticketOrderColumns = map[string]string{
+	"":             "t.id",
+	"id":           "t.id",
+	"key":          "t.key",
+	"summary":      "t.summary",
+	"created_date": "t.created_date",
+	"updated_date": "t.updated_date",
+}
+
+func ticketsFromRows(rows *sql.Rows, db *ctxDB) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.Ticket
+
+		err := intoTicket(rows, db, &t)
+		if err != nil {
+			Log.Error("Error getting tickets:", err)
+			return tickets, handleSqliteErr(err)
+		}
+
+		tickets = append(tickets, t)
+	}
+
+	return tickets, handleSqliteErr(rows.Err())
+}
+
+// getPaged will run the ticket select with the given where clause, limited
+// and ordered by opts, and return the page plus the total number of tickets
+// matching the where clause.
+func (ts *TicketStore) getPaged(where string, opts store.PageOptions,
+	args ...interface{}) ([]models.Ticket, int, error) {
+	var total int
+
+	orderBy, ok := ticketOrderColumns[opts.OrderBy]
+	name, isField := store.OrderField(opts.OrderBy)
+	if !ok && !isField {
+		return nil, 0, store.ErrInvalidOrderBy
+	}
+
+	err := ts.db.QueryRow("SELECT COUNT(t.id) "+ticketJoins+where, args...).
+		Scan(&total)
+	if err != nil {
+		return nil, 0, handleSqliteErr(err)
+	}
+
+	var join string
+
+	if isField {
+		fid, col, err := fieldOrderColumn(ts.db, name)
+		if err != nil {
+			return nil, total, err
+		}
+
+		// The join comes before the where clause but it's placeholder is
+		// numbered after the where clause's so they don't need renumbering.
+		args = append(args, fid)
+		join = `LEFT JOIN field_values AS ofv
+				ON ofv.ticket_id = t.id AND ofv.field_id = ?` +
+			strconv.Itoa(len(args)) + " "
+		orderBy = "ofv." + col
+	}
+
+	limit, args := limitClause(opts, args)
+
+	rows, err := ts.db.Query(ticketSelect+join+where+" ORDER BY "+orderBy+" "+
+		opts.Direction()+" NULLS LAST, t.id"+limit, args...)
+	if err != nil {
+		return nil, total, handleSqliteErr(err)
+	}
+
+	tickets, err := ticketsFromRows(rows, ts.db)
+	return tickets, total, err
+}
+
+// Get gets a Ticket from a SQLite DB by it's ID, or by it's key when the ID
+// is 0
+func (ts *TicketStore) Get(t *models.Ticket) error {
+	row := ts.db.QueryRow(ticketSelect+`WHERE (?1 <> 0 AND t.id = ?1)
+										   OR (?1 = 0 AND t.key = ?2)`,
+		t.ID, t.Key)
+
+	err := intoTicket(row, ts.db, t)
+	return handleSqliteErr(err)
+}
+
+// GetByKey gets a Ticket from a SQLite DB by it's key alone
+func (ts *TicketStore) GetByKey(key string) (models.Ticket, error) {
+	var t models.Ticket
+
+	row := ts.db.QueryRow(ticketSelect+`WHERE t.key = ?1`, key)
+
+	err := intoTicket(row, ts.db, &t)
+	return t, handleSqliteErr(err)
+}
+
+// GetChildren will get the sub tasks of the given ticket
+func (ts *TicketStore) GetChildren(t models.Ticket) ([]models.Ticket, error) {
+	rows, err := ts.db.Query(ticketSelect+`WHERE t.parent_id = 
+										   (SELECT id FROM tickets 
+											WHERE id = ?1 OR key = ?2)
+										   ORDER BY t.id`, t.ID, t.Key)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return ticketsFromRows(rows, ts.db)
+}
+
+// getByUser gets the tickets where the user joined as alias, either a for the
+// assignee or r for the reporter, matches u's id or username. An empty slice is
+// returned when there are none.
+func (ts *TicketStore) getByUser(alias string, u models.User) ([]models.Ticket, error) {
+	rows, err := ts.db.Query(ticketSelect+`WHERE `+alias+`.id = ?1 
+										   OR `+alias+`.username = ?2
+										   ORDER BY t.id`, u.ID, u.Username)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	tickets, err := ticketsFromRows(rows, ts.db)
+	if err == nil && tickets == nil {
+		tickets = []models.Ticket{}
+	}
+
+	return tickets, err
+}
+
+// GetByAssignee gets the tickets assigned to the given user
+func (ts *TicketStore) GetByAssignee(u models.User) ([]models.Ticket, error) {
+	return ts.getByUser("a", u)
+}
+
+// GetByReporter gets the tickets reported by the given user
+func (ts *TicketStore) GetByReporter(u models.User) ([]models.Ticket, error) {
+	return ts.getByUser("r", u)
+}
+
+// GetRecentlyUpdated gets the tickets in the project, the most recently
+// updated first
+func (ts *TicketStore) GetRecentlyUpdated(p models.Project, limit int) ([]models.Ticket, error) {
+	l, args := limitClause(store.PageOptions{Limit: limit},
+		[]interface{}{p.ID, p.Key})
+
+	rows, err := ts.db.Query(ticketSelect+`WHERE (p.id = ?1 OR p.key = ?2)
+										   AND NOT p.archived
+										   ORDER BY t.updated_date DESC, t.id DESC`+l,
+		args...)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return ticketsFromRows(rows, ts.db)
+}
+
+// GetOverdue gets the tickets in the project which are past their due date
+// and not closed, the longest overdue first
+func (ts *TicketStore) GetOverdue(p models.Project) ([]models.Ticket, error) {
+	rows, err := ts.db.Query(ticketSelect+`WHERE (p.id = ?1 OR p.key = ?2)
+										   AND t.due_date < ?3 AND NOT s.closed
+										   AND NOT p.archived
+										   ORDER BY t.due_date, t.id`,
+		p.ID, p.Key, time.Now().UTC())
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return ticketsFromRows(rows, ts.db)
+}
+
+// CountByStatus counts the tickets in the project for each status name, the
+// statuses used by the project's workflows are included even when no tickets
+// have them.
+func (ts *TicketStore) CountByStatus(p models.Project) (map[string]int, error) {
+	rows, err := ts.db.Query(`WITH project AS (
+								  SELECT id FROM projects WHERE id = ?1 OR key = ?2
+							  ), project_statuses AS (
+								  SELECT tr.from_status AS id FROM transitions AS tr
+								  JOIN workflows AS w ON w.id = tr.workflow_id
+								  WHERE w.project_id IN (SELECT id FROM project)
+								  UNION
+								  SELECT tr.to_status FROM transitions AS tr
+								  JOIN workflows AS w ON w.id = tr.workflow_id
+								  WHERE w.project_id IN (SELECT id FROM project)
+								  UNION
+								  SELECT status_id FROM tickets
+								  WHERE project_id IN (SELECT id FROM project)
+							  )
+							  SELECT s.name, COUNT(t.id) FROM project_statuses AS ps
+							  JOIN statuses AS s ON s.id = ps.id
+							  LEFT JOIN tickets AS t ON t.status_id = s.id
+							  AND t.project_id IN (SELECT id FROM project)
+							  GROUP BY s.name`, p.ID, p.Key)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var name string
+		var count int
+
+		err = rows.Scan(&name, &count)
+		if err != nil {
+			return nil, handleSqliteErr(err)
+		}
+
+		counts[name] = count
+	}
+
+	return counts, handleSqliteErr(rows.Err())
+}
+
+// CountAllByStatus counts the tickets of every project, including archived
+// ones, for each status name keyed by the project's key. Like CountByStatus
+// the statuses used by a project's workflows are included even when no
+// tickets have them.
+func (ts *TicketStore) CountAllByStatus() (map[string]map[string]int, error) {
+	rows, err := ts.db.Query(`WITH project_statuses AS (
+								  SELECT w.project_id, tr.from_status AS status_id
+								  FROM transitions AS tr
+								  JOIN workflows AS w ON w.id = tr.workflow_id
+								  UNION
+								  SELECT w.project_id, tr.to_status FROM transitions AS tr
+								  JOIN workflows AS w ON w.id = tr.workflow_id
+								  UNION
+								  SELECT project_id, status_id FROM tickets
+							  )
+							  SELECT p.key, s.name, COUNT(t.id) FROM project_statuses AS ps
+							  JOIN projects AS p ON p.id = ps.project_id
+							  JOIN statuses AS s ON s.id = ps.status_id
+							  LEFT JOIN tickets AS t ON t.status_id = s.id
+							  AND t.project_id = p.id
+							  GROUP BY p.key, s.name`)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]map[string]int)
+
+	for rows.Next() {
+		var key, name string
+		var count int
+
+		err = rows.Scan(&key, &name, &count)
+		if err != nil {
+			return nil, handleSqliteErr(err)
+		}
+
+		if counts[key] == nil {
+			counts[key] = make(map[string]int)
+		}
+
+		counts[key][name] = count
+	}
+
+	return counts, handleSqliteErr(rows.Err())
+}
+
+// GetAll gets all the Tickets from the database
+func (ts *TicketStore) GetAll() ([]models.Ticket, error) {
+	rows, err := ts.db.Query(ticketSelect + "WHERE NOT p.archived")
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return ticketsFromRows(rows, ts.db)
+}
+
+// GetAllPaged gets a page of Tickets from the database as described by opts
+// and the total number of tickets
+func (ts *TicketStore) GetAllPaged(opts store.PageOptions) ([]models.Ticket, int, error) {
+	return ts.getPaged("WHERE NOT p.archived", opts)
+}
+
+// GetAllByProject gets all the Tickets from the database based on the given
+// project, ordered by created date in the direction dir
+func (ts *TicketStore) GetAllByProject(p models.Project, dir string) ([]models.Ticket, error) {
+	dir, err := store.SortDirection(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := ts.db.Query(ticketSelect+`WHERE p.id = ?1 OR p.key = ?2
+										   ORDER BY t.created_date `+dir+`, t.id `+dir,
+		p.ID, p.Key)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return ticketsFromRows(rows, ts.db)
+}
+
+// GetAllByProjectPaged gets a page of Tickets for the given project as
+// described by opts and the total number of tickets in the project
+func (ts *TicketStore) GetAllByProjectPaged(p models.Project,
+	opts store.PageOptions) ([]models.Ticket, int, error) {
+	return ts.getPaged(`WHERE (p.id = ?1 OR p.key = ?2)`, opts, p.ID, p.Key)
+}
+
+// Search will return the tickets whose summary or description contain every
+// word of query, if p has an ID or Key only tickets in that project are
+// searched. SQLite has no full text search without an extension so the words
+// are matched with LIKE, ignoring case, and the tickets are ordered by id
+// rather than relevance.
+func (ts *TicketStore) Search(query string, p models.Project) ([]models.Ticket, error) {
+	var where []string
+	var args []interface{}
+
+	for _, word := range strings.Fields(query) {
+		args = append(args, "%"+likeEscaper.Replace(word)+"%")
+		n := "?" + strconv.Itoa(len(args))
+		where = append(where, `(t.summary LIKE `+n+` ESCAPE '\'
+							   OR t.description LIKE `+n+` ESCAPE '\')`)
+	}
+
+	// Like plainto_tsquery an empty query matches nothing.
+	if len(where) == 0 {
+		return nil, nil
+	}
+
+	if p.ID != 0 || p.Key != "" {
+		args = append(args, p.ID, p.Key)
+		where = append(where, `(p.id = ?`+strconv.Itoa(len(args)-1)+
+			` OR p.key = ?`+strconv.Itoa(len(args))+`)`)
+	}
+
+	where = append(where, "NOT p.archived")
+
+	rows, err := ts.db.Query(ticketSelect+"WHERE "+strings.Join(where, " AND ")+
+		" ORDER BY t.id", args...)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return ticketsFromRows(rows, ts.db)
+}
+
+// GetFiltered will return the tickets matching all of the set fields of f,
+// an empty filter returns all tickets.
+func (ts *TicketStore) GetFiltered(f store.TicketFilter) ([]models.Ticket, error) {
+	where := []string{"NOT p.archived"}
+	var args []interface{}
+
+	add := func(col string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, col+" = ?"+strconv.Itoa(len(args)))
+	}
+
+	if f.ProjectKey != "" {
+		add("p.key", f.ProjectKey)
+	}
+
+	if f.StatusID != 0 {
+		add("t.status_id", f.StatusID)
+	}
+
+	if f.TypeID != 0 {
+		add("t.ticket_type_id", f.TypeID)
+	}
+
+	if f.AssigneeUsername != "" {
+		add("a.username", f.AssigneeUsername)
+	}
+
+	q := ticketSelect + "WHERE " + strings.Join(where, " AND ")
+
+	rows, err := ts.db.Query(q+" ORDER BY t.id", args...)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return ticketsFromRows(rows, ts.db)
+}
+
+// SaveFilter will save the filter for the user, replacing the user's filter
+// with the same name if they have one.
+func (ts *TicketStore) SaveFilter(u models.User, f *store.SavedFilter) error {
+	if f.Name == "" {
+		return store.ErrMissingField
+	}
+
+	b, err := json.Marshal(f.Filter)
+	if err != nil {
+		return err
+	}
+
+	err = ts.db.QueryRow(`INSERT INTO saved_filters (name, filter_json, user_id)
+						  SELECT ?1, ?2, id FROM users
+						  WHERE id = ?3 OR username = ?4
+						  ON CONFLICT (user_id, name)
+						  DO UPDATE SET filter_json = excluded.filter_json
+						  RETURNING id`, f.Name, string(b), u.ID, u.Username).
+		Scan(&f.ID)
+	return handleSqliteErr(err)
+}
+
+// GetFilters will return the user's saved filters ordered by name
+func (ts *TicketStore) GetFilters(u models.User) ([]store.SavedFilter, error) {
+	var filters []store.SavedFilter
+
+	rows, err := ts.db.Query(`SELECT sf.id, sf.name, sf.filter_json
+							  FROM saved_filters AS sf
+							  JOIN users AS u ON u.id = sf.user_id
+							  WHERE u.id = ?1 OR u.username = ?2
+							  ORDER BY sf.name`, u.ID, u.Username)
+	if err != nil {
+		return filters, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var f store.SavedFilter
+		var fjson []byte
+
+		err = rows.Scan(&f.ID, &f.Name, &fjson)
+		if err != nil {
+			return filters, handleSqliteErr(err)
+		}
+
+		err = json.Unmarshal(fjson, &f.Filter)
+		if err != nil {
+			return filters, err
+		}
+
+		filters = append(filters, f)
+	}
+
+	return filters, handleSqliteErr(rows.Err())
+}
+
+// DeleteFilter will remove the user's filter with the given name,
+// store.ErrNotFound is returned if they have no such filter.
+func (ts *TicketStore) DeleteFilter(u models.User, name string) error {
+	res, err := ts.db.Exec(`DELETE FROM saved_filters
+							WHERE name = ?1
+							AND user_id = (SELECT id FROM users 
+										   WHERE id = ?2 OR username = ?3)`,
+		name, u.ID, u.Username)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	if n == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// RunFilter will return the tickets matching the user's filter with the given
+// name
+func (ts *TicketStore) RunFilter(name string, u models.User) ([]models.Ticket, error) {
+	var fjson []byte
+
+	err := ts.db.QueryRow(`SELECT sf.filter_json FROM saved_filters AS sf
+						   JOIN users AS u ON u.id = sf.user_id
+						   WHERE sf.name = ?1 
+						   AND (u.id = ?2 OR u.username = ?3)`,
+		name, u.ID, u.Username).Scan(&fjson)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	var f store.TicketFilter
+
+	err = json.Unmarshal(fjson, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.GetFiltered(f)
+}
+
+// fieldValueColumns maps a field's data type to the column of field_values
+// its value is stored in.
+var fieldValueColumns = map[string]string{
+	"FLOAT":     "flt_value",
+	"INT":       "int_value",
+	"STRING":    "str_value",
+	"DATE":      "dte_value",
+	"OPT":       "opt_value",
+	"BOOL":      "bln_value",
+	"MULTI_OPT": "mlt_value",
+}
+
+// fieldOrderColumn returns the id of the field named name and the column of
+// field_values it's values are stored in so tickets can be ordered by it.
+// store.ErrInvalidOrderBy is returned if there is no such field or it's values
+// can't be ordered.
+func fieldOrderColumn(db *ctxDB, name string) (int64, string, error) {
+	var id int64
+	var dataType sql.NullString
+
+	err := db.QueryRow(`SELECT id, data_type FROM fields WHERE name = ?1`, name).
+		Scan(&id, &dataType)
+	if err == sql.ErrNoRows {
+		return 0, "", store.ErrInvalidOrderBy
+	}
+
+	if err != nil {
+		return 0, "", handleSqliteErr(err)
+	}
+
+	col, ok := fieldValueColumns[dataType.String]
+	if !ok || dataType.String == "MULTI_OPT" {
+		return 0, "", store.ErrInvalidOrderBy
+	}
+
+	return id, col, nil
+}
+
+// validateFieldValue returns an error from models.ValidateField if the value
+// is invalid, OPT and MULTI_OPT values are checked against the options of their
+// field rather than the options sent with them.
+func validateFieldValue(q sqlExecutor, fv models.FieldValue) error {
+	fo, isOpt := fv.Value.(models.FieldOption)
+	if !isOpt && fv.DataType != "MULTI_OPT" {
+		return models.ValidateField(fv)
+	}
+
+	options, err := fieldOptions(q, fv.Name)
+	if err != nil {
+		return err
+	}
+
+	if !isOpt {
+		return models.ValidateSelections(fv, options)
+	}
+
+	fo.Options = options
+	fv.Value = fo
+	return models.ValidateField(fv)
+}
+
+// fieldOptions returns the options of the field with the given name
+func fieldOptions(q sqlExecutor, name string) ([]string, error) {
+	rows, err := q.Query(`SELECT fo.option FROM field_options AS fo
+						  JOIN fields AS f ON f.id = fo.field_id
+						  WHERE f.name = ?1`, name)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	var options []string
+	for rows.Next() {
+		var opt string
+
+		err = rows.Scan(&opt)
+		if err != nil {
+			return nil, handleSqliteErr(err)
+		}
+
+		options = append(options, opt)
+	}
+
+	return options, handleSqliteErr(rows.Err())
+}
+
+// fieldValueArg returns the column and query argument used to store the value
+// of the given FieldValue.
+func fieldValueArg(fv models.FieldValue) (string, interface{}, error) {
+	col, ok := fieldValueColumns[fv.DataType]
+	if !ok {
+		return "", nil, models.ErrInvalidDataType
+	}
+
+	if fo, ok := fv.Value.(models.FieldOption); ok {
+		return col, fo.Selected, nil
+	}
+
+	// MULTI_OPT values are stored as a json array of the selected options.
+	if selected, ok := models.Selections(fv.Value); ok {
+		b, err := json.Marshal(selected)
+		return col, string(b), err
+	}
+
+	return col, fv.Value, nil
+}
+
+// Save will update an existing ticket in the SQLite DB, the ticket and all
+// of it's field values are updated in a single transaction.
+func (ts *TicketStore) Save(ticket models.Ticket) error {
+	err := store.ValidateTicket(ticket)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	var oldSummary, oldDescription string
+	var oldOriginal, oldRemaining int64
+	var oldDue sql.NullTime
+	var oldPriority models.Priority
+
+	var projectID int64
+
+	var version int
+
+	// RETURNING can't refer to the row before the update in SQLite so the old
+	// values are read first, the transaction already holds the write lock.
+	err = tx.QueryRow(`SELECT id, project_id, summary, description, version,
+							  original_estimate, remaining_estimate, due_date,
+							  priority
+					   FROM tickets
+					   WHERE id = ?1 OR key = ?2`, ticket.ID, ticket.Key).
+		Scan(&ticket.ID, &projectID, &oldSummary, &oldDescription, &version,
+			&oldOriginal, &oldRemaining, &oldDue, &oldPriority)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if version != ticket.Version {
+		tx.Rollback()
+		return store.ErrStaleObject
+	}
+
+	_, err = tx.Exec(`UPDATE tickets SET
+					  (summary, description, updated_date, parent_id, version,
+					  original_estimate, remaining_estimate, due_date, priority)
+					  = (?1, ?2, ?3, NULLIF(?4, 0), version + 1, ?6, ?7, ?8, ?9)
+					  WHERE id = ?5`,
+		ticket.Summary, ticket.Description, time.Now(), ticket.ParentID,
+		ticket.ID, ticket.OriginalEstimate, ticket.RemainingEstimate,
+		dueDate(ticket), ticket.Priority)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if ticket.ParentID == ticket.ID {
+		tx.Rollback()
+		return store.ErrInvalidParent
+	}
+
+	err = checkParent(tx, projectID, ticket.ID, ticket.ParentID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordHistory(tx, ticket, "summary", oldSummary, ticket.Summary)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordHistory(tx, ticket, "description", oldDescription, ticket.Description)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordHistory(tx, ticket, "original_estimate",
+		strconv.FormatInt(oldOriginal, 10),
+		strconv.FormatInt(ticket.OriginalEstimate, 10))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordHistory(tx, ticket, "remaining_estimate",
+		strconv.FormatInt(oldRemaining, 10),
+		strconv.FormatInt(ticket.RemainingEstimate, 10))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordHistory(tx, ticket, "due_date", store.HistoryDate(oldDue.Time),
+		store.HistoryDate(ticket.DueDate))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = recordHistory(tx, ticket, "priority", strconv.Itoa(int(oldPriority)),
+		strconv.Itoa(int(ticket.Priority)))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, fv := range ticket.Fields {
+		err := validateFieldValue(tx, fv)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		col, val, err := fieldValueArg(fv)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		var oldVal, newVal string
+
+		err = tx.QueryRow(`SELECT COALESCE(CAST(fv.`+col+` AS TEXT), '')
+						   FROM field_values AS fv
+						   JOIN fields AS f ON f.id = fv.field_id
+						   WHERE fv.ticket_id = ?1 AND f.name = ?2`,
+			ticket.ID, fv.Name).Scan(&oldVal)
+		if err != nil && err != sql.ErrNoRows {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+
+		// A field the ticket has no value for yet is given one.
+		err = tx.QueryRow(`INSERT INTO field_values
+						   (ticket_id, field_id, name, data_type, `+col+`)
+						   VALUES (?1, (SELECT id FROM fields WHERE name = ?2),
+								   ?2, ?3, ?4)
+						   ON CONFLICT (ticket_id, field_id) DO UPDATE
+						   SET (name, data_type, `+col+`)
+						   = (excluded.name, excluded.data_type, excluded.`+col+`)
+						   RETURNING COALESCE(CAST(`+col+` AS TEXT), '')`,
+			ticket.ID, fv.Name, fv.DataType, val).Scan(&newVal)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+
+		err = recordHistory(tx, ticket, fv.Name, oldVal, newVal)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// recordHistory will add an entry to the ticket history if the value of the
+// field changed.
+func recordHistory(tx *ctxTx, t models.Ticket, field, oldVal, newVal string) error {
+	if oldVal == newVal {
+		return nil
+	}
+
+	_, err := tx.Exec(`INSERT INTO ticket_history 
+					   (field, old_value, new_value, ticket_id, user_id)
+					   VALUES (?1, ?2, ?3, ?4, NULLIF(?5, 0))`,
+		field, oldVal, newVal, t.ID, t.UpdatedBy.ID)
+	return handleSqliteErr(err)
+}
+
+// GetHistory will return the changes made to the ticket, oldest first
+func (ts *TicketStore) GetHistory(t models.Ticket) ([]models.HistoryEntry, error) {
+	var history []models.HistoryEntry
+
+	rows, err := ts.db.Query(`SELECT th.id, th.created_date, th.field,
+									 th.old_value, th.new_value,
+									 COALESCE(u.id, 0), COALESCE(u.username, ''),
+									 COALESCE(u.email, ''),
+									 COALESCE(u.full_name, ''),
+									 COALESCE(u.gravatar, ''),
+									 COALESCE(u.profile_picture, ''),
+									 COALESCE(u.is_admin, false)
+							  FROM ticket_history AS th
+							  JOIN tickets AS t ON t.id = th.ticket_id
+							  LEFT JOIN users AS u ON u.id = th.user_id
+							  WHERE t.id = ?1 OR t.key = ?2
+							  ORDER BY th.created_date, th.id`, t.ID, t.Key)
+	if err != nil {
+		return history, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var h models.HistoryEntry
+
+		err = rows.Scan(&h.ID, &h.CreatedDate, &h.Field, &h.OldValue,
+			&h.NewValue, &h.User.ID, &h.User.Username, &h.User.Email,
+			&h.User.FullName, &h.User.Gravatar, &h.User.ProfilePic,
+			&h.User.IsAdmin)
+		if err != nil {
+			return history, handleSqliteErr(err)
+		}
+
+		history = append(history, h)
+	}
+
+	return history, handleSqliteErr(rows.Err())
+}
+
+// Remove will update an existing ticket in the SQLite DB
+func (ts *TicketStore) Remove(ticket models.Ticket) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	ticket.ID, err = ticketID(tx, ticket)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var children bool
+
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM tickets 
+									  WHERE parent_id = ?1)`, ticket.ID).
+		Scan(&children)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if children {
+		tx.Rollback()
+		return store.ErrHasChildren
+	}
+
+	_, err = tx.Exec(`DELETE FROM field_values WHERE ticket_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM tickets_labels WHERE ticket_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM attachments WHERE ticket_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM ticket_history WHERE ticket_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM worklogs WHERE ticket_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM comment_revisions
+					  WHERE comment_id 
+					  in(SELECT id FROM comments WHERE ticket_id = ?1);`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM comment_reactions
+					  WHERE comment_id 
+					  in(SELECT id FROM comments WHERE ticket_id = ?1);`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM comments WHERE ticket_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM ticket_watchers WHERE ticket_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM ticket_links 
+					  WHERE source_id = ?1 OR target_id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM tickets WHERE id = ?1;`, ticket.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// missingReference returns the name of the first of the ticket's references
+// which doesn't exist, SQLite doesn't say which foreign key failed so they are
+// looked up after the insert fails.
+func missingReference(tx *ctxTx, t models.Ticket) string {
+	references := []struct {
+		field string
+		table string
+		id    int64
+	}{
+		{"reporter", "users", t.Reporter.ID},
+		{"assignee", "users", t.Assignee.ID},
+		{"status", "statuses", t.Status.ID},
+		{"type", "ticket_types", t.Type.ID},
+	}
+
+	for _, ref := range references {
+		if ref.field == "assignee" && ref.id == 0 {
+			continue
+		}
+
+		var exists bool
+
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+ref.table+`
+										   WHERE id = ?1)`, ref.id).Scan(&exists)
+		if err == nil && !exists {
+			return ref.field
+		}
+	}
+
+	return ""
+}
+
+// keyRetries is how many times New moves on to the project's next key when
+// the one it reserved is taken.
+const keyRetries = 5
+
+// reserveTicketKeys will increment the ticket counter of the project by n and
+// return the new value, the numbers up to and including it are reserved for
+// the tickets being created in tx. SQLite only allows one writing
+// transaction at a time so no two tickets get the same key. The ID and
+// Key of p are filled in from the projects table. store.ErrProjectArchived is
+// returned if the project is archived, tx should be rolled back then.
+func reserveTicketKeys(tx *ctxTx, p *models.Project, n int) (int, error) {
+	var last int
+	var archived bool
+
+	err := tx.QueryRow(`UPDATE projects 
+						SET ticket_counter = ticket_counter + ?3
+						WHERE id = ?1 OR key = ?2
+						RETURNING id, key, ticket_counter, archived`, p.ID, p.Key, n).
+		Scan(&p.ID, &p.Key, &last, &archived)
+	if err == nil && archived {
+		return last, store.ErrProjectArchived
+	}
+
+	return last, handleSqliteErr(err)
+}
+
+// checkParent will verify that the ticket with parentID is in the project,
+// returning store.ErrInvalidParent if it is in another project, doesn't exist
+// or is the ticket with ticketID or one of it's descendants. A parentID of 0
+// means the ticket has no parent and a ticketID of 0 is a new ticket.
+func checkParent(tx *ctxTx, projectID, ticketID, parentID int64) error {
+	if parentID == 0 {
+		return nil
+	}
+
+	var pid int64
+	var cycle bool
+
+	// UNION rather than UNION ALL stops the walk if there already is a cycle.
+	err := tx.QueryRow(`WITH RECURSIVE ancestors (id) AS (
+							SELECT parent_id FROM tickets WHERE id = ?1
+							UNION
+							SELECT t.parent_id FROM tickets AS t
+							JOIN ancestors AS a ON a.id = t.id
+						)
+						SELECT project_id, id = ?2 OR EXISTS (
+							SELECT 1 FROM ancestors WHERE id = ?2)
+						FROM tickets WHERE id = ?1`, parentID, ticketID).
+		Scan(&pid, &cycle)
+	if err == sql.ErrNoRows || pid != projectID || cycle {
+		return store.ErrInvalidParent
+	}
+
+	return handleSqliteErr(err)
+}
+
+// New will add a new Ticket to the SQLite DB, the ticket is given the next
+// key for the project. If the ticket has a ParentID the parent must be in the
+// same project.
+func (ts *TicketStore) New(project models.Project, ticket *models.Ticket) error {
+	err := store.ValidateTicket(*ticket)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	last, err := reserveTicketKeys(tx, &project, 1)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = checkParent(tx, project.ID, 0, ticket.ParentID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = checkRequiredFields(tx, project, *ticket)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		ticket.Key = project.Key + strconv.Itoa(last)
+
+		err = tx.QueryRow(`INSERT INTO tickets 
+						   (summary, description, project_id, assignee_id, 
+						   reporter_id, ticket_type_id, status_id, key, parent_id,
+						   original_estimate, remaining_estimate, due_date, 
+						   priority) 
+						   VALUES (?1, ?2, ?3, NULLIF(?4, 0), ?5, ?6, ?7, ?8, 
+								   NULLIF(?9, 0), ?10, ?11, ?12, ?13)
+						   ON CONFLICT (key) DO NOTHING
+						   RETURNING id, created_date, updated_date;`,
+			ticket.Summary, ticket.Description, project.ID,
+			ticket.Assignee.ID, ticket.Reporter.ID, ticket.Type.ID,
+			ticket.Status.ID, ticket.Key, ticket.ParentID,
+			ticket.OriginalEstimate, ticket.RemainingEstimate,
+			dueDate(*ticket), ticket.Priority).
+			Scan(&ticket.ID, &ticket.CreatedDate, &ticket.UpdatedDate)
+		if err != sql.ErrNoRows {
+			break
+		}
+
+		// No row means the key is already taken, which can happen when
+		// one project's key is another's followed by a number.
+		if attempt == keyRetries {
+			err = store.ErrDuplicateEntry
+			break
+		}
+
+		last, err = reserveTicketKeys(tx, &project, 1)
+		if err != nil {
+			break
+		}
+	}
+
+	err = handleSqliteErr(err)
+	if errors.Is(err, store.ErrInvalidReference) {
+		if field := missingReference(tx, *ticket); field != "" {
+			err = store.ReferenceError{Field: field, Err: err}
+		}
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	ticket.Version = 1
+	ticket.TimeSpent = 0
+
+	err = newFieldValues(tx, ticket)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// dueDate returns the ticket's due date as a query argument, a ticket
+// without a due date is stored as NULL. Timestamps are compared as text in
+// SQLite so due dates are always stored in UTC.
+func dueDate(t models.Ticket) sql.NullTime {
+	return sql.NullTime{Time: t.DueDate.UTC(), Valid: !t.DueDate.IsZero()}
+}
+
+// checkRequiredFields returns store.ErrMissingRequiredField if the ticket does
+// not have a value for every field required for it's type in the project.
+func checkRequiredFields(tx *ctxTx, project models.Project, t models.Ticket) error {
+	fields, err := fieldsForType(tx, project, t.Type)
+	if err != nil {
+		return err
+	}
+
+	if !store.HasRequiredFields(t, fields) {
+		return store.ErrMissingRequiredField
+	}
+
+	return nil
+}
+
+// newFieldValues will insert the field values for a newly created ticket
+func newFieldValues(tx *ctxTx, ticket *models.Ticket) error {
+	for i, fv := range ticket.Fields {
+		err := validateFieldValue(tx, fv)
+		if err != nil {
+			return err
+		}
+
+		col, val, err := fieldValueArg(fv)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(`INSERT INTO field_values 
+						   (ticket_id, field_id, name, data_type, `+col+`)
+						   VALUES (?1, (SELECT id FROM fields WHERE name = ?2), 
+								   ?2, ?3, ?4)
+						   RETURNING id`, ticket.ID, fv.Name, fv.DataType, val).
+			Scan(&ticket.Fields[i].ID)
+		if err != nil {
+			return handleSqliteErr(err)
+		}
+	}
+
+	return nil
+}
+
+// batchSize is the number of tickets inserted per statement by NewBatch, it
+// keeps the number of query parameters under the SQLite limit.
+const batchSize = 1000
+
+// NewBatch will add all of the tickets to the given project in a single
+// transaction, each ticket is given the next ticket key for the project in
+// order. If any ticket fails to insert none of them are added.
+func (ts *TicketStore) NewBatch(project models.Project, tickets []*models.Ticket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = newBatch(tx, project, tickets)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+func newBatch(tx *ctxTx, project models.Project, tickets []*models.Ticket) error {
+	last, err := reserveTicketKeys(tx, &project, len(tickets))
+	if err != nil {
+		return err
+	}
+
+	count := last - len(tickets)
+
+	byKey := make(map[string]*models.Ticket, len(tickets))
+	for i, t := range tickets {
+		err = checkParent(tx, project.ID, 0, t.ParentID)
+		if err != nil {
+			return err
+		}
+
+		err = checkRequiredFields(tx, project, *t)
+		if err != nil {
+			return err
+		}
+
+		err = store.ValidateTicket(*t)
+		if err != nil {
+			return err
+		}
+
+		t.Key = project.Key + strconv.Itoa(count+i+1)
+		byKey[t.Key] = t
+	}
+
+	for start := 0; start < len(tickets); start += batchSize {
+		end := start + batchSize
+		if end > len(tickets) {
+			end = len(tickets)
+		}
+
+		var values []string
+		var args []interface{}
+
+		for _, t := range tickets[start:end] {
+			n := len(args)
+			values = append(values, fmt.Sprintf("(?%d, ?%d, ?%d, NULLIF(?%d, 0), ?%d, ?%d, ?%d, ?%d, NULLIF(?%d, 0), ?%d, ?%d, ?%d, ?%d)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13))
+			args = append(args, t.Summary, t.Description, project.ID,
+				t.Assignee.ID, t.Reporter.ID, t.Type.ID, t.Status.ID, t.Key,
+				t.ParentID, t.OriginalEstimate, t.RemainingEstimate,
+				dueDate(*t), t.Priority)
+		}
+
+		rows, err := tx.Query(`INSERT INTO tickets 
+							   (summary, description, project_id, assignee_id, 
+							   reporter_id, ticket_type_id, status_id, key, 
+							   parent_id, original_estimate, remaining_estimate,
+							   due_date, priority) 
+							   VALUES `+strings.Join(values, ", ")+`
+							   RETURNING id, key, created_date, updated_date`,
+			args...)
+		if err != nil {
+			return handleSqliteErr(err)
+		}
+
+		for rows.Next() {
+			var id int64
+			var key string
+			var created, updated time.Time
+
+			err = rows.Scan(&id, &key, &created, &updated)
+			if err != nil {
+				rows.Close()
+				return handleSqliteErr(err)
+			}
+
+			byKey[key].ID = id
+			byKey[key].Version = 1
+			byKey[key].TimeSpent = 0
+			byKey[key].CreatedDate = created
+			byKey[key].UpdatedDate = updated
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return handleSqliteErr(err)
+		}
+	}
+
+	for _, t := range tickets {
+		err = newFieldValues(tx, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetComment will get a single comment and it's author by the comment's ID
+func (ts *TicketStore) GetComment(c *models.Comment) error {
+	row := ts.db.QueryRow(`SELECT c.id, c.created_date, c.updated_date,
+								  COALESCE(c.body, ''), `+joinedUserColumns("users")+`
+						   FROM comments AS c
+						   JOIN users ON users.id = c.author_id
+						   WHERE c.id = ?1`, c.ID)
+
+	err := intoComment(row, c)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	c.Reactions, err = ts.GetReactions(*c)
+	if len(c.Reactions) == 0 {
+		c.Reactions = nil
+	}
+
+	return err
+}
+
+// GetComments will return all comments for a ticket based on it's ID or Key
+// ordered by when they were created
+func (ts *TicketStore) GetComments(t models.Ticket) ([]models.Comment, error) {
+	comments, _, err := ts.GetCommentsPaged(t, store.PageOptions{})
+	return comments, err
+}
+
+// GetCommentsPaged will return a page of the comments for a ticket, ordered
+// by when they were created, and the total number of comments on the ticket.
+// Comments can only be ordered by created_date.
+func (ts *TicketStore) GetCommentsPaged(t models.Ticket, opts store.PageOptions) ([]models.Comment, int, error) {
+	var comments []models.Comment
+	var total int
+
+	if opts.OrderBy != "" && opts.OrderBy != "created_date" {
+		return nil, 0, store.ErrInvalidOrderBy
+	}
+
+	const where = `FROM comments AS c
+				   JOIN tickets AS t ON t.id = c.ticket_id
+				   JOIN users ON users.id = c.author_id
+				   WHERE t.id = ?1
+				   OR t.key = ?2 `
+
+	args := []interface{}{t.ID, t.Key}
+
+	err := ts.db.QueryRow("SELECT COUNT(c.id) "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, handleSqliteErr(err)
+	}
+
+	limit, args := limitClause(opts, args)
+
+	rows, err := ts.db.Query(`SELECT c.id, c.created_date, c.updated_date,
+									 COALESCE(c.body, ''), `+
+		joinedUserColumns("users")+" "+where+
+		`ORDER BY c.created_date `+opts.Direction()+`, c.id `+opts.Direction()+
+		limit, args...)
+	if err != nil {
+		return comments, total, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Comment
+
+		err := intoComment(rows, &c)
+		if err != nil {
+			return comments, total, handleSqliteErr(err)
+		}
+
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return comments, total, handleSqliteErr(err)
+	}
+
+	err = ticketReactions(ts.db, t, comments)
+	return comments, total, handleSqliteErr(err)
+}
+
+func intoComment(row rowScanner, c *models.Comment) error {
+	a := &c.Author
+
+	return row.Scan(&c.ID, &c.CreatedDate, &c.UpdatedDate, &c.Body, &a.ID,
+		&a.Username, &a.Password, &a.Email, &a.FullName, &a.Gravatar,
+		&a.ProfilePic, &a.IsAdmin, &a.IsActive, &a.EmailVerified,
+		&a.DisplayName, &a.AvatarURL, &a.Bio)
+}
+
+// ticketReactions will fill in the reaction counts of the comments, which
+// must all be on the ticket t.
+func ticketReactions(db *ctxDB, t models.Ticket, comments []models.Comment) error {
+	byID := make(map[int64]*models.Comment, len(comments))
+	for i := range comments {
+		byID[comments[i].ID] = &comments[i]
+	}
+
+	return fillReactions(db, byID, `SELECT cr.comment_id, cr.emoji, COUNT(cr.id)
+									FROM comment_reactions AS cr
+									JOIN comments AS c ON c.id = cr.comment_id
+									JOIN tickets AS t ON t.id = c.ticket_id
+									WHERE t.id = ?1 OR t.key = ?2
+									GROUP BY cr.comment_id, cr.emoji`, t.ID, t.Key)
+}
+
+// fillReactions will fill in the reaction counts of the comments in byID from
+// the comment id, emoji and count rows returned by query.
+func fillReactions(db *ctxDB, byID map[int64]*models.Comment, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var emoji string
+		var count int
+
+		err = rows.Scan(&id, &emoji, &count)
+		if err != nil {
+			return err
+		}
+
+		if c, ok := byID[id]; ok {
+			if c.Reactions == nil {
+				c.Reactions = make(map[string]int)
+			}
+
+			c.Reactions[emoji] = count
+		}
+	}
+
+	return rows.Err()
+}
+
+// AddReaction will add the emoji reaction from the user to the comment, a
+// user can only react with each emoji once so adding it again returns
+// store.ErrDuplicateEntry.
+func (ts *TicketStore) AddReaction(c models.Comment, u models.User, emoji string) error {
+	_, err := ts.db.Exec(`INSERT INTO comment_reactions 
+						  (comment_id, user_id, emoji) VALUES (?1, ?2, ?3)`,
+		c.ID, u.ID, emoji)
+	return handleSqliteErr(err)
+}
+
+// RemoveReaction will remove the emoji reaction from the user on the comment
+func (ts *TicketStore) RemoveReaction(c models.Comment, u models.User, emoji string) error {
+	_, err := ts.db.Exec(`DELETE FROM comment_reactions 
+						  WHERE comment_id = ?1 AND user_id = ?2 AND emoji = ?3`,
+		c.ID, u.ID, emoji)
+	return handleSqliteErr(err)
+}
+
+// GetReactions will return the number of users who reacted to the comment
+// with each emoji
+func (ts *TicketStore) GetReactions(c models.Comment) (map[string]int, error) {
+	reactions := make(map[string]int)
+
+	rows, err := ts.db.Query(`SELECT emoji, COUNT(id) FROM comment_reactions
+							  WHERE comment_id = ?1
+							  GROUP BY emoji`, c.ID)
+	if err != nil {
+		return reactions, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var emoji string
+		var count int
+
+		err = rows.Scan(&emoji, &count)
+		if err != nil {
+			return reactions, handleSqliteErr(err)
+		}
+
+		reactions[emoji] = count
+	}
+
+	return reactions, handleSqliteErr(rows.Err())
+}
+
+// NewComment will add a new Comment to the SQLite DB, the users mentioned
+// in the body are set as the comment's Mentions.
+func (ts *TicketStore) NewComment(t models.Ticket, c *models.Comment) error {
+	var err error
+
+	c.Mentions, err = store.ResolveMentions(&UserStore{ts.db}, c.Body)
+	if err != nil {
+		return err
+	}
+
+	// The ticket is bumped in the same transaction so it's updated date
+	// can't be left behind the comment if the insert fails.
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	t.ID, err = ticketID(tx, t)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE tickets SET updated_date = ?1 
+					  WHERE id = ?2;`, time.Now(), t.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`INSERT INTO comments 
+					   (body, ticket_id, author_id) VALUES (?1, ?2, ?3)
+					   RETURNING id;`, c.Body, t.ID, c.Author.ID).
+		Scan(&c.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// SaveComment will update the body of the comment in the SQLite DB, the
+// author is never changed. If the body changed the previous body is recorded
+// as a revision edited by c.UpdatedBy.
+func (ts *TicketStore) SaveComment(c models.Comment) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	var oldBody string
+
+	err = tx.QueryRow(`SELECT COALESCE(body, '') FROM comments WHERE id = ?1`,
+		c.ID).Scan(&oldBody)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`UPDATE comments SET (body, updated_date) = (?1, ?2)
+					  WHERE id = ?3`, c.Body, time.Now(), c.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if oldBody != c.Body {
+		_, err = tx.Exec(`INSERT INTO comment_revisions 
+						  (comment_id, old_body, editor_id)
+						  VALUES (?1, ?2, NULLIF(?3, 0))`,
+			c.ID, oldBody, c.UpdatedBy.ID)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// GetCommentHistory will return the revisions of the comment oldest first
+func (ts *TicketStore) GetCommentHistory(c models.Comment) ([]models.CommentRevision, error) {
+	var revisions []models.CommentRevision
+
+	rows, err := ts.db.Query(`SELECT cr.id, cr.old_body, cr.edited_at,
+									 COALESCE(u.id, 0), COALESCE(u.username, ''),
+									 COALESCE(u.full_name, '')
+							  FROM comment_revisions AS cr
+							  LEFT JOIN users AS u ON u.id = cr.editor_id
+							  WHERE cr.comment_id = ?1
+							  ORDER BY cr.edited_at, cr.id`, c.ID)
+	if err != nil {
+		return revisions, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.CommentRevision
+
+		err = rows.Scan(&r.ID, &r.OldBody, &r.EditedAt, &r.Editor.ID,
+			&r.Editor.Username, &r.Editor.FullName)
+		if err != nil {
+			return revisions, handleSqliteErr(err)
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	return revisions, handleSqliteErr(rows.Err())
+}
+
+// RemoveComment will remove the comment along with it's revisions and
+// reactions from the SQLite DB
+func (ts *TicketStore) RemoveComment(c models.Comment) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec("DELETE FROM comment_revisions WHERE comment_id = ?1", c.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec("DELETE FROM comment_reactions WHERE comment_id = ?1", c.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`UPDATE tickets SET updated_date = ?1
+					  WHERE id = (SELECT ticket_id FROM comments WHERE id = ?2)`,
+		time.Now(), c.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec("DELETE FROM comments WHERE id = ?1", c.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// AddLabel will add the label, found by ID or name, to the ticket. Adding a
+// label which the ticket already has does nothing.
+func (ts *TicketStore) AddLabel(t models.Ticket, label models.Label) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	res, err := tx.Exec(`INSERT INTO tickets_labels (label_id, ticket_id)
+						  SELECT l.id, t.id FROM tickets AS t, labels AS l
+						  WHERE (t.id = ?1 OR t.key = ?2)
+						  AND (l.id = ?3 OR l.name = ?4)
+						  AND NOT EXISTS (
+							  SELECT 1 FROM tickets_labels AS tl
+							  WHERE tl.ticket_id = t.id AND tl.label_id = l.id
+						  )`, t.ID, t.Key, label.ID, label.Name)
+	if err == nil {
+		err = touchTicket(tx, t, res)
+	}
+
+	if err != nil {
+		tx.Rollback()
+
+		err = handleSqliteErr(err)
+		if errors.Is(err, store.ErrDuplicateEntry) {
+			return nil
+		}
+
+		return err
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// RemoveLabel will remove the label, found by ID or name, from the ticket
+func (ts *TicketStore) RemoveLabel(t models.Ticket, label models.Label) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	res, err := tx.Exec(`DELETE FROM tickets_labels
+						  WHERE ticket_id IN (SELECT id FROM tickets 
+											  WHERE id = ?1 OR key = ?2)
+						  AND label_id IN (SELECT id FROM labels
+										   WHERE id = ?3 OR name = ?4)`,
+		t.ID, t.Key, label.ID, label.Name)
+	if err == nil {
+		err = touchTicket(tx, t, res)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// touchTicket bumps the updated date of the ticket, found by ID or key, when
+// res changed any rows so the ticket's ETag changes along with it's labels.
+func touchTicket(tx *ctxTx, t models.Ticket, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE tickets SET updated_date = ?1
+					  WHERE id = ?2 OR key = ?3`, time.Now(), t.ID, t.Key)
+	return err
+}
+
+// GetLabels will return all of the labels on the ticket
+func (ts *TicketStore) GetLabels(t models.Ticket) ([]models.Label, error) {
+	labels, err := getLabels(ts.db, t)
+	return labels, handleSqliteErr(err)
+}
+
+// AddWatcher will add the user as a watcher of the ticket, adding a user who
+// is already watching the ticket does nothing.
+func (ts *TicketStore) AddWatcher(t models.Ticket, u models.User) error {
+	_, err := ts.db.Exec(`INSERT INTO ticket_watchers (ticket_id, user_id)
+						  SELECT t.id, ?3 FROM tickets AS t
+						  WHERE (t.id = ?1 OR t.key = ?2)
+						  AND NOT EXISTS (
+							  SELECT 1 FROM ticket_watchers AS tw
+							  WHERE tw.ticket_id = t.id AND tw.user_id = ?3
+						  )`, t.ID, t.Key, u.ID)
+
+	err = handleSqliteErr(err)
+	if errors.Is(err, store.ErrDuplicateEntry) {
+		return nil
+	}
+
+	return err
+}
+
+// RemoveWatcher will remove the user from the watchers of the ticket
+func (ts *TicketStore) RemoveWatcher(t models.Ticket, u models.User) error {
+	_, err := ts.db.Exec(`DELETE FROM ticket_watchers
+						  WHERE user_id = ?3
+						  AND ticket_id IN (SELECT id FROM tickets 
+											WHERE id = ?1 OR key = ?2)`,
+		t.ID, t.Key, u.ID)
+	return handleSqliteErr(err)
+}
+
+// GetWatchers will return all of the users watching the ticket
+func (ts *TicketStore) GetWatchers(t models.Ticket) ([]models.User, error) {
+	var users []models.User
+
+	rows, err := ts.db.Query(`SELECT u.id, u.username, u.email, 
+									 u.full_name, u.gravatar, u.profile_picture,
+									 u.is_admin
+							  FROM ticket_watchers AS tw
+							  JOIN users AS u ON u.id = tw.user_id
+							  JOIN tickets AS t ON t.id = tw.ticket_id
+							  WHERE t.id = ?1 OR t.key = ?2`, t.ID, t.Key)
+	if err != nil {
+		return users, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+
+		err = rows.Scan(&u.ID, &u.Username, &u.Email, &u.FullName,
+			&u.Gravatar, &u.ProfilePic, &u.IsAdmin)
+		if err != nil {
+			return users, handleSqliteErr(err)
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// ticketID will return the ID of the given ticket, looking it up by key if
+// the ID is not set.
+func ticketID(tx *ctxTx, t models.Ticket) (int64, error) {
+	if t.ID != 0 {
+		return t.ID, nil
+	}
+
+	var id int64
+
+	err := tx.QueryRow(`SELECT id FROM tickets WHERE key = ?1`, t.Key).Scan(&id)
+	return id, handleSqliteErr(err)
+}
+
+// LinkTickets will link src to dst with the given link type, if the link type
+// has an inverse (for example blocks and blocked_by) the inverse link from dst
+// to src is created in the same transaction.
+func (ts *TicketStore) LinkTickets(src, dst models.Ticket, linkType string) error {
+	if !models.IsValidLinkType(linkType) {
+		return models.ErrInvalidLinkType
+	}
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	srcID, err := ticketID(tx, src)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	dstID, err := ticketID(tx, dst)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if srcID == dstID {
+		tx.Rollback()
+		return models.ErrSelfLink
+	}
+
+	_, err = tx.Exec(`INSERT INTO ticket_links (source_id, target_id, link_type)
+					  VALUES (?1, ?2, ?3)`, srcID, dstID, linkType)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if inverse, ok := models.InverseLinkType(linkType); ok {
+		_, err = tx.Exec(`INSERT INTO ticket_links (source_id, target_id, link_type)
+						  VALUES (?1, ?2, ?3)`, dstID, srcID, inverse)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// Unlink will remove the link of the given type from src to dst along with
+// it's inverse link if there is one.
+func (ts *TicketStore) Unlink(src, dst models.Ticket, linkType string) error {
+	if !models.IsValidLinkType(linkType) {
+		return models.ErrInvalidLinkType
+	}
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	srcID, err := ticketID(tx, src)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	dstID, err := ticketID(tx, dst)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM ticket_links 
+					  WHERE source_id = ?1 AND target_id = ?2 
+					  AND link_type = ?3`, srcID, dstID, linkType)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if inverse, ok := models.InverseLinkType(linkType); ok {
+		_, err = tx.Exec(`DELETE FROM ticket_links 
+						  WHERE source_id = ?1 AND target_id = ?2 
+						  AND link_type = ?3`, dstID, srcID, inverse)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// GetLinks will return all of the links from the given ticket to other
+// tickets
+func (ts *TicketStore) GetLinks(t models.Ticket) ([]models.TicketLink, error) {
+	var links []models.TicketLink
+
+	rows, err := ts.db.Query(`SELECT l.id, l.link_type, l.source_id, 
+									 l.target_id, target.key
+							  FROM ticket_links AS l
+							  JOIN tickets AS src ON src.id = l.source_id
+							  JOIN tickets AS target ON target.id = l.target_id
+							  WHERE src.id = ?1 OR src.key = ?2
+							  ORDER BY l.id`, t.ID, t.Key)
+	if err != nil {
+		return links, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.TicketLink
+
+		err = rows.Scan(&l.ID, &l.LinkType, &l.SourceID, &l.TargetID, &l.TargetKey)
+		if err != nil {
+			return links, handleSqliteErr(err)
+		}
+
+		links = append(links, l)
+	}
+
+	return links, nil
+}
+
+// transitionsFor will return the transitions available to a ticket in it's
+// current status from the workflows for it's project and type.
+func transitionsFor(q sqlExecutor, t models.Ticket) ([]models.Transition, error) {
+	var transitions []models.Transition
+
+	// SQLite takes the bare columns from the row with the MIN, so like
+	// DISTINCT ON this gives the first transition to each status.
+	rows, err := q.Query(`SELECT MIN(tr.id), tr.name, to_s.id, to_s.name
+						  FROM tickets AS t
+						  JOIN workflows AS w ON w.project_id = t.project_id
+						  JOIN transitions AS tr ON tr.workflow_id = w.id
+						  JOIN statuses AS to_s ON to_s.id = tr.to_status
+						  WHERE (t.id = ?1 OR t.key = ?2)
+						  AND tr.from_status = t.status_id
+						  AND (w.ticket_type_id IS NULL 
+							   OR w.ticket_type_id = t.ticket_type_id)
+						  GROUP BY to_s.id
+						  ORDER BY to_s.id`, t.ID, t.Key)
+	if err != nil {
+		return transitions, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var tr models.Transition
+
+		err = rows.Scan(&tr.ID, &tr.Name, &tr.ToStatus.ID, &tr.ToStatus.Name)
+		if err != nil {
+			return transitions, handleSqliteErr(err)
+		}
+
+		transitions = append(transitions, tr)
+	}
+
+	return transitions, handleSqliteErr(rows.Err())
+}
+
+// GetTransitions will return the transitions which are available to the
+// ticket from it's current status
+func (ts *TicketStore) GetTransitions(t models.Ticket) ([]models.Transition, error) {
+	return transitionsFor(ts.db, t)
+}
+
+// TransitionTicket will move the ticket to the given status, if the workflow
+// for the ticket does not allow moving from it's current status to toStatus
+// store.ErrInvalidTransition is returned.
+func (ts *TicketStore) TransitionTicket(t models.Ticket, toStatus models.Status) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = transitionTicket(tx, t, toStatus)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// TransitionBatch will move all of the tickets to toStatus, if any of the
+// transitions are invalid none of the tickets are moved.
+func (ts *TicketStore) TransitionBatch(tickets []models.Ticket, toStatus models.Status) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	for _, t := range tickets {
+		err = transitionTicket(tx, t, toStatus)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+func transitionTicket(tx *ctxTx, t models.Ticket, toStatus models.Status) error {
+	var from string
+
+	// The transaction holds the write lock so the ticket's status can't
+	// change between checking the transition and updating it.
+	err := tx.QueryRow(`SELECT t.id, s.name FROM tickets AS t
+						JOIN statuses AS s ON s.id = t.status_id
+						WHERE t.id = ?1 OR t.key = ?2`, t.ID, t.Key).
+		Scan(&t.ID, &from)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	transitions, err := transitionsFor(tx, t)
+	if err != nil {
+		return err
+	}
+
+	var to *models.Status
+	for _, tr := range transitions {
+		if (toStatus.ID != 0 && tr.ToStatus.ID == toStatus.ID) ||
+			(toStatus.ID == 0 && tr.ToStatus.Name == toStatus.Name) {
+			to = &tr.ToStatus
+			break
+		}
+	}
+
+	if to == nil {
+		return store.ErrInvalidTransition
+	}
+
+	_, err = tx.Exec(`UPDATE tickets SET (status_id, updated_date) = (?1, ?2)
+					  WHERE id = ?3`, to.ID, time.Now(), t.ID)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	return recordHistory(tx, t, "status", from, to.Name)
+}
+
+// AssignTicket will assign the ticket to the given user, or unassign it if
+// assignee is the zero user, and record the change in the ticket history.
+func (ts *TicketStore) AssignTicket(t models.Ticket, assignee models.User) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = assignTicket(tx, t, assignee)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+func assignTicket(tx *ctxTx, t models.Ticket, assignee models.User) error {
+	var from string
+
+	err := tx.QueryRow(`SELECT t.id, COALESCE(u.username, '') FROM tickets AS t
+						LEFT JOIN users AS u ON u.id = t.assignee_id
+						WHERE t.id = ?1 OR t.key = ?2`, t.ID, t.Key).
+		Scan(&t.ID, &from)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	if assignee.ID != 0 || assignee.Username != "" {
+		err = tx.QueryRow(`SELECT id, username FROM users
+						   WHERE (id = ?1 OR username = ?2) AND is_active`,
+			assignee.ID, assignee.Username).Scan(&assignee.ID, &assignee.Username)
+		if err != nil {
+			return handleSqliteErr(err)
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE tickets SET (assignee_id, updated_date, version)
+					  = (NULLIF(?1, 0), ?2, version + 1) WHERE id = ?3`,
+		assignee.ID, time.Now(), t.ID)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	return recordHistory(tx, t, "assignee", from, assignee.Username)
+}
+
+// LogWork will add a worklog for the ticket and update it's time spent and
+// remaining estimate in a single transaction.
+func (ts *TicketStore) LogWork(t models.Ticket, seconds int64, u models.User) error {
+	if seconds <= 0 {
+		return store.ErrInvalidDuration
+	}
+
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`UPDATE tickets SET 
+					   (time_spent, remaining_estimate, updated_date, version)
+					   = (time_spent + ?1, MAX(remaining_estimate - ?1, 0), ?2,
+						  version + 1)
+					   WHERE id = ?3 OR key = ?4
+					   RETURNING id`, seconds, time.Now(), t.ID, t.Key).Scan(&t.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO worklogs (seconds, ticket_id, author_id)
+					  VALUES (?1, ?2, ?3)`, seconds, t.ID, u.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// GetWorklogs will return the work logged on the ticket, oldest first
+func (ts *TicketStore) GetWorklogs(t models.Ticket) ([]models.Worklog, error) {
+	var worklogs []models.Worklog
+
+	rows, err := ts.db.Query(`SELECT wl.id, wl.created_date, wl.seconds,
+									 u.id, u.username, u.email, u.full_name,
+									 COALESCE(u.gravatar, ''),
+									 COALESCE(u.profile_picture, ''),
+									 COALESCE(u.is_admin, false)
+							  FROM worklogs AS wl
+							  JOIN tickets AS t ON t.id = wl.ticket_id
+							  JOIN users AS u ON u.id = wl.author_id
+							  WHERE t.id = ?1 OR t.key = ?2
+							  ORDER BY wl.created_date, wl.id`, t.ID, t.Key)
+	if err != nil {
+		return worklogs, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var wl models.Worklog
+		a := &wl.Author
+
+		err = rows.Scan(&wl.ID, &wl.CreatedDate, &wl.Seconds, &a.ID,
+			&a.Username, &a.Email, &a.FullName, &a.Gravatar, &a.ProfilePic,
+			&a.IsAdmin)
+		if err != nil {
+			return worklogs, handleSqliteErr(err)
+		}
+
+		worklogs = append(worklogs, wl)
+	}
+
+	return worklogs, handleSqliteErr(rows.Err())
+}
+
+// TotalTimeSpent will return the sum of the work logged on the ticket in
+// seconds, store.ErrNotFound is returned if the ticket doesn't exist.
+func (ts *TicketStore) TotalTimeSpent(t models.Ticket) (int64, error) {
+	var total int64
+
+	err := ts.db.QueryRow(`SELECT COALESCE(SUM(wl.seconds), 0) FROM tickets AS t
+						   LEFT JOIN worklogs AS wl ON wl.ticket_id = t.id
+						   WHERE t.id = ?1 OR t.key = ?2
+						   GROUP BY t.id`, t.ID, t.Key).Scan(&total)
+	return total, handleSqliteErr(err)
+}
+
+// AddAttachment will add the metadata for an attachment to the ticket, the
+// contents of the attachment should already be in a store.BlobStore under
+// a.StorageKey.
+func (ts *TicketStore) AddAttachment(t models.Ticket, a *models.Attachment) error {
+	err := ts.db.QueryRow(`INSERT INTO attachments 
+						   (filename, content_type, size, storage_key, 
+						   uploaded_by, ticket_id)
+						   SELECT ?1, ?2, ?3, ?4, NULLIF(?5, 0), t.id
+						   FROM tickets AS t
+						   WHERE t.id = ?6 OR t.key = ?7
+						   RETURNING id, created_date`,
+		a.Filename, a.ContentType, a.Size, a.StorageKey, a.UploadedBy.ID,
+		t.ID, t.Key).
+		Scan(&a.ID, &a.CreatedDate)
+
+	return handleSqliteErr(err)
+}
+
+// GetAttachments will return the metadata for all of the attachments on the
+// ticket
+func (ts *TicketStore) GetAttachments(t models.Ticket) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+
+	rows, err := ts.db.Query(`SELECT a.id, a.created_date, a.filename, 
+									 a.content_type, a.size, a.storage_key,
+									 COALESCE(u.id, 0), COALESCE(u.username, ''),
+									 COALESCE(u.email, ''),
+									 COALESCE(u.full_name, ''),
+									 COALESCE(u.gravatar, ''),
+									 COALESCE(u.profile_picture, ''),
+									 COALESCE(u.is_admin, false)
+							  FROM attachments AS a
+							  JOIN tickets AS t ON t.id = a.ticket_id
+							  LEFT JOIN users AS u ON u.id = a.uploaded_by
+							  WHERE t.id = ?1 OR t.key = ?2
+							  ORDER BY a.created_date, a.id`, t.ID, t.Key)
+	if err != nil {
+		return attachments, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.Attachment
+
+		err = rows.Scan(&a.ID, &a.CreatedDate, &a.Filename, &a.ContentType,
+			&a.Size, &a.StorageKey, &a.UploadedBy.ID, &a.UploadedBy.Username,
+			&a.UploadedBy.Email, &a.UploadedBy.FullName,
+			&a.UploadedBy.Gravatar, &a.UploadedBy.ProfilePic,
+			&a.UploadedBy.IsAdmin)
+		if err != nil {
+			return attachments, handleSqliteErr(err)
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	return attachments, handleSqliteErr(rows.Err())
+}
+
+// RemoveAttachment will remove the metadata for the attachment, removing the
+// contents from the store.BlobStore is left to the caller.
+func (ts *TicketStore) RemoveAttachment(a models.Attachment) error {
+	_, err := ts.db.Exec(`DELETE FROM attachments WHERE id = ?1`, a.ID)
+	return handleSqliteErr(err)
+}
+
+// NextTicketKey will return the key the next ticket created in the project
+// will get, the key is not reserved so it's only a preview. New assigns keys
+// itself.
+func (ts *TicketStore) NextTicketKey(p models.Project) string {
+	var count int
+
+	err := ts.db.QueryRow(`SELECT key, ticket_counter FROM projects
+						   WHERE id = ?1 OR key = ?2`, p.ID, p.Key).
+		Scan(&p.Key, &count)
+	if err != nil {
+		handleSqliteErr(err)
+		return p.Key + strconv.Itoa(1)
+	}
+
+	return p.Key + strconv.Itoa(count+1)
+}
+
+// GetCommentsForTickets will return the comments of the tickets with the
+// given ids keyed by ticket id using a single query, each ticket's comments
+// are ordered by when they were created.
+func (ts *TicketStore) GetCommentsForTickets(ids []int64) (map[int64][]models.Comment, error) {
+	comments := make(map[int64][]models.Comment)
+	if len(ids) == 0 {
+		return comments, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	params := make([]string, len(ids))
+	for i, id := range ids {
+		args[i] = id
+		params[i] = "?" + strconv.Itoa(i+1)
+	}
+
+	in := "(" + strings.Join(params, ", ") + ")"
+
+	rows, err := ts.db.Query(`SELECT c.ticket_id, c.id, c.created_date,
+									 c.updated_date, COALESCE(c.body, ''), `+
+		joinedUserColumns("users")+`
+							  FROM comments AS c
+							  JOIN users ON users.id = c.author_id
+							  WHERE c.ticket_id IN `+in+`
+							  ORDER BY c.created_date, c.id`, args...)
+	if err != nil {
+		return comments, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var tid int64
+		var c models.Comment
+		a := &c.Author
+
+		err := rows.Scan(&tid, &c.ID, &c.CreatedDate, &c.UpdatedDate, &c.Body,
+			&a.ID, &a.Username, &a.Password, &a.Email, &a.FullName, &a.Gravatar,
+			&a.ProfilePic, &a.IsAdmin, &a.IsActive, &a.EmailVerified,
+			&a.DisplayName, &a.AvatarURL, &a.Bio)
+		if err != nil {
+			return comments, handleSqliteErr(err)
+		}
+
+		comments[tid] = append(comments[tid], c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return comments, handleSqliteErr(err)
+	}
+
+	byID := make(map[int64]*models.Comment)
+	for tid := range comments {
+		for i := range comments[tid] {
+			byID[comments[tid][i].ID] = &comments[tid][i]
+		}
+	}
+
+	err = fillReactions(ts.db, byID, `SELECT cr.comment_id, cr.emoji, COUNT(cr.id)
+									  FROM comment_reactions AS cr
+									  JOIN comments AS c ON c.id = cr.comment_id
+									  WHERE c.ticket_id IN `+in+`
+									  GROUP BY cr.comment_id, cr.emoji`, args...)
+	return comments, handleSqliteErr(err)
+}
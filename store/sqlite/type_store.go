@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// TypeStore is used to store ticket types in a SQLite database
+type TypeStore struct {
+	db *ctxDB
+}
+
+// Get will get a ticket type by either name or id whichver is provided in tt
+func (ts *TypeStore) Get(tt *models.TicketType) error {
+	row := ts.db.QueryRow(`SELECT tt.id, tt.name
+						   FROM ticket_types AS tt
+						   WHERE tt.id = ?1
+						   OR tt.name = ?2`, tt.ID, tt.Name)
+	return handleSqliteErr(row.Scan(&tt.ID, &tt.Name))
+}
+
+// GetAll will return all ticket types from the database
+func (ts *TypeStore) GetAll() ([]models.TicketType, error) {
+	var typs []models.TicketType
+
+	rows, err := ts.db.Query(`SELECT tt.id, tt.name
+							  FROM ticket_types AS tt
+							  ORDER BY tt.id`)
+	if err != nil {
+		return typs, handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var tt models.TicketType
+
+		err = rows.Scan(&tt.ID, &tt.Name)
+		if err != nil {
+			return typs, handleSqliteErr(err)
+		}
+
+		typs = append(typs, tt)
+	}
+
+	return typs, handleSqliteErr(rows.Err())
+}
+
+// New will add a new TicketType to the SQLite DB
+func (ts *TypeStore) New(tt *models.TicketType) error {
+	row := ts.db.QueryRow(`INSERT INTO ticket_types (name)
+						   VALUES (?1)
+						   RETURNING id`, tt.Name)
+	return handleSqliteErr(row.Scan(&tt.ID))
+}
+
+// Save will update a TicketType in the SQLite DB, store.ErrNotFound is
+// returned if there is no type with it's ID.
+func (ts *TypeStore) Save(tt models.TicketType) error {
+	err := ts.db.QueryRow(`UPDATE ticket_types
+						   SET name = ?1
+						   WHERE id = ?2
+						   RETURNING id`, tt.Name, tt.ID).Scan(&tt.ID)
+	return handleSqliteErr(err)
+}
+
+// typeReferences count the rows using the ticket type with the id ?1, a type
+// can't be removed while any of them are above 0.
+var typeReferences = []string{
+	`SELECT COUNT(id) FROM tickets WHERE ticket_type_id = ?1`,
+	`SELECT COUNT(id) FROM workflows WHERE ticket_type_id = ?1`,
+	`SELECT COUNT(*) FROM field_tickettype_project WHERE ticket_type_id = ?1`,
+}
+
+// Remove removes a ticket type from the database, store.ErrInUse is returned
+// if any tickets, workflows or project fields use it.
+func (ts *TypeStore) Remove(tt models.TicketType) error {
+	tx, err := ts.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	for _, q := range typeReferences {
+		var c int
+
+		err = tx.QueryRow(q, tt.ID).Scan(&c)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+
+		if c > 0 {
+			tx.Rollback()
+			return store.ErrInUse
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM ticket_types WHERE id = ?1", tt.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
@@ -0,0 +1,530 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// UserStore contains methods for storing and retrieving Users from a SQLite
+// DB
+type UserStore struct {
+	db *ctxDB
+}
+
+// userColumns are the columns of users scanned by intoUser
+const userColumns = `id, username, password, email, full_name, gravatar,
+					 profile_picture, is_admin, is_active, email_verified,
+					 display_name, avatar_url, bio`
+
+// joinedUserColumns returns the columns scanned by intoUser for the users
+// table joined as alias, it takes the place of row_to_json in the postgres
+// queries. The password is never selected and a missing user from an outer
+// join scans as the zero user.
+func joinedUserColumns(alias string) string {
+	return `COALESCE(` + alias + `.id, 0), COALESCE(` + alias + `.username, ''), '',
+			COALESCE(` + alias + `.email, ''), COALESCE(` + alias + `.full_name, ''),
+			COALESCE(` + alias + `.gravatar, ''),
+			COALESCE(` + alias + `.profile_picture, ''),
+			COALESCE(` + alias + `.is_admin, false),
+			COALESCE(` + alias + `.is_active, false),
+			COALESCE(` + alias + `.email_verified, false),
+			COALESCE(` + alias + `.display_name, ''),
+			COALESCE(` + alias + `.avatar_url, ''), COALESCE(` + alias + `.bio, '')`
+}
+
+func intoUser(row rowScanner, u *models.User) error {
+	return row.Scan(&u.ID, &u.Username, &u.Password, &u.Email, &u.FullName,
+		&u.Gravatar, &u.ProfilePic, &u.IsAdmin, &u.IsActive, &u.EmailVerified,
+		&u.DisplayName, &u.AvatarURL, &u.Bio)
+}
+
+// Get retrieves the user by row id or username, users which have been
+// removed are not returned.
+func (s *UserStore) Get(u *models.User) error {
+	row := s.db.QueryRow(`SELECT `+userColumns+`
+						  FROM users
+						  WHERE (id = ?1 OR username = ?2)
+						  AND is_active`, u.ID, u.Username)
+
+	return handleSqliteErr(intoUser(row, u))
+}
+
+// GetByEmail retrieves the active user with the email, emails are compared
+// ignoring case.
+func (s *UserStore) GetByEmail(email string) (models.User, error) {
+	var u models.User
+
+	row := s.db.QueryRow(`SELECT `+userColumns+`
+						  FROM users
+						  WHERE lower(email) = lower(?1) AND is_active`, email)
+
+	return u, handleSqliteErr(intoUser(row, &u))
+}
+
+// GetAll retrieves all active users from the database.
+func (s *UserStore) GetAll() ([]models.User, error) {
+	return s.getAll(false)
+}
+
+// GetAllIncludingInactive retrieves all users from the database including
+// those which have been removed.
+func (s *UserStore) GetAllIncludingInactive() ([]models.User, error) {
+	return s.getAll(true)
+}
+
+func (s *UserStore) getAll(inactive bool) ([]models.User, error) {
+	rows, err := s.db.Query(`SELECT `+userColumns+`
+							 FROM users
+							 WHERE is_active OR ?1
+							 ORDER BY id`, inactive)
+	if err != nil {
+		return []models.User{}, handleSqliteErr(err)
+	}
+
+	return usersFromRows(rows)
+}
+
+// userOrderColumns maps the PageOptions.OrderBy values users can be ordered by
+// to their column
+var userOrderColumns = map[string]string{
+	"":         "id",
+	"id":       "id",
+	"username": "username",
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term, queries using it
+// must declare ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetAllFiltered returns a page of the active users whose username or display
+// name contains q, ignoring case, along with the total number of matches.
+// SQLite's LIKE ignores case for ASCII letters only.
+func (s *UserStore) GetAllFiltered(q string, opts store.PageOptions) ([]models.User, int, error) {
+	var total int
+
+	orderBy, ok := userOrderColumns[opts.OrderBy]
+	if !ok {
+		return nil, 0, store.ErrInvalidOrderBy
+	}
+
+	where := ` FROM users
+			   WHERE is_active
+			   AND (username LIKE ?1 ESCAPE '\' OR display_name LIKE ?1 ESCAPE '\')`
+	args := []interface{}{"%" + likeEscaper.Replace(q) + "%"}
+
+	err := s.db.QueryRow("SELECT COUNT(id)"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, handleSqliteErr(err)
+	}
+
+	limit, args := limitClause(opts, args)
+
+	rows, err := s.db.Query("SELECT "+userColumns+where+" ORDER BY "+orderBy+" "+
+		opts.Direction()+limit, args...)
+	if err != nil {
+		return nil, total, handleSqliteErr(err)
+	}
+
+	users, err := usersFromRows(rows)
+	return users, total, err
+}
+
+func usersFromRows(rows *sql.Rows) ([]models.User, error) {
+	users := []models.User{}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+
+		err := intoUser(rows, &u)
+		if err != nil {
+			return users, handleSqliteErr(err)
+		}
+
+		users = append(users, u)
+	}
+
+	return users, handleSqliteErr(rows.Err())
+}
+
+// Remove will deactivate the given user, the row is kept so the tickets and
+// comments for the user are not lost. Their refresh tokens are revoked so
+// they can't get new access tokens.
+func (s *UserStore) Remove(u models.User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_tokens SET used = true
+					  WHERE user_id IN (SELECT id FROM users
+										WHERE id = ?1 OR username = ?2)`,
+		u.ID, u.Username)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`UPDATE users
+					  SET is_active = false
+					  WHERE id = ?1
+					  OR username = ?2`, u.ID, u.Username)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// Save will update the given user into the database.
+func (s *UserStore) Save(u models.User) error {
+	if u.Password == "" {
+		_, err := s.db.Exec(`UPDATE users SET
+							 (username, email, full_name, is_admin,
+							  display_name, avatar_url, bio, email_verified)
+							 = (?1, ?2, ?3, ?4, ?5, ?6, ?7,
+								email_verified AND email = ?2) WHERE id = ?8`,
+			u.Username, u.Email, u.FullName, u.IsAdmin,
+			u.DisplayName, u.AvatarURL, u.Bio, u.ID)
+
+		return handleSqliteErr(err)
+	}
+
+	_, err := s.db.Exec(`UPDATE users SET
+						 (username, password, email, full_name, is_admin,
+						  display_name, avatar_url, bio, email_verified)
+						 = (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8,
+							email_verified AND email = ?3)
+						 WHERE id = ?9`,
+		u.Username, u.Password, u.Email, u.FullName, u.IsAdmin,
+		u.DisplayName, u.AvatarURL, u.Bio, u.ID)
+
+	return handleSqliteErr(err)
+}
+
+// New will create the user in the database
+func (s *UserStore) New(u *models.User) error {
+	err := s.db.QueryRow(`INSERT INTO users
+		(username, password, email, full_name, profile_picture, gravatar, is_admin,
+		 email_verified, display_name, avatar_url, bio)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+		RETURNING id`,
+		u.Username, u.Password, u.Email, u.FullName, u.ProfilePic,
+		u.Gravatar, u.IsAdmin, u.EmailVerified, u.DisplayName, u.AvatarURL,
+		u.Bio).
+		Scan(&u.ID)
+
+	return handleSqliteErr(err)
+}
+
+// CreatePasswordReset will store a hash of the given reset token for the user
+// which can be consumed once before expires.
+func (s *UserStore) CreatePasswordReset(u models.User, token string, expires time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO password_resets
+						 (user_id, token_hash, expires_date)
+						 VALUES (?1, ?2, ?3)`,
+		u.ID, store.HashToken(token), expires)
+
+	return handleSqliteErr(err)
+}
+
+// ConsumePasswordReset will mark the reset token for the user as used,
+// returning store.ErrInvalidToken if it does not exist or was already used
+// and store.ErrTokenExpired if it has expired.
+func (s *UserStore) ConsumePasswordReset(u models.User, token string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	var id int64
+	var used bool
+	var expires time.Time
+
+	// The expiry is compared in Go, SQLite compares timestamps as text so
+	// one written with a time zone would compare wrongly with
+	// current_timestamp.
+	err = tx.QueryRow(`SELECT id, used, expires_date
+					   FROM password_resets
+					   WHERE user_id = ?1 AND token_hash = ?2`,
+		u.ID, store.HashToken(token)).
+		Scan(&id, &used, &expires)
+	if err == sql.ErrNoRows || used {
+		tx.Rollback()
+		return store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	if expires.Before(time.Now()) {
+		tx.Rollback()
+		return store.ErrTokenExpired
+	}
+
+	_, err = tx.Exec(`UPDATE password_resets SET used = true WHERE id = ?1`, id)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// CreateEmailVerification will store a hash of the given verification token
+// for the user which can be used once before expires.
+func (s *UserStore) CreateEmailVerification(u models.User, token string, expires time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO email_verifications
+						 (user_id, token_hash, expires_date)
+						 VALUES (?1, ?2, ?3)`,
+		u.ID, store.HashToken(token), expires)
+
+	return handleSqliteErr(err)
+}
+
+// VerifyEmail will mark the email of the user the token was created for as
+// verified and return them, returning store.ErrInvalidToken if the token
+// does not exist or was already used and store.ErrTokenExpired if it has
+// expired.
+func (s *UserStore) VerifyEmail(token string) (models.User, error) {
+	var u models.User
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return u, handleSqliteErr(err)
+	}
+
+	var id int64
+	var used bool
+	var expires time.Time
+
+	err = tx.QueryRow(`SELECT id, user_id, used, expires_date
+					   FROM email_verifications
+					   WHERE token_hash = ?1`, store.HashToken(token)).
+		Scan(&id, &u.ID, &used, &expires)
+	if err == sql.ErrNoRows || used {
+		tx.Rollback()
+		return u, store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return u, handleSqliteErr(err)
+	}
+
+	if expires.Before(time.Now()) {
+		tx.Rollback()
+		return u, store.ErrTokenExpired
+	}
+
+	_, err = tx.Exec(`UPDATE email_verifications SET used = true WHERE id = ?1`, id)
+	if err != nil {
+		tx.Rollback()
+		return u, handleSqliteErr(err)
+	}
+
+	row := tx.QueryRow(`UPDATE users SET email_verified = true WHERE id = ?1
+						RETURNING `+userColumns, u.ID)
+
+	err = intoUser(row, &u)
+	if err != nil {
+		tx.Rollback()
+		return u, handleSqliteErr(err)
+	}
+
+	return u, handleSqliteErr(tx.Commit())
+}
+
+// CreateRefreshToken will store a hash of the refresh token for the user as
+// the first of a new family of tokens.
+func (s *UserStore) CreateRefreshToken(u models.User, token string, expires time.Time) error {
+	h := store.HashToken(token)
+
+	_, err := s.db.Exec(`INSERT INTO refresh_tokens
+						 (user_id, token_hash, family, expires_date)
+						 VALUES (?1, ?2, ?3, ?4)`,
+		u.ID, h, h, expires)
+
+	return handleSqliteErr(err)
+}
+
+// RotateRefreshToken will mark the token as used and store next in the same
+// family, returning the user the token belongs to. It returns
+// store.ErrInvalidToken if the token does not exist, store.ErrTokenExpired if
+// it has expired and store.ErrTokenReused, after revoking the family, if it
+// was already used.
+func (s *UserStore) RotateRefreshToken(token, next string, expires time.Time) (models.User, error) {
+	var u models.User
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return u, handleSqliteErr(err)
+	}
+
+	var id int64
+	var family string
+	var used bool
+	var expiry time.Time
+
+	err = tx.QueryRow(`SELECT id, user_id, family, used, expires_date
+					   FROM refresh_tokens
+					   WHERE token_hash = ?1`, store.HashToken(token)).
+		Scan(&id, &u.ID, &family, &used, &expiry)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return u, store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return u, handleSqliteErr(err)
+	}
+
+	if used {
+		// The revocation is committed so the thief can't use the token
+		// which replaced this one either.
+		_, err = tx.Exec(`UPDATE refresh_tokens SET used = true
+						  WHERE family = ?1`, family)
+		if err != nil {
+			tx.Rollback()
+			return u, handleSqliteErr(err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return u, handleSqliteErr(err)
+		}
+
+		return u, store.ErrTokenReused
+	}
+
+	if expiry.Before(time.Now()) {
+		tx.Rollback()
+		return u, store.ErrTokenExpired
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_tokens SET used = true WHERE id = ?1`, id)
+	if err != nil {
+		tx.Rollback()
+		return u, handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO refresh_tokens
+					  (user_id, token_hash, family, expires_date)
+					  VALUES (?1, ?2, ?3, ?4)`,
+		u.ID, store.HashToken(next), family, expires)
+	if err != nil {
+		tx.Rollback()
+		return u, handleSqliteErr(err)
+	}
+
+	// Removed users can't get new tokens.
+	row := tx.QueryRow(`SELECT `+userColumns+` FROM users
+						WHERE id = ?1 AND is_active`, u.ID)
+
+	err = intoUser(row, &u)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.User{}, store.ErrInvalidToken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return u, handleSqliteErr(err)
+	}
+
+	return u, handleSqliteErr(tx.Commit())
+}
+
+// RevokeRefreshToken will mark every token in the family of token as used,
+// returning store.ErrInvalidToken if the token does not exist or belongs to
+// another user.
+func (s *UserStore) RevokeRefreshToken(u models.User, token string) error {
+	res, err := s.db.Exec(`UPDATE refresh_tokens SET used = true
+						   WHERE family = (SELECT family FROM refresh_tokens
+										   WHERE token_hash = ?1
+										   AND user_id = ?2)`,
+		store.HashToken(token), u.ID)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	if n == 0 {
+		return store.ErrInvalidToken
+	}
+
+	return nil
+}
+
+// LoginLockedUntil will return when the last lockout of the user ends, the
+// zero time if they have never been locked out.
+func (s *UserStore) LoginLockedUntil(u models.User) (time.Time, error) {
+	var until sql.NullTime
+
+	err := s.db.QueryRow(`SELECT locked_until FROM login_attempts
+						  WHERE user_id = ?1`, u.ID).
+		Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, handleSqliteErr(err)
+	}
+
+	return until.Time, nil
+}
+
+// RecordFailedLogin will count a failed login for the user, locking them out
+// until lockUntil and starting the count again on the attempts'th failure.
+func (s *UserStore) RecordFailedLogin(u models.User, attempts int, lockUntil time.Time) (time.Time, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return time.Time{}, handleSqliteErr(err)
+	}
+
+	var failures int
+
+	err = tx.QueryRow(`INSERT INTO login_attempts (user_id, failures)
+					   VALUES (?1, 1)
+					   ON CONFLICT (user_id) DO UPDATE
+					   SET failures = login_attempts.failures + 1
+					   RETURNING failures`, u.ID).
+		Scan(&failures)
+	if err != nil {
+		tx.Rollback()
+		return time.Time{}, handleSqliteErr(err)
+	}
+
+	if failures < attempts {
+		return time.Time{}, handleSqliteErr(tx.Commit())
+	}
+
+	_, err = tx.Exec(`UPDATE login_attempts SET failures = 0, locked_until = ?2
+					  WHERE user_id = ?1`, u.ID, lockUntil)
+	if err != nil {
+		tx.Rollback()
+		return time.Time{}, handleSqliteErr(err)
+	}
+
+	return lockUntil, handleSqliteErr(tx.Commit())
+}
+
+// ResetFailedLogins will clear the failed logins counted for the user
+func (s *UserStore) ResetFailedLogins(u models.User) error {
+	_, err := s.db.Exec(`UPDATE login_attempts SET failures = 0
+						 WHERE user_id = ?1`, u.ID)
+	return handleSqliteErr(err)
+}
@@ -0,0 +1,281 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/praelatus/backend/models"
+)
+
+// WorkflowStore contains methods for saving/retrieving workflows from a
+// SQLite DB
+type WorkflowStore struct {
+	db *ctxDB
+}
+
+// Get gets a workflow from the database
+func (ws *WorkflowStore) Get(w *models.Workflow) error {
+	row := ws.db.QueryRow(`SELECT w.id, w.name, COALESCE(w.ticket_type_id, 0)
+						   FROM workflows AS w
+						   JOIN projects AS p ON w.project_id = p.id
+						   WHERE w.id = ?1 OR w.name = ?2`, w.ID, w.Name)
+
+	err := row.Scan(&w.ID, &w.Name, &w.TicketType.ID)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = ws.getTransitions(w)
+	return handleSqliteErr(err)
+}
+
+func (ws *WorkflowStore) getHooks(t *models.Transition) error {
+	rows, err := ws.db.Query(`SELECT h.id, COALESCE(endpoint, ''),
+									 COALESCE(method, ''), COALESCE(body, '')
+							  FROM hooks AS h
+							  WHERE h.transition_id = ?1
+							  ORDER BY h.id`, t.ID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var h models.Hook
+
+		err = rows.Scan(&h.ID, &h.Endpoint, &h.Method, &h.Body)
+		if err != nil {
+			return err
+		}
+
+		t.Hooks = append(t.Hooks, h)
+	}
+
+	return rows.Err()
+}
+
+func (ws *WorkflowStore) getTransitions(w *models.Workflow) error {
+	rows, err := ws.db.Query(`SELECT t.id, t.name, from_s.name,
+									 to_s.id, to_s.name
+							  FROM transitions AS t
+							  JOIN statuses AS from_s ON from_s.id = t.from_status
+							  JOIN statuses AS to_s ON to_s.id = t.to_status
+							  WHERE t.workflow_id = ?1
+							  ORDER BY t.id`, w.ID)
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	defer rows.Close()
+
+	if w.Transitions == nil {
+		w.Transitions = make(map[string][]models.Transition, 0)
+	}
+
+	for rows.Next() {
+		var t models.Transition
+		var fromStatus string
+
+		err = rows.Scan(&t.ID, &t.Name, &fromStatus, &t.ToStatus.ID,
+			&t.ToStatus.Name)
+		if err != nil {
+			return handleSqliteErr(err)
+		}
+
+		err = ws.getHooks(&t)
+		if err != nil {
+			return handleSqliteErr(err)
+		}
+
+		w.Transitions[fromStatus] = append(w.Transitions[fromStatus], t)
+	}
+
+	return handleSqliteErr(rows.Err())
+}
+
+func workflowsFromRows(rows *sql.Rows, ws *WorkflowStore) ([]models.Workflow, error) {
+	var workflows []models.Workflow
+
+	defer rows.Close()
+
+	for rows.Next() {
+		w := models.Workflow{}
+
+		err := rows.Scan(&w.ID, &w.Name, &w.TicketType.ID)
+		if err != nil {
+			return workflows, handleSqliteErr(err)
+		}
+
+		err = ws.getTransitions(&w)
+		if err != nil {
+			return workflows, handleSqliteErr(err)
+		}
+
+		workflows = append(workflows, w)
+	}
+
+	return workflows, handleSqliteErr(rows.Err())
+}
+
+// GetAll gets all the workflows from the database
+func (ws *WorkflowStore) GetAll() ([]models.Workflow, error) {
+	rows, err := ws.db.Query(`SELECT id, name, COALESCE(ticket_type_id, 0)
+							  FROM workflows
+							  ORDER BY id`)
+	if err != nil {
+		return nil, handleSqliteErr(err)
+	}
+
+	return workflowsFromRows(rows, ws)
+}
+
+// GetByProject gets all the workflows for the given project
+func (ws *WorkflowStore) GetByProject(p models.Project) ([]models.Workflow, error) {
+	rows, err := ws.db.Query(`SELECT w.id, w.name, COALESCE(w.ticket_type_id, 0)
+							  FROM workflows AS w
+							  JOIN projects AS p ON p.id = w.project_id
+							  WHERE p.id = ?1
+							  OR p.key = ?2
+							  ORDER BY w.id`, p.ID, p.Key)
+	if err != nil {
+		return []models.Workflow{}, handleSqliteErr(err)
+	}
+
+	return workflowsFromRows(rows, ws)
+}
+
+// New creates a new workflow in the database
+func (ws *WorkflowStore) New(p models.Project, workflow *models.Workflow) error {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	err = tx.QueryRow(`INSERT INTO workflows
+					   (name, project_id, ticket_type_id)
+					   VALUES (?1, ?2, NULLIF(?3, 0))
+					   RETURNING id`,
+		workflow.Name, p.ID, workflow.TicketType.ID).
+		Scan(&workflow.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	for fromStatus, transitions := range workflow.Transitions {
+		var fromID int64
+
+		err = tx.QueryRow(`SELECT id FROM statuses WHERE name = ?1`, fromStatus).
+			Scan(&fromID)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+
+		for _, t := range transitions {
+			err = tx.QueryRow(`INSERT INTO transitions
+							   (name, workflow_id, from_status, to_status)
+							   VALUES (?1, ?2, ?3, ?4)
+							   RETURNING id`, t.Name, workflow.ID, fromID, t.ToStatus.ID).
+				Scan(&t.ID)
+			if err != nil {
+				tx.Rollback()
+				return handleSqliteErr(err)
+			}
+
+			for _, h := range t.Hooks {
+				_, err = tx.Exec(`INSERT INTO hooks
+								  (endpoint, method, body, transition_id)
+								  VALUES (?1, ?2, ?3, ?4)`,
+					h.Endpoint, h.Method, h.Body, t.ID)
+				if err != nil {
+					tx.Rollback()
+					return handleSqliteErr(err)
+				}
+			}
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// Save updates a workflow in the database
+func (ws *WorkflowStore) Save(w models.Workflow) error {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`UPDATE workflows SET (name, ticket_type_id)
+					  = (?1, NULLIF(?2, 0)) WHERE id = ?3`,
+		w.Name, w.TicketType.ID, w.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	for fromStatus, transitions := range w.Transitions {
+		var fromID int64
+
+		err = tx.QueryRow(`SELECT id FROM statuses WHERE name = ?1`, fromStatus).
+			Scan(&fromID)
+		if err != nil {
+			tx.Rollback()
+			return handleSqliteErr(err)
+		}
+
+		for _, t := range transitions {
+			_, err = tx.Exec(`UPDATE transitions SET
+							  (name, workflow_id, from_status, to_status)
+							  = (?1, ?2, ?3, ?4)
+							  WHERE id = ?5`, t.Name, w.ID, fromID, t.ToStatus.ID, t.ID)
+			if err != nil {
+				tx.Rollback()
+				return handleSqliteErr(err)
+			}
+
+			for _, h := range t.Hooks {
+				_, err = tx.Exec(`UPDATE hooks SET
+								  (endpoint, method, body, transition_id)
+								  = (?1, ?2, ?3, ?4)
+								  WHERE id = ?5`, h.Endpoint, h.Method, h.Body, t.ID, h.ID)
+				if err != nil {
+					tx.Rollback()
+					return handleSqliteErr(err)
+				}
+			}
+		}
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
+
+// Remove removes a workflow from the database
+func (ws *WorkflowStore) Remove(w models.Workflow) error {
+	tx, err := ws.db.Begin()
+	if err != nil {
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM hooks
+					  WHERE transition_id
+					  IN (SELECT id FROM transitions WHERE workflow_id = ?1)`, w.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM transitions WHERE workflow_id = ?1`, w.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM workflows WHERE id = ?1`, w.ID)
+	if err != nil {
+		tx.Rollback()
+		return handleSqliteErr(err)
+	}
+
+	return handleSqliteErr(tx.Commit())
+}
@@ -1,8 +1,14 @@
 package store
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/praelatus/backend/models"
 )
@@ -10,11 +16,245 @@ import (
 var (
 	// ErrDuplicateEntry is returned when a unique constraint is violated.
 	ErrDuplicateEntry = errors.New("duplicate entry attempted")
+	// ErrInvalidReference is returned when a model refers to another, such as
+	// a ticket's assignee, which doesn't exist.
+	ErrInvalidReference = errors.New("referenced resource does not exist")
+	// ErrMissingField is returned when a model is saved without a value the
+	// database requires.
+	ErrMissingField = errors.New("required value is missing")
 	// ErrNotFound is returned when an invalid resource is given or searched
 	// for
 	ErrNotFound = errors.New("no such resource")
+	// ErrInvalidOrderBy is returned when results are requested in an order
+	// the store does not support.
+	ErrInvalidOrderBy = errors.New("invalid order by column")
+	// ErrInvalidDirection is returned when a sort direction is neither asc
+	// nor desc.
+	ErrInvalidDirection = errors.New("invalid sort direction, must be asc or desc")
+	// ErrInvalidTransition is returned when a ticket is moved to a status the
+	// workflow for the ticket does not allow
+	ErrInvalidTransition = errors.New("invalid transition for ticket")
+	// ErrInvalidToken is returned when a token does not exist or has already
+	// been used.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned when a token is used after it's expiry.
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrTokenReused is returned when a refresh token which has already
+	// been rotated is used again, every token rotated from the same login is
+	// revoked when it happens.
+	ErrTokenReused = errors.New("refresh token has already been used")
+	// ErrInvalidParent is returned when a ticket's parent is not another
+	// ticket in the same project or is one of the ticket's own subtasks.
+	ErrInvalidParent = errors.New("parent ticket must be in the same project and not a subtask of the ticket")
+	// ErrHasChildren is returned when removing a ticket which still has
+	// child tickets.
+	ErrHasChildren = errors.New("ticket has child tickets")
+	// ErrRemoveLead is returned when removing the lead of a team from it's
+	// members.
+	ErrRemoveLead = errors.New("the team lead can not be removed from the team")
+
+	// ErrStaleObject is returned when saving a ticket which has been changed
+	// since the version being saved was read.
+	ErrStaleObject = errors.New("ticket has been changed since it was read")
+
+	// ErrMissingRequiredField is returned when creating a ticket without a
+	// value for a field that is required for it's project and ticket type.
+	ErrMissingRequiredField = errors.New("ticket is missing a required field")
+
+	// ErrProjectHasTickets is returned when removing a project which still has
+	// tickets without cascading the removal to them.
+	ErrProjectHasTickets = errors.New("project has tickets")
+
+	// ErrProjectArchived is returned when creating tickets in an archived
+	// project.
+	ErrProjectArchived = errors.New("project is archived")
+
+	// ErrInvalidDuration is returned when logging work which isn't a positive
+	// number of seconds or when a ticket's estimates are negative.
+	ErrInvalidDuration = errors.New("invalid duration")
+
+	// ErrInvalidPriority is returned when a ticket's priority isn't one of
+	// the priorities defined in models.
+	ErrInvalidPriority = errors.New("invalid priority")
+
+	// ErrNoMembers is returned when suggesting an assignee for a project
+	// which has no active members.
+	ErrNoMembers = errors.New("project has no active members")
+
+	// ErrInUse is returned when removing something which is still referenced,
+	// such as a ticket type which tickets or workflows use.
+	ErrInUse = errors.New("that is currently in use, refusing to delete")
 )
 
+// ReferenceError is returned when a model refers to another which doesn't
+// exist, Field names the reference such as assignee or status. It matches
+// ErrInvalidReference with errors.Is, Err is the database's error if there
+// was one.
+type ReferenceError struct {
+	Field string
+	Err   error
+}
+
+func (e ReferenceError) Error() string {
+	return "the " + e.Field + " does not exist"
+}
+
+// Unwrap returns ErrInvalidReference along with Err
+func (e ReferenceError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrInvalidReference}
+	}
+
+	return []error{ErrInvalidReference, e.Err}
+}
+
+// ResolveMentions will look up the users mentioned in the body of a comment,
+// usernames which don't belong to a user are ignored.
+func ResolveMentions(users UserStore, body string) ([]models.User, error) {
+	var mentioned []models.User
+
+	for _, username := range models.ExtractMentions(body) {
+		u := models.User{Username: username}
+
+		err := users.Get(&u)
+		if err == ErrNotFound {
+			continue
+		}
+
+		if err != nil {
+			return mentioned, err
+		}
+
+		u.Password = ""
+		mentioned = append(mentioned, u)
+	}
+
+	return mentioned, nil
+}
+
+// HashToken returns the hash of a token which stores should persist instead
+// of the token itself.
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// ValidateTicket returns ErrInvalidDuration if either of the ticket's
+// estimates is negative and ErrInvalidPriority if it's priority is unknown.
+func ValidateTicket(t models.Ticket) error {
+	if t.OriginalEstimate < 0 || t.RemainingEstimate < 0 {
+		return ErrInvalidDuration
+	}
+
+	if !t.Priority.Valid() {
+		return ErrInvalidPriority
+	}
+
+	return nil
+}
+
+// HistoryDate formats a date for the ticket history, the zero time is
+// recorded as no value.
+func HistoryDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// HasRequiredFields reports whether the ticket has a value for every field
+// which is required, fields with a nil value count as missing.
+func HasRequiredFields(t models.Ticket, fields []models.Field) bool {
+	for _, f := range fields {
+		if !f.Required {
+			continue
+		}
+
+		found := false
+		for _, fv := range t.Fields {
+			if fv.Name == f.Name && fv.Value != nil {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PageOptions is used to request a single page of results from a store.
+type PageOptions struct {
+	// Limit is the maximum number of results to return, 0 means no limit.
+	Limit int
+	// Offset is the number of results to skip.
+	Offset int
+	// OrderBy is the name of the column to order the results by, if empty
+	// the results are ordered by ID. Tickets can also be ordered by the value
+	// of a custom field with "field:" followed by the field's name.
+	OrderBy string
+	// Desc reverses the order of the results.
+	Desc bool
+}
+
+// FieldOrderPrefix is the prefix of a PageOptions.OrderBy which orders tickets
+// by a custom field.
+const FieldOrderPrefix = "field:"
+
+// OrderField returns the name of the custom field orderBy orders tickets by,
+// ok is false if it does not order by a custom field.
+func OrderField(orderBy string) (name string, ok bool) {
+	if !strings.HasPrefix(orderBy, FieldOrderPrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(orderBy, FieldOrderPrefix), true
+}
+
+// Direction returns the SQL keyword for the order requested by o.
+func (o PageOptions) Direction() string {
+	if o.Desc {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// SortDirection returns the SQL keyword for the sort direction dir, which must
+// be asc or desc in either case. An empty dir is DESC so lists come back
+// newest first.
+func SortDirection(dir string) (string, error) {
+	switch strings.ToLower(dir) {
+	case "", "desc":
+		return "DESC", nil
+	case "asc":
+		return "ASC", nil
+	}
+
+	return "", ErrInvalidDirection
+}
+
+// TicketFilter is used to select tickets by multiple criteria, only the
+// fields which are set are filtered on.
+type TicketFilter struct {
+	ProjectKey       string `json:"project_key,omitempty"`
+	StatusID         int64  `json:"status_id,omitempty"`
+	TypeID           int64  `json:"type_id,omitempty"`
+	AssigneeUsername string `json:"assignee_username,omitempty"`
+}
+
+// SavedFilter is a TicketFilter a user has saved under a name so it can be
+// run again later, names are unique for each user.
+type SavedFilter struct {
+	ID     int64        `json:"id"`
+	Name   string       `json:"name"`
+	Filter TicketFilter `json:"filter"`
+}
+
 // Store is an interface for storing and retrieving models.
 type Store interface {
 	Users() UserStore
@@ -26,6 +266,15 @@ type Store interface {
 	Projects() ProjectStore
 	Statuses() StatusStore
 	Workflows() WorkflowStore
+
+	// WithContext returns a Store whose operations are cancelled when ctx
+	// is, it should be used with the context of the request being handled.
+	WithContext(context.Context) Store
+
+	// WithTx runs fn with a Store whose operations are all made in one
+	// transaction, it's committed if fn returns nil and rolled back
+	// otherwise. The error returned by fn is returned as is.
+	WithTx(fn func(tx Store) error) error
 }
 
 // SQLStore is an interface for a sql store so we can request direct
@@ -40,38 +289,143 @@ type Cache interface {
 	Set(string, interface{}) error
 }
 
+// BlobStore is an abstraction over storing the contents of files such as
+// ticket attachments, models only keep the key for a blob.
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Remove(key string) error
+}
+
 // FieldStore contains methods for storing and retrieving Fields and FieldValues
 type FieldStore interface {
 	Get(*models.Field) error
 	GetAll() ([]models.Field, error)
 
 	GetByProject(models.Project) ([]models.Field, error)
+	GetFieldsForType(models.Project, models.TicketType) ([]models.Field, error)
 	AddToProject(project models.Project, field *models.Field,
 		ticketTypes ...models.TicketType) error
 
 	New(*models.Field) error
 	Save(models.Field) error
 	Remove(models.Field) error
+
+	// GetFieldOptions returns the options of an OPT or MULTI_OPT field in the
+	// order they were added.
+	GetFieldOptions(fieldID int64) ([]string, error)
+	// AddFieldOption adds an option to the field, options are unique per
+	// field so adding one twice returns ErrDuplicateEntry.
+	AddFieldOption(fieldID int64, option string) error
+	// RemoveFieldOption removes an option from the field, ErrInUse is
+	// returned if any ticket has it selected.
+	RemoveFieldOption(fieldID int64, option string) error
 }
 
 // UserStore contains methods for storing and retrieving Users
 type UserStore interface {
 	Get(*models.User) error
 	GetAll() ([]models.User, error)
+	GetAllIncludingInactive() ([]models.User, error)
+
+	// GetByEmail returns the active user with the email, ignoring case.
+	GetByEmail(email string) (models.User, error)
+
+	// GetAllFiltered returns a page of the active users whose username or
+	// display name contains q, ignoring case, along with the total number of
+	// matching users.
+	GetAllFiltered(q string, opts PageOptions) ([]models.User, int, error)
 
 	New(*models.User) error
+
+	// Save never verifies the user's email, changing it makes the user
+	// unverified until the new address is verified.
 	Save(models.User) error
 	Remove(models.User) error
+
+	CreatePasswordReset(u models.User, token string, expires time.Time) error
+	ConsumePasswordReset(u models.User, token string) error
+
+	CreateEmailVerification(u models.User, token string, expires time.Time) error
+	VerifyEmail(token string) (models.User, error)
+
+	// CreateRefreshToken stores a hash of the refresh token for the user
+	// which starts a new family of tokens, one for each login.
+	CreateRefreshToken(u models.User, token string, expires time.Time) error
+
+	// RotateRefreshToken marks token as used and stores next in it's place
+	// until expires, returning the user it belongs to. Using a token which
+	// was already rotated revokes it's whole family and returns
+	// ErrTokenReused since it has most likely been stolen.
+	RotateRefreshToken(token, next string, expires time.Time) (models.User, error)
+
+	// RevokeRefreshToken revokes every token in the family of token, which
+	// must belong to u.
+	RevokeRefreshToken(u models.User, token string) error
+
+	// LoginLockedUntil returns when the last lockout of the user ends, the
+	// zero time if they have never been locked out.
+	LoginLockedUntil(u models.User) (time.Time, error)
+
+	// RecordFailedLogin counts a failed login for the user, on the
+	// attempts'th failure the user is locked out until lockUntil and their
+	// count starts again. It returns when the user is locked out until, the
+	// zero time if this failure didn't lock them out.
+	RecordFailedLogin(u models.User, attempts int, lockUntil time.Time) (time.Time, error)
+
+	// ResetFailedLogins clears the failed logins counted for the user.
+	ResetFailedLogins(u models.User) error
 }
 
 // ProjectStore contains methods for storing and retrieving Projects
 type ProjectStore interface {
 	Get(*models.Project) error
+
+	// GetAll returns every project which isn't archived,
+	// GetAllIncludingArchived returns archived projects too.
 	GetAll() ([]models.Project, error)
+	GetAllIncludingArchived() ([]models.Project, error)
+
+	// GetProjectForTicket returns the project the ticket with the given key
+	// belongs to, ErrNotFound is returned if there is no such ticket.
+	GetProjectForTicket(key string) (models.Project, error)
 
 	New(*models.Project) error
+
+	// Save updates the project, it doesn't change whether the project is
+	// archived.
 	Save(models.Project) error
-	Remove(models.Project) error
+
+	// ArchiveProject will archive the project so it's left out of GetAll
+	// and new tickets can't be created in it, UnarchiveProject reverses
+	// it. ErrNotFound is returned if there is no such project.
+	ArchiveProject(p models.Project) error
+	UnarchiveProject(p models.Project) error
+
+	// Remove will remove the project, if cascade is false and the project has
+	// tickets ErrProjectHasTickets is returned instead.
+	Remove(project models.Project, cascade bool) error
+
+	// AddMember will add the user to the project with the given role, if the
+	// user is already a member their role is changed instead.
+	AddMember(p models.Project, u models.User, role models.PermissionLevel) error
+	RemoveMember(p models.Project, u models.User) error
+	GetMembers(p models.Project) ([]models.ProjectMember, error)
+
+	// SuggestAssignee returns the active member of the project with the
+	// fewest open tickets assigned to them in any unarchived project, the
+	// earliest created on a tie. ErrNotFound is returned if the project
+	// doesn't exist and ErrNoMembers if it has no active members.
+	SuggestAssignee(p models.Project) (models.User, error)
+
+	// GetRole returns the role of the user in the project, ErrNotFound is
+	// returned if the user is not a member.
+	GetRole(p models.Project, u models.User) (models.PermissionLevel, error)
+
+	// GetRoles returns the roles of the user in every project they are a
+	// member or the lead of by the project's key, the lead of a project is
+	// an admin of it. It's empty if they are in none.
+	GetRoles(u models.User) (map[string]models.PermissionLevel, error)
 }
 
 // TypeStore is used to save and retrieve Ticket Types
@@ -86,18 +440,123 @@ type TypeStore interface {
 
 // TicketStore contains methods for storing and retrieving Tickets
 type TicketStore interface {
+	// Get gets the ticket by it's ID or, when the ID is 0, it's key.
 	Get(*models.Ticket) error
+
+	// GetByKey gets the ticket with the key, only the key is used to find
+	// it. ErrNotFound is returned if there is no such ticket.
+	GetByKey(key string) (models.Ticket, error)
+
+	// GetAll, GetAllPaged, Search, GetFiltered, GetOverdue and
+	// GetRecentlyUpdated leave out the tickets of archived projects, they
+	// are still returned by Get and the other project scoped reads.
 	GetAll() ([]models.Ticket, error)
-	GetAllByProject(models.Project) ([]models.Ticket, error)
 
+	// GetAllByProject returns the project's tickets ordered by their
+	// created date in the direction dir, as accepted by SortDirection.
+	GetAllByProject(p models.Project, dir string) ([]models.Ticket, error)
+
+	GetAllPaged(PageOptions) ([]models.Ticket, int, error)
+	GetAllByProjectPaged(models.Project, PageOptions) ([]models.Ticket, int, error)
+
+	Search(query string, p models.Project) ([]models.Ticket, error)
+	GetFiltered(TicketFilter) ([]models.Ticket, error)
+
+	// SaveFilter saves the filter for the user, replacing the user's filter
+	// with the same name if there is one. ErrMissingField is returned if the
+	// filter has no name.
+	SaveFilter(u models.User, f *SavedFilter) error
+	GetFilters(u models.User) ([]SavedFilter, error)
+	DeleteFilter(u models.User, name string) error
+
+	// RunFilter returns the tickets matching the user's filter with the given
+	// name, ErrNotFound is returned if the user has no such filter.
+	RunFilter(name string, u models.User) ([]models.Ticket, error)
+	GetChildren(models.Ticket) ([]models.Ticket, error)
+	GetByAssignee(models.User) ([]models.Ticket, error)
+	GetByReporter(models.User) ([]models.Ticket, error)
+	CountByStatus(models.Project) (map[string]int, error)
+
+	// CountAllByStatus returns CountByStatus for every project, including
+	// archived ones, keyed by the project's key. Projects without tickets
+	// or workflows are left out.
+	CountAllByStatus() (map[string]map[string]int, error)
+
+	// GetOverdue returns the project's tickets which are past their due date
+	// and not in a closed status, the longest overdue first.
+	GetOverdue(models.Project) ([]models.Ticket, error)
+
+	// GetRecentlyUpdated returns up to limit of the project's tickets, the
+	// most recently updated first. A limit of 0 returns all of them.
+	GetRecentlyUpdated(p models.Project, limit int) ([]models.Ticket, error)
+
+	GetComment(*models.Comment) error
 	GetComments(models.Ticket) ([]models.Comment, error)
+	GetCommentsPaged(models.Ticket, PageOptions) ([]models.Comment, int, error)
+
+	// GetCommentsForTickets returns the comments of each of the tickets
+	// with the given ids keyed by ticket id, ordered by when they were
+	// created. Tickets without comments are left out.
+	GetCommentsForTickets(ids []int64) (map[int64][]models.Comment, error)
+	GetCommentHistory(models.Comment) ([]models.CommentRevision, error)
+	AddReaction(c models.Comment, u models.User, emoji string) error
+	RemoveReaction(c models.Comment, u models.User, emoji string) error
+	GetReactions(models.Comment) (map[string]int, error)
+
+	// NewComment adds the comment to the ticket with the ID or, if it has
+	// none, the key of the given ticket.
 	NewComment(models.Ticket, *models.Comment) error
 	SaveComment(models.Comment) error
 	RemoveComment(models.Comment) error
 
+	AddLabel(models.Ticket, models.Label) error
+	RemoveLabel(models.Ticket, models.Label) error
+	GetLabels(models.Ticket) ([]models.Label, error)
+
+	AddWatcher(models.Ticket, models.User) error
+	RemoveWatcher(models.Ticket, models.User) error
+	GetWatchers(models.Ticket) ([]models.User, error)
+
+	LinkTickets(src, dst models.Ticket, linkType string) error
+	Unlink(src, dst models.Ticket, linkType string) error
+	GetLinks(models.Ticket) ([]models.TicketLink, error)
+
+	GetTransitions(models.Ticket) ([]models.Transition, error)
+	TransitionTicket(models.Ticket, models.Status) error
+
+	// TransitionBatch will move all of the tickets to toStatus in a single
+	// transaction, if the move isn't allowed for any of them none are moved
+	// and ErrInvalidTransition is returned.
+	TransitionBatch(tickets []models.Ticket, toStatus models.Status) error
+
+	// AssignTicket will assign the ticket to the active user matching
+	// assignee's ID or Username, a zero assignee unassigns the ticket.
+	// ErrNotFound is returned if the ticket or assignee doesn't exist.
+	AssignTicket(t models.Ticket, assignee models.User) error
+
+	// LogWork records seconds of work by u on the ticket, adding it to the
+	// ticket's TimeSpent and taking it off RemainingEstimate, which never
+	// goes below 0. ErrInvalidDuration is returned if seconds isn't positive.
+	LogWork(t models.Ticket, seconds int64, u models.User) error
+
+	// GetWorklogs returns the work logged on the ticket oldest first, with
+	// it's author.
+	GetWorklogs(models.Ticket) ([]models.Worklog, error)
+
+	// TotalTimeSpent returns the sum of the work logged on the ticket in
+	// seconds.
+	TotalTimeSpent(models.Ticket) (int64, error)
+
+	GetHistory(models.Ticket) ([]models.HistoryEntry, error)
+
+	AddAttachment(models.Ticket, *models.Attachment) error
+	GetAttachments(models.Ticket) ([]models.Attachment, error)
+	RemoveAttachment(models.Attachment) error
+
 	NextTicketKey(models.Project) string
 
 	New(models.Project, *models.Ticket) error
+	NewBatch(models.Project, []*models.Ticket) error
 	Save(models.Ticket) error
 	Remove(models.Ticket) error
 }
@@ -109,6 +568,9 @@ type TeamStore interface {
 	GetForUser(models.User) ([]models.Team, error)
 
 	AddMembers(models.Team, ...models.User) error
+	AddMember(models.Team, models.User) error
+	RemoveMember(models.Team, models.User) error
+	GetMembers(models.Team) ([]models.User, error)
 
 	New(*models.Team) error
 	Save(models.Team) error
@@ -120,9 +582,16 @@ type StatusStore interface {
 	Get(*models.Status) error
 	GetAll() ([]models.Status, error)
 
+	// GetAllOrdered returns the statuses in board order, by their Position
+	GetAllOrdered() ([]models.Status, error)
+
 	New(*models.Status) error
 	Save(models.Status) error
 	Remove(models.Status) error
+
+	// Reorder sets the Position of each status to it's index in ids, any
+	// statuses not in ids are kept in order after them.
+	Reorder(ids []int64) error
 }
 
 // WorkflowStore contains methods for storing and retrieving Workflows
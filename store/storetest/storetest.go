@@ -0,0 +1,3148 @@
+// Package storetest is a suite of tests which every store.Store
+// implementation should pass, it creates all of the data it uses so it can
+// be run against an empty store or one which has already been seeded.
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/praelatus/backend/models"
+	"github.com/praelatus/backend/store"
+)
+
+// fixtures holds the models created for the suite
+type fixtures struct {
+	suffix  string
+	user    models.User
+	project models.Project
+	status  models.Status
+	next    models.Status
+	typ     models.TicketType
+}
+
+// Run will run the suite against the given store
+func Run(t *testing.T, s store.Store) {
+	f := &fixtures{suffix: strconv.FormatInt(time.Now().UnixNano(), 36)}
+
+	t.Run("Users", func(t *testing.T) { testUsers(t, s, f) })
+	t.Run("PasswordResets", func(t *testing.T) { testPasswordResets(t, s, f) })
+	t.Run("EmailVerification", func(t *testing.T) { testEmailVerification(t, s, f) })
+	t.Run("RefreshTokens", func(t *testing.T) { testRefreshTokens(t, s, f) })
+	t.Run("LoginAttempts", func(t *testing.T) { testLoginAttempts(t, s, f) })
+	t.Run("Teams", func(t *testing.T) { testTeams(t, s, f) })
+	t.Run("Projects", func(t *testing.T) { testProjects(t, s, f) })
+	t.Run("ProjectMembers", func(t *testing.T) { testProjectMembers(t, s, f) })
+	t.Run("Statuses", func(t *testing.T) { testStatuses(t, s, f) })
+	t.Run("StatusOrder", func(t *testing.T) { testStatusOrder(t, s, f) })
+	t.Run("Types", func(t *testing.T) { testTypes(t, s, f) })
+	t.Run("Tickets", func(t *testing.T) { testTickets(t, s, f) })
+	t.Run("Comments", func(t *testing.T) { testComments(t, s, f) })
+	t.Run("CommentsForTickets", func(t *testing.T) { testCommentsForTickets(t, s, f) })
+	t.Run("Labels", func(t *testing.T) { testLabels(t, s, f) })
+	t.Run("Filters", func(t *testing.T) { testFilters(t, s, f) })
+	t.Run("NewBatch", func(t *testing.T) { testNewBatch(t, s, f) })
+	t.Run("ConcurrentKeys", func(t *testing.T) { testConcurrentKeys(t, s, f) })
+	t.Run("Transitions", func(t *testing.T) { testTransitions(t, s, f) })
+	t.Run("TransitionBatch", func(t *testing.T) { testTransitionBatch(t, s, f) })
+	t.Run("WithTx", func(t *testing.T) { testWithTx(t, s, f) })
+	t.Run("Subtasks", func(t *testing.T) { testSubtasks(t, s, f) })
+	t.Run("ByUser", func(t *testing.T) { testByUser(t, s, f) })
+	t.Run("CountByStatus", func(t *testing.T) { testCountByStatus(t, s, f) })
+	t.Run("FieldValues", func(t *testing.T) { testFieldValues(t, s, f) })
+	t.Run("RequiredFields", func(t *testing.T) { testRequiredFields(t, s, f) })
+	t.Run("FieldOptions", func(t *testing.T) { testFieldOptions(t, s, f) })
+	t.Run("AssignTicket", func(t *testing.T) { testAssignTicket(t, s, f) })
+	t.Run("Unassigned", func(t *testing.T) { testUnassigned(t, s, f) })
+	t.Run("ProjectForTicket", func(t *testing.T) { testProjectForTicket(t, s, f) })
+	t.Run("GetByKey", func(t *testing.T) { testGetByKey(t, s, f) })
+	t.Run("RecentlyUpdated", func(t *testing.T) { testRecentlyUpdated(t, s, f) })
+	t.Run("CommentCount", func(t *testing.T) { testCommentCount(t, s, f) })
+	t.Run("ConcurrentComments", func(t *testing.T) { testConcurrentComments(t, s, f) })
+	t.Run("OrderByField", func(t *testing.T) { testOrderByField(t, s, f) })
+	t.Run("CreatedOrder", func(t *testing.T) { testCreatedOrder(t, s, f) })
+	t.Run("KeyCollision", func(t *testing.T) { testKeyCollision(t, s, f) })
+	t.Run("InvalidReferences", func(t *testing.T) { testInvalidReferences(t, s, f) })
+	t.Run("TimeTracking", func(t *testing.T) { testTimeTracking(t, s, f) })
+	t.Run("Worklogs", func(t *testing.T) { testWorklogs(t, s, f) })
+	t.Run("Overdue", func(t *testing.T) { testOverdue(t, s, f) })
+	t.Run("SuggestAssignee", func(t *testing.T) { testSuggestAssignee(t, s, f) })
+	t.Run("ArchiveProjects", func(t *testing.T) { testArchiveProjects(t, s, f) })
+	t.Run("SavedFilters", func(t *testing.T) { testSavedFilters(t, s, f) })
+	t.Run("RemoveProject", func(t *testing.T) { testRemoveProject(t, s, f) })
+}
+
+func failIfErr(testName string, t *testing.T, e error) {
+	if e != nil {
+		t.Fatal(testName, " failed with error: ", e)
+	}
+}
+
+func testUsers(t *testing.T, s store.Store, f *fixtures) {
+	f.user = models.User{
+		Username: "suite" + f.suffix,
+		Password: "test",
+		Email:    "suite" + f.suffix + "@example.com",
+		FullName: "Suite User",
+	}
+
+	e := s.Users().New(&f.user)
+	failIfErr("User New", t, e)
+
+	if f.user.ID == 0 {
+		t.Fatal("Expected the user to have an ID")
+	}
+
+	u := models.User{Username: f.user.Username}
+	e = s.Users().Get(&u)
+	failIfErr("User Get", t, e)
+
+	if u.ID != f.user.ID {
+		t.Errorf("Expected user %d Got %d\n", f.user.ID, u.ID)
+	}
+
+	dup := models.User{Username: f.user.Username, Password: "test"}
+	e = s.Users().New(&dup)
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected ErrDuplicateEntry Got %v\n", e)
+	}
+
+	e = s.Users().Get(&models.User{Username: "missing" + f.suffix})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	u, e = s.Users().GetByEmail(strings.ToUpper(f.user.Email))
+	failIfErr("User Get By Email", t, e)
+
+	if u.ID != f.user.ID {
+		t.Errorf("Expected user %d Got %d\n", f.user.ID, u.ID)
+	}
+
+	_, e = s.Users().GetByEmail("missing" + f.suffix + "@example.com")
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	dup = models.User{Username: "dupemail" + f.suffix, Password: "test",
+		Email: strings.ToUpper(f.user.Email)}
+	e = s.Users().New(&dup)
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected ErrDuplicateEntry for the email Got %v\n", e)
+	}
+
+	changed := "changed" + f.suffix + "@example.com"
+	u.Email = changed
+	u.Password = ""
+	e = s.Users().Save(u)
+	failIfErr("User Save", t, e)
+
+	u = models.User{ID: f.user.ID}
+	e = s.Users().Get(&u)
+	failIfErr("User Save", t, e)
+
+	if u.Email != changed {
+		t.Errorf("Expected %s Got %s\n", changed, u.Email)
+	}
+
+	u.DisplayName = "Suite"
+	u.AvatarURL = "https://example.com/suite.png"
+	u.Bio = "Runs the store test suite."
+	u.Password = ""
+	e = s.Users().Save(u)
+	failIfErr("User Save Profile", t, e)
+
+	u = models.User{Username: f.user.Username}
+	e = s.Users().Get(&u)
+	failIfErr("User Save Profile", t, e)
+
+	if u.DisplayName != "Suite" || u.AvatarURL != "https://example.com/suite.png" ||
+		u.Bio != "Runs the store test suite." {
+		t.Errorf("Expected the profile to be saved Got %v\n", u)
+	}
+
+	removed := models.User{
+		Username: "removed" + f.suffix,
+		Password: "test",
+		Email:    "removed" + f.suffix + "@example.com",
+		FullName: "Removed User",
+	}
+
+	e = s.Users().New(&removed)
+	failIfErr("User New", t, e)
+
+	refresh := "removedrefresh" + f.suffix
+	e = s.Users().CreateRefreshToken(removed, refresh, time.Now().Add(time.Hour))
+	failIfErr("User Create Refresh Token", t, e)
+
+	e = s.Users().Remove(removed)
+	failIfErr("User Remove", t, e)
+
+	_, e = s.Users().RotateRefreshToken(refresh, "removednext"+f.suffix, time.Now().Add(time.Hour))
+	if e == nil {
+		t.Error("Expected a removed user's refresh token to be revoked")
+	}
+
+	// Tokens which somehow outlive the removal can't be used either.
+	late := "removedlate" + f.suffix
+	e = s.Users().CreateRefreshToken(removed, late, time.Now().Add(time.Hour))
+	failIfErr("User Create Refresh Token", t, e)
+
+	_, e = s.Users().RotateRefreshToken(late, "removednext"+f.suffix, time.Now().Add(time.Hour))
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a removed user Got %v\n", e)
+	}
+
+	e = s.Users().Get(&models.User{ID: removed.ID})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a removed user Got %v\n", e)
+	}
+
+	users, e := s.Users().GetAll()
+	failIfErr("User Get All", t, e)
+
+	if containsUser(users, removed.ID) {
+		t.Errorf("Expected %s to not be returned by GetAll\n", removed.Username)
+	}
+
+	users, e = s.Users().GetAllIncludingInactive()
+	failIfErr("User Get All Including Inactive", t, e)
+
+	if !containsUser(users, removed.ID) {
+		t.Errorf("Expected %s to still exist\n", removed.Username)
+	}
+
+	for _, usr := range users {
+		if usr.ID == removed.ID && usr.IsActive {
+			t.Errorf("Expected %s to be inactive\n", removed.Username)
+		}
+	}
+
+	findable := models.User{
+		Username:    "findable" + f.suffix,
+		Password:    "test",
+		Email:       "findable" + f.suffix + "@example.com",
+		DisplayName: "Search Target",
+	}
+
+	e = s.Users().New(&findable)
+	failIfErr("User New", t, e)
+
+	users, total, e := s.Users().GetAllFiltered("search TARGET", store.PageOptions{})
+	failIfErr("User Get All Filtered", t, e)
+
+	if !containsUser(users, findable.ID) || containsUser(users, f.user.ID) {
+		t.Errorf("Expected only %s to match the search Got %v\n",
+			findable.Username, users)
+	}
+
+	if total != len(users) {
+		t.Errorf("Expected a total of %d Got %d\n", len(users), total)
+	}
+
+	users, total, e = s.Users().GetAllFiltered(f.suffix, store.PageOptions{Limit: 1, Offset: 1})
+	failIfErr("User Get All Filtered", t, e)
+
+	if total != 2 {
+		t.Errorf("Expected suite and findable to match Got %d\n", total)
+	}
+
+	if len(users) != 1 || users[0].ID != findable.ID {
+		t.Errorf("Expected the second page to be %s Got %v\n",
+			findable.Username, users)
+	}
+}
+
+func containsUser(users []models.User, id int64) bool {
+	for _, u := range users {
+		if u.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testPasswordResets(t *testing.T, s store.Store, f *fixtures) {
+	token := "reset" + f.suffix
+
+	e := s.Users().CreatePasswordReset(f.user, token, time.Now().Add(time.Hour))
+	failIfErr("Create Password Reset", t, e)
+
+	e = s.Users().ConsumePasswordReset(models.User{ID: f.user.ID + 1}, token)
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for another user Got %v\n", e)
+	}
+
+	e = s.Users().ConsumePasswordReset(f.user, token)
+	failIfErr("Consume Password Reset", t, e)
+
+	e = s.Users().ConsumePasswordReset(f.user, token)
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a used token Got %v\n", e)
+	}
+
+	expired := "expired" + f.suffix
+
+	e = s.Users().CreatePasswordReset(f.user, expired, time.Now().Add(-time.Minute))
+	failIfErr("Create Password Reset", t, e)
+
+	e = s.Users().ConsumePasswordReset(f.user, expired)
+	if e != store.ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired Got %v\n", e)
+	}
+
+	e = s.Users().ConsumePasswordReset(f.user, "missing"+f.suffix)
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for a missing token Got %v\n", e)
+	}
+}
+
+func testRefreshTokens(t *testing.T, s store.Store, f *fixtures) {
+	first := "refresh" + f.suffix
+	second := "rotated" + f.suffix
+	expires := time.Now().Add(time.Hour)
+
+	e := s.Users().CreateRefreshToken(f.user, first, expires)
+	failIfErr("User Create Refresh Token", t, e)
+
+	u, e := s.Users().RotateRefreshToken(first, second, expires)
+	failIfErr("User Rotate Refresh Token", t, e)
+
+	if u.ID != f.user.ID || u.Username != f.user.Username {
+		t.Errorf("Expected %s Got %v\n", f.user.Username, u)
+	}
+
+	// Using the rotated token again is taken as theft, which revokes the
+	// token that replaced it as well.
+	_, e = s.Users().RotateRefreshToken(first, "stolen"+f.suffix, expires)
+	if e != store.ErrTokenReused {
+		t.Errorf("Expected ErrTokenReused Got %v\n", e)
+	}
+
+	_, e = s.Users().RotateRefreshToken(second, "stolen"+f.suffix, expires)
+	if e != store.ErrTokenReused {
+		t.Errorf("Expected the rotated token to be revoked Got %v\n", e)
+	}
+
+	_, e = s.Users().RotateRefreshToken("missingrefresh"+f.suffix, "next"+f.suffix, expires)
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken Got %v\n", e)
+	}
+
+	expired := "expiredrefresh" + f.suffix
+	e = s.Users().CreateRefreshToken(f.user, expired, time.Now().Add(-time.Minute))
+	failIfErr("User Create Refresh Token", t, e)
+
+	_, e = s.Users().RotateRefreshToken(expired, "next"+f.suffix, expires)
+	if e != store.ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired Got %v\n", e)
+	}
+
+	other := "logout" + f.suffix
+	e = s.Users().CreateRefreshToken(f.user, other, expires)
+	failIfErr("User Create Refresh Token", t, e)
+
+	e = s.Users().RevokeRefreshToken(models.User{ID: f.user.ID + 1000}, other)
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken for another user's token Got %v\n", e)
+	}
+
+	e = s.Users().RevokeRefreshToken(f.user, other)
+	failIfErr("User Revoke Refresh Token", t, e)
+
+	_, e = s.Users().RotateRefreshToken(other, "next"+f.suffix, expires)
+	if e == nil {
+		t.Errorf("Expected a revoked token to be rejected\n")
+	}
+
+	e = s.Users().RevokeRefreshToken(f.user, "missingrefresh"+f.suffix)
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken Got %v\n", e)
+	}
+}
+
+func testLoginAttempts(t *testing.T, s store.Store, f *fixtures) {
+	lockUntil := time.Now().Add(time.Hour)
+
+	fail := func(locks bool) {
+		until, e := s.Users().RecordFailedLogin(f.user, 3, lockUntil)
+		failIfErr("User Record Failed Login", t, e)
+
+		if until.IsZero() == locks {
+			t.Errorf("Expected locked out %v Got until %v\n", locks, until)
+		}
+	}
+
+	fail(false)
+	fail(false)
+
+	e := s.Users().ResetFailedLogins(f.user)
+	failIfErr("User Reset Failed Logins", t, e)
+
+	until, e := s.Users().LoginLockedUntil(f.user)
+	failIfErr("User Login Locked Until", t, e)
+
+	if !until.IsZero() {
+		t.Errorf("Expected no lockout Got %v\n", until)
+	}
+
+	fail(false)
+	fail(false)
+	fail(true)
+
+	until, e = s.Users().LoginLockedUntil(f.user)
+	failIfErr("User Login Locked Until", t, e)
+
+	if d := until.Sub(lockUntil); d > time.Second || d < -time.Second {
+		t.Errorf("Expected locked out until %v Got %v\n", lockUntil, until)
+	}
+
+	// The count starts again once the user has been locked out.
+	fail(false)
+
+	e = s.Users().ResetFailedLogins(f.user)
+	failIfErr("User Reset Failed Logins", t, e)
+}
+
+func testEmailVerification(t *testing.T, s store.Store, f *fixtures) {
+	token := "verify" + f.suffix
+
+	e := s.Users().CreateEmailVerification(f.user, token, time.Now().Add(time.Hour))
+	failIfErr("User Create Email Verification", t, e)
+
+	u, e := s.Users().VerifyEmail(token)
+	failIfErr("User Verify Email", t, e)
+
+	if u.ID != f.user.ID || !u.EmailVerified {
+		t.Errorf("Expected %s to be verified Got %v\n", f.user.Username, u)
+	}
+
+	u = models.User{ID: f.user.ID}
+	e = s.Users().Get(&u)
+	failIfErr("User Get", t, e)
+
+	if !u.EmailVerified {
+		t.Errorf("Expected the verification to be saved Got %v\n", u)
+	}
+
+	_, e = s.Users().VerifyEmail(token)
+	if e != store.ErrInvalidToken {
+		t.Errorf("Expected ErrInvalidToken Got %v\n", e)
+	}
+
+	expired := "expiredverify" + f.suffix
+	e = s.Users().CreateEmailVerification(f.user, expired, time.Now().Add(-time.Minute))
+	failIfErr("User Create Email Verification", t, e)
+
+	_, e = s.Users().VerifyEmail(expired)
+	if e != store.ErrTokenExpired {
+		t.Errorf("Expected ErrTokenExpired Got %v\n", e)
+	}
+
+	email := u.Email
+	u.Password = ""
+	e = s.Users().Save(u)
+	failIfErr("User Save", t, e)
+
+	e = s.Users().Get(&u)
+	failIfErr("User Get", t, e)
+
+	if !u.EmailVerified {
+		t.Errorf("Expected saving the same email to keep it verified Got %v\n", u)
+	}
+
+	u.Password = ""
+	u.Email = "unverified" + f.suffix + "@example.com"
+	e = s.Users().Save(u)
+	failIfErr("User Save", t, e)
+
+	e = s.Users().Get(&u)
+	failIfErr("User Get", t, e)
+
+	if u.EmailVerified {
+		t.Errorf("Expected changing the email to unverify it Got %v\n", u)
+	}
+
+	u.Email = email
+	u.Password = ""
+	e = s.Users().Save(u)
+	failIfErr("User Save", t, e)
+}
+
+func testTeams(t *testing.T, s store.Store, f *fixtures) {
+	team := models.Team{
+		Name:    "Suite Team " + f.suffix,
+		Lead:    f.user,
+		Members: []models.User{f.user},
+	}
+
+	e := s.Teams().New(&team)
+	failIfErr("Team New", t, e)
+
+	if team.URLSlug != "suite-team-"+f.suffix {
+		t.Errorf("Expected suite-team-%s Got %s\n", f.suffix, team.URLSlug)
+	}
+
+	got := models.Team{URLSlug: team.URLSlug}
+	e = s.Teams().Get(&got)
+	failIfErr("Team Get", t, e)
+
+	if got.ID != team.ID {
+		t.Errorf("Expected team %d Got %d\n", team.ID, got.ID)
+	}
+
+	if got.Lead.ID != f.user.ID || got.Lead.Username != f.user.Username {
+		t.Errorf("Expected lead %s Got %v\n", f.user.Username, got.Lead)
+	}
+
+	if got.Lead.Password != "" {
+		t.Error("Expected the lead's password to not be returned")
+	}
+
+	dup := models.Team{Name: team.Name, Lead: f.user}
+	e = s.Teams().New(&dup)
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected ErrDuplicateEntry Got %v\n", e)
+	}
+
+	if len(got.Members) != 1 || got.Members[0].ID != f.user.ID {
+		t.Errorf("Expected %s to be a member Got %v\n", f.user.Username, got.Members)
+	}
+
+	teams, e := s.Teams().GetForUser(f.user)
+	failIfErr("Team Get For User", t, e)
+
+	member := models.User{
+		Username: "member" + f.suffix,
+		Password: "test",
+		Email:    "member" + f.suffix + "@example.com",
+		FullName: "Team Member",
+	}
+
+	e = s.Users().New(&member)
+	failIfErr("User New", t, e)
+
+	// Adding the same member at once still adds them once.
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.Teams().AddMember(team, member)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, e := range errs {
+		failIfErr("Team Add Member", t, e)
+	}
+
+	members, e := s.Teams().GetMembers(team)
+	failIfErr("Team Get Members", t, e)
+
+	if len(members) != 2 || !containsUser(members, member.ID) {
+		t.Errorf("Expected %s to be added once Got %v\n", member.Username, members)
+	}
+
+	e = s.Teams().RemoveMember(team, member)
+	failIfErr("Team Remove Member", t, e)
+
+	e = s.Teams().RemoveMember(team, f.user)
+	if e != store.ErrRemoveLead {
+		t.Errorf("Expected ErrRemoveLead Got %v\n", e)
+	}
+
+	members, e = s.Teams().GetMembers(team)
+	failIfErr("Team Get Members", t, e)
+
+	if len(members) != 1 || members[0].ID != f.user.ID {
+		t.Errorf("Expected only %s to be a member Got %v\n", f.user.Username, members)
+	}
+
+	if len(teams) != 1 || teams[0].ID != team.ID {
+		t.Errorf("Expected team %d Got %v\n", team.ID, teams)
+	}
+
+	e = s.Teams().Remove(team)
+	failIfErr("Team Remove", t, e)
+
+	e = s.Teams().Get(&models.Team{ID: team.ID})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+}
+
+func testProjects(t *testing.T, s store.Store, f *fixtures) {
+	f.project = models.Project{
+		Name: "Suite Project",
+		Key:  "S" + f.suffix,
+		Lead: f.user,
+	}
+
+	e := s.Projects().New(&f.project)
+	failIfErr("Project New", t, e)
+
+	p := models.Project{Key: f.project.Key}
+	e = s.Projects().Get(&p)
+	failIfErr("Project Get", t, e)
+
+	if p.ID != f.project.ID || p.Lead.ID != f.user.ID {
+		t.Errorf("Expected %v Got %v\n", f.project, p)
+	}
+
+	dup := models.Project{Name: "Duplicate", Key: f.project.Key, Lead: f.user}
+	e = s.Projects().New(&dup)
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected ErrDuplicateEntry Got %v\n", e)
+	}
+
+	e = s.Projects().Get(&models.Project{Key: "MISSING" + f.suffix})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+}
+
+func testProjectMembers(t *testing.T, s store.Store, f *fixtures) {
+	_, e := s.Projects().GetRole(f.project, f.user)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a non member Got %v\n", e)
+	}
+
+	e = s.Projects().AddMember(f.project, f.user, models.CoreR)
+	failIfErr("Project Add Member", t, e)
+
+	role, e := s.Projects().GetRole(models.Project{Key: f.project.Key}, f.user)
+	failIfErr("Project Get Role", t, e)
+
+	if role != models.CoreR {
+		t.Errorf("Expected %s Got %s\n", models.CoreR, role)
+	}
+
+	e = s.Projects().AddMember(f.project, f.user, models.AdminR)
+	failIfErr("Project Add Member", t, e)
+
+	members, e := s.Projects().GetMembers(f.project)
+	failIfErr("Project Get Members", t, e)
+
+	if len(members) != 1 || members[0].User.ID != f.user.ID ||
+		members[0].Role != models.AdminR || members[0].User.Password != "" {
+		t.Errorf("Expected %s to be the only admin Got %v\n", f.user.Username, members)
+	}
+
+	roles, e := s.Projects().GetRoles(models.User{Username: f.user.Username})
+	failIfErr("Project Get Roles", t, e)
+
+	if roles[f.project.Key] != models.AdminR {
+		t.Errorf("Expected %s in %s Got %v\n", models.AdminR, f.project.Key, roles)
+	}
+
+	e = s.Projects().RemoveMember(f.project, f.user)
+	failIfErr("Project Remove Member", t, e)
+
+	roles, e = s.Projects().GetRoles(f.user)
+	failIfErr("Project Get Roles", t, e)
+
+	if roles[f.project.Key] != models.AdminR {
+		t.Errorf("Expected the lead to be %s in %s Got %v\n", models.AdminR, f.project.Key, roles)
+	}
+
+	member := models.User{
+		Username: "roles" + f.suffix,
+		Password: "test",
+		Email:    "roles" + f.suffix + "@example.com",
+		FullName: "Project Member",
+	}
+
+	e = s.Users().New(&member)
+	failIfErr("User New", t, e)
+
+	e = s.Projects().AddMember(f.project, member, models.CoreR)
+	failIfErr("Project Add Member", t, e)
+
+	roles, e = s.Projects().GetRoles(member)
+	failIfErr("Project Get Roles", t, e)
+
+	if len(roles) != 1 || roles[f.project.Key] != models.CoreR {
+		t.Errorf("Expected %s in %s Got %v\n", models.CoreR, f.project.Key, roles)
+	}
+
+	e = s.Projects().RemoveMember(f.project, member)
+	failIfErr("Project Remove Member", t, e)
+
+	roles, e = s.Projects().GetRoles(member)
+	failIfErr("Project Get Roles", t, e)
+
+	if _, ok := roles[f.project.Key]; ok {
+		t.Errorf("Expected no role in %s Got %v\n", f.project.Key, roles)
+	}
+
+	_, e = s.Projects().GetRole(f.project, f.user)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a removed member Got %v\n", e)
+	}
+}
+
+func testStatuses(t *testing.T, s store.Store, f *fixtures) {
+	f.status = models.Status{Name: "Suite Open " + f.suffix}
+	e := s.Statuses().New(&f.status)
+	failIfErr("Status New", t, e)
+
+	f.next = models.Status{Name: "Suite Closed " + f.suffix}
+	e = s.Statuses().New(&f.next)
+	failIfErr("Status New", t, e)
+
+	st := models.Status{Name: f.status.Name}
+	e = s.Statuses().Get(&st)
+	failIfErr("Status Get", t, e)
+
+	if st.ID != f.status.ID {
+		t.Errorf("Expected status %d Got %d\n", f.status.ID, st.ID)
+	}
+
+	f.typ = models.TicketType{Name: "Suite Type " + f.suffix}
+	e = s.Types().New(&f.typ)
+	failIfErr("Type New", t, e)
+}
+
+// statusIDs returns the ids of the statuses in order
+func statusIDs(statuses []models.Status) []int64 {
+	ids := make([]int64, len(statuses))
+	for i, st := range statuses {
+		ids[i] = st.ID
+	}
+
+	return ids
+}
+
+func testStatusOrder(t *testing.T, s store.Store, f *fixtures) {
+	before, e := s.Statuses().GetAllOrdered()
+	failIfErr("Status Get All Ordered", t, e)
+
+	last := models.Status{Name: "Suite Last " + f.suffix}
+	e = s.Statuses().New(&last)
+	failIfErr("Status New", t, e)
+
+	ordered, e := s.Statuses().GetAllOrdered()
+	failIfErr("Status Get All Ordered", t, e)
+
+	if ids := statusIDs(ordered); ids[len(ids)-1] != last.ID {
+		t.Errorf("Expected %d last Got %v\n", last.ID, ids)
+	}
+
+	// Moving the new status and the suite's closed status to the front
+	// should leave every other status behind them in the same order.
+	e = s.Statuses().Reorder([]int64{last.ID, f.next.ID})
+	failIfErr("Status Reorder", t, e)
+
+	expected := []int64{last.ID, f.next.ID}
+	for _, id := range statusIDs(before) {
+		if id != f.next.ID {
+			expected = append(expected, id)
+		}
+	}
+
+	ordered, e = s.Statuses().GetAllOrdered()
+	failIfErr("Status Get All Ordered", t, e)
+
+	if fmt.Sprint(statusIDs(ordered)) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v Got %v\n", expected, statusIDs(ordered))
+	}
+
+	e = s.Statuses().Reorder([]int64{f.status.ID, -1})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected %v Got %v\n", store.ErrNotFound, e)
+	}
+
+	ordered, e = s.Statuses().GetAllOrdered()
+	failIfErr("Status Get All Ordered", t, e)
+
+	if fmt.Sprint(statusIDs(ordered)) != fmt.Sprint(expected) {
+		t.Errorf("Expected a failed reorder to change nothing Got %v\n",
+			statusIDs(ordered))
+	}
+
+	e = s.Statuses().Remove(last)
+	failIfErr("Status Remove", t, e)
+}
+
+func testTypes(t *testing.T, s store.Store, f *fixtures) {
+	story := models.TicketType{Name: "Suite Story " + f.suffix}
+	e := s.Types().New(&story)
+	failIfErr("Type New", t, e)
+
+	found := models.TicketType{Name: story.Name}
+	e = s.Types().Get(&found)
+	failIfErr("Type Get", t, e)
+
+	if found.ID != story.ID {
+		t.Errorf("Expected type %d Got %d\n", story.ID, found.ID)
+	}
+
+	e = s.Types().New(&models.TicketType{Name: story.Name})
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected %v Got %v\n", store.ErrDuplicateEntry, e)
+	}
+
+	story.Name = "Suite Epic " + f.suffix
+	e = s.Types().Save(story)
+	failIfErr("Type Save", t, e)
+
+	e = s.Types().Save(models.TicketType{ID: story.ID, Name: f.typ.Name})
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected %v Got %v\n", store.ErrDuplicateEntry, e)
+	}
+
+	typs, e := s.Types().GetAll()
+	failIfErr("Type Get All", t, e)
+
+	renamed := false
+	for _, typ := range typs {
+		renamed = renamed || (typ.ID == story.ID && typ.Name == story.Name)
+	}
+
+	if !renamed {
+		t.Errorf("Expected %s in %v\n", story.Name, typs)
+	}
+
+	// The suite type is used by every ticket the suite creates.
+	inUse := newTicket(t, s, f, "Typed suite ticket")
+
+	e = s.Types().Remove(f.typ)
+	if e != store.ErrInUse {
+		t.Errorf("Expected %v Got %v\n", store.ErrInUse, e)
+	}
+
+	e = s.Tickets().Remove(inUse)
+	failIfErr("Ticket Remove", t, e)
+
+	e = s.Types().Remove(story)
+	failIfErr("Type Remove", t, e)
+
+	e = s.Types().Get(&models.TicketType{ID: story.ID})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected %v Got %v\n", store.ErrNotFound, e)
+	}
+}
+
+// newTicket creates a ticket in the suite project
+func newTicket(t *testing.T, s store.Store, f *fixtures, summary string) models.Ticket {
+	return newTicketIn(t, s, f, f.project, summary)
+}
+
+// newTicketIn creates a ticket in the project p instead of the suite project
+func newTicketIn(t *testing.T, s store.Store, f *fixtures, p models.Project,
+	summary string) models.Ticket {
+	tk := models.Ticket{
+		Summary:     summary,
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+	}
+
+	e := s.Tickets().New(p, &tk)
+	failIfErr("Ticket New", t, e)
+
+	return tk
+}
+
+func testTickets(t *testing.T, s store.Store, f *fixtures) {
+	first := newTicket(t, s, f, "First suite ticket")
+	second := newTicket(t, s, f, "Second suite ticket")
+
+	if first.Key == second.Key {
+		t.Errorf("Expected unique keys Got %s twice\n", first.Key)
+	}
+
+	invalid := models.Ticket{
+		Summary:     "Invalid field suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+		Fields: []models.FieldValue{
+			{Name: "Suite Points", DataType: "INT", Value: "three"},
+		},
+	}
+
+	e := s.Tickets().New(f.project, &invalid)
+	if _, ok := e.(*models.FieldValueError); !ok {
+		t.Errorf("Expected a FieldValueError Got %v\n", e)
+	}
+
+	tk := models.Ticket{Key: first.Key}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket Get", t, e)
+
+	if tk.ID != first.ID || tk.Status.Name != f.status.Name ||
+		tk.Reporter.ID != f.user.ID {
+		t.Errorf("Expected %v Got %v\n", first, tk)
+	}
+
+	e = s.Tickets().Get(&models.Ticket{Key: "MISSING" + f.suffix})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	tickets, total, e := s.Tickets().GetAllByProjectPaged(f.project,
+		store.PageOptions{Limit: 1, OrderBy: "key"})
+	failIfErr("Ticket Get All By Project Paged", t, e)
+
+	if total != 2 || len(tickets) != 1 {
+		t.Errorf("Expected 1 of 2 tickets Got %d of %d\n", len(tickets), total)
+	}
+
+	_, _, e = s.Tickets().GetAllByProjectPaged(f.project,
+		store.PageOptions{OrderBy: "password"})
+	if e != store.ErrInvalidOrderBy {
+		t.Errorf("Expected ErrInvalidOrderBy Got %v\n", e)
+	}
+
+	tk.Summary = "Changed suite ticket"
+	tk.UpdatedBy = f.user
+	e = s.Tickets().Save(tk)
+	failIfErr("Ticket Save", t, e)
+
+	history, e := s.Tickets().GetHistory(tk)
+	failIfErr("Ticket Get History", t, e)
+
+	if len(history) != 1 || history[0].Field != "summary" ||
+		history[0].User.ID != f.user.ID {
+		t.Errorf("Expected a summary change Got %v\n", history)
+	}
+
+	tk.Summary = "Stale suite ticket"
+	e = s.Tickets().Save(tk)
+	if e != store.ErrStaleObject {
+		t.Errorf("Expected ErrStaleObject Got %v\n", e)
+	}
+
+	fresh := models.Ticket{Key: tk.Key}
+	e = s.Tickets().Get(&fresh)
+	failIfErr("Ticket Get", t, e)
+
+	if fresh.Version != tk.Version+1 || fresh.Summary != "Changed suite ticket" {
+		t.Errorf("Expected version %d of the changed ticket Got %v\n",
+			tk.Version+1, fresh)
+	}
+
+	fresh.Summary = "Fresh suite ticket"
+	fresh.UpdatedBy = f.user
+	e = s.Tickets().Save(fresh)
+	failIfErr("Ticket Save", t, e)
+
+	e = s.Tickets().AddWatcher(tk, f.user)
+	failIfErr("Ticket Add Watcher", t, e)
+
+	e = s.Tickets().AddWatcher(tk, f.user)
+	failIfErr("Ticket Add Watcher", t, e)
+
+	watchers, e := s.Tickets().GetWatchers(tk)
+	failIfErr("Ticket Get Watchers", t, e)
+
+	if len(watchers) != 1 {
+		t.Errorf("Expected 1 watcher Got %d\n", len(watchers))
+	}
+
+	e = s.Tickets().LinkTickets(first, second, models.LinkBlocks)
+	failIfErr("Ticket Link", t, e)
+
+	e = s.Tickets().LinkTickets(first, second, models.LinkBlocks)
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected ErrDuplicateEntry Got %v\n", e)
+	}
+
+	links, e := s.Tickets().GetLinks(second)
+	failIfErr("Ticket Get Links", t, e)
+
+	if len(links) != 1 || links[0].LinkType != models.LinkBlockedBy ||
+		links[0].TargetKey != first.Key {
+		t.Errorf("Expected second to be blocked by first Got %v\n", links)
+	}
+
+	e = s.Tickets().Remove(second)
+	failIfErr("Ticket Remove", t, e)
+
+	e = s.Tickets().Get(&models.Ticket{ID: second.ID})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+}
+
+func testComments(t *testing.T, s store.Store, f *fixtures) {
+	tk := newTicket(t, s, f, "Commented suite ticket")
+
+	c := models.Comment{Body: "A suite comment", Author: f.user}
+	e := s.Tickets().NewComment(tk, &c)
+	failIfErr("Comment New", t, e)
+
+	found := models.Comment{ID: c.ID}
+	e = s.Tickets().GetComment(&found)
+	failIfErr("Comment Get", t, e)
+
+	if found.Body != c.Body || found.Author.ID != f.user.ID ||
+		found.Author.Password != "" {
+		t.Errorf("Expected %v Got %v\n", c, found)
+	}
+
+	e = s.Tickets().GetComment(&models.Comment{ID: c.ID + 1000000})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	// The API only knows the key of the ticket being commented on.
+	keyed := newTicket(t, s, f, "Key commented suite ticket")
+	kc := models.Comment{Body: "A comment by key", Author: f.user}
+	e = s.Tickets().NewComment(models.Ticket{Key: keyed.Key}, &kc)
+	failIfErr("Comment New By Key", t, e)
+
+	keyedComments, e := s.Tickets().GetComments(keyed)
+	failIfErr("Comment Get All", t, e)
+
+	if len(keyedComments) != 1 || keyedComments[0].ID != kc.ID {
+		t.Errorf("Expected comment %d on %s Got %v\n", kc.ID, keyed.Key,
+			keyedComments)
+	}
+
+	e = s.Tickets().NewComment(models.Ticket{Key: "NOPE-" + f.suffix}, &kc)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	c.Body = "An edited suite comment"
+	c.UpdatedBy = f.user
+	e = s.Tickets().SaveComment(c)
+	failIfErr("Comment Save", t, e)
+
+	e = s.Tickets().SaveComment(c)
+	failIfErr("Comment Save", t, e)
+
+	editor := models.User{
+		Username: "editor" + f.suffix,
+		Password: "test",
+		Email:    "editor" + f.suffix + "@example.com",
+		FullName: "Comment Editor",
+	}
+
+	e = s.Users().New(&editor)
+	failIfErr("User New", t, e)
+
+	c.Body = "A twice edited suite comment"
+	c.Author = editor
+	c.UpdatedBy = editor
+	e = s.Tickets().SaveComment(c)
+	failIfErr("Comment Save", t, e)
+
+	revisions, e := s.Tickets().GetCommentHistory(c)
+	failIfErr("Comment Get History", t, e)
+
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions Got %d\n", len(revisions))
+	}
+
+	if revisions[0].OldBody != "A suite comment" ||
+		revisions[1].OldBody != "An edited suite comment" {
+		t.Errorf("Expected revisions oldest first Got %v\n", revisions)
+	}
+
+	if revisions[0].Editor.ID != f.user.ID {
+		t.Errorf("Expected %s to be the editor Got %v\n", f.user.Username,
+			revisions[0].Editor)
+	}
+
+	e = s.Tickets().AddReaction(c, f.user, "+1")
+	failIfErr("Comment Add Reaction", t, e)
+
+	e = s.Tickets().AddReaction(c, f.user, "+1")
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected ErrDuplicateEntry Got %v\n", e)
+	}
+
+	e = s.Tickets().AddReaction(c, editor, "+1")
+	failIfErr("Comment Add Reaction", t, e)
+
+	e = s.Tickets().AddReaction(c, editor, "tada")
+	failIfErr("Comment Add Reaction", t, e)
+
+	e = s.Tickets().RemoveReaction(c, editor, "tada")
+	failIfErr("Comment Remove Reaction", t, e)
+
+	reactions, e := s.Tickets().GetReactions(c)
+	failIfErr("Comment Get Reactions", t, e)
+
+	if len(reactions) != 1 || reactions["+1"] != 2 {
+		t.Errorf("Expected 2 +1 reactions Got %v\n", reactions)
+	}
+
+	comments, e := s.Tickets().GetComments(tk)
+	failIfErr("Comment Get", t, e)
+
+	if len(comments) == 1 && comments[0].Reactions["+1"] != 2 {
+		t.Errorf("Expected 2 +1 reactions Got %v\n", comments[0].Reactions)
+	}
+
+	if len(comments) != 1 || comments[0].Body != c.Body ||
+		comments[0].Author.ID != f.user.ID {
+		t.Errorf("Expected %v Got %v\n", c, comments)
+	}
+
+	before := updatedDate(t, s, tk)
+
+	e = s.Tickets().RemoveComment(c)
+	failIfErr("Comment Remove", t, e)
+
+	if removed := updatedDate(t, s, tk); !removed.After(before) {
+		t.Errorf("Expected the updated date to move past %v Got %v\n", before, removed)
+	}
+
+	comments, e = s.Tickets().GetComments(tk)
+	failIfErr("Comment Get", t, e)
+
+	if len(comments) != 0 {
+		t.Errorf("Expected no comments Got %d\n", len(comments))
+	}
+
+	mentioned := newTicket(t, s, f, "Mentioned suite ticket")
+
+	mc := models.Comment{
+		Body: "@" + f.user.Username + ", @missing" + f.suffix +
+			" and suite@example.com",
+		Author: f.user,
+	}
+
+	e = s.Tickets().NewComment(mentioned, &mc)
+	failIfErr("Comment New", t, e)
+
+	if len(mc.Mentions) != 1 || mc.Mentions[0].ID != f.user.ID {
+		t.Errorf("Expected only %s to be mentioned Got %v\n", f.user.Username,
+			mc.Mentions)
+	}
+
+	paged := newTicket(t, s, f, "Paged comments suite ticket")
+
+	var created []models.Comment
+	for i := 0; i < 3; i++ {
+		pc := models.Comment{Body: fmt.Sprintf("Paged comment %d", i), Author: f.user}
+		e = s.Tickets().NewComment(paged, &pc)
+		failIfErr("Comment New", t, e)
+
+		created = append(created, pc)
+	}
+
+	page, total, e := s.Tickets().GetCommentsPaged(paged, store.PageOptions{Limit: 2})
+	failIfErr("Comment Get Paged", t, e)
+
+	if total != 3 || len(page) != 2 || page[0].ID != created[0].ID ||
+		page[1].ID != created[1].ID {
+		t.Errorf("Expected the first 2 of 3 comments Got %d of %d: %v\n",
+			len(page), total, page)
+	}
+
+	page, total, e = s.Tickets().GetCommentsPaged(paged,
+		store.PageOptions{Limit: 2, Offset: 2})
+	failIfErr("Comment Get Paged", t, e)
+
+	if total != 3 || len(page) != 1 || page[0].ID != created[2].ID {
+		t.Errorf("Expected the last of 3 comments Got %d of %d: %v\n",
+			len(page), total, page)
+	}
+
+	_, _, e = s.Tickets().GetCommentsPaged(paged, store.PageOptions{OrderBy: "body"})
+	if e != store.ErrInvalidOrderBy {
+		t.Errorf("Expected ErrInvalidOrderBy Got %v\n", e)
+	}
+}
+
+func testTransitions(t *testing.T, s store.Store, f *fixtures) {
+	w := models.Workflow{
+		Name: "Suite Workflow " + f.suffix,
+		Transitions: map[string][]models.Transition{
+			f.status.Name: []models.Transition{
+				models.Transition{
+					Name:     "Close",
+					ToStatus: f.next,
+					Hooks:    []models.Hook{},
+				},
+			},
+		},
+	}
+
+	e := s.Workflows().New(f.project, &w)
+	failIfErr("Workflow New", t, e)
+
+	tk := newTicket(t, s, f, "Transitioned suite ticket")
+
+	transitions, e := s.Tickets().GetTransitions(tk)
+	failIfErr("Ticket Get Transitions", t, e)
+
+	if len(transitions) != 1 || transitions[0].ToStatus.ID != f.next.ID {
+		t.Errorf("Expected a transition to %s Got %v\n", f.next.Name, transitions)
+	}
+
+	e = s.Tickets().TransitionTicket(tk, f.status)
+	if e != store.ErrInvalidTransition {
+		t.Errorf("Expected ErrInvalidTransition Got %v\n", e)
+	}
+
+	e = s.Tickets().TransitionTicket(tk, f.next)
+	failIfErr("Ticket Transition", t, e)
+
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket Transition", t, e)
+
+	if tk.Status.ID != f.next.ID {
+		t.Errorf("Expected status %d Got %d\n", f.next.ID, tk.Status.ID)
+	}
+}
+
+func testTransitionBatch(t *testing.T, s store.Store, f *fixtures) {
+	w := models.Workflow{
+		Name: "Suite Batch Workflow " + f.suffix,
+		Transitions: map[string][]models.Transition{
+			f.status.Name: []models.Transition{
+				models.Transition{
+					Name:     "Close",
+					ToStatus: f.next,
+					Hooks:    []models.Hook{},
+				},
+			},
+		},
+	}
+
+	e := s.Workflows().New(f.project, &w)
+	failIfErr("Transition Batch", t, e)
+
+	open := newTicket(t, s, f, "Open batch suite ticket")
+	other := newTicket(t, s, f, "Other batch suite ticket")
+	closed := newTicket(t, s, f, "Closed batch suite ticket")
+
+	e = s.Tickets().TransitionTicket(closed, f.next)
+	failIfErr("Transition Batch", t, e)
+
+	// closed can't move to the status it's already in so open must not be
+	// moved either.
+	e = s.Tickets().TransitionBatch([]models.Ticket{open, closed}, f.next)
+	if e != store.ErrInvalidTransition {
+		t.Errorf("Expected ErrInvalidTransition Got %v\n", e)
+	}
+
+	e = s.Tickets().Get(&open)
+	failIfErr("Transition Batch", t, e)
+
+	if open.Status.ID != f.status.ID {
+		t.Errorf("Expected the batch to be rolled back Got status %d\n",
+			open.Status.ID)
+	}
+
+	e = s.Tickets().TransitionBatch([]models.Ticket{open, other}, f.next)
+	failIfErr("Transition Batch", t, e)
+
+	for _, tk := range []models.Ticket{open, other} {
+		e = s.Tickets().Get(&tk)
+		failIfErr("Transition Batch", t, e)
+
+		if tk.Status.ID != f.next.ID {
+			t.Errorf("Expected %s to be in status %d Got %d\n", tk.Key,
+				f.next.ID, tk.Status.ID)
+		}
+	}
+}
+
+func testWithTx(t *testing.T, s store.Store, f *fixtures) {
+	var rolledBack models.Ticket
+
+	e := s.WithTx(func(tx store.Store) error {
+		rolledBack = models.Ticket{
+			Summary:  "Rolled back suite ticket",
+			Reporter: f.user,
+			Status:   f.status,
+			Type:     f.typ,
+		}
+
+		err := tx.Tickets().New(f.project, &rolledBack)
+		if err != nil {
+			return err
+		}
+
+		// There is no ticket with this ID so the comment fails.
+		return tx.Tickets().NewComment(models.Ticket{ID: rolledBack.ID + 1000},
+			&models.Comment{Body: "Never saved", Author: f.user})
+	})
+	if e == nil {
+		t.Error("Expected the comment to fail the transaction")
+	}
+
+	e = s.Tickets().Get(&models.Ticket{Key: rolledBack.Key})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected the ticket to be rolled back Got %v\n", e)
+	}
+
+	var committed models.Ticket
+
+	e = s.WithTx(func(tx store.Store) error {
+		committed = models.Ticket{
+			Summary:  "Committed suite ticket",
+			Reporter: f.user,
+			Status:   f.status,
+			Type:     f.typ,
+		}
+
+		err := tx.Tickets().New(f.project, &committed)
+		if err != nil {
+			return err
+		}
+
+		return tx.Tickets().NewComment(committed,
+			&models.Comment{Body: "Saved with the ticket", Author: f.user})
+	})
+	failIfErr("With Tx", t, e)
+
+	e = s.Tickets().Get(&committed)
+	failIfErr("With Tx", t, e)
+
+	if committed.CommentCount != 1 {
+		t.Errorf("Expected 1 comment Got %d\n", committed.CommentCount)
+	}
+
+	// Lists fill in each ticket with more queries, which have to work on the
+	// single connection of a transaction.
+	e = s.WithTx(func(tx store.Store) error {
+		tickets, err := tx.Tickets().GetAllByProject(f.project, "")
+		if err != nil {
+			return err
+		}
+
+		if !hasTicket(tickets, committed.ID) {
+			t.Errorf("Expected %s in the project's tickets Got %v\n", committed.Key, tickets)
+		}
+
+		_, err = tx.Teams().GetAll()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Tickets().GetFiltered(store.TicketFilter{ProjectKey: f.project.Key})
+		return err
+	})
+	failIfErr("With Tx List", t, e)
+}
+
+func hasTicket(tickets []models.Ticket, id int64) bool {
+	for _, t := range tickets {
+		if t.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testNewBatch(t *testing.T, s store.Store, f *fixtures) {
+	existing, e := s.Tickets().GetAllByProject(f.project, "")
+	failIfErr("Ticket New Batch", t, e)
+
+	next := keyNumber(t, f, s.Tickets().NextTicketKey(f.project))
+
+	var tickets []*models.Ticket
+	for i := 0; i < 100; i++ {
+		tickets = append(tickets, &models.Ticket{
+			Summary:     "Batch suite ticket " + strconv.Itoa(i),
+			Description: "Created by the store test suite",
+			Reporter:    f.user,
+			Assignee:    f.user,
+			Status:      f.status,
+			Type:        f.typ,
+		})
+	}
+
+	e = s.Tickets().NewBatch(f.project, tickets)
+	failIfErr("Ticket New Batch", t, e)
+
+	for i, tk := range tickets {
+		key := f.project.Key + strconv.Itoa(next+i)
+		if tk.Key != key || tk.ID == 0 {
+			t.Fatalf("Expected ticket %s with an ID Got %s %d\n", key, tk.Key, tk.ID)
+		}
+	}
+
+	tk := models.Ticket{Key: tickets[99].Key}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket New Batch", t, e)
+
+	if tk.Summary != tickets[99].Summary {
+		t.Errorf("Expected %s Got %s\n", tickets[99].Summary, tk.Summary)
+	}
+
+	bad := []*models.Ticket{
+		&models.Ticket{
+			Summary:  "Valid batch ticket",
+			Reporter: f.user,
+			Assignee: f.user,
+			Status:   f.status,
+			Type:     f.typ,
+		},
+		&models.Ticket{
+			Reporter: f.user,
+			Assignee: f.user,
+			Status:   f.status,
+			Type:     f.typ,
+		},
+	}
+
+	e = s.Tickets().NewBatch(f.project, bad)
+	if e == nil {
+		t.Errorf("Expected a ticket without a summary to fail\n")
+	}
+
+	after, e := s.Tickets().GetAllByProject(f.project, "")
+	failIfErr("Ticket New Batch", t, e)
+
+	if len(after) != len(existing)+100 {
+		t.Errorf("Expected the failed batch to be rolled back Got %d tickets\n",
+			len(after))
+	}
+}
+
+// keyNumber returns the number of a ticket key in the suite project
+func keyNumber(t *testing.T, f *fixtures, key string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(key, f.project.Key))
+	if err != nil {
+		t.Fatalf("Expected a key in %s Got %s\n", f.project.Key, key)
+	}
+
+	return n
+}
+
+func testConcurrentKeys(t *testing.T, s store.Store, f *fixtures) {
+	var wg sync.WaitGroup
+
+	tickets := make([]models.Ticket, 50)
+	errs := make([]error, len(tickets))
+
+	for i := range tickets {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			tickets[i] = models.Ticket{
+				Summary:     "Concurrent suite ticket " + strconv.Itoa(i),
+				Description: "Created by the store test suite",
+				Reporter:    f.user,
+				Assignee:    f.user,
+				Status:      f.status,
+				Type:        f.typ,
+			}
+
+			errs[i] = s.Tickets().New(f.project, &tickets[i])
+		}(i)
+	}
+
+	wg.Wait()
+
+	keys := make(map[string]bool)
+	for i, tk := range tickets {
+		failIfErr("Ticket Concurrent Keys", t, errs[i])
+
+		if keys[tk.Key] {
+			t.Errorf("Expected unique keys Got %s twice\n", tk.Key)
+		}
+
+		keys[tk.Key] = true
+	}
+}
+
+func testCommentsForTickets(t *testing.T, s store.Store, f *fixtures) {
+	first := newTicket(t, s, f, "First bulk comment ticket")
+	second := newTicket(t, s, f, "Second bulk comment ticket")
+	quiet := newTicket(t, s, f, "Uncommented bulk comment ticket")
+
+	for _, c := range []struct {
+		tk   models.Ticket
+		body string
+	}{
+		{first, "first one"},
+		{second, "second one"},
+		{first, "first two"},
+	} {
+		cm := models.Comment{Body: c.body, Author: f.user}
+		e := s.Tickets().NewComment(c.tk, &cm)
+		failIfErr("Comment New", t, e)
+	}
+
+	comments, e := s.Tickets().GetCommentsForTickets([]int64{first.ID, second.ID, quiet.ID})
+	failIfErr("Comments For Tickets", t, e)
+
+	bodies := func(id int64) []string {
+		var b []string
+		for _, c := range comments[id] {
+			b = append(b, c.Body)
+		}
+
+		return b
+	}
+
+	if b := bodies(first.ID); len(b) != 2 || b[0] != "first one" || b[1] != "first two" {
+		t.Errorf("Expected both comments on %s in order Got %v\n", first.Key, b)
+	}
+
+	if b := bodies(second.ID); len(b) != 1 || b[0] != "second one" {
+		t.Errorf("Expected one comment on %s Got %v\n", second.Key, b)
+	}
+
+	if b := bodies(quiet.ID); len(b) != 0 {
+		t.Errorf("Expected no comments on %s Got %v\n", quiet.Key, b)
+	}
+
+	if c := comments[first.ID]; len(c) > 0 && c[0].Author.Username != f.user.Username {
+		t.Errorf("Expected %s as the author Got %v\n", f.user.Username, c[0].Author)
+	}
+
+	comments, e = s.Tickets().GetCommentsForTickets(nil)
+	failIfErr("Comments For Tickets", t, e)
+
+	if len(comments) != 0 {
+		t.Errorf("Expected no comments for no tickets Got %v\n", comments)
+	}
+}
+
+func testLabels(t *testing.T, s store.Store, f *fixtures) {
+	tk := newTicket(t, s, f, "Labeled suite ticket")
+
+	label := models.Label{Name: "suite" + f.suffix}
+	e := s.Labels().New(&label)
+	failIfErr("Label New", t, e)
+
+	before := updatedDate(t, s, tk)
+
+	e = s.Tickets().AddLabel(tk, label)
+	failIfErr("Ticket Add Label", t, e)
+
+	// The updated date is the ticket's ETag so it has to change with the
+	// labels.
+	if added := updatedDate(t, s, tk); !added.After(before) {
+		t.Errorf("Expected the updated date to move past %v Got %v\n", before, added)
+	}
+
+	e = s.Tickets().AddLabel(tk, models.Label{Name: label.Name})
+	failIfErr("Ticket Add Label", t, e)
+
+	labels, e := s.Tickets().GetLabels(tk)
+	failIfErr("Ticket Get Labels", t, e)
+
+	if len(labels) != 1 || labels[0].ID != label.ID {
+		t.Errorf("Expected only %s Got %v\n", label.Name, labels)
+	}
+
+	got := models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if len(got.Labels) != 1 || got.Labels[0].Name != label.Name {
+		t.Errorf("Expected the ticket to have %s Got %v\n", label.Name, got.Labels)
+	}
+
+	before = updatedDate(t, s, tk)
+
+	e = s.Tickets().RemoveLabel(tk, label)
+	failIfErr("Ticket Remove Label", t, e)
+
+	if removed := updatedDate(t, s, tk); !removed.After(before) {
+		t.Errorf("Expected the updated date to move past %v Got %v\n", before, removed)
+	}
+
+	labels, e = s.Tickets().GetLabels(tk)
+	failIfErr("Ticket Get Labels", t, e)
+
+	if len(labels) != 0 {
+		t.Errorf("Expected no labels Got %v\n", labels)
+	}
+}
+
+// updatedDate returns the stored updated date of the ticket
+func updatedDate(t *testing.T, s store.Store, tk models.Ticket) time.Time {
+	got := models.Ticket{ID: tk.ID}
+	e := s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	return got.UpdatedDate
+}
+
+func testFilters(t *testing.T, s store.Store, f *fixtures) {
+	other := models.User{Username: "filter" + f.suffix, Password: "test"}
+	e := s.Users().New(&other)
+	failIfErr("Ticket Get Filtered", t, e)
+
+	mine := newTicket(t, s, f, "Filtered suite ticket")
+
+	theirs := models.Ticket{
+		Summary:     "Other filtered suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    other,
+		Status:      f.next,
+		Type:        f.typ,
+	}
+
+	e = s.Tickets().New(f.project, &theirs)
+	failIfErr("Ticket Get Filtered", t, e)
+
+	tickets, e := s.Tickets().GetFiltered(store.TicketFilter{
+		AssigneeUsername: other.Username,
+	})
+	failIfErr("Ticket Get Filtered", t, e)
+
+	if len(tickets) != 1 || tickets[0].ID != theirs.ID {
+		t.Errorf("Expected only %s Got %v\n", theirs.Key, tickets)
+	}
+
+	tickets, e = s.Tickets().GetFiltered(store.TicketFilter{
+		ProjectKey:       f.project.Key,
+		StatusID:         f.status.ID,
+		TypeID:           f.typ.ID,
+		AssigneeUsername: f.user.Username,
+	})
+	failIfErr("Ticket Get Filtered", t, e)
+
+	found := false
+	for _, tk := range tickets {
+		if tk.ID == theirs.ID {
+			t.Errorf("Expected %s to not match\n", theirs.Key)
+		}
+
+		if tk.Status.ID != f.status.ID || tk.Assignee.ID != f.user.ID {
+			t.Errorf("Expected only matching tickets Got %v\n", tk)
+		}
+
+		found = found || tk.ID == mine.ID
+	}
+
+	if !found {
+		t.Errorf("Expected %s to match\n", mine.Key)
+	}
+
+	all, e := s.Tickets().GetAll()
+	failIfErr("Ticket Get Filtered", t, e)
+
+	tickets, e = s.Tickets().GetFiltered(store.TicketFilter{})
+	failIfErr("Ticket Get Filtered", t, e)
+
+	if len(tickets) != len(all) {
+		t.Errorf("Expected %d tickets Got %d\n", len(all), len(tickets))
+	}
+}
+
+func testSubtasks(t *testing.T, s store.Store, f *fixtures) {
+	parent := newTicket(t, s, f, "Parent suite ticket")
+
+	child := models.Ticket{
+		Summary:     "Child suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+		ParentID:    parent.ID,
+	}
+
+	e := s.Tickets().New(f.project, &child)
+	failIfErr("Ticket New Subtask", t, e)
+
+	tk := models.Ticket{ID: child.ID}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket Get", t, e)
+
+	if tk.ParentID != parent.ID {
+		t.Errorf("Expected parent %d Got %d\n", parent.ID, tk.ParentID)
+	}
+
+	children, e := s.Tickets().GetChildren(models.Ticket{Key: parent.Key})
+	failIfErr("Ticket Get Children", t, e)
+
+	if len(children) != 1 || children[0].ID != child.ID {
+		t.Errorf("Expected only %s Got %v\n", child.Key, children)
+	}
+
+	other := models.Project{
+		Name: "Other Suite Project",
+		Key:  "SO" + f.suffix,
+		Lead: f.user,
+	}
+
+	e = s.Projects().New(&other)
+	failIfErr("Project New", t, e)
+
+	stray := models.Ticket{
+		Summary:     "Stray suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+		ParentID:    parent.ID,
+	}
+
+	e = s.Tickets().New(other, &stray)
+	if e != store.ErrInvalidParent {
+		t.Errorf("Expected ErrInvalidParent Got %v\n", e)
+	}
+
+	grandchild := stray
+	grandchild.Summary = "Grandchild suite ticket"
+	grandchild.ParentID = child.ID
+
+	e = s.Tickets().New(f.project, &grandchild)
+	failIfErr("Ticket New Subtask", t, e)
+
+	// A ticket can't be made a subtask of it's own subtasks.
+	for _, id := range []int64{parent.ID, child.ID, grandchild.ID} {
+		pt := models.Ticket{ID: parent.ID}
+		e = s.Tickets().Get(&pt)
+		failIfErr("Ticket Get", t, e)
+
+		pt.ParentID = id
+		pt.UpdatedBy = f.user
+		e = s.Tickets().Save(pt)
+		if e != store.ErrInvalidParent {
+			t.Errorf("Expected ErrInvalidParent for parent %d Got %v\n", id, e)
+		}
+	}
+
+	e = s.Tickets().Remove(parent)
+	if e != store.ErrHasChildren {
+		t.Errorf("Expected ErrHasChildren Got %v\n", e)
+	}
+
+	tk.ParentID = 0
+	e = s.Tickets().Save(tk)
+	failIfErr("Ticket Save", t, e)
+
+	e = s.Tickets().Remove(parent)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testByUser(t *testing.T, s store.Store, f *fixtures) {
+	preset := models.Ticket{
+		Key:         "PRESET" + f.suffix,
+		Summary:     "Preset key suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+	}
+
+	e := s.Tickets().New(f.project, &preset)
+	failIfErr("Ticket New", t, e)
+
+	if !strings.HasPrefix(preset.Key, f.project.Key) || preset.ID == 0 {
+		t.Errorf("Expected a generated %s key and an id Got %s %d\n",
+			f.project.Key, preset.Key, preset.ID)
+	}
+
+	assignee := models.User{
+		Username: "assignee" + f.suffix,
+		Password: "test",
+		Email:    "assignee" + f.suffix + "@example.com",
+		FullName: "Assignee User",
+	}
+
+	e = s.Users().New(&assignee)
+	failIfErr("User New", t, e)
+
+	idle := models.User{
+		Username: "idle" + f.suffix,
+		Password: "test",
+		Email:    "idle" + f.suffix + "@example.com",
+		FullName: "Idle User",
+	}
+
+	e = s.Users().New(&idle)
+	failIfErr("User New", t, e)
+
+	tk := models.Ticket{
+		Summary:     "Assigned suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    assignee,
+		Status:      f.status,
+		Type:        f.typ,
+	}
+
+	e = s.Tickets().New(f.project, &tk)
+	failIfErr("Ticket New", t, e)
+
+	tickets, e := s.Tickets().GetByAssignee(models.User{Username: assignee.Username})
+	failIfErr("Ticket Get By Assignee", t, e)
+
+	if len(tickets) != 1 || tickets[0].ID != tk.ID {
+		t.Errorf("Expected only %s Got %v\n", tk.Key, tickets)
+	}
+
+	tickets, e = s.Tickets().GetByAssignee(idle)
+	failIfErr("Ticket Get By Assignee", t, e)
+
+	if tickets == nil || len(tickets) != 0 {
+		t.Errorf("Expected an empty slice Got %#v\n", tickets)
+	}
+
+	tickets, e = s.Tickets().GetByReporter(f.user)
+	failIfErr("Ticket Get By Reporter", t, e)
+
+	if !containsTicket(tickets, tk.ID) {
+		t.Errorf("Expected %s to be reported by %s\n", tk.Key, f.user.Username)
+	}
+
+	tickets, e = s.Tickets().GetByReporter(models.User{Username: assignee.Username})
+	failIfErr("Ticket Get By Reporter", t, e)
+
+	if tickets == nil || len(tickets) != 0 {
+		t.Errorf("Expected an empty slice for the assignee Got %#v\n", tickets)
+	}
+}
+
+func containsTicket(tickets []models.Ticket, id int64) bool {
+	for _, t := range tickets {
+		if t.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testCountByStatus(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{
+		Name: "Stats Suite Project",
+		Key:  "SS" + f.suffix,
+		Lead: f.user,
+	}
+
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	var statuses []models.Status
+	for _, name := range []string{"Stats Open ", "Stats Doing ", "Stats Done "} {
+		st := models.Status{Name: name + f.suffix}
+		e = s.Statuses().New(&st)
+		failIfErr("Status New", t, e)
+
+		statuses = append(statuses, st)
+	}
+
+	w := models.Workflow{
+		Name: "Stats Workflow " + f.suffix,
+		Transitions: map[string][]models.Transition{
+			statuses[0].Name: []models.Transition{
+				models.Transition{Name: "Start", ToStatus: statuses[1]},
+			},
+			statuses[1].Name: []models.Transition{
+				models.Transition{Name: "Finish", ToStatus: statuses[2]},
+			},
+		},
+	}
+
+	e = s.Workflows().New(p, &w)
+	failIfErr("Workflow New", t, e)
+
+	for i, st := range []models.Status{statuses[0], statuses[0], statuses[1]} {
+		tk := models.Ticket{
+			Summary:     fmt.Sprintf("Stats suite ticket %d", i),
+			Description: "Created by the store test suite",
+			Reporter:    f.user,
+			Assignee:    f.user,
+			Status:      st,
+			Type:        f.typ,
+		}
+
+		e = s.Tickets().New(p, &tk)
+		failIfErr("Ticket New", t, e)
+	}
+
+	counts, e := s.Tickets().CountByStatus(models.Project{Key: p.Key})
+	failIfErr("Ticket Count By Status", t, e)
+
+	expected := map[string]int{
+		statuses[0].Name: 2,
+		statuses[1].Name: 1,
+		statuses[2].Name: 0,
+	}
+
+	if len(counts) != len(expected) {
+		t.Errorf("Expected %v Got %v\n", expected, counts)
+	}
+
+	for name, n := range expected {
+		if c, ok := counts[name]; !ok || c != n {
+			t.Errorf("Expected %d tickets in %s Got %v\n", n, name, counts)
+		}
+	}
+
+	all, e := s.Tickets().CountAllByStatus()
+	failIfErr("Ticket Count All By Status", t, e)
+
+	if !reflect.DeepEqual(all[p.Key], expected) {
+		t.Errorf("Expected %v for %s Got %v\n", expected, p.Key, all[p.Key])
+	}
+}
+
+func testFieldValues(t *testing.T, s store.Store, f *fixtures) {
+	blocker := models.Field{Name: "Suite Blocker " + f.suffix, DataType: "BOOL"}
+	e := s.Fields().New(&blocker)
+	failIfErr("Field New", t, e)
+
+	flagged := models.Ticket{
+		Summary:     "Bool field suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+		Fields: []models.FieldValue{
+			{Name: blocker.Name, DataType: "BOOL", Value: true},
+		},
+	}
+
+	e = s.Tickets().New(f.project, &flagged)
+	failIfErr("Ticket New", t, e)
+
+	flagged = models.Ticket{Key: flagged.Key}
+	e = s.Tickets().Get(&flagged)
+	failIfErr("Ticket Get", t, e)
+
+	if len(flagged.Fields) != 1 || flagged.Fields[0].Value != true {
+		t.Errorf("Expected %s to be true Got %v\n", blocker.Name, flagged.Fields)
+	}
+
+	reviewed := models.Field{Name: "Suite Reviewed " + f.suffix, DataType: "BOOL"}
+	e = s.Fields().New(&reviewed)
+	failIfErr("Field New", t, e)
+
+	// Saving a value for a field the ticket doesn't have yet adds it and
+	// saving it again without it's ID updates it rather than adding another.
+	for _, v := range []bool{true, false} {
+		flagged.UpdatedBy = f.user
+		flagged.Fields = []models.FieldValue{
+			{Name: reviewed.Name, DataType: "BOOL", Value: v},
+		}
+
+		e = s.Tickets().Save(flagged)
+		failIfErr("Ticket Save", t, e)
+
+		flagged = models.Ticket{Key: flagged.Key}
+		e = s.Tickets().Get(&flagged)
+		failIfErr("Ticket Get", t, e)
+
+		values := make(map[string]interface{})
+		for _, fv := range flagged.Fields {
+			values[fv.Name] = fv.Value
+		}
+
+		if len(flagged.Fields) != 2 || values[blocker.Name] != true ||
+			values[reviewed.Name] != v {
+			t.Errorf("Expected %s to be true and %s to be %v Got %v\n",
+				blocker.Name, reviewed.Name, v, flagged.Fields)
+		}
+	}
+
+	e = s.Tickets().Remove(flagged)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testFieldOptions(t *testing.T, s store.Store, f *fixtures) {
+	severity := models.Field{Name: "Suite Severity " + f.suffix, DataType: "OPT"}
+	e := s.Fields().New(&severity)
+	failIfErr("Field New", t, e)
+
+	platforms := models.Field{Name: "Suite Platforms " + f.suffix, DataType: "MULTI_OPT"}
+	e = s.Fields().New(&platforms)
+	failIfErr("Field New", t, e)
+
+	for _, opt := range []string{"HIGH", "LOW"} {
+		e = s.Fields().AddFieldOption(severity.ID, opt)
+		failIfErr("Field Add Option", t, e)
+	}
+
+	for _, opt := range []string{"linux", "mac"} {
+		e = s.Fields().AddFieldOption(platforms.ID, opt)
+		failIfErr("Field Add Option", t, e)
+	}
+
+	e = s.Fields().AddFieldOption(severity.ID, "HIGH")
+	if !errors.Is(e, store.ErrDuplicateEntry) {
+		t.Errorf("Expected %v Got %v\n", store.ErrDuplicateEntry, e)
+	}
+
+	options, e := s.Fields().GetFieldOptions(severity.ID)
+	failIfErr("Field Get Options", t, e)
+
+	if fmt.Sprint(options) != "[HIGH LOW]" {
+		t.Errorf("Expected [HIGH LOW] Got %v\n", options)
+	}
+
+	_, e = s.Fields().GetFieldOptions(-1)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected %v Got %v\n", store.ErrNotFound, e)
+	}
+
+	selected := models.Ticket{
+		Summary:     "Option field suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+		Fields: []models.FieldValue{
+			{Name: severity.Name, DataType: "OPT",
+				Value: models.FieldOption{Selected: "HIGH"}},
+			{Name: platforms.Name, DataType: "MULTI_OPT", Value: []string{"mac"}},
+		},
+	}
+
+	e = s.Tickets().New(f.project, &selected)
+	failIfErr("Ticket New", t, e)
+
+	e = s.Fields().RemoveFieldOption(severity.ID, "HIGH")
+	if e != store.ErrInUse {
+		t.Errorf("Expected HIGH to be in use Got %v\n", e)
+	}
+
+	e = s.Fields().RemoveFieldOption(platforms.ID, "mac")
+	if e != store.ErrInUse {
+		t.Errorf("Expected mac to be in use Got %v\n", e)
+	}
+
+	e = s.Fields().RemoveFieldOption(severity.ID, "LOW")
+	failIfErr("Field Remove Option", t, e)
+
+	e = s.Fields().RemoveFieldOption(platforms.ID, "linux")
+	failIfErr("Field Remove Option", t, e)
+
+	e = s.Fields().RemoveFieldOption(severity.ID, "LOW")
+	if e != store.ErrNotFound {
+		t.Errorf("Expected %v Got %v\n", store.ErrNotFound, e)
+	}
+
+	options, e = s.Fields().GetFieldOptions(severity.ID)
+	failIfErr("Field Get Options", t, e)
+
+	if fmt.Sprint(options) != "[HIGH]" {
+		t.Errorf("Expected [HIGH] Got %v\n", options)
+	}
+
+	e = s.Tickets().Remove(selected)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testRequiredFields(t *testing.T, s store.Store, f *fixtures) {
+	bug := models.TicketType{Name: "Suite Bug " + f.suffix}
+	e := s.Types().New(&bug)
+	failIfErr("Type New", t, e)
+
+	version := models.Field{Name: "Suite Version " + f.suffix, DataType: "STRING"}
+	e = s.Fields().New(&version)
+	failIfErr("Field New", t, e)
+
+	version.Required = true
+	e = s.Fields().AddToProject(f.project, &version, bug)
+	failIfErr("Field Add To Project", t, e)
+
+	fields, e := s.Fields().GetFieldsForType(f.project, bug)
+	failIfErr("Field Get Fields For Type", t, e)
+
+	if len(fields) != 1 || fields[0].ID != version.ID || !fields[0].Required {
+		t.Errorf("Expected %s to be required Got %v\n", version.Name, fields)
+	}
+
+	fields, e = s.Fields().GetFieldsForType(f.project, f.typ)
+	failIfErr("Field Get Fields For Type", t, e)
+
+	if len(fields) != 0 {
+		t.Errorf("Expected no fields for %s Got %v\n", f.typ.Name, fields)
+	}
+
+	tk := models.Ticket{
+		Summary:     "Required field suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Assignee:    f.user,
+		Status:      f.status,
+		Type:        bug,
+	}
+
+	e = s.Tickets().New(f.project, &tk)
+	if e != store.ErrMissingRequiredField {
+		t.Errorf("Expected ErrMissingRequiredField Got %v\n", e)
+	}
+
+	tk.Fields = []models.FieldValue{
+		{Name: version.Name, DataType: "STRING", Value: "1.0"},
+	}
+
+	e = s.Tickets().New(f.project, &tk)
+	failIfErr("Ticket New", t, e)
+
+	e = s.Tickets().Remove(tk)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testAssignTicket(t *testing.T, s store.Store, f *fixtures) {
+	tk := models.Ticket{
+		Summary:     "Assignment suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+	}
+
+	e := s.Tickets().New(f.project, &tk)
+	failIfErr("Ticket New", t, e)
+
+	e = s.Tickets().AssignTicket(tk, models.User{Username: "nobody" + f.suffix})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing assignee Got %v\n", e)
+	}
+
+	e = s.Tickets().AssignTicket(models.Ticket{Key: "NOPE-" + f.suffix}, f.user)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing ticket Got %v\n", e)
+	}
+
+	tk.UpdatedBy = f.user
+	e = s.Tickets().AssignTicket(tk, models.User{Username: f.user.Username})
+	failIfErr("Ticket Assign", t, e)
+
+	got := models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if got.Assignee.ID != f.user.ID {
+		t.Errorf("Expected the ticket to be assigned to %s Got %v\n",
+			f.user.Username, got.Assignee)
+	}
+
+	// A save based on the ticket from before the assignment is stale.
+	if got.Version != tk.Version+1 {
+		t.Errorf("Expected version %d Got %d\n", tk.Version+1, got.Version)
+	}
+
+	stale := tk
+	stale.Summary = "Stale assignment suite ticket"
+	e = s.Tickets().Save(stale)
+	if e != store.ErrStaleObject {
+		t.Errorf("Expected ErrStaleObject Got %v\n", e)
+	}
+
+	e = s.Tickets().AssignTicket(tk, models.User{})
+	failIfErr("Ticket Unassign", t, e)
+
+	got = models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if got.Assignee.ID != 0 {
+		t.Errorf("Expected the ticket to be unassigned Got %v\n", got.Assignee)
+	}
+
+	history, e := s.Tickets().GetHistory(tk)
+	failIfErr("Ticket Get History", t, e)
+
+	if len(history) != 2 || history[0].Field != "assignee" ||
+		history[0].NewValue != f.user.Username || history[1].OldValue != f.user.Username ||
+		history[1].NewValue != "" || history[0].User.ID != f.user.ID {
+		t.Errorf("Expected the assignment history Got %v\n", history)
+	}
+
+	e = s.Tickets().Remove(tk)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testUnassigned(t *testing.T, s store.Store, f *fixtures) {
+	tk := models.Ticket{
+		Summary:     "Unassigned suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+	}
+
+	e := s.Tickets().New(f.project, &tk)
+	failIfErr("Ticket New", t, e)
+
+	// The assignee is set to check Get clears it rather than leaving it.
+	got := models.Ticket{Key: tk.Key, Assignee: f.user}
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if got.Assignee != (models.User{}) {
+		t.Errorf("Expected an empty assignee Got %v\n", got.Assignee)
+	}
+
+	if got.Reporter.ID != f.user.ID {
+		t.Errorf("Expected the reporter %s Got %v\n", f.user.Username, got.Reporter)
+	}
+
+	all, e := s.Tickets().GetAll()
+	failIfErr("Ticket Get All", t, e)
+
+	byProject, e := s.Tickets().GetAllByProject(f.project, "")
+	failIfErr("Ticket Get All By Project", t, e)
+
+	for name, tickets := range map[string][]models.Ticket{
+		"GetAll":          all,
+		"GetAllByProject": byProject,
+	} {
+		found := false
+		for _, ticket := range tickets {
+			if ticket.Key == tk.Key {
+				found = ticket.Assignee == (models.User{})
+			}
+		}
+
+		if !found {
+			t.Errorf("Expected %s to return %s with an empty assignee\n",
+				name, tk.Key)
+		}
+	}
+
+	e = s.Tickets().Remove(tk)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testProjectForTicket(t *testing.T, s store.Store, f *fixtures) {
+	tk := models.Ticket{
+		Summary:     "Project lookup suite ticket",
+		Description: "Created by the store test suite",
+		Reporter:    f.user,
+		Status:      f.status,
+		Type:        f.typ,
+	}
+
+	e := s.Tickets().New(f.project, &tk)
+	failIfErr("Ticket New", t, e)
+
+	p, e := s.Projects().GetProjectForTicket(tk.Key)
+	failIfErr("Project Get Project For Ticket", t, e)
+
+	if p.ID != f.project.ID || p.Key != f.project.Key || p.Lead.ID != f.user.ID {
+		t.Errorf("Expected project %s Got %v\n", f.project.Key, p)
+	}
+
+	_, e = s.Projects().GetProjectForTicket("NOPE-" + f.suffix)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	e = s.Tickets().Remove(tk)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testGetByKey(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{Name: "Engineering Suite Project", Key: "ENG" + f.suffix,
+		Lead: f.user}
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	first := newTicketIn(t, s, f, p, "First keyed suite ticket")
+	second := newTicketIn(t, s, f, p, "Second keyed suite ticket")
+
+	tk, e := s.Tickets().GetByKey(first.Key)
+	failIfErr("Ticket Get By Key", t, e)
+
+	if tk.ID != first.ID || tk.Summary != first.Summary {
+		t.Errorf("Expected %s Got %v\n", first.Key, tk)
+	}
+
+	_, e = s.Tickets().GetByKey("NOPE-" + f.suffix)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	// With an ID the key isn't used to find the ticket.
+	tk = models.Ticket{ID: second.ID, Key: first.Key}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket Get", t, e)
+
+	if tk.ID != second.ID {
+		t.Errorf("Expected %s Got %v\n", second.Key, tk)
+	}
+
+	tk = models.Ticket{Key: second.Key}
+	e = s.Tickets().Get(&tk)
+	failIfErr("Ticket Get", t, e)
+
+	if tk.ID != second.ID {
+		t.Errorf("Expected %s Got %v\n", second.Key, tk)
+	}
+
+	e = s.Tickets().Get(&models.Ticket{})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an empty ticket Got %v\n", e)
+	}
+}
+
+func testRecentlyUpdated(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{Name: "Recent Suite Project", Key: "RU" + f.suffix,
+		Lead: f.user}
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	var keys []string
+
+	for _, summary := range []string{"Older", "Newer"} {
+		tk := models.Ticket{
+			Summary:     summary + " suite ticket",
+			Description: "Created by the store test suite",
+			Reporter:    f.user,
+			Status:      f.status,
+			Type:        f.typ,
+		}
+
+		e = s.Tickets().New(p, &tk)
+		failIfErr("Ticket New", t, e)
+
+		keys = append(keys, tk.Key)
+	}
+
+	recent, e := s.Tickets().GetRecentlyUpdated(p, 0)
+	failIfErr("Ticket Get Recently Updated", t, e)
+
+	if len(recent) != 2 || recent[0].Key != keys[1] || recent[1].Key != keys[0] {
+		t.Errorf("Expected %s then %s Got %v\n", keys[1], keys[0], recent)
+	}
+
+	older := models.Ticket{Key: keys[0]}
+	e = s.Tickets().Get(&older)
+	failIfErr("Ticket Get", t, e)
+
+	older.Summary = "Updated suite ticket"
+	older.UpdatedBy = f.user
+	e = s.Tickets().Save(older)
+	failIfErr("Ticket Save", t, e)
+
+	recent, e = s.Tickets().GetRecentlyUpdated(p, 1)
+	failIfErr("Ticket Get Recently Updated", t, e)
+
+	if len(recent) != 1 || recent[0].Key != keys[0] {
+		t.Errorf("Expected only the updated %s Got %v\n", keys[0], recent)
+	}
+
+	e = s.Projects().Remove(p, true)
+	failIfErr("Project Remove", t, e)
+}
+
+func testCommentCount(t *testing.T, s store.Store, f *fixtures) {
+	tk := newTicket(t, s, f, "Counted suite ticket")
+
+	got := models.Ticket{Key: tk.Key}
+	e := s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if got.CommentCount != 0 {
+		t.Errorf("Expected no comments Got %d\n", got.CommentCount)
+	}
+
+	for i := 0; i < 2; i++ {
+		c := models.Comment{Body: "A counted suite comment", Author: f.user}
+		e = s.Tickets().NewComment(tk, &c)
+		failIfErr("Comment New", t, e)
+	}
+
+	got = models.Ticket{Key: tk.Key}
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if got.CommentCount != 2 || got.Comments != nil {
+		t.Errorf("Expected 2 comments without loading them Got %d %v\n",
+			got.CommentCount, got.Comments)
+	}
+
+	tickets, e := s.Tickets().GetAllByProject(f.project, "")
+	failIfErr("Ticket Get All By Project", t, e)
+
+	for _, ticket := range tickets {
+		if ticket.Key == tk.Key && ticket.CommentCount != 2 {
+			t.Errorf("Expected 2 comments in the list Got %d\n", ticket.CommentCount)
+		}
+	}
+
+	e = s.Tickets().Remove(tk)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testConcurrentComments(t *testing.T, s store.Store, f *fixtures) {
+	tk := newTicket(t, s, f, "Concurrently commented suite ticket")
+
+	removed := make([]models.Comment, 10)
+	for i := range removed {
+		removed[i] = models.Comment{Body: "A removed suite comment", Author: f.user}
+		e := s.Tickets().NewComment(tk, &removed[i])
+		failIfErr("Comment New", t, e)
+	}
+
+	// Comments are added while the earlier ones are removed.
+	var wg sync.WaitGroup
+
+	added := make([]error, 20)
+	errs := make([]error, len(removed))
+
+	for i := range added {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			c := models.Comment{Body: "A concurrent suite comment", Author: f.user}
+			added[i] = s.Tickets().NewComment(tk, &c)
+		}(i)
+	}
+
+	for i := range removed {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.Tickets().RemoveComment(removed[i])
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, e := range append(added, errs...) {
+		failIfErr("Ticket Concurrent Comments", t, e)
+	}
+
+	got := models.Ticket{Key: tk.Key}
+	e := s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if got.CommentCount != len(added) {
+		t.Errorf("Expected %d comments Got %d\n", len(added), got.CommentCount)
+	}
+
+	comments, e := s.Tickets().GetComments(tk)
+	failIfErr("Ticket Get Comments", t, e)
+
+	if len(comments) != got.CommentCount {
+		t.Errorf("Expected the count to match %d comments Got %d\n",
+			len(comments), got.CommentCount)
+	}
+
+	e = s.Tickets().Remove(tk)
+	failIfErr("Ticket Remove", t, e)
+}
+
+func testCreatedOrder(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{Name: "Created Suite Project", Key: "CO" + f.suffix,
+		Lead: f.user}
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	var keys []string
+
+	for i := 0; i < 3; i++ {
+		tk := models.Ticket{
+			Summary:  "Created suite ticket " + strconv.Itoa(i),
+			Reporter: f.user,
+			Status:   f.status,
+			Type:     f.typ,
+		}
+
+		e = s.Tickets().New(p, &tk)
+		failIfErr("Ticket New", t, e)
+
+		keys = append(keys, tk.Key)
+	}
+
+	newest := []string{keys[2], keys[1], keys[0]}
+
+	for dir, expected := range map[string][]string{
+		"":     newest,
+		"desc": newest,
+		"ASC":  keys,
+	} {
+		tickets, e := s.Tickets().GetAllByProject(p, dir)
+		failIfErr("Ticket Get All By Project", t, e)
+
+		var got []string
+		for _, tk := range tickets {
+			got = append(got, tk.Key)
+		}
+
+		if strings.Join(got, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected %v for %q Got %v\n", expected, dir, got)
+		}
+	}
+
+	_, e = s.Tickets().GetAllByProject(p, "sideways")
+	if e != store.ErrInvalidDirection {
+		t.Errorf("Expected ErrInvalidDirection Got %v\n", e)
+	}
+}
+
+func testKeyCollision(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{Name: "Collision Suite Project", Key: "KC" + f.suffix,
+		Lead: f.user}
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	// The first ticket of this project has the key the 11th ticket of p
+	// would get.
+	other := models.Project{Name: "Other Collision Suite Project",
+		Key: p.Key + "1", Lead: f.user}
+	e = s.Projects().New(&other)
+	failIfErr("Project New", t, e)
+
+	taken := newTicketIn(t, s, f, other, "Colliding suite ticket")
+	if taken.Key != p.Key+"11" {
+		t.Fatalf("Expected %s11 Got %s\n", p.Key, taken.Key)
+	}
+
+	for i := 0; i < 10; i++ {
+		newTicketIn(t, s, f, p, "Collision suite ticket")
+	}
+
+	tk := newTicketIn(t, s, f, p, "Renumbered suite ticket")
+	if tk.Key != p.Key+"12" {
+		t.Errorf("Expected the taken key to be skipped for %s12 Got %s\n",
+			p.Key, tk.Key)
+	}
+}
+
+func testTimeTracking(t *testing.T, s store.Store, f *fixtures) {
+	tk := newTicket(t, s, f, "Time tracking suite ticket")
+
+	tk.OriginalEstimate = 3600
+	tk.RemainingEstimate = 3600
+	tk.UpdatedBy = f.user
+
+	e := s.Tickets().Save(tk)
+	failIfErr("Ticket Save", t, e)
+
+	before := models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(&before)
+	failIfErr("Ticket Get", t, e)
+
+	e = s.Tickets().LogWork(tk, 1800, f.user)
+	failIfErr("Ticket LogWork", t, e)
+
+	got := models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	// Saving the ticket read before the work was logged would lose it.
+	if got.Version != before.Version+1 {
+		t.Errorf("Expected version %d Got %d\n", before.Version+1, got.Version)
+	}
+
+	before.UpdatedBy = f.user
+	e = s.Tickets().Save(before)
+	if e != store.ErrStaleObject {
+		t.Errorf("Expected ErrStaleObject Got %v\n", e)
+	}
+
+	if got.OriginalEstimate != 3600 || got.TimeSpent != 1800 ||
+		got.RemainingEstimate != 1800 {
+		t.Errorf("Expected 3600 estimated, 1800 spent and 1800 remaining Got %d, %d and %d\n",
+			got.OriginalEstimate, got.TimeSpent, got.RemainingEstimate)
+	}
+
+	// Logging more than is remaining leaves nothing remaining rather than a
+	// negative estimate.
+	e = s.Tickets().LogWork(tk, 3600, f.user)
+	failIfErr("Ticket LogWork", t, e)
+
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if got.TimeSpent != 5400 || got.RemainingEstimate != 0 {
+		t.Errorf("Expected 5400 spent and 0 remaining Got %d and %d\n",
+			got.TimeSpent, got.RemainingEstimate)
+	}
+
+	e = s.Tickets().LogWork(tk, 0, f.user)
+	if e != store.ErrInvalidDuration {
+		t.Errorf("Expected ErrInvalidDuration Got %v\n", e)
+	}
+
+	e = s.Tickets().LogWork(models.Ticket{Key: "NOPE" + f.suffix + "1"}, 60, f.user)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing ticket Got %v\n", e)
+	}
+
+	got.RemainingEstimate = -1
+	e = s.Tickets().Save(got)
+	if e != store.ErrInvalidDuration {
+		t.Errorf("Expected ErrInvalidDuration for a negative estimate Got %v\n", e)
+	}
+}
+
+func testWorklogs(t *testing.T, s store.Store, f *fixtures) {
+	tk := newTicket(t, s, f, "Worklog suite ticket")
+
+	total, e := s.Tickets().TotalTimeSpent(tk)
+	failIfErr("Ticket TotalTimeSpent", t, e)
+
+	if total != 0 {
+		t.Errorf("Expected no time spent on a new ticket Got %d\n", total)
+	}
+
+	e = s.Tickets().LogWork(tk, 1200, f.user)
+	failIfErr("Ticket LogWork", t, e)
+
+	e = s.Tickets().LogWork(tk, 300, f.user)
+	failIfErr("Ticket LogWork", t, e)
+
+	worklogs, e := s.Tickets().GetWorklogs(tk)
+	failIfErr("Ticket GetWorklogs", t, e)
+
+	if len(worklogs) != 2 {
+		t.Fatalf("Expected 2 worklogs Got %v\n", worklogs)
+	}
+
+	if worklogs[0].Seconds != 1200 || worklogs[1].Seconds != 300 {
+		t.Errorf("Expected 1200 then 300 seconds Got %v\n", worklogs)
+	}
+
+	for _, wl := range worklogs {
+		if wl.Author.ID != f.user.ID || wl.Author.Username != f.user.Username {
+			t.Errorf("Expected the worklog by %s Got %v\n", f.user.Username, wl.Author)
+		}
+
+		if wl.Author.Password != "" {
+			t.Errorf("Expected the author's password to be removed Got %v\n", wl.Author)
+		}
+
+		if wl.CreatedDate.IsZero() {
+			t.Errorf("Expected the worklog to have a created date Got %v\n", wl)
+		}
+	}
+
+	total, e = s.Tickets().TotalTimeSpent(tk)
+	failIfErr("Ticket TotalTimeSpent", t, e)
+
+	if total != 1500 {
+		t.Errorf("Expected 1500 seconds spent Got %d\n", total)
+	}
+
+	_, e = s.Tickets().TotalTimeSpent(models.Ticket{Key: "NOPE" + f.suffix + "1"})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing ticket Got %v\n", e)
+	}
+}
+
+func testOverdue(t *testing.T, s store.Store, f *fixtures) {
+	closed := models.Status{Name: "Closed suite status " + f.suffix, Closed: true}
+	e := s.Statuses().New(&closed)
+	failIfErr("Status New", t, e)
+
+	past := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	future := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+
+	newDue := func(summary string, due time.Time, st models.Status) models.Ticket {
+		tk := models.Ticket{
+			Summary:  summary,
+			Reporter: f.user,
+			Status:   st,
+			Type:     f.typ,
+			DueDate:  due,
+			Priority: models.PriorityHigh,
+		}
+
+		e := s.Tickets().New(f.project, &tk)
+		failIfErr("Ticket New", t, e)
+		return tk
+	}
+
+	overdue := newDue("Overdue suite ticket", past, f.status)
+	upcoming := newDue("Upcoming suite ticket", future, f.status)
+	finished := newDue("Finished suite ticket", past, closed)
+
+	got := models.Ticket{ID: overdue.ID}
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if !got.DueDate.Equal(past) || got.Priority != models.PriorityHigh {
+		t.Errorf("Expected due %v with high priority Got %v and %d\n",
+			past, got.DueDate, got.Priority)
+	}
+
+	tickets, e := s.Tickets().GetOverdue(f.project)
+	failIfErr("Ticket GetOverdue", t, e)
+
+	found := map[int64]bool{}
+	for _, tk := range tickets {
+		found[tk.ID] = true
+	}
+
+	if !found[overdue.ID] {
+		t.Errorf("Expected %s to be overdue Got %v\n", overdue.Key, tickets)
+	}
+
+	if found[upcoming.ID] || found[finished.ID] {
+		t.Errorf("Expected only tickets past due and not closed Got %v\n", tickets)
+	}
+
+	// Clearing the due date means the ticket is no longer overdue.
+	got.DueDate = time.Time{}
+	got.Priority = models.PriorityLow
+	got.UpdatedBy = f.user
+	e = s.Tickets().Save(got)
+	failIfErr("Ticket Save", t, e)
+
+	e = s.Tickets().Get(&got)
+	failIfErr("Ticket Get", t, e)
+
+	if !got.DueDate.IsZero() || got.Priority != models.PriorityLow {
+		t.Errorf("Expected no due date with low priority Got %v and %d\n",
+			got.DueDate, got.Priority)
+	}
+
+	tickets, e = s.Tickets().GetOverdue(f.project)
+	failIfErr("Ticket GetOverdue", t, e)
+
+	for _, tk := range tickets {
+		if tk.ID == overdue.ID {
+			t.Errorf("Expected %s to no longer be overdue\n", overdue.Key)
+		}
+	}
+
+	bad := models.Ticket{Summary: "Bad priority", Reporter: f.user,
+		Status: f.status, Type: f.typ, Priority: models.Priority(42)}
+	e = s.Tickets().New(f.project, &bad)
+	if e != store.ErrInvalidPriority {
+		t.Errorf("Expected ErrInvalidPriority Got %v\n", e)
+	}
+}
+
+func testSuggestAssignee(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{Name: "Triage Suite Project", Key: "W" + f.suffix, Lead: f.user}
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	closed := models.Status{Name: "Triaged suite status " + f.suffix, Closed: true}
+	e = s.Statuses().New(&closed)
+	failIfErr("Status New", t, e)
+
+	// Members get open tickets by their load and closed ones which a
+	// correct suggestion has to ignore.
+	loads := []struct {
+		name   string
+		open   int
+		closed int
+	}{
+		{"busy", 3, 0},
+		{"light", 1, 0},
+		{"finished", 2, 4},
+	}
+
+	members := make(map[string]models.User)
+	for _, l := range loads {
+		u := models.User{
+			Username: l.name + f.suffix,
+			Password: "test",
+			Email:    l.name + f.suffix + "@example.com",
+		}
+
+		e = s.Users().New(&u)
+		failIfErr("User New", t, e)
+
+		e = s.Projects().AddMember(p, u, models.CoreR)
+		failIfErr("Project Add Member", t, e)
+
+		for i := 0; i < l.open+l.closed; i++ {
+			st := f.status
+			if i >= l.open {
+				st = closed
+			}
+
+			tk := models.Ticket{
+				Summary:  "Triage suite ticket",
+				Reporter: f.user,
+				Assignee: u,
+				Status:   st,
+				Type:     f.typ,
+			}
+
+			e = s.Tickets().New(p, &tk)
+			failIfErr("Ticket New", t, e)
+		}
+
+		members[l.name] = u
+	}
+
+	u, e := s.Projects().SuggestAssignee(models.Project{Key: p.Key})
+	failIfErr("Project Suggest Assignee", t, e)
+
+	if u.ID != members["light"].ID || u.Password != "" {
+		t.Errorf("Expected %s Got %v\n", members["light"].Username, u)
+	}
+
+	// Removed users aren't suggested however idle they are.
+	e = s.Users().Remove(members["light"])
+	failIfErr("User Remove", t, e)
+
+	u, e = s.Projects().SuggestAssignee(models.Project{Key: p.Key})
+	failIfErr("Project Suggest Assignee", t, e)
+
+	if u.ID != members["finished"].ID {
+		t.Errorf("Expected %s Got %v\n", members["finished"].Username, u)
+	}
+
+	_, e = s.Projects().SuggestAssignee(models.Project{Key: "MISSING" + f.suffix})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	empty := models.Project{Name: "Empty Suite Project", Key: "WE" + f.suffix, Lead: f.user}
+	e = s.Projects().New(&empty)
+	failIfErr("Project New", t, e)
+
+	_, e = s.Projects().SuggestAssignee(empty)
+	if e != store.ErrNoMembers {
+		t.Errorf("Expected ErrNoMembers Got %v\n", e)
+	}
+}
+
+func testSavedFilters(t *testing.T, s store.Store, f *fixtures) {
+	tk := newTicket(t, s, f, "Saved filter suite ticket")
+
+	saved := store.SavedFilter{
+		Name:   "Suite filter",
+		Filter: store.TicketFilter{ProjectKey: f.project.Key, StatusID: f.next.ID},
+	}
+
+	e := s.Tickets().SaveFilter(f.user, &saved)
+	failIfErr("Ticket SaveFilter", t, e)
+
+	if saved.ID == 0 {
+		t.Errorf("Expected the filter to get an ID\n")
+	}
+
+	// Saving under the same name replaces the filter rather than adding
+	// another one.
+	replaced := store.SavedFilter{
+		Name:   saved.Name,
+		Filter: store.TicketFilter{ProjectKey: f.project.Key, StatusID: tk.Status.ID},
+	}
+
+	e = s.Tickets().SaveFilter(f.user, &replaced)
+	failIfErr("Ticket SaveFilter", t, e)
+
+	if replaced.ID != saved.ID {
+		t.Errorf("Expected the filter %d to be replaced Got %d\n", saved.ID, replaced.ID)
+	}
+
+	filters, e := s.Tickets().GetFilters(f.user)
+	failIfErr("Ticket GetFilters", t, e)
+
+	if len(filters) != 1 || filters[0].Filter != replaced.Filter {
+		t.Errorf("Expected the replaced filter Got %v\n", filters)
+	}
+
+	tickets, e := s.Tickets().RunFilter(saved.Name, f.user)
+	failIfErr("Ticket RunFilter", t, e)
+
+	expected, e := s.Tickets().GetFiltered(replaced.Filter)
+	failIfErr("Ticket GetFiltered", t, e)
+
+	if len(tickets) != len(expected) {
+		t.Errorf("Expected the same tickets as GetFiltered Got %d not %d\n",
+			len(tickets), len(expected))
+	}
+
+	found := false
+	for _, got := range tickets {
+		found = found || got.ID == tk.ID
+	}
+
+	if !found {
+		t.Errorf("Expected %s in the filter's tickets\n", tk.Key)
+	}
+
+	e = s.Tickets().SaveFilter(f.user, &store.SavedFilter{})
+	if e != store.ErrMissingField {
+		t.Errorf("Expected ErrMissingField without a name Got %v\n", e)
+	}
+
+	e = s.Tickets().DeleteFilter(f.user, saved.Name)
+	failIfErr("Ticket DeleteFilter", t, e)
+
+	_, e = s.Tickets().RunFilter(saved.Name, f.user)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a deleted filter Got %v\n", e)
+	}
+
+	e = s.Tickets().DeleteFilter(f.user, saved.Name)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting twice Got %v\n", e)
+	}
+}
+
+func testInvalidReferences(t *testing.T, s store.Store, f *fixtures) {
+	missing := models.User{ID: f.user.ID + 1000000}
+	missingStatus := models.Status{ID: f.next.ID + 1000000}
+
+	tests := []struct {
+		field  string
+		ticket models.Ticket
+	}{
+		{"assignee", models.Ticket{Assignee: missing, Status: f.status}},
+		{"status", models.Ticket{Assignee: f.user, Status: missingStatus}},
+	}
+
+	for _, test := range tests {
+		tk := test.ticket
+		tk.Summary = "Invalid reference suite ticket"
+		tk.Reporter = f.user
+		tk.Type = f.typ
+
+		e := s.Tickets().New(f.project, &tk)
+		if !errors.Is(e, store.ErrInvalidReference) {
+			t.Errorf("Expected ErrInvalidReference for a missing %s Got %v\n",
+				test.field, e)
+		}
+
+		var ref store.ReferenceError
+		if !errors.As(e, &ref) || ref.Field != test.field {
+			t.Errorf("Expected the error to name %s Got %v\n", test.field, e)
+		}
+	}
+}
+
+func testOrderByField(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{Name: "Ordered Suite Project", Key: "OF" + f.suffix,
+		Lead: f.user}
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	points := models.Field{Name: "Suite Points " + f.suffix, DataType: "INT"}
+	e = s.Fields().New(&points)
+	failIfErr("Field New", t, e)
+
+	var keys []string
+
+	for _, v := range []interface{}{5, 1, 3, nil} {
+		tk := models.Ticket{
+			Summary:     "Ordered suite ticket",
+			Description: "Created by the store test suite",
+			Reporter:    f.user,
+			Assignee:    f.user,
+			Status:      f.status,
+			Type:        f.typ,
+		}
+
+		if v != nil {
+			tk.Fields = []models.FieldValue{
+				{Name: points.Name, DataType: "INT", Value: v},
+			}
+		}
+
+		e = s.Tickets().New(p, &tk)
+		failIfErr("Ticket New", t, e)
+
+		keys = append(keys, tk.Key)
+	}
+
+	orders := []struct {
+		desc bool
+		keys []string
+	}{
+		{false, []string{keys[1], keys[2], keys[0], keys[3]}},
+		{true, []string{keys[0], keys[2], keys[1], keys[3]}},
+	}
+
+	for _, o := range orders {
+		opts := store.PageOptions{
+			OrderBy: store.FieldOrderPrefix + points.Name,
+			Desc:    o.desc,
+		}
+
+		tks, total, e := s.Tickets().GetAllByProjectPaged(p, opts)
+		failIfErr("Ticket Get All By Project Paged", t, e)
+
+		if total != len(o.keys) || len(tks) != len(o.keys) {
+			t.Fatalf("Expected %d tickets Got %d of %d\n", len(o.keys), len(tks), total)
+		}
+
+		for i := range tks {
+			if tks[i].Key != o.keys[i] {
+				t.Errorf("Expected %s at %d ordering desc %v Got %s\n",
+					o.keys[i], i, o.desc, tks[i].Key)
+			}
+		}
+	}
+
+	_, _, e = s.Tickets().GetAllByProjectPaged(p,
+		store.PageOptions{OrderBy: store.FieldOrderPrefix + "No Such Field " + f.suffix})
+	if e != store.ErrInvalidOrderBy {
+		t.Errorf("Expected ErrInvalidOrderBy Got %v\n", e)
+	}
+
+	e = s.Projects().Remove(p, true)
+	failIfErr("Project Remove", t, e)
+}
+
+func testRemoveProject(t *testing.T, s store.Store, f *fixtures) {
+	e := s.Projects().Remove(models.Project{Key: f.project.Key}, false)
+	if e != store.ErrProjectHasTickets {
+		t.Errorf("Expected ErrProjectHasTickets Got %v\n", e)
+	}
+
+	empty := models.Project{Name: "Empty Suite Project", Key: "E" + f.suffix,
+		Lead: f.user}
+	e = s.Projects().New(&empty)
+	failIfErr("Project New", t, e)
+
+	e = s.Projects().Remove(models.Project{Key: empty.Key}, false)
+	failIfErr("Project Remove", t, e)
+
+	e = s.Projects().Get(&models.Project{Key: empty.Key})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+
+	e = s.Projects().Remove(models.Project{Key: empty.Key}, false)
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+}
+
+// hasProject reports whether a project with the key is in projects
+func hasProject(projects []models.Project, key string) bool {
+	for _, p := range projects {
+		if p.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func testArchiveProjects(t *testing.T, s store.Store, f *fixtures) {
+	p := models.Project{Name: "Archived Suite Project", Key: "AR" + f.suffix, Lead: f.user}
+	e := s.Projects().New(&p)
+	failIfErr("Project New", t, e)
+
+	e = s.Projects().ArchiveProject(models.Project{Key: p.Key})
+	failIfErr("Project Archive", t, e)
+
+	projects, e := s.Projects().GetAll()
+	failIfErr("Project Get All", t, e)
+
+	if hasProject(projects, p.Key) || !hasProject(projects, f.project.Key) {
+		t.Errorf("Expected %s without %s Got %v\n", f.project.Key, p.Key, projects)
+	}
+
+	projects, e = s.Projects().GetAllIncludingArchived()
+	failIfErr("Project Get All Including Archived", t, e)
+
+	if !hasProject(projects, p.Key) {
+		t.Errorf("Expected %s Got %v\n", p.Key, projects)
+	}
+
+	// Saving the project doesn't bring it back.
+	p.Name = "Renamed Archived Suite Project"
+	e = s.Projects().Save(p)
+	failIfErr("Project Save", t, e)
+
+	got := models.Project{ID: p.ID}
+	e = s.Projects().Get(&got)
+	failIfErr("Project Get", t, e)
+
+	if !got.Archived {
+		t.Errorf("Expected %s to still be archived Got %v\n", p.Key, got)
+	}
+
+	tk := models.Ticket{
+		Summary:  "Archived suite ticket arch" + f.suffix,
+		Reporter: f.user,
+		Assignee: f.user,
+		Status:   f.status,
+		Type:     f.typ,
+		DueDate:  time.Now().Add(-time.Hour),
+	}
+
+	e = s.Tickets().New(p, &tk)
+	if e != store.ErrProjectArchived {
+		t.Errorf("Expected ErrProjectArchived Got %v\n", e)
+	}
+
+	batch := tk
+	e = s.Tickets().NewBatch(p, []*models.Ticket{&batch})
+	if e != store.ErrProjectArchived {
+		t.Errorf("Expected ErrProjectArchived from NewBatch Got %v\n", e)
+	}
+
+	e = s.Projects().UnarchiveProject(models.Project{Key: p.Key})
+	failIfErr("Project Unarchive", t, e)
+
+	e = s.Tickets().New(p, &tk)
+	failIfErr("Ticket New", t, e)
+
+	lists := map[string]func() ([]models.Ticket, error){
+		"Get All": s.Tickets().GetAll,
+		"Get All Paged": func() ([]models.Ticket, error) {
+			tickets, _, err := s.Tickets().GetAllPaged(store.PageOptions{})
+			return tickets, err
+		},
+		"Get Filtered": func() ([]models.Ticket, error) {
+			return s.Tickets().GetFiltered(store.TicketFilter{ProjectKey: p.Key})
+		},
+		"Search": func() ([]models.Ticket, error) {
+			return s.Tickets().Search("arch"+f.suffix, models.Project{})
+		},
+		"Get Overdue": func() ([]models.Ticket, error) {
+			return s.Tickets().GetOverdue(p)
+		},
+		"Get Recently Updated": func() ([]models.Ticket, error) {
+			return s.Tickets().GetRecentlyUpdated(p, 0)
+		},
+	}
+
+	for _, archived := range []bool{false, true} {
+		if archived {
+			e = s.Projects().ArchiveProject(models.Project{Key: p.Key})
+			failIfErr("Project Archive", t, e)
+		}
+
+		for name, list := range lists {
+			tickets, err := list()
+			failIfErr("Ticket "+name, t, err)
+
+			if hasTicket(tickets, tk.ID) == archived {
+				t.Errorf("Expected %s with archived %v to return %s %v Got %v\n",
+					name, archived, tk.Key, !archived, tickets)
+			}
+		}
+	}
+
+	// The archived project's tickets are preserved.
+	stored := models.Ticket{ID: tk.ID}
+	e = s.Tickets().Get(&stored)
+	failIfErr("Ticket Get", t, e)
+
+	tickets, e := s.Tickets().GetAllByProject(p, "")
+	failIfErr("Ticket Get All By Project", t, e)
+
+	if !hasTicket(tickets, tk.ID) {
+		t.Errorf("Expected %s Got %v\n", tk.Key, tickets)
+	}
+
+	e = s.Projects().ArchiveProject(models.Project{Key: "MISSING" + f.suffix})
+	if e != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound Got %v\n", e)
+	}
+}